@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/delgado-jacob/spl-toolkit/pkg/mapper"
 )
@@ -29,6 +32,34 @@ func main() {
 		discoverCommand()
 	case "validate":
 		validateCommand()
+	case "invert":
+		invertCommand()
+	case "convert":
+		convertCommand()
+	case "schema":
+		schemaCommand()
+	case "lint":
+		lintCommand()
+	case "cost":
+		costCommand()
+	case "optimize":
+		optimizeCommand()
+	case "commands":
+		commandsCommand()
+	case "cim":
+		cimCommand()
+	case "coverage":
+		coverageCommand()
+	case "analyze-app":
+		analyzeAppCommand()
+	case "rewrite-app":
+		rewriteAppCommand()
+	case "dashboard":
+		dashboardCommand()
+	case "lint-config":
+		lintConfigCommand()
+	case "test":
+		testCommand()
 	case "demo":
 		runDemo()
 	case "help", "--help", "-h":
@@ -48,6 +79,40 @@ func showHelp() {
 	fmt.Println("  map <query>       Map fields in SPL query")
 	fmt.Println("  discover <query>  Discover query information")
 	fmt.Println("  validate <query>  Validate SPL query syntax")
+	fmt.Println("  invert <config>   Print the inverse of a mapping configuration")
+	fmt.Println("                    (--roundtrip <config> <query>... checks queries map back unchanged)")
+	fmt.Println("  convert <config> <json|yaml|toml>")
+	fmt.Println("                    Convert a mapping configuration between formats")
+	fmt.Println("  schema            Print the JSON Schema for mapping configurations")
+	fmt.Println("  lint <query>      Report likely problems in a SPL query")
+	fmt.Println("                    (--disable r1,r2 skips rules, --severity r1=error overrides severities,")
+	fmt.Println("                    --rules lists the built-in rules)")
+	fmt.Println("  cost <query>      Estimate how expensive a SPL query is, with suggested rewrites")
+	fmt.Println("  optimize --apply <r1,r2|all> <query>")
+	fmt.Println("                    Apply semantics-preserving rewrites to a SPL query")
+	fmt.Println("                    (--rewrites lists the available rewrites)")
+	fmt.Println("  commands [name]   List the cataloged SPL commands with their types, or describe one")
+	fmt.Println("  cim <query>       Check a SPL query's datamodel fields and field names against the CIM")
+	fmt.Println("                    (--datasets [Datamodel.Dataset] lists the bundled CIM field list)")
+	fmt.Println("  coverage --sources <config> <dir|file>...")
+	fmt.Println("                    Report the data sources and ATT&CK data components detections rely on")
+	fmt.Println("                    (--format csv prints the coverage matrix as CSV)")
+	fmt.Println("  analyze-app <app>")
+	fmt.Println("                    Validate, discover, lint and map the saved searches and macros of an")
+	fmt.Println("                    app directory or .spl/.tgz package (--config <config> maps searches,")
+	fmt.Println("                    --format json|csv|markdown, --concurrency N)")
+	fmt.Println("  rewrite-app --config <config> <app>")
+	fmt.Println("                    Print the diff of mapping the saved searches, macros and dashboards of")
+	fmt.Println("                    an app in place (--write writes the changes)")
+	fmt.Println("  dashboard <dashboard|app>...")
+	fmt.Println("                    Analyze each panel search of Simple XML and Dashboard Studio dashboards,")
+	fmt.Println("                    with post-process searches run on their base (--config <config> maps)")
+	fmt.Println("  lint-config <config>")
+	fmt.Println("                    Report likely mistakes in a mapping configuration")
+	fmt.Println("                    (--metadata-keys k1,k2 lists the metadata keys your tools read)")
+	fmt.Println("  test <fixtures>...")
+	fmt.Println("                    Run a mapping configuration's golden query fixtures")
+	fmt.Println("                    (--config <config> overrides the config named by the fixtures)")
 	fmt.Println("  demo              Run demonstration examples")
 	fmt.Println("  help              Show this help message")
 }
@@ -107,6 +172,595 @@ func validateCommand() {
 	fmt.Println("Valid")
 }
 
+func invertCommand() {
+	args := os.Args[2:]
+	roundTrip := len(args) > 0 && args[0] == "--roundtrip"
+	if roundTrip {
+		args = args[1:]
+	}
+	if len(args) < 1 || roundTrip && len(args) < 2 {
+		fmt.Println("Usage: spl-toolkit invert <config.json>")
+		fmt.Println("       spl-toolkit invert --roundtrip <config.json> <query>...")
+		os.Exit(1)
+	}
+
+	config, err := mapper.LoadMappingConfigFile(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !roundTrip {
+		inverse, problems := config.Invert()
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", problem.Location, problem.Message)
+		}
+
+		result, _ := inverse.ToJSON()
+		fmt.Println(string(result))
+		return
+	}
+
+	results, problems := config.CheckRoundTrip(args[1:])
+	for _, problem := range problems {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", problem.Location, problem.Message)
+	}
+
+	failed := false
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			failed = true
+			fmt.Printf("ERROR %s\n  %s\n", r.Query, r.Error)
+		case r.Match:
+			fmt.Printf("OK    %s\n", r.Query)
+		default:
+			failed = true
+			fmt.Printf("DIFF  %s\n  mapped:   %s\n  restored: %s\n", r.Query, r.Mapped, r.Restored)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func convertCommand() {
+	if len(os.Args) < 4 {
+		fmt.Println("Usage: spl-toolkit convert <config> <json|yaml|toml>")
+		os.Exit(1)
+	}
+
+	from, err := mapper.ConfigFormatForPath(os.Args[2])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	to, err := mapper.ParseConfigFormat(os.Args[3])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(os.Args[2])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	result, err := mapper.ConvertMappingConfig(data, from, to)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(string(result))
+}
+
+func schemaCommand() {
+	schema, err := mapper.MappingConfigSchemaJSON()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(string(schema))
+}
+
+func lintCommand() {
+	args := os.Args[2:]
+	linter := mapper.NewQueryLinter()
+	options := mapper.QueryLintOptions{Severities: map[string]string{}}
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		switch {
+		case args[0] == "--rules":
+			for _, rule := range linter.Rules() {
+				fmt.Printf("%-28s %-7s %s\n", rule.ID(), rule.Severity(), rule.Description())
+			}
+			return
+		case args[0] == "--disable" && len(args) > 1:
+			options.Disable = append(options.Disable, strings.Split(args[1], ",")...)
+		case args[0] == "--severity" && len(args) > 1:
+			for _, setting := range strings.Split(args[1], ",") {
+				rule, severity, _ := strings.Cut(setting, "=")
+				options.Severities[rule] = severity
+			}
+		default:
+			fmt.Printf("Error: unknown or incomplete option %s\n", args[0])
+			os.Exit(1)
+		}
+		args = args[2:]
+	}
+	if len(args) < 1 {
+		fmt.Println("Usage: spl-toolkit lint [--disable r1,r2] [--severity r1=error] <query>")
+		fmt.Println("       spl-toolkit lint --rules")
+		os.Exit(1)
+	}
+
+	issues, err := linter.Lint(args[0], options)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, issue := range issues {
+		if issue.Severity != mapper.QueryLintInfo {
+			failed = true
+		}
+		fmt.Printf("%-7s %d:%d: %s [%s]\n", issue.Severity, issue.Line, issue.Column, issue.Message, issue.Rule)
+	}
+	if len(issues) == 0 {
+		fmt.Println("No issues found")
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func costCommand() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: spl-toolkit cost <query>")
+		os.Exit(1)
+	}
+
+	estimate, err := mapper.EstimateQueryCost(os.Args[2])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(estimate)
+}
+
+func optimizeCommand() {
+	args := os.Args[2:]
+	var options mapper.OptimizeOptions
+	if len(args) > 0 && args[0] == "--rewrites" {
+		for _, rewrite := range mapper.QueryRewrites() {
+			fmt.Printf("%-24s %s\n", rewrite.ID, rewrite.Description)
+		}
+		return
+	}
+	if len(args) > 1 && args[0] == "--apply" {
+		if args[1] == "all" {
+			for _, rewrite := range mapper.QueryRewrites() {
+				options.Rewrites = append(options.Rewrites, rewrite.ID)
+			}
+		} else {
+			options.Rewrites = strings.Split(args[1], ",")
+		}
+		args = args[2:]
+	}
+	if len(args) < 1 || len(options.Rewrites) == 0 {
+		fmt.Println("Usage: spl-toolkit optimize --apply <r1,r2|all> <query>")
+		fmt.Println("       spl-toolkit optimize --rewrites")
+		os.Exit(1)
+	}
+
+	result, err := mapper.OptimizeQuery(args[0], options)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(result.Optimized)
+	for _, rewrite := range result.Applied {
+		fmt.Printf("applied %s: %s\n", rewrite.Rewrite, rewrite.Explanation)
+	}
+	for _, rewrite := range result.Skipped {
+		fmt.Printf("skipped %s: %s (%s)\n", rewrite.Rewrite, rewrite.Explanation, rewrite.Error)
+	}
+}
+
+func commandsCommand() {
+	if len(os.Args) > 2 {
+		info, ok := mapper.LookupCommand(os.Args[2])
+		if !ok {
+			fmt.Printf("Error: unknown command %q\n", os.Args[2])
+			os.Exit(1)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(info)
+		return
+	}
+
+	for _, info := range mapper.CommandCatalog() {
+		var notes []string
+		if info.ResetsFields {
+			notes = append(notes, "resets fields")
+		}
+		if info.Branch {
+			notes = append(notes, "runs search branches")
+		}
+		line := fmt.Sprintf("%-20s %-24s %s", info.Name, info.Type, strings.Join(notes, ", "))
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+}
+
+func cimCommand() {
+	if len(os.Args) > 2 && os.Args[2] == "--datasets" {
+		if len(os.Args) > 3 {
+			datamodel, name, _ := strings.Cut(os.Args[3], ".")
+			dataset, ok := mapper.LookupCIMDataset(datamodel, name)
+			if !ok {
+				fmt.Printf("Error: unknown CIM dataset %q\n", os.Args[3])
+				os.Exit(1)
+			}
+			fmt.Println(strings.Join(dataset.Fields, "\n"))
+			return
+		}
+		fmt.Printf("CIM %s\n", mapper.CIMVersion)
+		for _, dataset := range mapper.CIMDatasets() {
+			fmt.Println(dataset.FullName())
+		}
+		return
+	}
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: spl-toolkit cim <query>")
+		fmt.Println("       spl-toolkit cim --datasets [Datamodel.Dataset]")
+		os.Exit(1)
+	}
+
+	report, err := mapper.CheckCIMCompliance(os.Args[2])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+}
+
+func coverageCommand() {
+	args := os.Args[2:]
+	sources, format := "", "json"
+	for len(args) > 1 && strings.HasPrefix(args[0], "--") {
+		switch args[0] {
+		case "--sources":
+			sources = args[1]
+		case "--format":
+			format = args[1]
+		default:
+			fmt.Printf("Error: unknown option %s\n", args[0])
+			os.Exit(1)
+		}
+		args = args[2:]
+	}
+	if sources == "" || len(args) == 0 || (format != "json" && format != "csv") {
+		fmt.Println("Usage: spl-toolkit coverage --sources <config> [--format json|csv] <dir|file>...")
+		os.Exit(1)
+	}
+
+	config, err := mapper.LoadCoverageConfig(sources)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var detections []mapper.Detection
+	for _, path := range args {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if info.IsDir() {
+			loaded, err := mapper.LoadDetectionDir(path)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			detections = append(detections, loaded...)
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		detections = append(detections, mapper.Detection{
+			Name:  strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+			Query: strings.TrimSpace(string(data)),
+		})
+	}
+
+	report := mapper.AnalyzeCoverage(config, detections)
+	if format == "csv" {
+		if err := report.WriteCSV(os.Stdout); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+}
+
+func analyzeAppCommand() {
+	args := os.Args[2:]
+	format := "json"
+	var options mapper.AppAnalysisOptions
+	for len(args) > 1 && strings.HasPrefix(args[0], "--") {
+		switch args[0] {
+		case "--config":
+			config, err := mapper.LoadMappingConfigFile(args[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			options.Mapper = mapper.NewWithConfig(config)
+		case "--format":
+			format = args[1]
+		case "--concurrency":
+			concurrency, err := strconv.Atoi(args[1])
+			if err != nil || concurrency < 1 {
+				fmt.Printf("Error: invalid concurrency %q\n", args[1])
+				os.Exit(1)
+			}
+			options.Concurrency = concurrency
+		default:
+			fmt.Printf("Error: unknown option %s\n", args[0])
+			os.Exit(1)
+		}
+		args = args[2:]
+	}
+	if len(args) != 1 || (format != "json" && format != "csv" && format != "markdown") {
+		fmt.Println("Usage: spl-toolkit analyze-app [--config <config>] [--format json|csv|markdown] [--concurrency N] <app>")
+		os.Exit(1)
+	}
+
+	app, err := mapper.LoadSplunkApp(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	report := mapper.AnalyzeSplunkApp(app, options)
+	switch format {
+	case "csv":
+		err = report.WriteCSV(os.Stdout)
+	case "markdown":
+		err = report.WriteMarkdown(os.Stdout)
+	default:
+		err = report.WriteJSON(os.Stdout)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func rewriteAppCommand() {
+	args := os.Args[2:]
+	configPath := ""
+	write := false
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		switch {
+		case args[0] == "--write":
+			write = true
+			args = args[1:]
+		case args[0] == "--config" && len(args) > 1:
+			configPath = args[1]
+			args = args[2:]
+		default:
+			fmt.Printf("Error: unknown option %s\n", args[0])
+			os.Exit(1)
+		}
+	}
+	if len(args) != 1 || configPath == "" {
+		fmt.Println("Usage: spl-toolkit rewrite-app --config <config> [--write] <app>")
+		os.Exit(1)
+	}
+
+	config, err := mapper.LoadMappingConfigFile(configPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	rewrite, err := mapper.RewriteSplunkApp(args[0], mapper.NewWithConfig(config))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(rewrite.Diff())
+	for _, queryErr := range rewrite.Errors {
+		location := queryErr.File
+		if queryErr.Line > 0 {
+			location = fmt.Sprintf("%s:%d", location, queryErr.Line)
+		}
+		if queryErr.Location != "" {
+			location += " [" + queryErr.Location + "]"
+		}
+		fmt.Fprintf(os.Stderr, "Skipped %s: %s\n", location, queryErr.Error)
+	}
+	if len(rewrite.Files) == 0 {
+		fmt.Fprintln(os.Stderr, "No searches changed")
+		return
+	}
+	if !write {
+		return
+	}
+	if err := rewrite.Apply(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Rewrote %d files\n", len(rewrite.Files))
+}
+
+func dashboardCommand() {
+	args := os.Args[2:]
+	var options mapper.DashboardAnalysisOptions
+	if len(args) > 1 && args[0] == "--config" {
+		config, err := mapper.LoadMappingConfigFile(args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		options.Mapper = mapper.NewWithConfig(config)
+		args = args[2:]
+	}
+	if len(args) < 1 {
+		fmt.Println("Usage: spl-toolkit dashboard [--config <config>] <dashboard|app>...")
+		os.Exit(1)
+	}
+
+	var dashboards []*mapper.Dashboard
+	for _, arg := range args {
+		ext := strings.ToLower(filepath.Ext(arg))
+		if ext == ".xml" || ext == ".json" {
+			dashboard, err := mapper.LoadDashboard(arg)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			dashboards = append(dashboards, dashboard)
+			continue
+		}
+		appDashboards, err := mapper.LoadAppDashboards(arg)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		dashboards = append(dashboards, appDashboards...)
+	}
+
+	reports := make([]*mapper.DashboardAnalysisReport, 0, len(dashboards))
+	for _, dashboard := range dashboards {
+		reports = append(reports, mapper.AnalyzeDashboard(dashboard, options))
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(reports)
+}
+
+func lintConfigCommand() {
+	args := os.Args[2:]
+	var options mapper.ConfigLintOptions
+	if len(args) > 1 && args[0] == "--metadata-keys" {
+		options.MetadataKeys = strings.Split(args[1], ",")
+		args = args[2:]
+	}
+	if len(args) < 1 {
+		fmt.Println("Usage: spl-toolkit lint-config [--metadata-keys k1,k2] <config>")
+		os.Exit(1)
+	}
+
+	config, err := mapper.LoadMappingConfigFile(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	issues := config.Lint(options)
+	warnings := 0
+	for _, issue := range issues {
+		if issue.Severity == mapper.ConfigLintWarning {
+			warnings++
+		}
+		fmt.Printf("%-7s %s: %s [%s]\n", issue.Severity, issue.Location, issue.Message, issue.Check)
+	}
+	if len(issues) == 0 {
+		fmt.Println("No issues found")
+	}
+
+	if warnings > 0 {
+		os.Exit(1)
+	}
+}
+
+func testCommand() {
+	args := os.Args[2:]
+	configPath := ""
+	if len(args) > 1 && args[0] == "--config" {
+		configPath = args[1]
+		args = args[2:]
+	}
+	if len(args) < 1 {
+		fmt.Println("Usage: spl-toolkit test [--config <config>] <fixtures>...")
+		os.Exit(1)
+	}
+
+	passed, failed := 0, 0
+	for _, path := range args {
+		suite, err := mapper.LoadConfigTestSuite(path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		suiteConfig := suite.Config
+		if configPath != "" {
+			suiteConfig = configPath
+		}
+		if suiteConfig == "" {
+			fmt.Printf("Error: %s does not name a config; pass --config\n", path)
+			os.Exit(1)
+		}
+
+		config, err := mapper.LoadMappingConfigFile(suiteConfig)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, result := range suite.Run(config) {
+			if result.Passed {
+				passed++
+				fmt.Printf("PASS  %s\n", result.Name)
+				continue
+			}
+
+			failed++
+			fmt.Printf("FAIL  %s\n  query: %s\n", result.Name, result.Query)
+			if result.Error != "" {
+				fmt.Printf("  error: %s\n", result.Error)
+			}
+			for _, diff := range result.Diffs {
+				fmt.Println("  " + strings.ReplaceAll(strings.TrimSuffix(diff, "\n"), "\n", "\n  "))
+			}
+		}
+	}
+
+	fmt.Printf("\n%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
 func runDemo() {
 	fmt.Println("SPL Toolkit Library - Demo")
 	fmt.Println("================================")
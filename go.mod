@@ -3,8 +3,10 @@ module github.com/delgado-jacob/spl-toolkit
 go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/antlr4-go/antlr/v4 v4.13.1
 	github.com/swaggo/swag/v2 v2.0.0-rc4
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -20,5 +22,4 @@ require (
 	golang.org/x/exp v0.0.0-20240506185415-9bf2ced13842 // indirect
 	golang.org/x/tools v0.21.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
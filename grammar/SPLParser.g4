@@ -35,11 +35,11 @@ query
     ;
 
 initCommand
-    : PIPE? INIT_COMMAND? operation+ subquery?
+    : PIPE? INIT_COMMAND? operation+ subquery*
     ;
 
 nextCommand
-    : command operation+ subquery?
+    : command operation* subquery*
     ;
 
 subquery
@@ -52,109 +52,112 @@ func splparserParserInit() {
 	}
 	staticData.PredictionContextCache = antlr.NewPredictionContextCache()
 	staticData.serializedATN = []int32{
-		4, 1, 43, 224, 2, 0, 7, 0, 2, 1, 7, 1, 2, 2, 7, 2, 2, 3, 7, 3, 2, 4, 7,
+		4, 1, 43, 231, 2, 0, 7, 0, 2, 1, 7, 1, 2, 2, 7, 2, 2, 3, 7, 3, 2, 4, 7,
 		4, 2, 5, 7, 5, 2, 6, 7, 6, 2, 7, 7, 7, 2, 8, 7, 8, 2, 9, 7, 9, 2, 10, 7,
 		10, 1, 0, 1, 0, 1, 0, 5, 0, 26, 8, 0, 10, 0, 12, 0, 29, 9, 0, 1, 0, 1,
 		0, 1, 1, 3, 1, 34, 8, 1, 1, 1, 3, 1, 37, 8, 1, 1, 1, 4, 1, 40, 8, 1, 11,
-		1, 12, 1, 41, 1, 1, 3, 1, 45, 8, 1, 1, 2, 1, 2, 4, 2, 49, 8, 2, 11, 2,
-		12, 2, 50, 1, 2, 3, 2, 54, 8, 2, 1, 3, 1, 3, 1, 3, 1, 3, 5, 3, 60, 8, 3,
-		10, 3, 12, 3, 63, 9, 3, 1, 3, 1, 3, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4,
-		1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 5, 4, 78, 8, 4, 10, 4, 12, 4, 81, 9, 4, 3,
-		4, 83, 8, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4,
-		1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4,
-		1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 4, 4, 112, 8, 4, 11, 4, 12, 4, 113, 1, 4,
-		1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4,
-		3, 4, 129, 8, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 5, 4, 137, 8, 4, 10,
-		4, 12, 4, 140, 9, 4, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 5, 5, 148, 8,
-		5, 10, 5, 12, 5, 151, 9, 5, 3, 5, 153, 8, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1,
-		5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 4, 5, 170,
-		8, 5, 11, 5, 12, 5, 171, 1, 5, 3, 5, 175, 8, 5, 1, 5, 1, 5, 1, 5, 1, 5,
-		1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 5, 5, 191,
-		8, 5, 10, 5, 12, 5, 194, 9, 5, 1, 6, 1, 6, 1, 6, 1, 6, 3, 6, 200, 8, 6,
-		1, 6, 3, 6, 203, 8, 6, 1, 7, 3, 7, 206, 8, 7, 1, 7, 1, 7, 3, 7, 210, 8,
-		7, 1, 7, 3, 7, 213, 8, 7, 1, 8, 1, 8, 1, 8, 3, 8, 218, 8, 8, 1, 9, 1, 9,
-		1, 10, 1, 10, 1, 10, 0, 2, 8, 10, 11, 0, 2, 4, 6, 8, 10, 12, 14, 16, 18,
-		20, 0, 6, 1, 0, 27, 28, 1, 0, 10, 15, 2, 0, 3, 3, 5, 5, 1, 0, 1, 2, 3,
-		0, 30, 30, 32, 32, 34, 36, 1, 0, 31, 33, 258, 0, 22, 1, 0, 0, 0, 2, 33,
-		1, 0, 0, 0, 4, 46, 1, 0, 0, 0, 6, 55, 1, 0, 0, 0, 8, 128, 1, 0, 0, 0, 10,
-		174, 1, 0, 0, 0, 12, 202, 1, 0, 0, 0, 14, 212, 1, 0, 0, 0, 16, 217, 1,
-		0, 0, 0, 18, 219, 1, 0, 0, 0, 20, 221, 1, 0, 0, 0, 22, 27, 3, 2, 1, 0,
-		23, 24, 5, 16, 0, 0, 24, 26, 3, 4, 2, 0, 25, 23, 1, 0, 0, 0, 26, 29, 1,
-		0, 0, 0, 27, 25, 1, 0, 0, 0, 27, 28, 1, 0, 0, 0, 28, 30, 1, 0, 0, 0, 29,
-		27, 1, 0, 0, 0, 30, 31, 5, 0, 0, 1, 31, 1, 1, 0, 0, 0, 32, 34, 5, 16, 0,
-		0, 33, 32, 1, 0, 0, 0, 33, 34, 1, 0, 0, 0, 34, 36, 1, 0, 0, 0, 35, 37,
-		5, 31, 0, 0, 36, 35, 1, 0, 0, 0, 36, 37, 1, 0, 0, 0, 37, 39, 1, 0, 0, 0,
-		38, 40, 3, 8, 4, 0, 39, 38, 1, 0, 0, 0, 40, 41, 1, 0, 0, 0, 41, 39, 1,
-		0, 0, 0, 41, 42, 1, 0, 0, 0, 42, 44, 1, 0, 0, 0, 43, 45, 3, 6, 3, 0, 44,
-		43, 1, 0, 0, 0, 44, 45, 1, 0, 0, 0, 45, 3, 1, 0, 0, 0, 46, 48, 3, 20, 10,
-		0, 47, 49, 3, 8, 4, 0, 48, 47, 1, 0, 0, 0, 49, 50, 1, 0, 0, 0, 50, 48,
-		1, 0, 0, 0, 50, 51, 1, 0, 0, 0, 51, 53, 1, 0, 0, 0, 52, 54, 3, 6, 3, 0,
-		53, 52, 1, 0, 0, 0, 53, 54, 1, 0, 0, 0, 54, 5, 1, 0, 0, 0, 55, 56, 5, 19,
-		0, 0, 56, 61, 3, 2, 1, 0, 57, 58, 5, 16, 0, 0, 58, 60, 3, 4, 2, 0, 59,
-		57, 1, 0, 0, 0, 60, 63, 1, 0, 0, 0, 61, 59, 1, 0, 0, 0, 61, 62, 1, 0, 0,
-		0, 62, 64, 1, 0, 0, 0, 63, 61, 1, 0, 0, 0, 64, 65, 5, 20, 0, 0, 65, 7,
-		1, 0, 0, 0, 66, 67, 6, 4, -1, 0, 67, 68, 3, 10, 5, 0, 68, 69, 5, 30, 0,
-		0, 69, 70, 3, 12, 6, 0, 70, 129, 1, 0, 0, 0, 71, 72, 3, 10, 5, 0, 72, 73,
-		5, 29, 0, 0, 73, 82, 5, 17, 0, 0, 74, 79, 3, 10, 5, 0, 75, 76, 5, 21, 0,
-		0, 76, 78, 3, 10, 5, 0, 77, 75, 1, 0, 0, 0, 78, 81, 1, 0, 0, 0, 79, 77,
-		1, 0, 0, 0, 79, 80, 1, 0, 0, 0, 80, 83, 1, 0, 0, 0, 81, 79, 1, 0, 0, 0,
-		82, 74, 1, 0, 0, 0, 82, 83, 1, 0, 0, 0, 83, 84, 1, 0, 0, 0, 84, 85, 5,
-		18, 0, 0, 85, 129, 1, 0, 0, 0, 86, 87, 5, 9, 0, 0, 87, 129, 3, 8, 4, 9,
-		88, 89, 3, 10, 5, 0, 89, 90, 7, 0, 0, 0, 90, 91, 3, 16, 8, 0, 91, 129,
-		1, 0, 0, 0, 92, 93, 3, 10, 5, 0, 93, 94, 3, 10, 5, 0, 94, 95, 5, 27, 0,
-		0, 95, 96, 3, 16, 8, 0, 96, 97, 5, 21, 0, 0, 97, 98, 3, 16, 8, 0, 98, 129,
-		1, 0, 0, 0, 99, 100, 3, 10, 5, 0, 100, 101, 3, 10, 5, 0, 101, 102, 3, 10,
-		5, 0, 102, 103, 7, 0, 0, 0, 103, 104, 3, 16, 8, 0, 104, 105, 5, 21, 0,
-		0, 105, 106, 3, 16, 8, 0, 106, 107, 5, 21, 0, 0, 107, 108, 3, 16, 8, 0,
-		108, 129, 1, 0, 0, 0, 109, 111, 5, 26, 0, 0, 110, 112, 3, 16, 8, 0, 111,
-		110, 1, 0, 0, 0, 112, 113, 1, 0, 0, 0, 113, 111, 1, 0, 0, 0, 113, 114,
-		1, 0, 0, 0, 114, 129, 1, 0, 0, 0, 115, 116, 3, 10, 5, 0, 116, 117, 5, 25,
-		0, 0, 117, 118, 3, 16, 8, 0, 118, 129, 1, 0, 0, 0, 119, 120, 3, 16, 8,
-		0, 120, 121, 7, 1, 0, 0, 121, 122, 3, 10, 5, 0, 122, 129, 1, 0, 0, 0, 123,
-		129, 3, 10, 5, 0, 124, 125, 5, 17, 0, 0, 125, 126, 3, 8, 4, 0, 126, 127,
-		5, 18, 0, 0, 127, 129, 1, 0, 0, 0, 128, 66, 1, 0, 0, 0, 128, 71, 1, 0,
-		0, 0, 128, 86, 1, 0, 0, 0, 128, 88, 1, 0, 0, 0, 128, 92, 1, 0, 0, 0, 128,
-		99, 1, 0, 0, 0, 128, 109, 1, 0, 0, 0, 128, 115, 1, 0, 0, 0, 128, 119, 1,
-		0, 0, 0, 128, 123, 1, 0, 0, 0, 128, 124, 1, 0, 0, 0, 129, 138, 1, 0, 0,
-		0, 130, 131, 10, 13, 0, 0, 131, 132, 5, 7, 0, 0, 132, 137, 3, 8, 4, 14,
-		133, 134, 10, 12, 0, 0, 134, 135, 5, 8, 0, 0, 135, 137, 3, 8, 4, 13, 136,
-		130, 1, 0, 0, 0, 136, 133, 1, 0, 0, 0, 137, 140, 1, 0, 0, 0, 138, 136,
-		1, 0, 0, 0, 138, 139, 1, 0, 0, 0, 139, 9, 1, 0, 0, 0, 140, 138, 1, 0, 0,
-		0, 141, 142, 6, 5, -1, 0, 142, 143, 3, 18, 9, 0, 143, 152, 5, 17, 0, 0,
-		144, 149, 3, 10, 5, 0, 145, 146, 5, 21, 0, 0, 146, 148, 3, 10, 5, 0, 147,
-		145, 1, 0, 0, 0, 148, 151, 1, 0, 0, 0, 149, 147, 1, 0, 0, 0, 149, 150,
-		1, 0, 0, 0, 150, 153, 1, 0, 0, 0, 151, 149, 1, 0, 0, 0, 152, 144, 1, 0,
-		0, 0, 152, 153, 1, 0, 0, 0, 153, 154, 1, 0, 0, 0, 154, 155, 5, 18, 0, 0,
-		155, 175, 1, 0, 0, 0, 156, 157, 5, 17, 0, 0, 157, 158, 3, 10, 5, 0, 158,
-		159, 5, 18, 0, 0, 159, 175, 1, 0, 0, 0, 160, 161, 5, 3, 0, 0, 161, 162,
-		3, 10, 5, 0, 162, 163, 5, 3, 0, 0, 163, 175, 1, 0, 0, 0, 164, 165, 5, 3,
-		0, 0, 165, 175, 3, 10, 5, 6, 166, 175, 5, 3, 0, 0, 167, 168, 5, 4, 0, 0,
-		168, 170, 3, 16, 8, 0, 169, 167, 1, 0, 0, 0, 170, 171, 1, 0, 0, 0, 171,
-		169, 1, 0, 0, 0, 171, 172, 1, 0, 0, 0, 172, 175, 1, 0, 0, 0, 173, 175,
-		3, 12, 6, 0, 174, 141, 1, 0, 0, 0, 174, 156, 1, 0, 0, 0, 174, 160, 1, 0,
-		0, 0, 174, 164, 1, 0, 0, 0, 174, 166, 1, 0, 0, 0, 174, 169, 1, 0, 0, 0,
-		174, 173, 1, 0, 0, 0, 175, 192, 1, 0, 0, 0, 176, 177, 10, 10, 0, 0, 177,
-		178, 5, 6, 0, 0, 178, 191, 3, 10, 5, 10, 179, 180, 10, 9, 0, 0, 180, 181,
-		7, 2, 0, 0, 181, 191, 3, 10, 5, 10, 182, 183, 10, 8, 0, 0, 183, 184, 5,
-		4, 0, 0, 184, 191, 3, 10, 5, 9, 185, 186, 10, 2, 0, 0, 186, 187, 7, 3,
-		0, 0, 187, 191, 3, 10, 5, 3, 188, 189, 10, 5, 0, 0, 189, 191, 5, 3, 0,
-		0, 190, 176, 1, 0, 0, 0, 190, 179, 1, 0, 0, 0, 190, 182, 1, 0, 0, 0, 190,
-		185, 1, 0, 0, 0, 190, 188, 1, 0, 0, 0, 191, 194, 1, 0, 0, 0, 192, 190,
-		1, 0, 0, 0, 192, 193, 1, 0, 0, 0, 193, 11, 1, 0, 0, 0, 194, 192, 1, 0,
-		0, 0, 195, 203, 3, 14, 7, 0, 196, 203, 5, 39, 0, 0, 197, 203, 3, 16, 8,
-		0, 198, 200, 7, 3, 0, 0, 199, 198, 1, 0, 0, 0, 199, 200, 1, 0, 0, 0, 200,
-		201, 1, 0, 0, 0, 201, 203, 5, 38, 0, 0, 202, 195, 1, 0, 0, 0, 202, 196,
-		1, 0, 0, 0, 202, 197, 1, 0, 0, 0, 202, 199, 1, 0, 0, 0, 203, 13, 1, 0,
-		0, 0, 204, 206, 5, 24, 0, 0, 205, 204, 1, 0, 0, 0, 205, 206, 1, 0, 0, 0,
-		206, 207, 1, 0, 0, 0, 207, 209, 5, 35, 0, 0, 208, 210, 5, 24, 0, 0, 209,
-		208, 1, 0, 0, 0, 209, 210, 1, 0, 0, 0, 210, 213, 1, 0, 0, 0, 211, 213,
-		5, 37, 0, 0, 212, 205, 1, 0, 0, 0, 212, 211, 1, 0, 0, 0, 213, 15, 1, 0,
-		0, 0, 214, 218, 5, 40, 0, 0, 215, 218, 3, 20, 10, 0, 216, 218, 3, 18, 9,
-		0, 217, 214, 1, 0, 0, 0, 217, 215, 1, 0, 0, 0, 217, 216, 1, 0, 0, 0, 218,
-		17, 1, 0, 0, 0, 219, 220, 7, 4, 0, 0, 220, 19, 1, 0, 0, 0, 221, 222, 7,
-		5, 0, 0, 222, 21, 1, 0, 0, 0, 26, 27, 33, 36, 41, 44, 50, 53, 61, 79, 82,
-		113, 128, 136, 138, 149, 152, 171, 174, 190, 192, 199, 202, 205, 209, 212,
-		217,
+		1, 12, 1, 41, 1, 1, 5, 1, 45, 8, 1, 10, 1, 12, 1, 48, 9, 1, 1, 2, 1, 2,
+		5, 2, 52, 8, 2, 10, 2, 12, 2, 55, 9, 2, 1, 2, 5, 2, 58, 8, 2, 10, 2, 12,
+		2, 61, 9, 2, 1, 3, 1, 3, 1, 3, 1, 3, 5, 3, 67, 8, 3, 10, 3, 12, 3, 70,
+		9, 3, 1, 3, 1, 3, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4,
+		1, 4, 1, 4, 5, 4, 85, 8, 4, 10, 4, 12, 4, 88, 9, 4, 3, 4, 90, 8, 4, 1,
+		4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1,
+		4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1,
+		4, 1, 4, 1, 4, 4, 4, 119, 8, 4, 11, 4, 12, 4, 120, 1, 4, 1, 4, 1, 4, 1,
+		4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 3, 4, 136, 8,
+		4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 1, 4, 5, 4, 144, 8, 4, 10, 4, 12, 4, 147,
+		9, 4, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 5, 5, 155, 8, 5, 10, 5, 12, 5,
+		158, 9, 5, 3, 5, 160, 8, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1,
+		5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 4, 5, 177, 8, 5, 11, 5, 12,
+		5, 178, 1, 5, 3, 5, 182, 8, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5,
+		1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 1, 5, 5, 5, 198, 8, 5, 10, 5, 12, 5,
+		201, 9, 5, 1, 6, 1, 6, 1, 6, 1, 6, 3, 6, 207, 8, 6, 1, 6, 3, 6, 210, 8,
+		6, 1, 7, 3, 7, 213, 8, 7, 1, 7, 1, 7, 3, 7, 217, 8, 7, 1, 7, 3, 7, 220,
+		8, 7, 1, 8, 1, 8, 1, 8, 3, 8, 225, 8, 8, 1, 9, 1, 9, 1, 10, 1, 10, 1, 10,
+		0, 2, 8, 10, 11, 0, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 0, 6, 1, 0, 27,
+		28, 1, 0, 10, 15, 2, 0, 3, 3, 5, 5, 1, 0, 1, 2, 3, 0, 30, 30, 32, 32, 34,
+		36, 1, 0, 31, 33, 265, 0, 22, 1, 0, 0, 0, 2, 33, 1, 0, 0, 0, 4, 49, 1,
+		0, 0, 0, 6, 62, 1, 0, 0, 0, 8, 135, 1, 0, 0, 0, 10, 181, 1, 0, 0, 0, 12,
+		209, 1, 0, 0, 0, 14, 219, 1, 0, 0, 0, 16, 224, 1, 0, 0, 0, 18, 226, 1,
+		0, 0, 0, 20, 228, 1, 0, 0, 0, 22, 27, 3, 2, 1, 0, 23, 24, 5, 16, 0, 0,
+		24, 26, 3, 4, 2, 0, 25, 23, 1, 0, 0, 0, 26, 29, 1, 0, 0, 0, 27, 25, 1,
+		0, 0, 0, 27, 28, 1, 0, 0, 0, 28, 30, 1, 0, 0, 0, 29, 27, 1, 0, 0, 0, 30,
+		31, 5, 0, 0, 1, 31, 1, 1, 0, 0, 0, 32, 34, 5, 16, 0, 0, 33, 32, 1, 0, 0,
+		0, 33, 34, 1, 0, 0, 0, 34, 36, 1, 0, 0, 0, 35, 37, 5, 31, 0, 0, 36, 35,
+		1, 0, 0, 0, 36, 37, 1, 0, 0, 0, 37, 39, 1, 0, 0, 0, 38, 40, 3, 8, 4, 0,
+		39, 38, 1, 0, 0, 0, 40, 41, 1, 0, 0, 0, 41, 39, 1, 0, 0, 0, 41, 42, 1,
+		0, 0, 0, 42, 46, 1, 0, 0, 0, 43, 45, 3, 6, 3, 0, 44, 43, 1, 0, 0, 0, 45,
+		48, 1, 0, 0, 0, 46, 44, 1, 0, 0, 0, 46, 47, 1, 0, 0, 0, 47, 3, 1, 0, 0,
+		0, 48, 46, 1, 0, 0, 0, 49, 53, 3, 20, 10, 0, 50, 52, 3, 8, 4, 0, 51, 50,
+		1, 0, 0, 0, 52, 55, 1, 0, 0, 0, 53, 51, 1, 0, 0, 0, 53, 54, 1, 0, 0, 0,
+		54, 59, 1, 0, 0, 0, 55, 53, 1, 0, 0, 0, 56, 58, 3, 6, 3, 0, 57, 56, 1,
+		0, 0, 0, 58, 61, 1, 0, 0, 0, 59, 57, 1, 0, 0, 0, 59, 60, 1, 0, 0, 0, 60,
+		5, 1, 0, 0, 0, 61, 59, 1, 0, 0, 0, 62, 63, 5, 19, 0, 0, 63, 68, 3, 2, 1,
+		0, 64, 65, 5, 16, 0, 0, 65, 67, 3, 4, 2, 0, 66, 64, 1, 0, 0, 0, 67, 70,
+		1, 0, 0, 0, 68, 66, 1, 0, 0, 0, 68, 69, 1, 0, 0, 0, 69, 71, 1, 0, 0, 0,
+		70, 68, 1, 0, 0, 0, 71, 72, 5, 20, 0, 0, 72, 7, 1, 0, 0, 0, 73, 74, 6,
+		4, -1, 0, 74, 75, 3, 10, 5, 0, 75, 76, 5, 30, 0, 0, 76, 77, 3, 12, 6, 0,
+		77, 136, 1, 0, 0, 0, 78, 79, 3, 10, 5, 0, 79, 80, 5, 29, 0, 0, 80, 89,
+		5, 17, 0, 0, 81, 86, 3, 10, 5, 0, 82, 83, 5, 21, 0, 0, 83, 85, 3, 10, 5,
+		0, 84, 82, 1, 0, 0, 0, 85, 88, 1, 0, 0, 0, 86, 84, 1, 0, 0, 0, 86, 87,
+		1, 0, 0, 0, 87, 90, 1, 0, 0, 0, 88, 86, 1, 0, 0, 0, 89, 81, 1, 0, 0, 0,
+		89, 90, 1, 0, 0, 0, 90, 91, 1, 0, 0, 0, 91, 92, 5, 18, 0, 0, 92, 136, 1,
+		0, 0, 0, 93, 94, 5, 9, 0, 0, 94, 136, 3, 8, 4, 9, 95, 96, 3, 10, 5, 0,
+		96, 97, 7, 0, 0, 0, 97, 98, 3, 16, 8, 0, 98, 136, 1, 0, 0, 0, 99, 100,
+		3, 10, 5, 0, 100, 101, 3, 10, 5, 0, 101, 102, 5, 27, 0, 0, 102, 103, 3,
+		16, 8, 0, 103, 104, 5, 21, 0, 0, 104, 105, 3, 16, 8, 0, 105, 136, 1, 0,
+		0, 0, 106, 107, 3, 10, 5, 0, 107, 108, 3, 10, 5, 0, 108, 109, 3, 10, 5,
+		0, 109, 110, 7, 0, 0, 0, 110, 111, 3, 16, 8, 0, 111, 112, 5, 21, 0, 0,
+		112, 113, 3, 16, 8, 0, 113, 114, 5, 21, 0, 0, 114, 115, 3, 16, 8, 0, 115,
+		136, 1, 0, 0, 0, 116, 118, 5, 26, 0, 0, 117, 119, 3, 16, 8, 0, 118, 117,
+		1, 0, 0, 0, 119, 120, 1, 0, 0, 0, 120, 118, 1, 0, 0, 0, 120, 121, 1, 0,
+		0, 0, 121, 136, 1, 0, 0, 0, 122, 123, 3, 10, 5, 0, 123, 124, 5, 25, 0,
+		0, 124, 125, 3, 16, 8, 0, 125, 136, 1, 0, 0, 0, 126, 127, 3, 16, 8, 0,
+		127, 128, 7, 1, 0, 0, 128, 129, 3, 10, 5, 0, 129, 136, 1, 0, 0, 0, 130,
+		136, 3, 10, 5, 0, 131, 132, 5, 17, 0, 0, 132, 133, 3, 8, 4, 0, 133, 134,
+		5, 18, 0, 0, 134, 136, 1, 0, 0, 0, 135, 73, 1, 0, 0, 0, 135, 78, 1, 0,
+		0, 0, 135, 93, 1, 0, 0, 0, 135, 95, 1, 0, 0, 0, 135, 99, 1, 0, 0, 0, 135,
+		106, 1, 0, 0, 0, 135, 116, 1, 0, 0, 0, 135, 122, 1, 0, 0, 0, 135, 126,
+		1, 0, 0, 0, 135, 130, 1, 0, 0, 0, 135, 131, 1, 0, 0, 0, 136, 145, 1, 0,
+		0, 0, 137, 138, 10, 13, 0, 0, 138, 139, 5, 7, 0, 0, 139, 144, 3, 8, 4,
+		14, 140, 141, 10, 12, 0, 0, 141, 142, 5, 8, 0, 0, 142, 144, 3, 8, 4, 13,
+		143, 137, 1, 0, 0, 0, 143, 140, 1, 0, 0, 0, 144, 147, 1, 0, 0, 0, 145,
+		143, 1, 0, 0, 0, 145, 146, 1, 0, 0, 0, 146, 9, 1, 0, 0, 0, 147, 145, 1,
+		0, 0, 0, 148, 149, 6, 5, -1, 0, 149, 150, 3, 18, 9, 0, 150, 159, 5, 17,
+		0, 0, 151, 156, 3, 10, 5, 0, 152, 153, 5, 21, 0, 0, 153, 155, 3, 10, 5,
+		0, 154, 152, 1, 0, 0, 0, 155, 158, 1, 0, 0, 0, 156, 154, 1, 0, 0, 0, 156,
+		157, 1, 0, 0, 0, 157, 160, 1, 0, 0, 0, 158, 156, 1, 0, 0, 0, 159, 151,
+		1, 0, 0, 0, 159, 160, 1, 0, 0, 0, 160, 161, 1, 0, 0, 0, 161, 162, 5, 18,
+		0, 0, 162, 182, 1, 0, 0, 0, 163, 164, 5, 17, 0, 0, 164, 165, 3, 10, 5,
+		0, 165, 166, 5, 18, 0, 0, 166, 182, 1, 0, 0, 0, 167, 168, 5, 3, 0, 0, 168,
+		169, 3, 10, 5, 0, 169, 170, 5, 3, 0, 0, 170, 182, 1, 0, 0, 0, 171, 172,
+		5, 3, 0, 0, 172, 182, 3, 10, 5, 6, 173, 182, 5, 3, 0, 0, 174, 175, 5, 4,
+		0, 0, 175, 177, 3, 16, 8, 0, 176, 174, 1, 0, 0, 0, 177, 178, 1, 0, 0, 0,
+		178, 176, 1, 0, 0, 0, 178, 179, 1, 0, 0, 0, 179, 182, 1, 0, 0, 0, 180,
+		182, 3, 12, 6, 0, 181, 148, 1, 0, 0, 0, 181, 163, 1, 0, 0, 0, 181, 167,
+		1, 0, 0, 0, 181, 171, 1, 0, 0, 0, 181, 173, 1, 0, 0, 0, 181, 176, 1, 0,
+		0, 0, 181, 180, 1, 0, 0, 0, 182, 199, 1, 0, 0, 0, 183, 184, 10, 10, 0,
+		0, 184, 185, 5, 6, 0, 0, 185, 198, 3, 10, 5, 10, 186, 187, 10, 9, 0, 0,
+		187, 188, 7, 2, 0, 0, 188, 198, 3, 10, 5, 10, 189, 190, 10, 8, 0, 0, 190,
+		191, 5, 4, 0, 0, 191, 198, 3, 10, 5, 9, 192, 193, 10, 2, 0, 0, 193, 194,
+		7, 3, 0, 0, 194, 198, 3, 10, 5, 3, 195, 196, 10, 5, 0, 0, 196, 198, 5,
+		3, 0, 0, 197, 183, 1, 0, 0, 0, 197, 186, 1, 0, 0, 0, 197, 189, 1, 0, 0,
+		0, 197, 192, 1, 0, 0, 0, 197, 195, 1, 0, 0, 0, 198, 201, 1, 0, 0, 0, 199,
+		197, 1, 0, 0, 0, 199, 200, 1, 0, 0, 0, 200, 11, 1, 0, 0, 0, 201, 199, 1,
+		0, 0, 0, 202, 210, 3, 14, 7, 0, 203, 210, 5, 39, 0, 0, 204, 210, 3, 16,
+		8, 0, 205, 207, 7, 3, 0, 0, 206, 205, 1, 0, 0, 0, 206, 207, 1, 0, 0, 0,
+		207, 208, 1, 0, 0, 0, 208, 210, 5, 38, 0, 0, 209, 202, 1, 0, 0, 0, 209,
+		203, 1, 0, 0, 0, 209, 204, 1, 0, 0, 0, 209, 206, 1, 0, 0, 0, 210, 13, 1,
+		0, 0, 0, 211, 213, 5, 24, 0, 0, 212, 211, 1, 0, 0, 0, 212, 213, 1, 0, 0,
+		0, 213, 214, 1, 0, 0, 0, 214, 216, 5, 35, 0, 0, 215, 217, 5, 24, 0, 0,
+		216, 215, 1, 0, 0, 0, 216, 217, 1, 0, 0, 0, 217, 220, 1, 0, 0, 0, 218,
+		220, 5, 37, 0, 0, 219, 212, 1, 0, 0, 0, 219, 218, 1, 0, 0, 0, 220, 15,
+		1, 0, 0, 0, 221, 225, 5, 40, 0, 0, 222, 225, 3, 20, 10, 0, 223, 225, 3,
+		18, 9, 0, 224, 221, 1, 0, 0, 0, 224, 222, 1, 0, 0, 0, 224, 223, 1, 0, 0,
+		0, 225, 17, 1, 0, 0, 0, 226, 227, 7, 4, 0, 0, 227, 19, 1, 0, 0, 0, 228,
+		229, 7, 5, 0, 0, 229, 21, 1, 0, 0, 0, 26, 27, 33, 36, 41, 46, 53, 59, 68,
+		86, 89, 120, 135, 143, 145, 156, 159, 178, 181, 197, 199, 206, 209, 212,
+		216, 219, 224,
 	}
 	deserializer := antlr.NewATNDeserializer(nil)
 	staticData.atn = deserializer.Deserialize(staticData.serializedATN)
@@ -475,7 +478,8 @@ type IInitCommandContext interface {
 	INIT_COMMAND() antlr.TerminalNode
 	AllOperation() []IOperationContext
 	Operation(i int) IOperationContext
-	Subquery() ISubqueryContext
+	AllSubquery() []ISubqueryContext
+	Subquery(i int) ISubqueryContext
 
 	// IsInitCommandContext differentiates from other interfaces.
 	IsInitCommandContext()
@@ -562,12 +566,37 @@ func (s *InitCommandContext) Operation(i int) IOperationContext {
 	return t.(IOperationContext)
 }
 
-func (s *InitCommandContext) Subquery() ISubqueryContext {
+func (s *InitCommandContext) AllSubquery() []ISubqueryContext {
+	children := s.GetChildren()
+	len := 0
+	for _, ctx := range children {
+		if _, ok := ctx.(ISubqueryContext); ok {
+			len++
+		}
+	}
+
+	tst := make([]ISubqueryContext, len)
+	i := 0
+	for _, ctx := range children {
+		if t, ok := ctx.(ISubqueryContext); ok {
+			tst[i] = t.(ISubqueryContext)
+			i++
+		}
+	}
+
+	return tst
+}
+
+func (s *InitCommandContext) Subquery(i int) ISubqueryContext {
 	var t antlr.RuleContext
+	j := 0
 	for _, ctx := range s.GetChildren() {
 		if _, ok := ctx.(ISubqueryContext); ok {
-			t = ctx.(antlr.RuleContext)
-			break
+			if j == i {
+				t = ctx.(antlr.RuleContext)
+				break
+			}
+			j++
 		}
 	}
 
@@ -668,19 +697,25 @@ func (p *SPLParser) InitCommand() (localctx IInitCommandContext) {
 		}
 		_la = p.GetTokenStream().LA(1)
 	}
-	p.SetState(44)
+	p.SetState(46)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
 	}
 	_la = p.GetTokenStream().LA(1)
 
-	if _la == SPLParserLBRACK {
+	for _la == SPLParserLBRACK {
 		{
 			p.SetState(43)
 			p.Subquery()
 		}
 
+		p.SetState(48)
+		p.GetErrorHandler().Sync(p)
+		if p.HasError() {
+			goto errorExit
+		}
+		_la = p.GetTokenStream().LA(1)
 	}
 
 errorExit:
@@ -707,7 +742,8 @@ type INextCommandContext interface {
 	Command() ICommandContext
 	AllOperation() []IOperationContext
 	Operation(i int) IOperationContext
-	Subquery() ISubqueryContext
+	AllSubquery() []ISubqueryContext
+	Subquery(i int) ISubqueryContext
 
 	// IsNextCommandContext differentiates from other interfaces.
 	IsNextCommandContext()
@@ -802,12 +838,37 @@ func (s *NextCommandContext) Operation(i int) IOperationContext {
 	return t.(IOperationContext)
 }
 
-func (s *NextCommandContext) Subquery() ISubqueryContext {
+func (s *NextCommandContext) AllSubquery() []ISubqueryContext {
+	children := s.GetChildren()
+	len := 0
+	for _, ctx := range children {
+		if _, ok := ctx.(ISubqueryContext); ok {
+			len++
+		}
+	}
+
+	tst := make([]ISubqueryContext, len)
+	i := 0
+	for _, ctx := range children {
+		if t, ok := ctx.(ISubqueryContext); ok {
+			tst[i] = t.(ISubqueryContext)
+			i++
+		}
+	}
+
+	return tst
+}
+
+func (s *NextCommandContext) Subquery(i int) ISubqueryContext {
 	var t antlr.RuleContext
+	j := 0
 	for _, ctx := range s.GetChildren() {
 		if _, ok := ctx.(ISubqueryContext); ok {
-			t = ctx.(antlr.RuleContext)
-			break
+			if j == i {
+				t = ctx.(antlr.RuleContext)
+				break
+			}
+			j++
 		}
 	}
 
@@ -855,42 +916,48 @@ func (p *SPLParser) NextCommand() (localctx INextCommandContext) {
 
 	p.EnterOuterAlt(localctx, 1)
 	{
-		p.SetState(46)
+		p.SetState(49)
 		p.Command()
 	}
-	p.SetState(48)
+	p.SetState(53)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
 	}
 	_la = p.GetTokenStream().LA(1)
 
-	for ok := true; ok; ok = ((int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&2198033531422) != 0) {
+	for (int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&2198033531422) != 0 {
 		{
-			p.SetState(47)
+			p.SetState(50)
 			p.operation(0)
 		}
 
-		p.SetState(50)
+		p.SetState(55)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
 		}
 		_la = p.GetTokenStream().LA(1)
 	}
-	p.SetState(53)
+	p.SetState(59)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
 	}
 	_la = p.GetTokenStream().LA(1)
 
-	if _la == SPLParserLBRACK {
+	for _la == SPLParserLBRACK {
 		{
-			p.SetState(52)
+			p.SetState(56)
 			p.Subquery()
 		}
 
+		p.SetState(61)
+		p.GetErrorHandler().Sync(p)
+		if p.HasError() {
+			goto errorExit
+		}
+		_la = p.GetTokenStream().LA(1)
 	}
 
 errorExit:
@@ -1068,7 +1135,7 @@ func (p *SPLParser) Subquery() (localctx ISubqueryContext) {
 
 	p.EnterOuterAlt(localctx, 1)
 	{
-		p.SetState(55)
+		p.SetState(62)
 		p.Match(SPLParserLBRACK)
 		if p.HasError() {
 			// Recognition error - abort rule
@@ -1076,10 +1143,10 @@ func (p *SPLParser) Subquery() (localctx ISubqueryContext) {
 		}
 	}
 	{
-		p.SetState(56)
+		p.SetState(63)
 		p.InitCommand()
 	}
-	p.SetState(61)
+	p.SetState(68)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -1088,7 +1155,7 @@ func (p *SPLParser) Subquery() (localctx ISubqueryContext) {
 
 	for _la == SPLParserPIPE {
 		{
-			p.SetState(57)
+			p.SetState(64)
 			p.Match(SPLParserPIPE)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -1096,11 +1163,11 @@ func (p *SPLParser) Subquery() (localctx ISubqueryContext) {
 			}
 		}
 		{
-			p.SetState(58)
+			p.SetState(65)
 			p.NextCommand()
 		}
 
-		p.SetState(63)
+		p.SetState(70)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -1108,7 +1175,7 @@ func (p *SPLParser) Subquery() (localctx ISubqueryContext) {
 		_la = p.GetTokenStream().LA(1)
 	}
 	{
-		p.SetState(64)
+		p.SetState(71)
 		p.Match(SPLParserRBRACK)
 		if p.HasError() {
 			// Recognition error - abort rule
@@ -2333,7 +2400,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 	var _alt int
 
 	p.EnterOuterAlt(localctx, 1)
-	p.SetState(128)
+	p.SetState(135)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -2346,11 +2413,11 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		_prevctx = localctx
 
 		{
-			p.SetState(67)
+			p.SetState(74)
 			p.expression(0)
 		}
 		{
-			p.SetState(68)
+			p.SetState(75)
 			p.Match(SPLParserLIKE)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2358,7 +2425,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(69)
+			p.SetState(76)
 			p.Value()
 		}
 
@@ -2367,11 +2434,11 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		p.SetParserRuleContext(localctx)
 		_prevctx = localctx
 		{
-			p.SetState(71)
+			p.SetState(78)
 			p.expression(0)
 		}
 		{
-			p.SetState(72)
+			p.SetState(79)
 			p.Match(SPLParserIN)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2379,14 +2446,14 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(73)
+			p.SetState(80)
 			p.Match(SPLParserLPAREN)
 			if p.HasError() {
 				// Recognition error - abort rule
 				goto errorExit
 			}
 		}
-		p.SetState(82)
+		p.SetState(89)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -2395,10 +2462,10 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 
 		if (int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&2197966422046) != 0 {
 			{
-				p.SetState(74)
+				p.SetState(81)
 				p.expression(0)
 			}
-			p.SetState(79)
+			p.SetState(86)
 			p.GetErrorHandler().Sync(p)
 			if p.HasError() {
 				goto errorExit
@@ -2407,7 +2474,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 
 			for _la == SPLParserCOMMA {
 				{
-					p.SetState(75)
+					p.SetState(82)
 					p.Match(SPLParserCOMMA)
 					if p.HasError() {
 						// Recognition error - abort rule
@@ -2415,11 +2482,11 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 					}
 				}
 				{
-					p.SetState(76)
+					p.SetState(83)
 					p.expression(0)
 				}
 
-				p.SetState(81)
+				p.SetState(88)
 				p.GetErrorHandler().Sync(p)
 				if p.HasError() {
 					goto errorExit
@@ -2429,7 +2496,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 
 		}
 		{
-			p.SetState(84)
+			p.SetState(91)
 			p.Match(SPLParserRPAREN)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2442,7 +2509,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		p.SetParserRuleContext(localctx)
 		_prevctx = localctx
 		{
-			p.SetState(86)
+			p.SetState(93)
 			p.Match(SPLParserNOT)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2450,7 +2517,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(87)
+			p.SetState(94)
 			p.operation(9)
 		}
 
@@ -2459,11 +2526,11 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		p.SetParserRuleContext(localctx)
 		_prevctx = localctx
 		{
-			p.SetState(88)
+			p.SetState(95)
 			p.expression(0)
 		}
 		{
-			p.SetState(89)
+			p.SetState(96)
 			_la = p.GetTokenStream().LA(1)
 
 			if !(_la == SPLParserOUTPUT || _la == SPLParserOUTPUTNEW) {
@@ -2474,7 +2541,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(90)
+			p.SetState(97)
 			p.Id()
 		}
 
@@ -2483,15 +2550,15 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		p.SetParserRuleContext(localctx)
 		_prevctx = localctx
 		{
-			p.SetState(92)
+			p.SetState(99)
 			p.expression(0)
 		}
 		{
-			p.SetState(93)
+			p.SetState(100)
 			p.expression(0)
 		}
 		{
-			p.SetState(94)
+			p.SetState(101)
 			p.Match(SPLParserOUTPUT)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2499,11 +2566,11 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(95)
+			p.SetState(102)
 			p.Id()
 		}
 		{
-			p.SetState(96)
+			p.SetState(103)
 			p.Match(SPLParserCOMMA)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2511,7 +2578,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(97)
+			p.SetState(104)
 			p.Id()
 		}
 
@@ -2520,19 +2587,19 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		p.SetParserRuleContext(localctx)
 		_prevctx = localctx
 		{
-			p.SetState(99)
+			p.SetState(106)
 			p.expression(0)
 		}
 		{
-			p.SetState(100)
+			p.SetState(107)
 			p.expression(0)
 		}
 		{
-			p.SetState(101)
+			p.SetState(108)
 			p.expression(0)
 		}
 		{
-			p.SetState(102)
+			p.SetState(109)
 			_la = p.GetTokenStream().LA(1)
 
 			if !(_la == SPLParserOUTPUT || _la == SPLParserOUTPUTNEW) {
@@ -2543,11 +2610,11 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(103)
+			p.SetState(110)
 			p.Id()
 		}
 		{
-			p.SetState(104)
+			p.SetState(111)
 			p.Match(SPLParserCOMMA)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2555,11 +2622,11 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(105)
+			p.SetState(112)
 			p.Id()
 		}
 		{
-			p.SetState(106)
+			p.SetState(113)
 			p.Match(SPLParserCOMMA)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2567,7 +2634,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(107)
+			p.SetState(114)
 			p.Id()
 		}
 
@@ -2576,14 +2643,14 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		p.SetParserRuleContext(localctx)
 		_prevctx = localctx
 		{
-			p.SetState(109)
+			p.SetState(116)
 			p.Match(SPLParserBY)
 			if p.HasError() {
 				// Recognition error - abort rule
 				goto errorExit
 			}
 		}
-		p.SetState(111)
+		p.SetState(118)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -2593,7 +2660,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			switch _alt {
 			case 1:
 				{
-					p.SetState(110)
+					p.SetState(117)
 					p.Id()
 				}
 
@@ -2602,7 +2669,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 				goto errorExit
 			}
 
-			p.SetState(113)
+			p.SetState(120)
 			p.GetErrorHandler().Sync(p)
 			_alt = p.GetInterpreter().AdaptivePredict(p.BaseParser, p.GetTokenStream(), 10, p.GetParserRuleContext())
 			if p.HasError() {
@@ -2615,11 +2682,11 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		p.SetParserRuleContext(localctx)
 		_prevctx = localctx
 		{
-			p.SetState(115)
+			p.SetState(122)
 			p.expression(0)
 		}
 		{
-			p.SetState(116)
+			p.SetState(123)
 			p.Match(SPLParserAS)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2627,7 +2694,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(117)
+			p.SetState(124)
 			p.Id()
 		}
 
@@ -2636,11 +2703,11 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		p.SetParserRuleContext(localctx)
 		_prevctx = localctx
 		{
-			p.SetState(119)
+			p.SetState(126)
 			p.Id()
 		}
 		{
-			p.SetState(120)
+			p.SetState(127)
 			_la = p.GetTokenStream().LA(1)
 
 			if !((int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&64512) != 0) {
@@ -2651,7 +2718,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(121)
+			p.SetState(128)
 			p.expression(0)
 		}
 
@@ -2660,7 +2727,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		p.SetParserRuleContext(localctx)
 		_prevctx = localctx
 		{
-			p.SetState(123)
+			p.SetState(130)
 			p.expression(0)
 		}
 
@@ -2669,7 +2736,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		p.SetParserRuleContext(localctx)
 		_prevctx = localctx
 		{
-			p.SetState(124)
+			p.SetState(131)
 			p.Match(SPLParserLPAREN)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2677,11 +2744,11 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 		}
 		{
-			p.SetState(125)
+			p.SetState(132)
 			p.operation(0)
 		}
 		{
-			p.SetState(126)
+			p.SetState(133)
 			p.Match(SPLParserRPAREN)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -2693,7 +2760,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 		goto errorExit
 	}
 	p.GetParserRuleContext().SetStop(p.GetTokenStream().LT(-1))
-	p.SetState(138)
+	p.SetState(145)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -2708,7 +2775,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 				p.TriggerExitRuleEvent()
 			}
 			_prevctx = localctx
-			p.SetState(136)
+			p.SetState(143)
 			p.GetErrorHandler().Sync(p)
 			if p.HasError() {
 				goto errorExit
@@ -2718,14 +2785,14 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			case 1:
 				localctx = NewANDOPContext(p, NewOperationContext(p, _parentctx, _parentState))
 				p.PushNewRecursionContext(localctx, _startState, SPLParserRULE_operation)
-				p.SetState(130)
+				p.SetState(137)
 
 				if !(p.Precpred(p.GetParserRuleContext(), 13)) {
 					p.SetError(antlr.NewFailedPredicateException(p, "p.Precpred(p.GetParserRuleContext(), 13)", ""))
 					goto errorExit
 				}
 				{
-					p.SetState(131)
+					p.SetState(138)
 					p.Match(SPLParserAND)
 					if p.HasError() {
 						// Recognition error - abort rule
@@ -2733,21 +2800,21 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 					}
 				}
 				{
-					p.SetState(132)
+					p.SetState(139)
 					p.operation(14)
 				}
 
 			case 2:
 				localctx = NewOROPContext(p, NewOperationContext(p, _parentctx, _parentState))
 				p.PushNewRecursionContext(localctx, _startState, SPLParserRULE_operation)
-				p.SetState(133)
+				p.SetState(140)
 
 				if !(p.Precpred(p.GetParserRuleContext(), 12)) {
 					p.SetError(antlr.NewFailedPredicateException(p, "p.Precpred(p.GetParserRuleContext(), 12)", ""))
 					goto errorExit
 				}
 				{
-					p.SetState(134)
+					p.SetState(141)
 					p.Match(SPLParserOR)
 					if p.HasError() {
 						// Recognition error - abort rule
@@ -2755,7 +2822,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 					}
 				}
 				{
-					p.SetState(135)
+					p.SetState(142)
 					p.operation(13)
 				}
 
@@ -2764,7 +2831,7 @@ func (p *SPLParser) operation(_p int) (localctx IOperationContext) {
 			}
 
 		}
-		p.SetState(140)
+		p.SetState(147)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -3061,7 +3128,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 	var _alt int
 
 	p.EnterOuterAlt(localctx, 1)
-	p.SetState(174)
+	p.SetState(181)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -3070,18 +3137,18 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 	switch p.GetInterpreter().AdaptivePredict(p.BaseParser, p.GetTokenStream(), 17, p.GetParserRuleContext()) {
 	case 1:
 		{
-			p.SetState(142)
+			p.SetState(149)
 			p.Function()
 		}
 		{
-			p.SetState(143)
+			p.SetState(150)
 			p.Match(SPLParserLPAREN)
 			if p.HasError() {
 				// Recognition error - abort rule
 				goto errorExit
 			}
 		}
-		p.SetState(152)
+		p.SetState(159)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -3090,10 +3157,10 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 
 		if (int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&2197966422046) != 0 {
 			{
-				p.SetState(144)
+				p.SetState(151)
 				p.expression(0)
 			}
-			p.SetState(149)
+			p.SetState(156)
 			p.GetErrorHandler().Sync(p)
 			if p.HasError() {
 				goto errorExit
@@ -3102,7 +3169,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 
 			for _la == SPLParserCOMMA {
 				{
-					p.SetState(145)
+					p.SetState(152)
 					p.Match(SPLParserCOMMA)
 					if p.HasError() {
 						// Recognition error - abort rule
@@ -3110,11 +3177,11 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 					}
 				}
 				{
-					p.SetState(146)
+					p.SetState(153)
 					p.expression(0)
 				}
 
-				p.SetState(151)
+				p.SetState(158)
 				p.GetErrorHandler().Sync(p)
 				if p.HasError() {
 					goto errorExit
@@ -3124,7 +3191,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 
 		}
 		{
-			p.SetState(154)
+			p.SetState(161)
 			p.Match(SPLParserRPAREN)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -3134,7 +3201,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 
 	case 2:
 		{
-			p.SetState(156)
+			p.SetState(163)
 			p.Match(SPLParserLPAREN)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -3142,11 +3209,11 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 			}
 		}
 		{
-			p.SetState(157)
+			p.SetState(164)
 			p.expression(0)
 		}
 		{
-			p.SetState(158)
+			p.SetState(165)
 			p.Match(SPLParserRPAREN)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -3156,7 +3223,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 
 	case 3:
 		{
-			p.SetState(160)
+			p.SetState(167)
 			p.Match(SPLParserMULT)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -3164,11 +3231,11 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 			}
 		}
 		{
-			p.SetState(161)
+			p.SetState(168)
 			p.expression(0)
 		}
 		{
-			p.SetState(162)
+			p.SetState(169)
 			p.Match(SPLParserMULT)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -3178,7 +3245,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 
 	case 4:
 		{
-			p.SetState(164)
+			p.SetState(171)
 			p.Match(SPLParserMULT)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -3186,13 +3253,13 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 			}
 		}
 		{
-			p.SetState(165)
+			p.SetState(172)
 			p.expression(6)
 		}
 
 	case 5:
 		{
-			p.SetState(166)
+			p.SetState(173)
 			p.Match(SPLParserMULT)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -3201,7 +3268,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 		}
 
 	case 6:
-		p.SetState(169)
+		p.SetState(176)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -3211,7 +3278,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 			switch _alt {
 			case 1:
 				{
-					p.SetState(167)
+					p.SetState(174)
 					p.Match(SPLParserDIV)
 					if p.HasError() {
 						// Recognition error - abort rule
@@ -3219,7 +3286,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 					}
 				}
 				{
-					p.SetState(168)
+					p.SetState(175)
 					p.Id()
 				}
 
@@ -3228,7 +3295,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 				goto errorExit
 			}
 
-			p.SetState(171)
+			p.SetState(178)
 			p.GetErrorHandler().Sync(p)
 			_alt = p.GetInterpreter().AdaptivePredict(p.BaseParser, p.GetTokenStream(), 16, p.GetParserRuleContext())
 			if p.HasError() {
@@ -3238,7 +3305,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 
 	case 7:
 		{
-			p.SetState(173)
+			p.SetState(180)
 			p.Value()
 		}
 
@@ -3246,7 +3313,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 		goto errorExit
 	}
 	p.GetParserRuleContext().SetStop(p.GetTokenStream().LT(-1))
-	p.SetState(192)
+	p.SetState(199)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -3261,7 +3328,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 				p.TriggerExitRuleEvent()
 			}
 			_prevctx = localctx
-			p.SetState(190)
+			p.SetState(197)
 			p.GetErrorHandler().Sync(p)
 			if p.HasError() {
 				goto errorExit
@@ -3271,14 +3338,14 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 			case 1:
 				localctx = NewExpressionContext(p, _parentctx, _parentState)
 				p.PushNewRecursionContext(localctx, _startState, SPLParserRULE_expression)
-				p.SetState(176)
+				p.SetState(183)
 
 				if !(p.Precpred(p.GetParserRuleContext(), 10)) {
 					p.SetError(antlr.NewFailedPredicateException(p, "p.Precpred(p.GetParserRuleContext(), 10)", ""))
 					goto errorExit
 				}
 				{
-					p.SetState(177)
+					p.SetState(184)
 					p.Match(SPLParserPOW)
 					if p.HasError() {
 						// Recognition error - abort rule
@@ -3286,21 +3353,21 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 					}
 				}
 				{
-					p.SetState(178)
+					p.SetState(185)
 					p.expression(10)
 				}
 
 			case 2:
 				localctx = NewExpressionContext(p, _parentctx, _parentState)
 				p.PushNewRecursionContext(localctx, _startState, SPLParserRULE_expression)
-				p.SetState(179)
+				p.SetState(186)
 
 				if !(p.Precpred(p.GetParserRuleContext(), 9)) {
 					p.SetError(antlr.NewFailedPredicateException(p, "p.Precpred(p.GetParserRuleContext(), 9)", ""))
 					goto errorExit
 				}
 				{
-					p.SetState(180)
+					p.SetState(187)
 					_la = p.GetTokenStream().LA(1)
 
 					if !(_la == SPLParserMULT || _la == SPLParserMOD) {
@@ -3311,21 +3378,21 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 					}
 				}
 				{
-					p.SetState(181)
+					p.SetState(188)
 					p.expression(10)
 				}
 
 			case 3:
 				localctx = NewExpressionContext(p, _parentctx, _parentState)
 				p.PushNewRecursionContext(localctx, _startState, SPLParserRULE_expression)
-				p.SetState(182)
+				p.SetState(189)
 
 				if !(p.Precpred(p.GetParserRuleContext(), 8)) {
 					p.SetError(antlr.NewFailedPredicateException(p, "p.Precpred(p.GetParserRuleContext(), 8)", ""))
 					goto errorExit
 				}
 				{
-					p.SetState(183)
+					p.SetState(190)
 					p.Match(SPLParserDIV)
 					if p.HasError() {
 						// Recognition error - abort rule
@@ -3333,21 +3400,21 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 					}
 				}
 				{
-					p.SetState(184)
+					p.SetState(191)
 					p.expression(9)
 				}
 
 			case 4:
 				localctx = NewExpressionContext(p, _parentctx, _parentState)
 				p.PushNewRecursionContext(localctx, _startState, SPLParserRULE_expression)
-				p.SetState(185)
+				p.SetState(192)
 
 				if !(p.Precpred(p.GetParserRuleContext(), 2)) {
 					p.SetError(antlr.NewFailedPredicateException(p, "p.Precpred(p.GetParserRuleContext(), 2)", ""))
 					goto errorExit
 				}
 				{
-					p.SetState(186)
+					p.SetState(193)
 					_la = p.GetTokenStream().LA(1)
 
 					if !(_la == SPLParserADD || _la == SPLParserSUB) {
@@ -3358,21 +3425,21 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 					}
 				}
 				{
-					p.SetState(187)
+					p.SetState(194)
 					p.expression(3)
 				}
 
 			case 5:
 				localctx = NewExpressionContext(p, _parentctx, _parentState)
 				p.PushNewRecursionContext(localctx, _startState, SPLParserRULE_expression)
-				p.SetState(188)
+				p.SetState(195)
 
 				if !(p.Precpred(p.GetParserRuleContext(), 5)) {
 					p.SetError(antlr.NewFailedPredicateException(p, "p.Precpred(p.GetParserRuleContext(), 5)", ""))
 					goto errorExit
 				}
 				{
-					p.SetState(189)
+					p.SetState(196)
 					p.Match(SPLParserMULT)
 					if p.HasError() {
 						// Recognition error - abort rule
@@ -3385,7 +3452,7 @@ func (p *SPLParser) expression(_p int) (localctx IExpressionContext) {
 			}
 
 		}
-		p.SetState(194)
+		p.SetState(201)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -3543,7 +3610,7 @@ func (p *SPLParser) Value() (localctx IValueContext) {
 	p.EnterRule(localctx, 12, SPLParserRULE_value)
 	var _la int
 
-	p.SetState(202)
+	p.SetState(209)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -3553,14 +3620,14 @@ func (p *SPLParser) Value() (localctx IValueContext) {
 	case 1:
 		p.EnterOuterAlt(localctx, 1)
 		{
-			p.SetState(195)
+			p.SetState(202)
 			p.Date()
 		}
 
 	case 2:
 		p.EnterOuterAlt(localctx, 2)
 		{
-			p.SetState(196)
+			p.SetState(203)
 			p.Match(SPLParserSTRING)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -3571,13 +3638,13 @@ func (p *SPLParser) Value() (localctx IValueContext) {
 	case 3:
 		p.EnterOuterAlt(localctx, 3)
 		{
-			p.SetState(197)
+			p.SetState(204)
 			p.Id()
 		}
 
 	case 4:
 		p.EnterOuterAlt(localctx, 4)
-		p.SetState(199)
+		p.SetState(206)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -3586,7 +3653,7 @@ func (p *SPLParser) Value() (localctx IValueContext) {
 
 		if _la == SPLParserADD || _la == SPLParserSUB {
 			{
-				p.SetState(198)
+				p.SetState(205)
 				_la = p.GetTokenStream().LA(1)
 
 				if !(_la == SPLParserADD || _la == SPLParserSUB) {
@@ -3599,7 +3666,7 @@ func (p *SPLParser) Value() (localctx IValueContext) {
 
 		}
 		{
-			p.SetState(201)
+			p.SetState(208)
 			p.Match(SPLParserNUMBER)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -3724,7 +3791,7 @@ func (p *SPLParser) Date() (localctx IDateContext) {
 	p.EnterRule(localctx, 14, SPLParserRULE_date)
 	var _la int
 
-	p.SetState(212)
+	p.SetState(219)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -3733,7 +3800,7 @@ func (p *SPLParser) Date() (localctx IDateContext) {
 	switch p.GetTokenStream().LA(1) {
 	case SPLParserQUOTE, SPLParserTIME_AND_FUNCTION:
 		p.EnterOuterAlt(localctx, 1)
-		p.SetState(205)
+		p.SetState(212)
 		p.GetErrorHandler().Sync(p)
 		if p.HasError() {
 			goto errorExit
@@ -3742,7 +3809,7 @@ func (p *SPLParser) Date() (localctx IDateContext) {
 
 		if _la == SPLParserQUOTE {
 			{
-				p.SetState(204)
+				p.SetState(211)
 				p.Match(SPLParserQUOTE)
 				if p.HasError() {
 					// Recognition error - abort rule
@@ -3752,19 +3819,19 @@ func (p *SPLParser) Date() (localctx IDateContext) {
 
 		}
 		{
-			p.SetState(207)
+			p.SetState(214)
 			p.Match(SPLParserTIME_AND_FUNCTION)
 			if p.HasError() {
 				// Recognition error - abort rule
 				goto errorExit
 			}
 		}
-		p.SetState(209)
+		p.SetState(216)
 		p.GetErrorHandler().Sync(p)
 
 		if p.GetInterpreter().AdaptivePredict(p.BaseParser, p.GetTokenStream(), 23, p.GetParserRuleContext()) == 1 {
 			{
-				p.SetState(208)
+				p.SetState(215)
 				p.Match(SPLParserQUOTE)
 				if p.HasError() {
 					// Recognition error - abort rule
@@ -3779,7 +3846,7 @@ func (p *SPLParser) Date() (localctx IDateContext) {
 	case SPLParserTIME:
 		p.EnterOuterAlt(localctx, 2)
 		{
-			p.SetState(211)
+			p.SetState(218)
 			p.Match(SPLParserTIME)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -4018,7 +4085,7 @@ func (s *FunctionUseContext) Accept(visitor antlr.ParseTreeVisitor) interface{}
 func (p *SPLParser) Id() (localctx IIdContext) {
 	localctx = NewIdContext(p, p.GetParserRuleContext(), p.GetState())
 	p.EnterRule(localctx, 16, SPLParserRULE_id)
-	p.SetState(217)
+	p.SetState(224)
 	p.GetErrorHandler().Sync(p)
 	if p.HasError() {
 		goto errorExit
@@ -4029,7 +4096,7 @@ func (p *SPLParser) Id() (localctx IIdContext) {
 		localctx = NewFieldUseContext(p, localctx)
 		p.EnterOuterAlt(localctx, 1)
 		{
-			p.SetState(214)
+			p.SetState(221)
 			p.Match(SPLParserIDENTIFIER)
 			if p.HasError() {
 				// Recognition error - abort rule
@@ -4041,7 +4108,7 @@ func (p *SPLParser) Id() (localctx IIdContext) {
 		localctx = NewCommandUseContext(p, localctx)
 		p.EnterOuterAlt(localctx, 2)
 		{
-			p.SetState(215)
+			p.SetState(222)
 			p.Command()
 		}
 
@@ -4049,7 +4116,7 @@ func (p *SPLParser) Id() (localctx IIdContext) {
 		localctx = NewFunctionUseContext(p, localctx)
 		p.EnterOuterAlt(localctx, 3)
 		{
-			p.SetState(216)
+			p.SetState(223)
 			p.Function()
 		}
 
@@ -4177,7 +4244,7 @@ func (p *SPLParser) Function() (localctx IFunctionContext) {
 
 	p.EnterOuterAlt(localctx, 1)
 	{
-		p.SetState(219)
+		p.SetState(226)
 		_la = p.GetTokenStream().LA(1)
 
 		if !((int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&125627793408) != 0) {
@@ -4298,7 +4365,7 @@ func (p *SPLParser) Command() (localctx ICommandContext) {
 
 	p.EnterOuterAlt(localctx, 1)
 	{
-		p.SetState(221)
+		p.SetState(228)
 		_la = p.GetTokenStream().LA(1)
 
 		if !((int64(_la) & ^0x3f) == 0 && ((int64(1)<<_la)&15032385536) != 0) {
@@ -27,7 +27,7 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 // handleMapQuery handles field mapping requests
 // @Summary Map fields in an SPL query
-// @Description Apply field mappings to transform field names in an SPL query. Supports both stateless operation (provide mappings/config in request) and stateful operation (use pre-loaded mappings). For production use, provide mappings or config in each request to avoid global state.
+// @Description Apply field mappings to transform field names in an SPL query. The response lists every replacement with the mapping or rule that caused it, input fields without a mapping and mappings that never applied. Supports both stateless operation (provide mappings/config in request) and stateful operation (use pre-loaded mappings). For production use, provide mappings or config in each request to avoid global state.
 // @Tags query
 // @Accept json
 // @Produce json
@@ -61,20 +61,24 @@ func (s *Server) handleMapQuery(w http.ResponseWriter, r *http.Request) {
 	if req.Config != nil || len(req.Mappings) > 0 {
 		// Stateless mode: use mappings/config from request with caching
 		mappingsHash := s.computeMappingsHash(req.Mappings, req.Config)
-		currentMapper = s.getCachedMapper(mappingsHash, req.Mappings, req.Config)
+		var err error
+		if currentMapper, err = s.getCachedMapper(mappingsHash, req.Mappings, req.Config); err != nil {
+			s.writeErrorResponse(w, http.StatusBadRequest, "Invalid mappings: "+err.Error())
+			return
+		}
 	} else {
 		// Stateful mode: use global mapper (fallback for backward compatibility)
 		currentMapper = s.mapper.Load()
 	}
 
 	// Map the query
-	var mappedQuery string
+	var report *mapper.MappingReport
 	var err error
 
 	if req.Context != nil {
-		mappedQuery, err = currentMapper.MapQueryWithContext(req.Query, req.Context)
+		report, err = currentMapper.MapQueryDetailedWithContext(req.Query, req.Context)
 	} else {
-		mappedQuery, err = currentMapper.MapQuery(req.Query)
+		report, err = currentMapper.MapQueryDetailed(req.Query)
 	}
 
 	if err != nil {
@@ -83,9 +87,13 @@ func (s *Server) handleMapQuery(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := MapQueryResponse{
-		OriginalQuery: req.Query,
-		MappedQuery:   mappedQuery,
-		Success:       true,
+		OriginalQuery:  req.Query,
+		MappedQuery:    report.MappedQuery,
+		Success:        true,
+		Replacements:   report.Replacements,
+		UnmappedFields: report.UnmappedFields,
+		UnusedMappings: report.UnusedMappings,
+		Collisions:     report.Collisions,
 	}
 	s.writeJSONResponse(w, http.StatusOK, response)
 }
@@ -192,6 +200,233 @@ func (s *Server) handleValidateQuery(w http.ResponseWriter, r *http.Request) {
 	s.writeJSONResponse(w, http.StatusOK, response)
 }
 
+// handleLintQuery handles checking a query for likely problems
+// @Summary Lint an SPL query
+// @Description Check an SPL query for likely problems such as leading wildcards, searches without an index, join without max, transaction without maxspan, search after stats and table before stats. Rules can also be configured with inline lint comments in the query.
+// @Tags query
+// @Accept json
+// @Produce json
+// @Param request body LintQueryRequest true "Lint request"
+// @Success 200 {object} LintQueryResponse "Lint issues (empty when the query looks fine)"
+// @Failure 400 {object} ValidationErrorResponse "Invalid request or rule options"
+// @Failure 422 {object} LintQueryResponse "Query has invalid syntax"
+// @Router /query/lint [post]
+func (s *Server) handleLintQuery(w http.ResponseWriter, r *http.Request) {
+	var req LintQueryRequest
+	if err := parseJSONRequest(w, r, &req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	linter := mapper.NewQueryLinter()
+	if validationErrors := validateLintQueryRequest(&req, linter); len(validationErrors) > 0 {
+		response := ValidationErrorResponse{
+			Error:   true,
+			Message: "Validation failed",
+			Code:    http.StatusBadRequest,
+			Errors:  validationErrors,
+		}
+		s.writeJSONResponse(w, http.StatusBadRequest, response)
+		return
+	}
+
+	issues, err := linter.Lint(req.Query, mapper.QueryLintOptions{Disable: req.Disable, Severities: req.Severities})
+	if err != nil {
+		response := LintQueryResponse{
+			Query:   req.Query,
+			Success: false,
+			Issues:  []mapper.QueryLintIssue{},
+			Error:   err.Error(),
+		}
+		s.writeJSONResponse(w, http.StatusUnprocessableEntity, response)
+		return
+	}
+	if issues == nil {
+		issues = []mapper.QueryLintIssue{}
+	}
+
+	response := LintQueryResponse{
+		Query:   req.Query,
+		Success: true,
+		Issues:  issues,
+	}
+	s.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// handleEstimateCost handles estimating how expensive a query is
+// @Summary Estimate the cost of an SPL query
+// @Description Score an SPL query from its parse tree: missing index or time bounds, commands that run on the search head, subsearches, join, transaction and append, expensive regexes and stats searches that tstats could answer. Each factor has its points, its position in the query and, where possible, a suggested rewrite.
+// @Tags query
+// @Accept json
+// @Produce json
+// @Param request body EstimateCostRequest true "Cost estimate request"
+// @Success 200 {object} EstimateCostResponse "Cost estimate"
+// @Failure 400 {object} ValidationErrorResponse "Invalid request structure"
+// @Failure 422 {object} EstimateCostResponse "Query has invalid syntax"
+// @Router /query/cost [post]
+func (s *Server) handleEstimateCost(w http.ResponseWriter, r *http.Request) {
+	var req EstimateCostRequest
+	if err := parseJSONRequest(w, r, &req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if validationErrors := validateValidateQueryRequest(&ValidateQueryRequest{Query: req.Query}); len(validationErrors) > 0 {
+		response := ValidationErrorResponse{
+			Error:   true,
+			Message: "Validation failed",
+			Code:    http.StatusBadRequest,
+			Errors:  validationErrors,
+		}
+		s.writeJSONResponse(w, http.StatusBadRequest, response)
+		return
+	}
+
+	estimate, err := mapper.EstimateQueryCost(req.Query)
+	if err != nil {
+		response := EstimateCostResponse{
+			Success: false,
+			Error:   err.Error(),
+		}
+		s.writeJSONResponse(w, http.StatusUnprocessableEntity, response)
+		return
+	}
+
+	response := EstimateCostResponse{
+		Success:  true,
+		Estimate: estimate,
+	}
+	s.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// handleOptimizeQuery handles applying rewrites to a query
+// @Summary Optimize an SPL query
+// @Description Apply the selected semantics-preserving rewrites to an SPL query: moving search filters into the base search or before eval, merging consecutive evals and dropping redundant fields commands. Each applied rewrite is explained; rewrites whose result does not parse are reported as skipped.
+// @Tags query
+// @Accept json
+// @Produce json
+// @Param request body OptimizeQueryRequest true "Optimize request"
+// @Success 200 {object} OptimizeQueryResponse "Optimized query"
+// @Failure 400 {object} ValidationErrorResponse "Invalid request or unknown rewrite"
+// @Failure 422 {object} OptimizeQueryResponse "Query has invalid syntax"
+// @Router /query/optimize [post]
+func (s *Server) handleOptimizeQuery(w http.ResponseWriter, r *http.Request) {
+	var req OptimizeQueryRequest
+	if err := parseJSONRequest(w, r, &req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if validationErrors := validateOptimizeQueryRequest(&req); len(validationErrors) > 0 {
+		response := ValidationErrorResponse{
+			Error:   true,
+			Message: "Validation failed",
+			Code:    http.StatusBadRequest,
+			Errors:  validationErrors,
+		}
+		s.writeJSONResponse(w, http.StatusBadRequest, response)
+		return
+	}
+
+	result, err := mapper.OptimizeQuery(req.Query, mapper.OptimizeOptions{Rewrites: req.Rewrites})
+	if err != nil {
+		response := OptimizeQueryResponse{
+			Success: false,
+			Error:   err.Error(),
+		}
+		s.writeJSONResponse(w, http.StatusUnprocessableEntity, response)
+		return
+	}
+
+	response := OptimizeQueryResponse{
+		Success: true,
+		Result:  result,
+	}
+	s.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// handleCheckCIMCompliance handles checking a query against the CIM
+// @Summary Check an SPL query against the CIM
+// @Description Check the fields a tstats or datamodel search reads against the datasets of the bundled CIM field list, with suggested corrections for near-miss misspellings. Raw searches are flagged for fields that have a CIM-normalized name.
+// @Tags query
+// @Accept json
+// @Produce json
+// @Param request body CIMComplianceRequest true "CIM compliance request"
+// @Success 200 {object} CIMComplianceResponse "CIM compliance report"
+// @Failure 400 {object} ValidationErrorResponse "Invalid request structure"
+// @Failure 422 {object} CIMComplianceResponse "Query has invalid syntax"
+// @Router /query/cim [post]
+func (s *Server) handleCheckCIMCompliance(w http.ResponseWriter, r *http.Request) {
+	var req CIMComplianceRequest
+	if err := parseJSONRequest(w, r, &req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if validationErrors := validateValidateQueryRequest(&ValidateQueryRequest{Query: req.Query}); len(validationErrors) > 0 {
+		response := ValidationErrorResponse{
+			Error:   true,
+			Message: "Validation failed",
+			Code:    http.StatusBadRequest,
+			Errors:  validationErrors,
+		}
+		s.writeJSONResponse(w, http.StatusBadRequest, response)
+		return
+	}
+
+	report, err := mapper.CheckCIMCompliance(req.Query)
+	if err != nil {
+		response := CIMComplianceResponse{
+			Success: false,
+			Error:   err.Error(),
+		}
+		s.writeJSONResponse(w, http.StatusUnprocessableEntity, response)
+		return
+	}
+
+	response := CIMComplianceResponse{
+		Success: true,
+		Report:  report,
+	}
+	s.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// handleAnalyzeCoverage handles analyzing the coverage of a set of detections
+// @Summary Analyze detection coverage
+// @Description Report the onboarded data sources each detection relies on, based on the sourcetypes and datamodels it searches, and the fields it requires that those data sources do not produce. The report includes a matrix of ATT&CK data components to detections. Detections that do not parse are reported with an error.
+// @Tags coverage
+// @Accept json
+// @Produce json
+// @Param request body CoverageRequest true "Coverage request"
+// @Success 200 {object} CoverageResponse "Coverage report"
+// @Failure 400 {object} ValidationErrorResponse "Invalid request or data source configuration"
+// @Router /coverage [post]
+func (s *Server) handleAnalyzeCoverage(w http.ResponseWriter, r *http.Request) {
+	var req CoverageRequest
+	if err := parseJSONRequest(w, r, &req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if validationErrors := validateCoverageRequest(&req); len(validationErrors) > 0 {
+		response := ValidationErrorResponse{
+			Error:   true,
+			Message: "Validation failed",
+			Code:    http.StatusBadRequest,
+			Errors:  validationErrors,
+		}
+		s.writeJSONResponse(w, http.StatusBadRequest, response)
+		return
+	}
+
+	config := &mapper.CoverageConfig{DataSources: req.DataSources}
+	response := CoverageResponse{
+		Success: true,
+		Report:  mapper.AnalyzeCoverage(config, req.Detections),
+	}
+	s.writeJSONResponse(w, http.StatusOK, response)
+}
+
 // handleLoadMappings handles loading field mappings (admin-only endpoint)
 // @Summary Load field mappings into the server (ADMIN ONLY - DEV USE)
 // @Description **WARNING: This is an ephemeral, process-global, development-only endpoint.** Loads field mappings or mapping configuration globally for all subsequent requests. Not suitable for production multi-user environments. Use the mappings/config parameter in /query/map instead.
@@ -263,6 +498,58 @@ func (s *Server) handleLoadMappings(w http.ResponseWriter, r *http.Request) {
 	s.writeJSONResponse(w, http.StatusOK, response)
 }
 
+// handleMappingConfigSchema serves the JSON Schema for mapping configurations
+// @Summary Get the mapping configuration schema
+// @Description Get the JSON Schema that mapping configurations (the config parameter of /query/map and /mappings) must follow. Editors can use it to validate configuration files.
+// @Tags mappings
+// @Produce json
+// @Success 200 {object} map[string]interface{} "JSON Schema (draft 2020-12)"
+// @Router /mappings/schema [get]
+func (s *Server) handleMappingConfigSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	s.writeJSONResponse(w, http.StatusOK, mapper.MappingConfigSchema())
+}
+
+// handleLintConfig handles checking a mapping configuration for likely mistakes
+// @Summary Lint a mapping configuration
+// @Description Check a mapping configuration for chained, cyclic and self mappings, rules that can never match or are shadowed by a later rule, unused metadata keys and disabled rules nothing refers to
+// @Tags mappings
+// @Accept json
+// @Produce json
+// @Param request body LintConfigRequest true "Lint request"
+// @Success 200 {object} LintConfigResponse "Lint issues (empty when the configuration looks fine)"
+// @Failure 400 {object} ValidationErrorResponse "Invalid request or configuration"
+// @Router /mappings/lint [post]
+func (s *Server) handleLintConfig(w http.ResponseWriter, r *http.Request) {
+	var req LintConfigRequest
+	if err := parseJSONRequest(w, r, &req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if validationErrors := validateLintConfigRequest(&req); len(validationErrors) > 0 {
+		response := ValidationErrorResponse{
+			Error:   true,
+			Message: "Validation failed",
+			Code:    http.StatusBadRequest,
+			Errors:  validationErrors,
+		}
+		s.writeJSONResponse(w, http.StatusBadRequest, response)
+		return
+	}
+
+	issues := req.Config.Lint(mapper.ConfigLintOptions{MetadataKeys: req.MetadataKeys})
+	if issues == nil {
+		issues = []mapper.ConfigLintIssue{}
+	}
+
+	response := LintConfigResponse{
+		Success: true,
+		Issues:  issues,
+	}
+	s.writeJSONResponse(w, http.StatusOK, response)
+}
+
 // handleDocs serves the Swagger UI documentation with SRI protection
 // @Summary Get API documentation
 // @Description Serve interactive Swagger UI documentation for the SPL Toolkit API
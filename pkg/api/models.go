@@ -24,9 +24,13 @@ type MapQueryRequest struct {
 // MapQueryResponse represents the response from mapping a query
 // @Description Response from mapping an SPL query
 type MapQueryResponse struct {
-	OriginalQuery string `json:"original_query" example:"search src_ip=192.168.1.1" extensions:"x-order=1"`  // Original input query
-	MappedQuery   string `json:"mapped_query" example:"search source_ip=192.168.1.1" extensions:"x-order=2"` // Query with mapped field names
-	Success       bool   `json:"success" example:"true" extensions:"x-order=3"`                              // Whether the mapping was successful
+	OriginalQuery  string                      `json:"original_query" example:"search src_ip=192.168.1.1" extensions:"x-order=1"`  // Original input query
+	MappedQuery    string                      `json:"mapped_query" example:"search source_ip=192.168.1.1" extensions:"x-order=2"` // Query with mapped field names
+	Success        bool                        `json:"success" example:"true" extensions:"x-order=3"`                              // Whether the mapping was successful
+	Replacements   []mapper.MappingReplacement `json:"replacements" extensions:"x-order=4"`                                        // Every rewrite applied to the query
+	UnmappedFields []string                    `json:"unmapped_fields" extensions:"x-order=5"`                                     // Input fields without a mapping
+	UnusedMappings []mapper.UnusedMapping      `json:"unused_mappings" extensions:"x-order=6"`                                     // Mappings that never applied to the query
+	Collisions     []mapper.MappingCollision   `json:"collisions" extensions:"x-order=7"`                                          // Derived fields that clash with a mapping target
 }
 
 // DiscoverQueryRequest represents a request to discover query information
@@ -58,6 +62,80 @@ type ValidateQueryResponse struct {
 	Error   string `json:"error,omitempty" example:"syntax error at position 10" extensions:"x-order=4"` // Error message if validation failed
 }
 
+// LintQueryRequest represents a request to lint a query
+// @Description Request to check an SPL query for likely problems
+type LintQueryRequest struct {
+	Query      string            `json:"query" validate:"required" example:"index=web *error | table status | stats count by status" extensions:"x-order=1"` // SPL query to lint
+	Disable    []string          `json:"disable,omitempty" example:"missing-index" extensions:"x-order=2"`                                                   // IDs of rules not to run
+	Severities map[string]string `json:"severities,omitempty" extensions:"x-order=3"`                                                                        // Severity overrides by rule ID (error, warning or info)
+}
+
+// LintQueryResponse represents the response from linting a query
+// @Description Response from linting an SPL query
+type LintQueryResponse struct {
+	Query   string                  `json:"query" example:"index=web *error | table status | stats count by status" extensions:"x-order=1"` // Original query
+	Success bool                    `json:"success" example:"true" extensions:"x-order=2"`                                                  // Whether the query could be parsed and linted
+	Issues  []mapper.QueryLintIssue `json:"issues" extensions:"x-order=3"`                                                                  // Problems found, ordered by position
+	Error   string                  `json:"error,omitempty" example:"parse errors: line 1:6 mismatched input" extensions:"x-order=4"`       // Parse error if the query could not be linted
+}
+
+// EstimateCostRequest represents a request to estimate the cost of a query
+// @Description Request to estimate how expensive an SPL query is
+type EstimateCostRequest struct {
+	Query string `json:"query" validate:"required" example:"sourcetype=access | join user [search index=vpn] | stats count by user"` // SPL query to score
+}
+
+// EstimateCostResponse represents the response from estimating the cost of a query
+// @Description Response from estimating the cost of an SPL query
+type EstimateCostResponse struct {
+	Success  bool                      `json:"success" example:"true" extensions:"x-order=1"`                                            // Whether the query could be parsed and scored
+	Estimate *mapper.QueryCostEstimate `json:"estimate,omitempty" extensions:"x-order=2"`                                                // Score, rating and cost factors with suggested rewrites
+	Error    string                    `json:"error,omitempty" example:"parse errors: line 1:6 mismatched input" extensions:"x-order=3"` // Parse error if the query could not be scored
+}
+
+// OptimizeQueryRequest represents a request to optimize a query
+// @Description Request to apply semantics-preserving rewrites to an SPL query
+type OptimizeQueryRequest struct {
+	Query    string   `json:"query" validate:"required" example:"index=web | eval kb=bytes/1024 | search status=500" extensions:"x-order=1"` // SPL query to optimize
+	Rewrites []string `json:"rewrites" validate:"required" example:"search-to-base-filter" extensions:"x-order=2"`                           // IDs of the rewrites to apply
+}
+
+// OptimizeQueryResponse represents the response from optimizing a query
+// @Description Response from optimizing an SPL query
+type OptimizeQueryResponse struct {
+	Success bool                   `json:"success" example:"true" extensions:"x-order=1"`                                            // Whether the query could be parsed and optimized
+	Result  *mapper.OptimizedQuery `json:"result,omitempty" extensions:"x-order=2"`                                                  // Optimized query with the applied and skipped rewrites
+	Error   string                 `json:"error,omitempty" example:"parse errors: line 1:6 mismatched input" extensions:"x-order=3"` // Parse error if the query could not be optimized
+}
+
+// CIMComplianceRequest represents a request to check a query against the CIM
+// @Description Request to check an SPL query against the bundled CIM field list
+type CIMComplianceRequest struct {
+	Query string `json:"query" validate:"required" example:"| tstats count from datamodel=Endpoint.Processes by Processes.proces_name"` // SPL query to check
+}
+
+// CIMComplianceResponse represents the response from checking a query against the CIM
+// @Description Response from checking an SPL query against the CIM
+type CIMComplianceResponse struct {
+	Success bool                        `json:"success" example:"true" extensions:"x-order=1"`                                            // Whether the query could be parsed and checked
+	Report  *mapper.CIMComplianceReport `json:"report,omitempty" extensions:"x-order=2"`                                                  // CIM datasets the query reads and its issues
+	Error   string                      `json:"error,omitempty" example:"parse errors: line 1:6 mismatched input" extensions:"x-order=3"` // Parse error if the query could not be checked
+}
+
+// CoverageRequest represents a request to analyze the coverage of a set of detections
+// @Description Request to report the data sources and ATT&CK data components detections rely on
+type CoverageRequest struct {
+	DataSources []mapper.CoverageDataSource `json:"data_sources" validate:"required" extensions:"x-order=1"` // Onboarded data sources with the fields and data components they provide
+	Detections  []mapper.Detection          `json:"detections" validate:"required" extensions:"x-order=2"`   // Named detection searches to analyze
+}
+
+// CoverageResponse represents the response from analyzing detection coverage
+// @Description Response from analyzing detection coverage
+type CoverageResponse struct {
+	Success bool                   `json:"success" example:"true" extensions:"x-order=1"` // Whether the coverage was analyzed
+	Report  *mapper.CoverageReport `json:"report,omitempty" extensions:"x-order=2"`       // Per-detection coverage and the data component matrix
+}
+
 // LoadMappingsRequest represents a request to load field mappings
 // @Description Request to load field mappings into the server
 type LoadMappingsRequest struct {
@@ -73,6 +151,20 @@ type LoadMappingsResponse struct {
 	RulesCount    int  `json:"rules_count,omitempty" example:"2" extensions:"x-order=3"` // Number of conditional rules loaded
 }
 
+// LintConfigRequest represents a request to lint a mapping configuration
+// @Description Request to check a mapping configuration for likely mistakes
+type LintConfigRequest struct {
+	Config       *mapper.MappingConfig `json:"config" validate:"required" extensions:"x-order=1"`                   // Mapping configuration to lint
+	MetadataKeys []string              `json:"metadata_keys,omitempty" example:"owner,tags" extensions:"x-order=2"` // Metadata keys read by your tools; other keys are reported as unused
+}
+
+// LintConfigResponse represents the response from linting a mapping configuration
+// @Description Response from linting a mapping configuration
+type LintConfigResponse struct {
+	Success bool                     `json:"success" example:"true" extensions:"x-order=1"` // Whether the lint ran
+	Issues  []mapper.ConfigLintIssue `json:"issues" extensions:"x-order=2"`                 // Likely mistakes found in the configuration
+}
+
 // HealthResponse represents the health check response
 // @Description Health check response
 type HealthResponse struct {
@@ -154,10 +246,11 @@ func validateMapQueryRequest(req *MapQueryRequest) []ValidationError {
 				Message: "source field is required",
 			})
 		}
-		if strings.TrimSpace(mapping.Target) == "" {
+		// The same checks LoadMappings runs: one target and value transforms that compile
+		if err := mapping.Validate(); err != nil {
 			errors = append(errors, ValidationError{
-				Field:   fmt.Sprintf("mappings[%d].target", i),
-				Message: "target field is required",
+				Field:   fmt.Sprintf("mappings[%d]", i),
+				Message: err.Error(),
 			})
 		}
 	}
@@ -222,6 +315,87 @@ func validateValidateQueryRequest(req *ValidateQueryRequest) []ValidationError {
 	return errors
 }
 
+// validateLintQueryRequest validates a LintQueryRequest, including its rule options
+func validateLintQueryRequest(req *LintQueryRequest, linter *mapper.QueryLinter) []ValidationError {
+	errors := validateValidateQueryRequest(&ValidateQueryRequest{Query: req.Query})
+
+	for _, id := range req.Disable {
+		if err := linter.ValidateOptions(mapper.QueryLintOptions{Disable: []string{id}}); err != nil {
+			errors = append(errors, ValidationError{Field: "disable", Message: err.Error()})
+		}
+	}
+	for id, severity := range req.Severities {
+		if err := linter.ValidateOptions(mapper.QueryLintOptions{Severities: map[string]string{id: severity}}); err != nil {
+			errors = append(errors, ValidationError{Field: "severities", Message: err.Error()})
+		}
+	}
+
+	return errors
+}
+
+// validateOptimizeQueryRequest validates an OptimizeQueryRequest
+func validateOptimizeQueryRequest(req *OptimizeQueryRequest) []ValidationError {
+	errors := validateValidateQueryRequest(&ValidateQueryRequest{Query: req.Query})
+
+	if len(req.Rewrites) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   "rewrites",
+			Message: "at least one rewrite is required",
+		})
+	}
+	known := make(map[string]bool)
+	for _, rewrite := range mapper.QueryRewrites() {
+		known[rewrite.ID] = true
+	}
+	for _, id := range req.Rewrites {
+		if !known[id] {
+			errors = append(errors, ValidationError{
+				Field:   "rewrites",
+				Message: fmt.Sprintf("unknown rewrite %q", id),
+			})
+		}
+	}
+
+	return errors
+}
+
+// validateCoverageRequest validates a CoverageRequest
+func validateCoverageRequest(req *CoverageRequest) []ValidationError {
+	var errors []ValidationError
+
+	config := &mapper.CoverageConfig{DataSources: req.DataSources}
+	if err := config.Validate(); err != nil {
+		errors = append(errors, ValidationError{
+			Field:   "data_sources",
+			Message: err.Error(),
+		})
+	}
+
+	if len(req.Detections) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   "detections",
+			Message: "at least one detection is required",
+		})
+	}
+	// Limit the batch size to prevent excessive processing
+	if len(req.Detections) > 1000 {
+		errors = append(errors, ValidationError{
+			Field:   "detections",
+			Message: "too many detections (maximum 1000)",
+		})
+	}
+	for i, detection := range req.Detections {
+		for _, err := range validateValidateQueryRequest(&ValidateQueryRequest{Query: detection.Query}) {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("detections[%d].%s", i, err.Field),
+				Message: err.Message,
+			})
+		}
+	}
+
+	return errors
+}
+
 // validateLoadMappingsRequest validates a LoadMappingsRequest
 func validateLoadMappingsRequest(req *LoadMappingsRequest) []ValidationError {
 	var errors []ValidationError
@@ -246,7 +420,7 @@ func validateLoadMappingsRequest(req *LoadMappingsRequest) []ValidationError {
 				Message: "source field is required",
 			})
 		}
-		if strings.TrimSpace(mapping.Target) == "" {
+		if strings.TrimSpace(mapping.Target) == "" && len(mapping.Targets) == 0 && strings.TrimSpace(mapping.Expression) == "" {
 			errors = append(errors, ValidationError{
 				Field:   fmt.Sprintf("mappings[%d].target", i),
 				Message: "target field is required",
@@ -269,3 +443,25 @@ func validateLoadMappingsRequest(req *LoadMappingsRequest) []ValidationError {
 
 	return errors
 }
+
+// validateLintConfigRequest validates a LintConfigRequest
+func validateLintConfigRequest(req *LintConfigRequest) []ValidationError {
+	var errors []ValidationError
+
+	if req.Config == nil {
+		errors = append(errors, ValidationError{
+			Field:   "config",
+			Message: "config is required",
+		})
+		return errors
+	}
+
+	for _, err := range req.Config.Validate().Errors {
+		errors = append(errors, ValidationError{
+			Field:   "config",
+			Message: err,
+		})
+	}
+
+	return errors
+}
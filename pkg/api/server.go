@@ -130,12 +130,13 @@ func getServerConfigFromEnv() ServerConfig {
 	return config
 }
 
-// getCachedMapper retrieves a cached temporary mapper or creates one if not found/expired
-func (s *Server) getCachedMapper(mappingsHash string, mappings []mapper.FieldMapping, config *mapper.MappingConfig) *mapper.Mapper {
+// getCachedMapper retrieves a cached temporary mapper or creates one if not found/expired.
+// Mappings that fail to load return an error and are not cached.
+func (s *Server) getCachedMapper(mappingsHash string, mappings []mapper.FieldMapping, config *mapper.MappingConfig) (*mapper.Mapper, error) {
 	if entry, ok := s.mapperCache.Load(mappingsHash); ok {
 		cacheEntry := entry.(*mapperCacheEntry)
 		if time.Now().Before(cacheEntry.expiresAt) {
-			return cacheEntry.mapper
+			return cacheEntry.mapper, nil
 		}
 		// Expired, remove from cache
 		s.mapperCache.Delete(mappingsHash)
@@ -148,8 +149,13 @@ func (s *Server) getCachedMapper(mappingsHash string, mappings []mapper.FieldMap
 	} else {
 		tempMapper = mapper.New()
 		if len(mappings) > 0 {
-			mappingsJSON, _ := json.Marshal(mappings)
-			tempMapper.LoadMappings(mappingsJSON)
+			mappingsJSON, err := json.Marshal(mappings)
+			if err != nil {
+				return nil, err
+			}
+			if err := tempMapper.LoadMappings(mappingsJSON); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -160,7 +166,7 @@ func (s *Server) getCachedMapper(mappingsHash string, mappings []mapper.FieldMap
 	}
 	s.mapperCache.Store(mappingsHash, cacheEntry)
 
-	return tempMapper
+	return tempMapper, nil
 }
 
 // computeMappingsHash creates a hash of mappings/config for cache key
@@ -190,9 +196,18 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("POST /api/v1/query/map", s.handleMapQuery)
 	s.mux.HandleFunc("POST /api/v1/query/discover", s.handleDiscoverQuery)
 	s.mux.HandleFunc("POST /api/v1/query/validate", s.handleValidateQuery)
+	s.mux.HandleFunc("POST /api/v1/query/lint", s.handleLintQuery)
+	s.mux.HandleFunc("POST /api/v1/query/cost", s.handleEstimateCost)
+	s.mux.HandleFunc("POST /api/v1/query/optimize", s.handleOptimizeQuery)
+	s.mux.HandleFunc("POST /api/v1/query/cim", s.handleCheckCIMCompliance)
 
 	// Mapping configuration endpoints
 	s.mux.HandleFunc("POST /api/v1/mappings", s.handleLoadMappings)
+	s.mux.HandleFunc("GET /api/v1/mappings/schema", s.handleMappingConfigSchema)
+	s.mux.HandleFunc("POST /api/v1/mappings/lint", s.handleLintConfig)
+
+	// Detection coverage endpoint
+	s.mux.HandleFunc("POST /api/v1/coverage", s.handleAnalyzeCoverage)
 
 	// Documentation endpoint (will serve static swagger UI)
 	s.mux.HandleFunc("GET /api/v1/docs", s.handleDocs)
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/delgado-jacob/spl-toolkit/pkg/mapper"
@@ -594,6 +596,91 @@ func TestStatelessMapping(t *testing.T) {
 	}
 }
 
+func TestStatelessMappingValidation(t *testing.T) {
+	server := NewServer()
+
+	// The regex of the value replacement does not compile
+	requestBody := `{
+		"query": "search host=web01.corp",
+		"mappings": [{"source": "host", "target": "dest_host", "replace": [{"pattern": "(corp", "replacement": ""}]}]
+	}`
+
+	req, err := http.NewRequest("POST", "/api/v1/query/map", bytes.NewBuffer([]byte(requestBody)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response ValidationErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Errors) != 1 || response.Errors[0].Field != "mappings[0]" || !strings.Contains(response.Errors[0].Message, "(corp") {
+		t.Errorf("Expected an error for the replacement pattern of mappings[0], got %+v", response.Errors)
+	}
+
+	// A mapper whose mappings fail to load is never cached
+	mappings := []mapper.FieldMapping{{Source: "host", Target: "dest_host", Replace: []mapper.ValueReplacement{{Pattern: "(corp"}}}}
+	if _, err := server.getCachedMapper("invalid", mappings, nil); err == nil {
+		t.Error("Expected an error for mappings that fail to load")
+	}
+	if _, cached := server.mapperCache.Load("invalid"); cached {
+		t.Error("Expected the failed mapper not to be cached")
+	}
+}
+
+func TestMapQueryEndpointReport(t *testing.T) {
+	server := NewServer()
+
+	requestBody := `{
+		"query": "search src_ip=192.168.1.1 | stats count by dest",
+		"config": {
+			"version": "1.0",
+			"mappings": [
+				{"id": "ip", "source": "src_ip", "target": "source_ip"},
+				{"source": "user", "target": "user_name"}
+			]
+		}
+	}`
+
+	req, err := http.NewRequest("POST", "/api/v1/query/map", bytes.NewBuffer([]byte(requestBody)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response MapQueryResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if len(response.Replacements) != 1 {
+		t.Fatalf("Expected 1 replacement, got %+v", response.Replacements)
+	}
+	if response.Replacements[0].Original != "src_ip" || response.Replacements[0].MappingID != "ip" {
+		t.Errorf("Unexpected replacement: %+v", response.Replacements[0])
+	}
+	if len(response.UnmappedFields) != 1 || response.UnmappedFields[0] != "dest" {
+		t.Errorf("Expected 'dest' to be unmapped, got %v", response.UnmappedFields)
+	}
+	if len(response.UnusedMappings) != 1 || response.UnusedMappings[0].Source != "user" {
+		t.Errorf("Expected 'user' mapping to be unused, got %+v", response.UnusedMappings)
+	}
+}
+
 func TestMapperCaching(t *testing.T) {
 	server := NewServer()
 
@@ -636,3 +723,434 @@ func TestMapperCaching(t *testing.T) {
 		t.Errorf("Cached response differs from original")
 	}
 }
+
+func TestMappingConfigSchemaEndpoint(t *testing.T) {
+	server := NewServer()
+
+	req, err := http.NewRequest("GET", "/api/v1/mappings/schema", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/schema+json" {
+		t.Errorf("Expected Content-Type application/schema+json, got %s", contentType)
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &schema); err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	if schema["$schema"] != "https://json-schema.org/draft/2020-12/schema" {
+		t.Errorf("Expected a draft 2020-12 schema, got %v", schema["$schema"])
+	}
+	if _, ok := schema["$defs"].(map[string]interface{})["Condition"]; !ok {
+		t.Error("Expected the schema to define Condition")
+	}
+}
+
+func TestLintConfigEndpoint(t *testing.T) {
+	server := NewServer()
+
+	tests := []struct {
+		name           string
+		request        LintConfigRequest
+		expectedStatus int
+		expectedChecks []string
+	}{
+		{
+			name: "Config with issues",
+			request: LintConfigRequest{
+				Config: &mapper.MappingConfig{
+					Version: "1.0",
+					Mappings: []mapper.FieldMapping{
+						{Source: "a", Target: "b"},
+						{Source: "b", Target: "c"},
+					},
+					Metadata: map[string]interface{}{"owner": "soc", "notes": "todo"},
+				},
+				MetadataKeys: []string{"owner"},
+			},
+			expectedStatus: http.StatusOK,
+			expectedChecks: []string{mapper.ConfigCheckChainedMapping, mapper.ConfigCheckUnusedMetadata},
+		},
+		{
+			name: "Clean config",
+			request: LintConfigRequest{
+				Config: &mapper.MappingConfig{
+					Version:  "1.0",
+					Mappings: []mapper.FieldMapping{{Source: "src_ip", Target: "src"}},
+				},
+			},
+			expectedStatus: http.StatusOK,
+			expectedChecks: []string{},
+		},
+		{
+			name:           "Missing config",
+			request:        LintConfigRequest{},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.request)
+			req, err := http.NewRequest("POST", "/api/v1/mappings/lint", bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v, body: %s", status, tt.expectedStatus, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response LintConfigResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			checks := []string{}
+			for _, issue := range response.Issues {
+				checks = append(checks, issue.Check)
+			}
+			if strings.Join(checks, ",") != strings.Join(tt.expectedChecks, ",") {
+				t.Errorf("Expected checks %v, got %v", tt.expectedChecks, checks)
+			}
+		})
+	}
+}
+
+func TestLintQueryEndpoint(t *testing.T) {
+	server := NewServer()
+
+	tests := []struct {
+		name           string
+		request        LintQueryRequest
+		expectedStatus int
+		expectedRules  []string
+	}{
+		{
+			name:           "Query with issues",
+			request:        LintQueryRequest{Query: "index=web *error | table host | stats count by host"},
+			expectedStatus: http.StatusOK,
+			expectedRules:  []string{mapper.QueryCheckLeadingWildcard, mapper.QueryCheckTableBeforeStats},
+		},
+		{
+			name: "Disabled rules",
+			request: LintQueryRequest{
+				Query:   "error | join user [search index=vpn]",
+				Disable: []string{mapper.QueryCheckMissingIndex, mapper.QueryCheckJoinWithoutMax},
+			},
+			expectedStatus: http.StatusOK,
+			expectedRules:  []string{},
+		},
+		{
+			name:           "Unknown rule",
+			request:        LintQueryRequest{Query: "index=web", Severities: map[string]string{"nope": "error"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid query",
+			request:        LintQueryRequest{Query: "index=web | stats count("},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Missing query",
+			request:        LintQueryRequest{},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.request)
+			req, err := http.NewRequest("POST", "/api/v1/query/lint", bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v, body: %s", status, tt.expectedStatus, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response LintQueryResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			rules := []string{}
+			for _, issue := range response.Issues {
+				rules = append(rules, issue.Rule)
+			}
+			if strings.Join(rules, ",") != strings.Join(tt.expectedRules, ",") {
+				t.Errorf("Expected rules %v, got %v", tt.expectedRules, rules)
+			}
+		})
+	}
+}
+
+func TestEstimateCostEndpoint(t *testing.T) {
+	server := NewServer()
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedRating string
+	}{
+		{"Expensive query", "sourcetype=access | join user [search sourcetype=vpn]", http.StatusOK, mapper.QueryCostHigh},
+		{"Cheap query", "index=web earliest=-1h | stats count by user", http.StatusOK, mapper.QueryCostLow},
+		{"Invalid query", "index=web | stats count(", http.StatusUnprocessableEntity, ""},
+		{"Missing query", "", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(EstimateCostRequest{Query: tt.query})
+			req, err := http.NewRequest("POST", "/api/v1/query/cost", bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v, body: %s", status, tt.expectedStatus, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response EstimateCostResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if !response.Success || response.Estimate == nil || response.Estimate.Rating != tt.expectedRating {
+				t.Errorf("Expected a %s estimate, got %s", tt.expectedRating, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestOptimizeQueryEndpoint(t *testing.T) {
+	server := NewServer()
+
+	tests := []struct {
+		name              string
+		request           OptimizeQueryRequest
+		expectedStatus    int
+		expectedOptimized string
+	}{
+		{
+			name:              "Applies selected rewrites",
+			request:           OptimizeQueryRequest{Query: "index=web | eval kb=bytes/1024 | search status=500", Rewrites: []string{mapper.RewriteSearchToBaseFilter}},
+			expectedStatus:    http.StatusOK,
+			expectedOptimized: "index=web status=500 | eval kb=bytes/1024",
+		},
+		{
+			name:           "Unknown rewrite",
+			request:        OptimizeQueryRequest{Query: "index=web", Rewrites: []string{"nope"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "No rewrites",
+			request:        OptimizeQueryRequest{Query: "index=web"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid query",
+			request:        OptimizeQueryRequest{Query: "index=web | stats count(", Rewrites: []string{mapper.RewriteDropRedundantFields}},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.request)
+			req, err := http.NewRequest("POST", "/api/v1/query/optimize", bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v, body: %s", status, tt.expectedStatus, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response OptimizeQueryResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if response.Result == nil || response.Result.Optimized != tt.expectedOptimized {
+				t.Errorf("Expected %q, got %s", tt.expectedOptimized, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestCIMComplianceEndpoint(t *testing.T) {
+	server := NewServer()
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedChecks []string
+	}{
+		{"Misspelled datamodel field", "| tstats count from datamodel=Endpoint.Processes by Processes.proces_name", http.StatusOK, []string{mapper.CIMMisspelledField}},
+		{"Compliant query", "| tstats count from datamodel=Web by Web.url Web.status", http.StatusOK, nil},
+		{"Invalid query", "index=web | stats count(", http.StatusUnprocessableEntity, nil},
+		{"Missing query", "", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(CIMComplianceRequest{Query: tt.query})
+			req, err := http.NewRequest("POST", "/api/v1/query/cim", bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v, body: %s", status, tt.expectedStatus, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response CIMComplianceResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if !response.Success || response.Report == nil {
+				t.Fatalf("Expected a report, got %s", rr.Body.String())
+			}
+			var checks []string
+			for _, issue := range response.Report.Issues {
+				checks = append(checks, issue.Check)
+			}
+			if !reflect.DeepEqual(checks, tt.expectedChecks) {
+				t.Errorf("Expected checks %v, got %v", tt.expectedChecks, checks)
+			}
+		})
+	}
+}
+
+func TestCoverageEndpoint(t *testing.T) {
+	server := NewServer()
+	sysmon := mapper.CoverageDataSource{
+		Name:           "Sysmon",
+		Sourcetypes:    []string{"XmlWinEventLog:Microsoft-Windows-Sysmon/Operational"},
+		Datamodels:     []string{"Endpoint.Processes"},
+		Fields:         []string{"process_name", "dest"},
+		DataComponents: []string{"Process Creation"},
+	}
+
+	tests := []struct {
+		name               string
+		request            CoverageRequest
+		expectedStatus     int
+		expectedComponents []string
+		expectedMissing    []string
+	}{
+		{
+			name: "Reports data components and missing fields",
+			request: CoverageRequest{
+				DataSources: []mapper.CoverageDataSource{sysmon},
+				Detections: []mapper.Detection{{
+					Name:  "encoded_powershell",
+					Query: "| tstats count from datamodel=Endpoint.Processes where Processes.process_name=powershell.exe by Processes.dest Processes.parent_process",
+				}},
+			},
+			expectedStatus:     http.StatusOK,
+			expectedComponents: []string{"Process Creation"},
+			expectedMissing:    []string{"parent_process"},
+		},
+		{
+			name:           "No detections",
+			request:        CoverageRequest{DataSources: []mapper.CoverageDataSource{sysmon}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Empty detection query",
+			request: CoverageRequest{
+				DataSources: []mapper.CoverageDataSource{sysmon},
+				Detections:  []mapper.Detection{{Name: "empty"}},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Data source without sourcetypes or datamodels",
+			request: CoverageRequest{
+				DataSources: []mapper.CoverageDataSource{{Name: "Sysmon"}},
+				Detections:  []mapper.Detection{{Name: "all", Query: "index=main"}},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.request)
+			req, err := http.NewRequest("POST", "/api/v1/coverage", bytes.NewBuffer(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			server.Handler().ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v, body: %s", status, tt.expectedStatus, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response CoverageResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if response.Report == nil || len(response.Report.Detections) != 1 {
+				t.Fatalf("Expected one detection, got %s", rr.Body.String())
+			}
+			coverage := response.Report.Detections[0]
+			if !reflect.DeepEqual(coverage.DataComponents, tt.expectedComponents) {
+				t.Errorf("Expected data components %v, got %v", tt.expectedComponents, coverage.DataComponents)
+			}
+			if !reflect.DeepEqual(coverage.MissingFields, tt.expectedMissing) {
+				t.Errorf("Expected missing fields %v, got %v", tt.expectedMissing, coverage.MissingFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package mapper
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Kinds of analyzed app stanzas
+const (
+	StanzaSavedSearch = "savedsearch"
+	StanzaMacro       = "macro"
+)
+
+// AppAnalysisOptions configures AnalyzeSplunkApp
+type AppAnalysisOptions struct {
+	Mapper      *Mapper          // Maps every search when set; its command registry also applies to validation
+	Linter      *QueryLinter     // Defaults to a linter with the built-in rules and the mapper's command registry
+	Lint        QueryLintOptions // Rules to disable and severity overrides
+	Concurrency int              // Stanzas analyzed at once; defaults to the number of CPUs
+}
+
+// StanzaAnalysis is the analysis of a saved search or macro definition
+type StanzaAnalysis struct {
+	Kind            string           `json:"kind"` // StanzaSavedSearch or StanzaMacro
+	Name            string           `json:"name"`
+	File            string           `json:"file"`
+	Line            int              `json:"line"`
+	Disabled        bool             `json:"disabled,omitempty"`
+	Query           string           `json:"query"` // The search with its macros expanded
+	Valid           bool             `json:"valid"`
+	ValidationError string           `json:"validation_error,omitempty"`
+	Discovery       *QueryInfo       `json:"discovery,omitempty"`
+	LintIssues      []QueryLintIssue `json:"lint_issues"`
+	MappedQuery     string           `json:"mapped_query,omitempty"` // Set when a mapper is given
+	Skipped         string           `json:"skipped,omitempty"`      // Why the stanza was not analyzed
+	// Errors are the failures of this stanza's analysis; the other stanzas are still analyzed
+	Errors []string `json:"errors,omitempty"`
+}
+
+// AppAnalysisSummary counts the results of an app analysis
+type AppAnalysisSummary struct {
+	SavedSearches int `json:"saved_searches"`
+	Macros        int `json:"macros"`
+	Valid         int `json:"valid"`
+	Invalid       int `json:"invalid"`
+	LintIssues    int `json:"lint_issues"`
+	Mapped        int `json:"mapped"` // Stanzas the mapper changed
+	Skipped       int `json:"skipped"`
+	Errors        int `json:"errors"` // Stanzas with errors
+}
+
+// AppAnalysisReport is the consolidated analysis of the stanzas of a Splunk app
+type AppAnalysisReport struct {
+	App     string             `json:"app"`
+	Summary AppAnalysisSummary `json:"summary"`
+	Stanzas []StanzaAnalysis   `json:"stanzas"`
+}
+
+// macroArgumentPattern matches $argument$ references in macro definitions
+var macroArgumentPattern = regexp.MustCompile(`\$([A-Za-z0-9_]+)\$`)
+
+// AnalyzeSplunkApp validates, discovers, lints and optionally maps every saved search and
+// macro definition of an app concurrently. Saved searches are analyzed with their macros
+// expanded; macro definitions are analyzed on their own, with each $argument$ replaced by
+// the argument's name. Eval-based macros are skipped.
+func AnalyzeSplunkApp(app *SplunkApp, options AppAnalysisOptions) *AppAnalysisReport {
+	registry := defaultRegistry
+	if options.Mapper != nil {
+		registry = options.Mapper.registry
+	}
+	if options.Linter == nil {
+		options.Linter = NewQueryLinter()
+		options.Linter.SetCommandRegistry(registry)
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = runtime.NumCPU()
+	}
+
+	stanzas := make([]StanzaAnalysis, 0, len(app.SavedSearches)+len(app.Macros))
+	for _, search := range app.SavedSearches {
+		stanzas = append(stanzas, StanzaAnalysis{
+			Kind:     StanzaSavedSearch,
+			Name:     search.Name,
+			File:     search.File,
+			Line:     search.Line,
+			Disabled: search.Disabled,
+			Query:    search.Search,
+		})
+	}
+	for _, macro := range app.Macros {
+		stanza := StanzaAnalysis{Kind: StanzaMacro, Name: macro.Name, File: macro.File, Line: macro.Line, Query: macro.Definition}
+		if macro.IsEval {
+			stanza.Skipped = "eval-based macros build their search at run time"
+		}
+		stanzas = append(stanzas, stanza)
+	}
+
+	indexes := make(chan int)
+	var wait sync.WaitGroup
+	for worker := 0; worker < options.Concurrency; worker++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			validator := NewParserWithRegistry(registry)
+			for i := range indexes {
+				analyzeAppStanza(app, &stanzas[i], validator, options)
+			}
+		}()
+	}
+	for i := range stanzas {
+		indexes <- i
+	}
+	close(indexes)
+	wait.Wait()
+
+	report := &AppAnalysisReport{App: app.Name, Stanzas: stanzas}
+	for _, stanza := range stanzas {
+		summary := &report.Summary
+		if stanza.Kind == StanzaMacro {
+			summary.Macros++
+		} else {
+			summary.SavedSearches++
+		}
+		switch {
+		case stanza.Skipped != "":
+			summary.Skipped++
+		case stanza.Valid:
+			summary.Valid++
+		default:
+			summary.Invalid++
+		}
+		summary.LintIssues += len(stanza.LintIssues)
+		if stanza.MappedQuery != "" && stanza.MappedQuery != stanza.Query {
+			summary.Mapped++
+		}
+		if len(stanza.Errors) > 0 {
+			summary.Errors++
+		}
+	}
+	return report
+}
+
+// analyzeAppStanza analyzes one stanza, recording its failures instead of returning them
+func analyzeAppStanza(app *SplunkApp, stanza *StanzaAnalysis, validator *Parser, options AppAnalysisOptions) {
+	stanza.LintIssues = []QueryLintIssue{}
+	if stanza.Skipped != "" {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			stanza.Errors = append(stanza.Errors, fmt.Sprintf("analysis failed: %v", r))
+		}
+	}()
+
+	if stanza.Kind == StanzaMacro {
+		stanza.Query = macroArgumentPattern.ReplaceAllString(stanza.Query, "$1")
+	}
+	expanded, err := app.ExpandMacros(stanza.Query)
+	if err != nil {
+		stanza.Errors = append(stanza.Errors, err.Error())
+	} else {
+		stanza.Query = expanded
+	}
+
+	if err := validator.ValidateQuery(stanza.Query); err != nil {
+		stanza.ValidationError = err.Error()
+		return
+	}
+	stanza.Valid = true
+
+	m := options.Mapper
+	if m == nil {
+		m = New()
+	}
+	if stanza.Discovery, err = m.DiscoverQuery(stanza.Query); err != nil {
+		stanza.Errors = append(stanza.Errors, "discovery: "+err.Error())
+	}
+	if issues, err := options.Linter.Lint(stanza.Query, options.Lint); err != nil {
+		stanza.Errors = append(stanza.Errors, "lint: "+err.Error())
+	} else if issues != nil {
+		stanza.LintIssues = issues
+	}
+	if options.Mapper != nil {
+		if stanza.MappedQuery, err = options.Mapper.MapQuery(stanza.Query); err != nil {
+			stanza.Errors = append(stanza.Errors, "mapping: "+err.Error())
+		}
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *AppAnalysisReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes a row per stanza. Lists are joined with semicolons.
+func (r *AppAnalysisReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{
+		"kind", "name", "file", "line", "disabled", "valid", "validation_error", "indexes",
+		"sourcetypes", "datamodels", "input_fields", "lint_issues", "mapped_query", "skipped", "errors",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, stanza := range r.Stanzas {
+		info := stanza.Discovery
+		if info == nil {
+			info = &QueryInfo{}
+		}
+		var issues []string
+		for _, issue := range stanza.LintIssues {
+			issues = append(issues, issue.Rule)
+		}
+		row := []string{
+			stanza.Kind, stanza.Name, stanza.File, strconv.Itoa(stanza.Line),
+			strconv.FormatBool(stanza.Disabled), strconv.FormatBool(stanza.Valid), stanza.ValidationError,
+			strings.Join(info.Indexes, ";"), strings.Join(info.SourceTypes, ";"),
+			strings.Join(info.DataModels, ";"), strings.Join(info.InputFields, ";"),
+			strings.Join(issues, ";"), stanza.MappedQuery, stanza.Skipped, strings.Join(stanza.Errors, ";"),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteMarkdown writes the summary, a table of the stanzas and the problems of each stanza
+func (r *AppAnalysisReport) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	s := r.Summary
+	fmt.Fprintf(&b, "# Analysis of %s\n\n", r.App)
+	fmt.Fprintf(&b, "| Saved searches | Macros | Valid | Invalid | Skipped | Lint issues | Mapped | With errors |\n")
+	fmt.Fprintf(&b, "|---|---|---|---|---|---|---|---|\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d | %d | %d |\n\n",
+		s.SavedSearches, s.Macros, s.Valid, s.Invalid, s.Skipped, s.LintIssues, s.Mapped, s.Errors)
+
+	fmt.Fprintf(&b, "## Stanzas\n\n")
+	fmt.Fprintf(&b, "| Stanza | Kind | Location | Status | Lint issues | Input fields |\n")
+	fmt.Fprintf(&b, "|---|---|---|---|---|---|\n")
+	for _, stanza := range r.Stanzas {
+		var fields []string
+		if stanza.Discovery != nil {
+			fields = stanza.Discovery.InputFields
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s:%d | %s | %d | %s |\n", markdownCell(stanza.Name), stanza.Kind,
+			stanza.File, stanza.Line, stanzaStatus(stanza), len(stanza.LintIssues), markdownCell(strings.Join(fields, ", ")))
+	}
+
+	problems := false
+	for _, stanza := range r.Stanzas {
+		if stanza.ValidationError == "" && len(stanza.LintIssues) == 0 && len(stanza.Errors) == 0 {
+			continue
+		}
+		if !problems {
+			fmt.Fprintf(&b, "\n## Problems\n")
+			problems = true
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", markdownCell(stanza.Name))
+		if stanza.ValidationError != "" {
+			fmt.Fprintf(&b, "- **invalid**: %s\n", stanza.ValidationError)
+		}
+		for _, err := range stanza.Errors {
+			fmt.Fprintf(&b, "- **error**: %s\n", err)
+		}
+		for _, issue := range stanza.LintIssues {
+			fmt.Fprintf(&b, "- **%s** `%s` (line %d, column %d): %s\n", issue.Severity, issue.Rule, issue.Line, issue.Column, issue.Message)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func stanzaStatus(stanza StanzaAnalysis) string {
+	switch {
+	case stanza.Skipped != "":
+		return "skipped"
+	case !stanza.Valid:
+		return "invalid"
+	case len(stanza.Errors) > 0:
+		return "errors"
+	case stanza.MappedQuery != "" && stanza.MappedQuery != stanza.Query:
+		return "mapped"
+	}
+	return "valid"
+}
+
+// markdownCell escapes text for a Markdown table cell
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
@@ -0,0 +1,109 @@
+package mapper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeSplunkApp(t *testing.T) {
+	app := &SplunkApp{
+		Name: "my_app",
+		SavedSearches: []SavedSearch{
+			{Name: "Failed Logons", Search: "`win` EventCode=4625 | stats count by src_ip user", File: "default/savedsearches.conf", Line: 2},
+			{Name: "Broken", Search: "index=web | stats count(", File: "default/savedsearches.conf", Line: 5},
+			{Name: "Undefined", Search: "`nope` | stats count", File: "default/savedsearches.conf", Line: 8},
+			{Name: "No Index", Search: "sourcetype=proxy | stats count by src_ip", File: "local/savedsearches.conf", Line: 2},
+		},
+		Macros: []SearchMacro{
+			{Name: "win", Definition: "index=wineventlog", File: "default/macros.conf", Line: 2},
+			{Name: "failed(1)", Args: []string{"code"}, Definition: "index=wineventlog EventCode=$code$", File: "default/macros.conf", Line: 5},
+			{Name: "dyn", Definition: `"index=main"`, IsEval: true, File: "default/macros.conf", Line: 9},
+		},
+	}
+	m := New()
+	if err := m.LoadMappings([]byte(`[{"source": "src_ip", "target": "src"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	report := AnalyzeSplunkApp(app, AppAnalysisOptions{Mapper: m, Concurrency: 3})
+
+	tests := []struct {
+		name       string
+		valid      bool
+		query      string
+		mapped     string
+		lintIssues []string
+		hasErrors  bool
+		skipped    bool
+	}{
+		{name: "Failed Logons", valid: true, query: "index=wineventlog EventCode=4625 | stats count by src_ip user", mapped: "index=wineventlog EventCode=4625 | stats count by src user"},
+		{name: "Broken"},
+		{name: "Undefined", hasErrors: true},
+		{name: "No Index", valid: true, mapped: "sourcetype=proxy | stats count by src", lintIssues: []string{"missing-index"}},
+		{name: "win", valid: true, mapped: "index=wineventlog"},
+		{name: "failed(1)", valid: true, query: "index=wineventlog EventCode=code", mapped: "index=wineventlog EventCode=code"},
+		{name: "dyn", skipped: true},
+	}
+	if len(report.Stanzas) != len(tests) {
+		t.Fatalf("Expected %d stanzas, got %d", len(tests), len(report.Stanzas))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stanza := report.Stanzas[i]
+			if stanza.Name != tt.name {
+				t.Fatalf("Expected stanza %s, got %s", tt.name, stanza.Name)
+			}
+			if stanza.Valid != tt.valid || (!tt.valid && !tt.skipped && stanza.ValidationError == "") {
+				t.Errorf("Expected valid %v, got %v (%s)", tt.valid, stanza.Valid, stanza.ValidationError)
+			}
+			if tt.query != "" && stanza.Query != tt.query {
+				t.Errorf("Expected query %q, got %q", tt.query, stanza.Query)
+			}
+			if stanza.MappedQuery != tt.mapped {
+				t.Errorf("Expected mapped query %q, got %q", tt.mapped, stanza.MappedQuery)
+			}
+			var rules []string
+			for _, issue := range stanza.LintIssues {
+				rules = append(rules, issue.Rule)
+			}
+			if !reflect.DeepEqual(rules, tt.lintIssues) {
+				t.Errorf("Expected lint issues %v, got %v", tt.lintIssues, rules)
+			}
+			if (len(stanza.Errors) > 0) != tt.hasErrors {
+				t.Errorf("Expected errors %v, got %v", tt.hasErrors, stanza.Errors)
+			}
+			if (stanza.Skipped != "") != tt.skipped {
+				t.Errorf("Expected skipped %v, got %q", tt.skipped, stanza.Skipped)
+			}
+		})
+	}
+
+	expected := AppAnalysisSummary{SavedSearches: 4, Macros: 3, Valid: 4, Invalid: 2, LintIssues: 1, Mapped: 2, Skipped: 1, Errors: 1}
+	if report.Summary != expected {
+		t.Errorf("Expected summary %+v, got %+v", expected, report.Summary)
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteCSV(&out); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != len(tests)+1 || !strings.HasPrefix(lines[1], "savedsearch,Failed Logons,default/savedsearches.conf,2,false,true,") {
+		t.Errorf("Unexpected CSV:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := report.WriteMarkdown(&out); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	for _, want := range []string{"# Analysis of my_app", "| 4 | 3 | 4 | 2 | 1 | 1 | 2 | 1 |", "| failed(1) | macro | default/macros.conf:5 | valid |", "### No Index", "undefined macro `nope`"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected Markdown to contain %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := report.WriteJSON(&out); err != nil || !strings.Contains(out.String(), `"mapped_query": "sourcetype=proxy | stats count by src"`) {
+		t.Errorf("Unexpected JSON (%v):\n%s", err, out.String())
+	}
+}
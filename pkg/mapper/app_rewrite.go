@@ -0,0 +1,613 @@
+package mapper
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// AppRewrite is the result of applying a mapping configuration to the searches of a Splunk
+// app. Nothing is written until Apply is called.
+type AppRewrite struct {
+	App    string           `json:"app"`
+	Path   string           `json:"path"`   // App directory or package
+	Files  []AppFileRewrite `json:"files"`  // Files with at least one changed search
+	Errors []AppQueryError  `json:"errors"` // Searches left unchanged because they could not be mapped
+}
+
+// AppFileRewrite lists the changed searches of a file of an app
+type AppFileRewrite struct {
+	File     string            `json:"file"` // Slash-separated path within the app
+	Searches []AppQueryRewrite `json:"searches"`
+
+	original  []byte
+	rewritten []byte
+}
+
+// AppQueryRewrite is a search of an app file changed by the mapping
+type AppQueryRewrite struct {
+	Location string `json:"location"` // Stanza, dashboard search ID or data source ID
+	Line     int    `json:"line"`     // 1-based line of the search in the file
+	Original string `json:"original"`
+	Mapped   string `json:"mapped"`
+}
+
+// AppQueryError is a search of an app that could not be mapped
+type AppQueryError struct {
+	File     string `json:"file"`
+	Location string `json:"location"`
+	Line     int    `json:"line"`
+	Error    string `json:"error"`
+}
+
+// appQuery is a search embedded in a file of an app, decoded from the file's escaping
+type appQuery struct {
+	location string
+	start    int    // Byte offset of the encoded search in the file
+	end      int    // Byte offset just past the encoded search
+	encoding string // One of the appQuery encodings
+}
+
+// Encodings of searches in app files
+const (
+	appQueryConf  = "conf"  // .conf value with backslash continuations
+	appQueryXML   = "xml"   // XML character data with entities
+	appQueryCDATA = "cdata" // XML CDATA section
+	appQueryJSON  = "json"  // JSON string content
+)
+
+// RewriteSplunkApp applies a mapper to every search of an app directory or .spl, .tgz or
+// .tar.gz package: the search settings of savedsearches.conf, the definitions of
+// macros.conf, and the queries of Simple XML and Dashboard Studio dashboards. Only the
+// mapped parts of each search change; every other byte of the files is kept.
+func RewriteSplunkApp(appPath string, m *Mapper) (*AppRewrite, error) {
+	name, files, err := readAppFiles(appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AppRewrite{App: name, Path: appPath, Files: []AppFileRewrite{}, Errors: []AppQueryError{}}
+	paths := make([]string, 0, len(files))
+	for file := range files {
+		paths = append(paths, file)
+	}
+	sort.Strings(paths)
+
+	for _, file := range paths {
+		data := files[file]
+		queries, err := appFileQueries(file, data)
+		if err != nil {
+			result.Errors = append(result.Errors, AppQueryError{File: file, Error: err.Error()})
+			continue
+		}
+
+		rewrite := AppFileRewrite{File: file, Searches: []AppQueryRewrite{}, original: data}
+		var rewritten bytes.Buffer
+		last := 0
+		for _, query := range queries {
+			line := 1 + bytes.Count(data[:query.start], []byte("\n"))
+			raw := data[query.start:query.end]
+			encoded, original, mapped, err := rewriteAppQuery(m, query.encoding, raw)
+			if err != nil {
+				result.Errors = append(result.Errors, AppQueryError{File: file, Location: query.location, Line: line, Error: err.Error()})
+				continue
+			}
+			if mapped == original {
+				continue
+			}
+			rewritten.Write(data[last:query.start])
+			rewritten.Write(encoded)
+			last = query.end
+			rewrite.Searches = append(rewrite.Searches, AppQueryRewrite{Location: query.location, Line: line, Original: original, Mapped: mapped})
+		}
+		if len(rewrite.Searches) == 0 {
+			continue
+		}
+		rewritten.Write(data[last:])
+		rewrite.rewritten = rewritten.Bytes()
+		result.Files = append(result.Files, rewrite)
+	}
+	return result, nil
+}
+
+// Diff returns the unified diff of the changed files
+func (r *AppRewrite) Diff() string {
+	var diff strings.Builder
+	for _, file := range r.Files {
+		diff.WriteString(unifiedDiff(file.File, file.original, file.rewritten))
+	}
+	return diff.String()
+}
+
+// Apply writes the changed files to the app directory, or replaces the app package with
+// one holding the changed files
+func (r *AppRewrite) Apply() error {
+	if len(r.Files) == 0 {
+		return nil
+	}
+	changed := make(map[string][]byte)
+	for _, file := range r.Files {
+		changed[file.File] = file.rewritten
+	}
+
+	info, err := os.Stat(r.Path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return rewriteAppPackage(r.Path, changed)
+	}
+	for file, data := range changed {
+		target := filepath.Join(r.Path, filepath.FromSlash(file))
+		fileInfo, err := os.Stat(target)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, fileInfo.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteAppPackage replaces the changed files of a package, keeping its other entries
+func rewriteAppPackage(packagePath string, changed map[string][]byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(packagePath), ".spl-toolkit-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	gzipWriter := gzip.NewWriter(temp)
+	tarWriter := tar.NewWriter(gzipWriter)
+	err = readAppPackage(packagePath, func(header *tar.Header, reader io.Reader) error {
+		_, relative, _ := strings.Cut(strings.TrimPrefix(path.Clean(header.Name), "./"), "/")
+		if data, ok := changed[relative]; ok && header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(data))
+			reader = bytes.NewReader(data)
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := io.Copy(tarWriter, reader)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", packagePath, err)
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(packagePath); err == nil {
+		os.Chmod(temp.Name(), info.Mode().Perm())
+	}
+	return os.Rename(temp.Name(), packagePath)
+}
+
+// rewriteAppQuery maps an encoded search and returns its new encoding with the decoded
+// original and mapped searches. The edits between the two are applied to the encoded text,
+// so unchanged parts keep their original escaping.
+func rewriteAppQuery(m *Mapper, encoding string, raw []byte) ([]byte, string, string, error) {
+	original, offsets, err := decodeAppQuery(encoding, raw)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if strings.TrimSpace(original) == "" {
+		return raw, original, original, nil
+	}
+
+	mapped, err := mapSearchPlaceholders(m, original)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if mapped == original {
+		return raw, original, mapped, nil
+	}
+
+	// Byte offsets of the runes of the decoded search
+	runeOffsets := make([]int, 0, len(original)+1)
+	for i := range original {
+		runeOffsets = append(runeOffsets, i)
+	}
+	runeOffsets = append(runeOffsets, len(original))
+
+	a, b := []rune(original), []rune(mapped)
+	ops := diffSlices(a, b)
+	var encoded bytes.Buffer
+	last := 0
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == '=' {
+			i++
+			continue
+		}
+		start, deleted := ops[i].A, 0
+		var inserted []rune
+		for ; i < len(ops) && ops[i].Kind != '='; i++ {
+			if ops[i].Kind == '-' {
+				deleted++
+			} else {
+				inserted = append(inserted, b[ops[i].B])
+			}
+		}
+		rawStart, rawEnd := offsets[runeOffsets[start]], offsets[runeOffsets[start+deleted]]
+		encoded.Write(raw[last:rawStart])
+		encoded.WriteString(encodeAppQuery(encoding, raw, string(inserted)))
+		last = rawEnd
+	}
+	encoded.Write(raw[last:])
+
+	if check, _, err := decodeAppQuery(encoding, encoded.Bytes()); err != nil || check != mapped {
+		return nil, "", "", fmt.Errorf("the mapped search cannot be written back in place")
+	}
+	return encoded.Bytes(), original, mapped, nil
+}
+
+// mapSearchPlaceholders maps a search with $token$ references and `macro` invocations, which
+// the grammar does not parse. Tokens are replaced with the placeholders of
+// substituteDashboardTokens and macros with identifiers, and both are restored in the mapped
+// search.
+func mapSearchPlaceholders(m *Mapper, search string) (string, error) {
+	substituted := substituteDashboardTokens(search)
+	masked, macros := maskSearchMacros(substituted.text)
+	mapped, err := m.MapQuery(masked)
+	if err != nil {
+		return "", err
+	}
+	return substituted.restoreMapped(macros.Replace(mapped))
+}
+
+// maskSearchMacros replaces the `macro` invocations of a search with identifiers so it
+// parses, and returns a replacer that restores them. A macro that is a whole command of the
+// pipeline is replaced with a search command.
+func maskSearchMacros(search string) (string, *strings.Replacer) {
+	comments := commentRanges(search)
+	var masked strings.Builder
+	var restore []string
+	last := 0
+	for _, match := range macroPattern.FindAllStringIndex(search, -1) {
+		start, end := match[0], match[1]
+		if inRanges(comments, start) {
+			continue
+		}
+		placeholder := fmt.Sprintf("__splt%d__", len(restore)/2)
+		if isWholeCommand(search, start, end) {
+			placeholder = "search " + placeholder
+		}
+		masked.WriteString(search[last:start])
+		masked.WriteString(placeholder)
+		restore = append(restore, placeholder, search[start:end])
+		last = end
+	}
+	masked.WriteString(search[last:])
+	return masked.String(), strings.NewReplacer(restore...)
+}
+
+// isWholeCommand reports whether search[start:end] follows a pipe and is followed by a pipe
+// or the end of the search
+func isWholeCommand(search string, start, end int) bool {
+	before := strings.TrimRight(search[:start], " \t\r\n")
+	after := strings.TrimLeft(search[end:], " \t\r\n")
+	return strings.HasSuffix(before, "|") && (after == "" || strings.HasPrefix(after, "|"))
+}
+
+// appFileQueries locates the searches of an app file
+func appFileQueries(file string, data []byte) ([]appQuery, error) {
+	switch {
+	case file == "default/savedsearches.conf" || file == "local/savedsearches.conf":
+		return confQueries(data, "search", nil), nil
+	case file == "default/macros.conf" || file == "local/macros.conf":
+		return confQueries(data, "definition", func(stanza ConfStanza) bool {
+			iseval, _ := stanza.Get("iseval")
+			return isConfTrue(iseval)
+		}), nil
+	case isDashboardFile(file) && strings.HasSuffix(file, ".xml"):
+		return dashboardXMLQueries(data)
+	case isDashboardFile(file) && strings.HasSuffix(file, ".json"):
+		return studioQueries(data, 0, len(data))
+	}
+	return nil, nil
+}
+
+// isDashboardFile reports whether an app file is a dashboard source
+func isDashboardFile(file string) bool {
+	return strings.HasPrefix(file, "default/data/ui/views/") || strings.HasPrefix(file, "local/data/ui/views/")
+}
+
+// confQueries locates the values of a setting in every stanza of a .conf file
+func confQueries(data []byte, key string, skip func(ConfStanza) bool) []appQuery {
+	var queries []appQuery
+	for _, stanza := range ParseConf(data) {
+		if skip != nil && skip(stanza) {
+			continue
+		}
+		for _, setting := range stanza.Settings {
+			if setting.Key == key {
+				queries = append(queries, appQuery{
+					location: stanza.Name,
+					start:    setting.ValueStart,
+					end:      setting.ValueEnd,
+					encoding: appQueryConf,
+				})
+			}
+		}
+	}
+	return queries
+}
+
+// dashboardSearchElements are the Simple XML elements that hold searches
+var dashboardSearchElements = []string{"query", "searchString", "searchTemplate", "searchPostProcess"}
+
+var (
+	xmlIDPattern       = regexp.MustCompile(`\sid\s*=\s*["']([^"']*)["']`)
+	xmlCDATAPattern    = regexp.MustCompile(`(?s)^\s*<!\[CDATA\[(.*)\]\]>\s*$`)
+	definitionPattern  = regexp.MustCompile(`(?s)<definition(?:\s[^>]*)?>\s*<!\[CDATA\[(.*?)\]\]>\s*</definition>`)
+	searchStartPattern = regexp.MustCompile(`<search(?:\s[^>]*)?>`)
+)
+
+// dashboardXMLQueries locates the searches of a Simple XML dashboard, and of the Dashboard
+// Studio definition it wraps
+func dashboardXMLQueries(data []byte) ([]appQuery, error) {
+	text := string(data)
+	var queries []appQuery
+	for _, element := range dashboardSearchElements {
+		pattern := regexp.MustCompile(`(?s)<` + element + `(?:\s[^>]*)?>(.*?)</` + element + `>`)
+		for _, match := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			query := appQuery{start: match[2], end: match[3], encoding: appQueryXML}
+			if cdata := xmlCDATAPattern.FindStringSubmatchIndex(text[query.start:query.end]); cdata != nil {
+				query.start, query.end = query.start+cdata[2], query.start+cdata[3]
+				query.encoding = appQueryCDATA
+			} else if strings.Contains(text[query.start:query.end], "<![CDATA[") {
+				return nil, fmt.Errorf("line %d: searches mixing CDATA and text are not supported", 1+strings.Count(text[:query.start], "\n"))
+			}
+			query.location = dashboardSearchID(text, match[0])
+			queries = append(queries, query)
+		}
+	}
+
+	for _, match := range definitionPattern.FindAllStringSubmatchIndex(text, -1) {
+		studio, err := studioQueries(data, match[2], match[3])
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, studio...)
+	}
+
+	sort.Slice(queries, func(i, j int) bool { return queries[i].start < queries[j].start })
+	return queries, nil
+}
+
+// dashboardSearchID returns the ID of the <search> element enclosing an offset, if any
+func dashboardSearchID(text string, offset int) string {
+	starts := searchStartPattern.FindAllStringIndex(text[:offset], -1)
+	if len(starts) == 0 {
+		return ""
+	}
+	last := starts[len(starts)-1]
+	if strings.Contains(text[last[1]:offset], "</search>") {
+		return ""
+	}
+	if id := xmlIDPattern.FindStringSubmatch(text[last[0]:last[1]]); id != nil {
+		return id[1]
+	}
+	return ""
+}
+
+// studioQueries locates the queries of the ds.search and ds.chain data sources of a
+// Dashboard Studio definition held in data[start:end]
+func studioQueries(data []byte, start, end int) ([]appQuery, error) {
+	definition := data[start:end]
+	var parsed struct {
+		DataSources map[string]struct {
+			Type string `json:"type"`
+		} `json:"dataSources"`
+	}
+	if err := json.Unmarshal(definition, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid Dashboard Studio definition: %w", err)
+	}
+
+	type frame struct {
+		object    bool
+		key       string
+		expectKey bool
+	}
+	var stack []*frame
+	var queries []appQuery
+	decoder := json.NewDecoder(bytes.NewReader(definition))
+	for {
+		before := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Dashboard Studio definition: %w", err)
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		switch token := token.(type) {
+		case json.Delim:
+			if token == '{' || token == '[' {
+				stack = append(stack, &frame{object: token == '{', expectKey: true})
+				continue
+			}
+			stack = stack[:len(stack)-1]
+		case string:
+			if top != nil && top.object && top.expectKey {
+				top.key, top.expectKey = token, false
+				continue
+			}
+			if len(stack) == 4 && stack[0].key == "dataSources" && stack[2].key == "options" && stack[3].key == "query" {
+				source := parsed.DataSources[stack[1].key]
+				if source.Type == "ds.search" || source.Type == "ds.chain" {
+					quote := before + int64(bytes.IndexByte(definition[before:], '"'))
+					queries = append(queries, appQuery{
+						location: stack[1].key,
+						start:    start + int(quote) + 1,
+						end:      start + int(decoder.InputOffset()) - 1,
+						encoding: appQueryJSON,
+					})
+				}
+			}
+		}
+		if len(stack) > 0 && stack[len(stack)-1].object {
+			stack[len(stack)-1].expectKey = true
+		}
+	}
+	return queries, nil
+}
+
+// decodeAppQuery decodes an encoded search and returns, for each byte of the search and
+// for its end, the offset of the encoded text it was decoded from
+func decodeAppQuery(encoding string, raw []byte) (string, []int, error) {
+	var text strings.Builder
+	offsets := make([]int, 0, len(raw)+1)
+	write := func(s string, offset int) {
+		for range len(s) {
+			offsets = append(offsets, offset)
+		}
+		text.WriteString(s)
+	}
+
+	for i := 0; i < len(raw); {
+		switch {
+		case encoding == appQueryConf && bytes.HasPrefix(raw[i:], []byte("\\\n")):
+			write("\n", i)
+			i += 2
+		case encoding == appQueryConf && bytes.HasPrefix(raw[i:], []byte("\\\r\n")):
+			write("\n", i)
+			i += 3
+		case encoding == appQueryXML && raw[i] == '&':
+			semicolon := bytes.IndexByte(raw[i:], ';')
+			if semicolon < 0 {
+				return "", nil, fmt.Errorf("unterminated XML entity")
+			}
+			decoded, err := decodeXMLEntity(string(raw[i+1 : i+semicolon]))
+			if err != nil {
+				return "", nil, err
+			}
+			write(decoded, i)
+			i += semicolon + 1
+		case encoding == appQueryJSON && raw[i] == '\\':
+			decoded, length, err := decodeJSONEscape(raw[i:])
+			if err != nil {
+				return "", nil, err
+			}
+			write(decoded, i)
+			i += length
+		default:
+			write(string(raw[i]), i)
+			i++
+		}
+	}
+	offsets = append(offsets, len(raw))
+	if !utf8.ValidString(text.String()) {
+		return "", nil, fmt.Errorf("search is not valid UTF-8")
+	}
+	return text.String(), offsets, nil
+}
+
+func decodeXMLEntity(entity string) (string, error) {
+	switch entity {
+	case "lt":
+		return "<", nil
+	case "gt":
+		return ">", nil
+	case "amp":
+		return "&", nil
+	case "quot":
+		return `"`, nil
+	case "apos":
+		return "'", nil
+	}
+	if strings.HasPrefix(entity, "#") {
+		base, digits := 10, entity[1:]
+		if strings.HasPrefix(digits, "x") || strings.HasPrefix(digits, "X") {
+			base, digits = 16, digits[1:]
+		}
+		if code, err := strconv.ParseInt(digits, base, 32); err == nil {
+			return string(rune(code)), nil
+		}
+	}
+	return "", fmt.Errorf("unknown XML entity &%s;", entity)
+}
+
+// decodeJSONEscape decodes the escape sequence at the start of raw and returns its length
+func decodeJSONEscape(raw []byte) (string, int, error) {
+	if len(raw) < 2 {
+		return "", 0, fmt.Errorf("unterminated JSON escape")
+	}
+	switch raw[1] {
+	case '"', '\\', '/':
+		return string(raw[1]), 2, nil
+	case 'b':
+		return "\b", 2, nil
+	case 'f':
+		return "\f", 2, nil
+	case 'n':
+		return "\n", 2, nil
+	case 'r':
+		return "\r", 2, nil
+	case 't':
+		return "\t", 2, nil
+	case 'u':
+		length := 6
+		var decoded string
+		// Decode through encoding/json, which combines surrogate pairs
+		if len(raw) >= 12 && raw[6] == '\\' && raw[7] == 'u' {
+			if err := json.Unmarshal(append(append([]byte{'"'}, raw[:12]...), '"'), &decoded); err == nil && utf8.RuneCountInString(decoded) == 1 {
+				return decoded, 12, nil
+			}
+		}
+		if len(raw) < length {
+			return "", 0, fmt.Errorf("unterminated JSON escape")
+		}
+		if err := json.Unmarshal(append(append([]byte{'"'}, raw[:length]...), '"'), &decoded); err != nil {
+			return "", 0, err
+		}
+		return decoded, length, nil
+	}
+	return "", 0, fmt.Errorf("invalid JSON escape %q", raw[:2])
+}
+
+// encodeAppQuery encodes text inserted into an encoded search. raw is the encoded search,
+// whose line breaks conf continuations follow.
+func encodeAppQuery(encoding string, raw []byte, text string) string {
+	switch encoding {
+	case appQueryConf:
+		if bytes.Contains(raw, []byte("\\\r\n")) {
+			return strings.ReplaceAll(text, "\n", "\\\r\n")
+		}
+		return strings.ReplaceAll(text, "\n", "\\\n")
+	case appQueryXML:
+		return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	case appQueryJSON:
+		var encoded bytes.Buffer
+		encoder := json.NewEncoder(&encoded)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(text)
+		return strings.TrimSuffix(strings.TrimSuffix(encoded.String(), "\n"), `"`)[1:]
+	}
+	return text
+}
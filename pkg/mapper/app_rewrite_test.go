@@ -0,0 +1,112 @@
+package mapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewriteSplunkApp(t *testing.T) {
+	files := map[string]string{
+		"default/savedsearches.conf": "# Detections\n[default]\ndispatch.earliest_time = -24h\n\n[Scan]\nsearch = index=fw src_ip=10.0.0.1 \\\n| stats count by src_ip \\\n| where count > 5\ncron_schedule = */5 * * * *\n\n[Token]\nsearch = index=fw src_ip=$ip$ `fw_filter` | `fw_fields` | table src_ip\n\n[Broken]\nsearch = index=fw src_ip=1 | stats count(\n",
+		"local/macros.conf":          "[fw_filter(1)]\r\nargs = f\r\ndefinition = search src_ip=$f$ \\\r\n| fields src_ip\r\n\r\n[dyn]\r\niseval = 1\r\ndefinition = \"src_ip=1\"\r\n",
+		"default/data/ui/views/simple.xml": "<form>\n  <search id=\"base\">\n    <query>index=fw src_ip=\"$ip$\" dest_port&lt;100 | fields src_ip</query>\n  </search>\n" +
+			"  <row><panel><chart>\n    <search base=\"base\"><query><![CDATA[| stats count by src_ip | where count > 1]]></query></search>\n  </chart></panel></row>\n</form>\n",
+		"default/data/ui/views/studio.json": "{\n  \"dataSources\": {\n    \"ds_1\": {\"type\": \"ds.search\", \"options\": {\"query\": \"index=fw src_ip=\\\"1.2.3.4\\\"\\n| stats count by src_ip\"}},\n" +
+			"    \"ds_2\": {\"type\": \"ds.chain\", \"options\": {\"extend\": \"ds_1\", \"query\": \"| where src_ip!=\\\"x\\\"\"}},\n    \"ds_3\": {\"type\": \"ds.test\", \"options\": {\"query\": \"src_ip=1\"}}\n  }\n}\n",
+		"default/app.conf": "[launcher]\nversion = 1.0\n",
+	}
+	expected := map[string]string{
+		"default/savedsearches.conf":       strings.NewReplacer("src_ip=10", "src=10", "by src_ip", "by src", "src_ip=$ip$", "src=$ip$", "table src_ip", "table src").Replace(files["default/savedsearches.conf"]),
+		"local/macros.conf":                strings.NewReplacer("src_ip=$f$", "src=$f$", "fields src_ip", "fields src").Replace(files["local/macros.conf"]),
+		"default/data/ui/views/simple.xml": strings.NewReplacer("src_ip=", "src=", "src_ip |", "src |", "fields src_ip", "fields src").Replace(files["default/data/ui/views/simple.xml"]),
+		"default/data/ui/views/studio.json": strings.NewReplacer("\"ds.search\", \"options\": {\"query\": \"index=fw src_ip", "\"ds.search\", \"options\": {\"query\": \"index=fw src",
+			"by src_ip", "by src", "where src_ip", "where src").Replace(files["default/data/ui/views/studio.json"]),
+		"default/app.conf": files["default/app.conf"],
+	}
+
+	m := New()
+	if err := m.LoadMappings([]byte(`[{"source": "src_ip", "target": "src"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	dir, packagePath := writeTestApp(t, files)
+	for _, path := range []string{dir, packagePath} {
+		rewrite, err := RewriteSplunkApp(path, m)
+		if err != nil {
+			t.Fatalf("RewriteSplunkApp(%s) failed: %v", path, err)
+		}
+		if len(rewrite.Files) != 4 {
+			t.Fatalf("Expected 4 changed files, got %+v", rewrite.Files)
+		}
+		if len(rewrite.Errors) != 1 || rewrite.Errors[0].Location != "Broken" || rewrite.Errors[0].Line != 15 {
+			t.Errorf("Expected the Broken search to be skipped, got %+v", rewrite.Errors)
+		}
+		if searches := rewrite.Files[0].Searches; rewrite.Files[0].File != "default/data/ui/views/simple.xml" || len(searches) != 2 || searches[0].Location != "base" ||
+			searches[0].Original != `index=fw src_ip="$ip$" dest_port<100 | fields src_ip` || searches[0].Mapped != `index=fw src="$ip$" dest_port<100 | fields src` {
+			t.Errorf("Unexpected dashboard searches %+v", rewrite.Files[0])
+		}
+
+		diff := rewrite.Diff()
+		for _, want := range []string{
+			"--- a/default/savedsearches.conf\n+++ b/default/savedsearches.conf\n@@ -3,13 +3,13 @@\n",
+			"-search = index=fw src_ip=10.0.0.1 \\\n-| stats count by src_ip \\\n+search = index=fw src=10.0.0.1 \\\n+| stats count by src \\\n",
+			"+    \"ds_2\": {\"type\": \"ds.chain\", \"options\": {\"extend\": \"ds_1\", \"query\": \"| where src!=\\\"x\\\"\"}},\n",
+		} {
+			if !strings.Contains(diff, want) {
+				t.Errorf("Expected diff to contain %q:\n%s", want, diff)
+			}
+		}
+		if err := rewrite.Apply(); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		_, written, err := readAppFiles(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for file, want := range expected {
+			if string(written[file]) != want {
+				t.Errorf("Unexpected %s in %s:\n%q\nwant:\n%q", file, path, written[file], want)
+			}
+		}
+
+		again, err := RewriteSplunkApp(path, m)
+		if err != nil || len(again.Files) != 0 || again.Diff() != "" {
+			t.Errorf("Expected a rewritten app to be unchanged by a second rewrite, got %+v (%v)", again, err)
+		}
+	}
+}
+
+func TestRewriteAppQuery(t *testing.T) {
+	m := New()
+	if err := m.LoadMappings([]byte(`[{"source": "src_ip", "target": "source_address"}]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		encoding string
+		raw      string
+		expected string
+	}{
+		{appQueryConf, "src_ip=1 \\\n| stats count by src_ip", "source_address=1 \\\n| stats count by source_address"},
+		{appQueryXML, `src_ip=&quot;a&amp;b&quot; | where src_ip&gt;1`, `source_address=&quot;a&amp;b&quot; | where source_address&gt;1`},
+		{appQueryCDATA, "src_ip=1 | where x<1", "source_address=1 | where x<1"},
+		{appQueryJSON, `src_ip=\"caf\u00e9\"\n| table src_ip`, `source_address=\"caf\u00e9\"\n| table source_address`},
+		{appQueryConf, "`m(src_ip)` src_ip=$tok|s$ | `fields`", "`m(src_ip)` source_address=$tok|s$ | `fields`"},
+		{appQueryConf, "src_ip=1 | $filter$ | table src_ip", "source_address=1 | $filter$ | table source_address"},
+		{appQueryConf, "src_ip=$v$ earliest=$t$ | timechart span=$s$ count by src_ip | head $n$", "source_address=$v$ earliest=$t$ | timechart span=$s$ count by source_address | head $n$"},
+		{appQueryConf, "`m($tok$)` src_ip=1 | where src_ip > $min$", "`m($tok$)` source_address=1 | where source_address > $min$"},
+	}
+	for _, tt := range tests {
+		encoded, _, _, err := rewriteAppQuery(m, tt.encoding, []byte(tt.raw))
+		if err != nil || string(encoded) != tt.expected {
+			t.Errorf("rewriteAppQuery(%s, %q) = %q (%v), want %q", tt.encoding, tt.raw, encoded, err, tt.expected)
+		}
+	}
+
+	// A value rule that would rewrite a token's placeholder fails instead of losing the token
+	if err := m.LoadMappings([]byte(`[{"source": "user", "target": "user", "case": "upper"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := rewriteAppQuery(m, appQueryConf, []byte("user=$u$ src_ip=1")); err == nil || !strings.Contains(err.Error(), "$u$") {
+		t.Errorf("Expected an error for the changed placeholder of $u$, got %v", err)
+	}
+}
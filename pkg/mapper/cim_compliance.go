@@ -0,0 +1,190 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Checks of CIM compliance issues
+const (
+	CIMUnknownDatamodel = "unknown-datamodel" // The query reads a datamodel that is not part of the CIM
+	CIMUnknownDataset   = "unknown-dataset"   // The query reads a dataset its CIM datamodel does not define
+	CIMUnknownField     = "unknown-field"     // A datamodel field the dataset does not define
+	CIMMisspelledField  = "misspelled-field"  // A datamodel field within two edits of a field the dataset defines
+	CIMNonCIMField      = "non-cim-field"     // A raw search field with a CIM-normalized name
+)
+
+// CIMIssue is a field, dataset or datamodel of a query that does not follow the CIM
+type CIMIssue struct {
+	Check      string `json:"check"`                // One of the CIM check constants
+	Name       string `json:"name"`                 // Field, dataset or datamodel as written in the query
+	Dataset    string `json:"dataset,omitempty"`    // "Datamodel.Dataset" a field was checked against
+	Suggestion string `json:"suggestion,omitempty"` // Suggested CIM name
+	Message    string `json:"message"`
+}
+
+// CIMComplianceReport lists the CIM issues of a query
+type CIMComplianceReport struct {
+	Query      string     `json:"query"`
+	CIMVersion string     `json:"cim_version"` // Version of the bundled CIM field list
+	Datasets   []string   `json:"datasets"`    // CIM datasets the query reads, as "Datamodel.Dataset"
+	Issues     []CIMIssue `json:"issues"`
+}
+
+// CheckCIMCompliance checks a query against the bundled CIM field list. Fields that tstats
+// and datamodel searches read from a CIM dataset must be defined by it, and raw searches
+// are flagged for field names that have a CIM-normalized equivalent.
+func CheckCIMCompliance(query string) (*CIMComplianceReport, error) {
+	info, err := New().DiscoverQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	checker := &cimChecker{
+		report:   &CIMComplianceReport{Query: query, CIMVersion: CIMVersion, Issues: []CIMIssue{}},
+		models:   make(map[string]bool),
+		datasets: make(map[string]bool),
+		seen:     make(map[string]bool),
+	}
+	for _, reference := range uniqueStrings(append(append([]string{}, info.DataModels...), info.Datasets...)) {
+		checker.checkReference(reference)
+	}
+	for _, field := range info.InputFields {
+		if strings.Contains(field, ".") {
+			checker.checkDatamodelField(field)
+		} else if len(info.DataModels) == 0 {
+			checker.checkRawField(field)
+		}
+	}
+
+	checker.report.Datasets = sortedSet(checker.datasets)
+	return checker.report, nil
+}
+
+type cimChecker struct {
+	report   *CIMComplianceReport
+	models   map[string]bool // CIM datamodels the query reads
+	datasets map[string]bool
+	seen     map[string]bool
+}
+
+func (c *cimChecker) add(check, name, dataset, suggestion, format string, args ...interface{}) {
+	if c.seen[check+"\x00"+name] {
+		return
+	}
+	c.seen[check+"\x00"+name] = true
+
+	message := fmt.Sprintf(format, args...)
+	if suggestion != "" {
+		message += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+	}
+	c.report.Issues = append(c.report.Issues, CIMIssue{
+		Check:      check,
+		Name:       name,
+		Dataset:    dataset,
+		Suggestion: suggestion,
+		Message:    message,
+	})
+}
+
+// checkReference checks a "Datamodel" or "Datamodel.Dataset" the query reads
+func (c *cimChecker) checkReference(reference string) {
+	modelName, datasetName, _ := strings.Cut(reference, ".")
+	model, ok := cimDatamodel(modelName)
+	if !ok {
+		c.add(CIMUnknownDatamodel, modelName, "", closestName(modelName, cimDatamodelNames()),
+			"'%s' is not a CIM %s datamodel", modelName, CIMVersion)
+		return
+	}
+	c.models[model] = true
+
+	if datasetName == "" {
+		return
+	}
+	// Child datasets are referenced by their lineage, e.g. Authentication.Failed_Authentication
+	datasetName = datasetName[strings.LastIndex(datasetName, ".")+1:]
+	if dataset, ok := LookupCIMDataset(model, datasetName); ok {
+		c.datasets[dataset.FullName()] = true
+		return
+	}
+	c.add(CIMUnknownDataset, reference, "", closestName(datasetName, cimDatasetNames(model)),
+		"the CIM %s datamodel has no '%s' dataset", model, datasetName)
+}
+
+// checkDatamodelField checks a Dataset.field or Datamodel.Dataset.field reference against
+// the datasets of the CIM datamodels the query reads
+func (c *cimChecker) checkDatamodelField(field string) {
+	if len(c.models) == 0 {
+		return
+	}
+	parts := strings.Split(field, ".")
+	var model, datasetName, name string
+	switch len(parts) {
+	case 2:
+		datasetName, name = parts[0], parts[1]
+		for _, candidate := range sortedSet(c.models) {
+			if _, ok := LookupCIMDataset(candidate, datasetName); ok {
+				model = candidate
+				break
+			}
+		}
+	case 3:
+		datasetName, name = parts[1], parts[2]
+		model, _ = cimDatamodel(parts[0])
+		if !c.models[model] {
+			return
+		}
+	default:
+		return
+	}
+
+	if model == "" {
+		var names []string
+		for _, candidate := range sortedSet(c.models) {
+			names = append(names, cimDatasetNames(candidate)...)
+		}
+		c.add(CIMUnknownDataset, datasetName, "", closestName(datasetName, names),
+			"'%s' in %s is not a dataset of %s", datasetName, field, strings.Join(sortedSet(c.models), ", "))
+		return
+	}
+
+	dataset, _ := LookupCIMDataset(model, datasetName)
+	c.datasets[dataset.FullName()] = true
+	for _, defined := range dataset.Fields {
+		if defined == name {
+			return
+		}
+	}
+	if suggestion := closestName(name, dataset.Fields); suggestion != "" {
+		c.add(CIMMisspelledField, field, dataset.FullName(), parts[len(parts)-2]+"."+suggestion,
+			"%s is not defined by the CIM %s dataset", field, dataset.FullName())
+		return
+	}
+	c.add(CIMUnknownField, field, dataset.FullName(), "",
+		"%s is not defined by the CIM %s dataset", field, dataset.FullName())
+}
+
+// checkRawField flags raw search fields that have a CIM-normalized name
+func (c *cimChecker) checkRawField(field string) {
+	if name, ok := CIMFieldAlias(field); ok && name != field {
+		c.add(CIMNonCIMField, field, "", name, "'%s' is not a CIM field name", field)
+	}
+}
+
+func cimDatamodelNames() []string {
+	var names []string
+	for _, dataset := range cimDatasets {
+		names = append(names, dataset.Datamodel)
+	}
+	return uniqueStrings(names)
+}
+
+func cimDatasetNames(model string) []string {
+	var names []string
+	for _, dataset := range cimDatasets {
+		if dataset.Datamodel == model {
+			names = append(names, dataset.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,129 @@
+package mapper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckCIMCompliance(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		datasets    []string
+		issues      []string // check:name
+		suggestions []string
+	}{
+		{
+			name:     "Compliant tstats search",
+			query:    "| tstats count from datamodel=Endpoint.Processes where Processes.process_name=cmd.exe by Processes.dest Processes.parent_process",
+			datasets: []string{"Endpoint.Processes"},
+		},
+		{
+			name:        "Misspelled and unknown datamodel fields",
+			query:       "| tstats count from datamodel=Endpoint.Processes where Processes.proces_name=cmd.exe by Processes.dest Processes.widget",
+			datasets:    []string{"Endpoint.Processes"},
+			issues:      []string{"misspelled-field:Processes.proces_name", "unknown-field:Processes.widget"},
+			suggestions: []string{"Processes.process_name", ""},
+		},
+		{
+			// 'foo' is two edits from 'tos', too many for a three-letter name
+			name:        "Short unknown datamodel field",
+			query:       "| tstats count from datamodel=Network_Traffic.All_Traffic by All_Traffic.foo All_Traffic.dests",
+			datasets:    []string{"Network_Traffic.All_Traffic"},
+			issues:      []string{"unknown-field:All_Traffic.foo", "misspelled-field:All_Traffic.dests"},
+			suggestions: []string{"", "All_Traffic.dest"},
+		},
+		{
+			name:     "Child datasets inherit fields",
+			query:    "| datamodel Authentication Failed_Authentication search | stats count by Authentication.user",
+			datasets: []string{"Authentication.Authentication", "Authentication.Failed_Authentication"},
+		},
+		{
+			name:        "Unknown dataset",
+			query:       "| datamodel Endpoint Proceses search | stats count by Processes.dest",
+			datasets:    []string{"Endpoint.Processes"},
+			issues:      []string{"unknown-dataset:Endpoint.Proceses"},
+			suggestions: []string{"Processes"},
+		},
+		{
+			name:        "Unknown datamodel",
+			query:       "| tstats count from datamodel=Endpoints by Endpoints.Processes.dest",
+			issues:      []string{"unknown-datamodel:Endpoints"},
+			suggestions: []string{"Endpoint"},
+		},
+		{
+			name:        "Raw search with vendor field names",
+			query:       "index=web sourcetype=access_combined clientip=10.0.0.1 | stats count by useragent status",
+			issues:      []string{"non-cim-field:clientip", "non-cim-field:useragent"},
+			suggestions: []string{"src", "http_user_agent"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := CheckCIMCompliance(tt.query)
+			if err != nil {
+				t.Fatalf("CheckCIMCompliance failed: %v", err)
+			}
+			if report.CIMVersion != CIMVersion {
+				t.Errorf("Expected CIM version %s, got %s", CIMVersion, report.CIMVersion)
+			}
+			if len(report.Datasets) != len(tt.datasets) || (len(tt.datasets) > 0 && !reflect.DeepEqual(report.Datasets, tt.datasets)) {
+				t.Errorf("Expected datasets %v, got %v", tt.datasets, report.Datasets)
+			}
+
+			var issues, suggestions []string
+			for _, issue := range report.Issues {
+				issues = append(issues, issue.Check+":"+issue.Name)
+				suggestions = append(suggestions, issue.Suggestion)
+			}
+			if !reflect.DeepEqual(issues, tt.issues) {
+				t.Errorf("Expected issues %v, got %v", tt.issues, issues)
+			}
+			if !reflect.DeepEqual(suggestions, tt.suggestions) {
+				t.Errorf("Expected suggestions %v, got %v", tt.suggestions, suggestions)
+			}
+		})
+	}
+
+	if _, err := CheckCIMCompliance("index=web | stats count("); err == nil {
+		t.Error("Expected an error for an invalid query")
+	}
+}
+
+func TestCIMFieldList(t *testing.T) {
+	defined := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, dataset := range CIMDatasets() {
+		if seen[dataset.FullName()] {
+			t.Errorf("Duplicate CIM dataset %s", dataset.FullName())
+		}
+		seen[dataset.FullName()] = true
+
+		resolved, ok := LookupCIMDataset(dataset.Datamodel, dataset.Name)
+		if !ok {
+			t.Fatalf("LookupCIMDataset(%s) failed", dataset.FullName())
+		}
+		if dataset.Parent != "" {
+			if _, ok := LookupCIMDataset(dataset.Datamodel, dataset.Parent); !ok {
+				t.Errorf("%s has unknown parent %s", dataset.FullName(), dataset.Parent)
+			}
+		}
+		for i, field := range resolved.Fields {
+			if i > 0 && resolved.Fields[i-1] == field {
+				t.Errorf("%s defines %s twice", dataset.FullName(), field)
+			}
+			defined[field] = true
+		}
+	}
+
+	for alias, name := range cimFieldAliases {
+		if alias != strings.ToLower(alias) {
+			t.Errorf("Alias %s must be lowercase", alias)
+		}
+		if !defined[name] {
+			t.Errorf("Alias %s maps to %s, which no CIM dataset defines", alias, name)
+		}
+	}
+}
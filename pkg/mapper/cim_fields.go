@@ -0,0 +1,281 @@
+package mapper
+
+import (
+	"sort"
+	"strings"
+)
+
+// CIMVersion is the version of the Splunk Common Information Model the bundled field list
+// follows
+const CIMVersion = "5.3.0"
+
+// CIMDataset is a dataset of a CIM datamodel with the fields it defines
+type CIMDataset struct {
+	Datamodel string `json:"datamodel"`
+	Name      string `json:"name"`
+	// Parent is the dataset this one is constrained from; it inherits the parent's fields
+	Parent string   `json:"parent,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// FullName returns the dataset name in "Datamodel.Dataset" form
+func (d CIMDataset) FullName() string {
+	return d.Datamodel + "." + d.Name
+}
+
+// cimInheritedFields are defined by every root dataset
+var cimInheritedFields = []string{"_time", "host", "source", "sourcetype"}
+
+// cimDatasets is the bundled CIM field list, by datamodel and dataset
+var cimDatasets = []CIMDataset{
+	{Datamodel: "Alerts", Name: "Alerts", Fields: []string{
+		"app", "body", "dest", "dest_bunit", "dest_category", "dest_priority", "description", "id",
+		"mitre_technique_id", "severity", "severity_id", "signature", "signature_id", "src",
+		"src_bunit", "src_category", "src_priority", "subject", "tag", "type", "user", "user_bunit",
+		"user_category", "user_priority", "vendor_account", "vendor_region",
+	}},
+	{Datamodel: "Authentication", Name: "Authentication", Fields: []string{
+		"action", "app", "authentication_method", "authentication_service", "dest", "dest_bunit",
+		"dest_category", "dest_nt_domain", "dest_priority", "duration", "reason", "response_time",
+		"signature", "signature_id", "src", "src_bunit", "src_category", "src_nt_domain",
+		"src_priority", "src_user", "src_user_bunit", "src_user_category", "src_user_id",
+		"src_user_priority", "src_user_role", "src_user_type", "tag", "user", "user_agent",
+		"user_bunit", "user_category", "user_id", "user_priority", "user_role", "user_type",
+		"vendor_account",
+	}},
+	{Datamodel: "Authentication", Name: "Default_Authentication", Parent: "Authentication"},
+	{Datamodel: "Authentication", Name: "Failed_Authentication", Parent: "Authentication"},
+	{Datamodel: "Authentication", Name: "Insecure_Authentication", Parent: "Authentication"},
+	{Datamodel: "Authentication", Name: "Privileged_Authentication", Parent: "Authentication"},
+	{Datamodel: "Authentication", Name: "Successful_Authentication", Parent: "Authentication"},
+	{Datamodel: "Change", Name: "All_Changes", Fields: []string{
+		"action", "change_type", "command", "dest", "dest_bunit", "dest_category", "dest_ip_range",
+		"dest_priority", "dvc", "image_id", "instance_type", "object", "object_attrs",
+		"object_category", "object_id", "object_path", "result", "result_id", "src", "src_bunit",
+		"src_category", "src_priority", "status", "tag", "user", "user_agent", "user_name",
+		"user_type", "vendor_account", "vendor_product", "vendor_region",
+	}},
+	{Datamodel: "Change", Name: "Account_Management", Parent: "All_Changes", Fields: []string{
+		"dest_nt_domain", "src_nt_domain", "src_user", "src_user_bunit", "src_user_category",
+		"src_user_priority",
+	}},
+	{Datamodel: "Change", Name: "Auditing_Changes", Parent: "All_Changes"},
+	{Datamodel: "Change", Name: "Endpoint_Changes", Parent: "All_Changes"},
+	{Datamodel: "Change", Name: "Network_Changes", Parent: "All_Changes", Fields: []string{
+		"device", "direction",
+	}},
+	{Datamodel: "Email", Name: "All_Email", Fields: []string{
+		"action", "delay", "dest", "dest_bunit", "dest_category", "dest_priority", "duration",
+		"file_hash", "file_name", "file_size", "internal_message_id", "message_id", "message_info",
+		"orig_dest", "orig_recipient", "orig_src", "process", "process_id", "protocol", "recipient",
+		"recipient_count", "recipient_domain", "recipient_status", "response_time", "retries",
+		"return_addr", "size", "src", "src_bunit", "src_category", "src_priority", "src_user",
+		"src_user_bunit", "src_user_category", "src_user_domain", "src_user_priority",
+		"status_code", "subject", "tag", "url", "user", "user_bunit", "user_category",
+		"user_priority", "vendor_product", "xdelay", "xref",
+	}},
+	{Datamodel: "Email", Name: "Delivery", Parent: "All_Email"},
+	{Datamodel: "Email", Name: "Content", Parent: "All_Email"},
+	{Datamodel: "Email", Name: "Filtering", Parent: "All_Email", Fields: []string{
+		"filter_action", "filter_score", "signature", "signature_extra", "signature_id",
+	}},
+	{Datamodel: "Endpoint", Name: "Filesystem", Fields: []string{
+		"action", "dest", "dest_bunit", "dest_category", "dest_priority", "file_access_time",
+		"file_acl", "file_create_time", "file_hash", "file_modify_time", "file_name", "file_path",
+		"file_size", "process_guid", "process_id", "tag", "user", "user_bunit", "user_category",
+		"user_priority", "vendor_product",
+	}},
+	{Datamodel: "Endpoint", Name: "Ports", Fields: []string{
+		"creation_time", "dest", "dest_bunit", "dest_category", "dest_port", "dest_priority",
+		"process_guid", "process_id", "src", "src_bunit", "src_category", "src_port",
+		"src_priority", "state", "tag", "transport", "transport_dest_port", "user", "user_bunit",
+		"user_category", "user_priority", "vendor_product",
+	}},
+	{Datamodel: "Endpoint", Name: "Processes", Fields: []string{
+		"action", "cpu_load_percent", "dest", "dest_bunit", "dest_category", "dest_priority",
+		"mem_used", "original_file_name", "os", "parent_process", "parent_process_exec",
+		"parent_process_guid", "parent_process_id", "parent_process_name", "parent_process_path",
+		"process", "process_current_directory", "process_exec", "process_guid", "process_hash",
+		"process_id", "process_integrity_level", "process_name", "process_path", "tag", "user",
+		"user_bunit", "user_category", "user_id", "user_priority", "vendor_product",
+	}},
+	{Datamodel: "Endpoint", Name: "Registry", Fields: []string{
+		"action", "dest", "dest_bunit", "dest_category", "dest_priority", "process_guid",
+		"process_id", "registry_hive", "registry_key_name", "registry_path", "registry_value_data",
+		"registry_value_name", "registry_value_text", "registry_value_type", "status", "tag",
+		"user", "user_bunit", "user_category", "user_priority", "vendor_product",
+	}},
+	{Datamodel: "Endpoint", Name: "Services", Fields: []string{
+		"description", "dest", "dest_bunit", "dest_category", "dest_priority", "process_guid",
+		"process_id", "service", "service_dll", "service_dll_hash", "service_dll_path",
+		"service_dll_signature_exists", "service_dll_signature_verified", "service_exec",
+		"service_hash", "service_id", "service_name", "service_path", "service_signature_exists",
+		"service_signature_verified", "start_mode", "status", "tag", "user", "user_bunit",
+		"user_category", "user_priority", "vendor_product",
+	}},
+	{Datamodel: "Intrusion_Detection", Name: "IDS_Attacks", Fields: []string{
+		"action", "category", "dest", "dest_bunit", "dest_category", "dest_ip", "dest_port",
+		"dest_priority", "dvc", "dvc_bunit", "dvc_category", "dvc_priority", "file_hash",
+		"file_name", "file_path", "ids_type", "mitre_technique_id", "severity", "severity_id",
+		"signature", "signature_id", "src", "src_bunit", "src_category", "src_ip", "src_port",
+		"src_priority", "tag", "transport", "user", "user_bunit", "user_category", "user_priority",
+		"vendor_product",
+	}},
+	{Datamodel: "Malware", Name: "Malware_Attacks", Fields: []string{
+		"action", "category", "date", "dest", "dest_bunit", "dest_category", "dest_nt_domain",
+		"dest_priority", "dest_requires_av", "file_hash", "file_name", "file_path", "sender",
+		"signature", "signature_id", "src", "src_bunit", "src_category", "src_priority",
+		"src_user", "tag", "url", "user", "user_bunit", "user_category", "user_priority",
+		"vendor_product",
+	}},
+	{Datamodel: "Network_Resolution", Name: "DNS", Fields: []string{
+		"additional_answer_count", "answer", "answer_count", "authority_answer_count", "dest",
+		"dest_bunit", "dest_category", "dest_port", "dest_priority", "duration", "message_type",
+		"name", "query", "query_count", "query_type", "record_type", "reply_code", "reply_code_id",
+		"response_time", "src", "src_bunit", "src_category", "src_port", "src_priority", "tag",
+		"transaction_id", "transport", "ttl", "vendor_product",
+	}},
+	{Datamodel: "Network_Traffic", Name: "All_Traffic", Fields: []string{
+		"action", "app", "bytes", "bytes_in", "bytes_out", "channel", "dest", "dest_bunit",
+		"dest_category", "dest_interface", "dest_ip", "dest_mac", "dest_port", "dest_priority",
+		"dest_translated_ip", "dest_translated_port", "dest_zone", "direction", "duration", "dvc",
+		"dvc_bunit", "dvc_category", "dvc_ip", "dvc_mac", "dvc_priority", "dvc_zone", "flow_id",
+		"icmp_code", "icmp_type", "packets", "packets_in", "packets_out", "process_id", "protocol",
+		"protocol_version", "response_time", "rule", "session_id", "src", "src_bunit",
+		"src_category", "src_interface", "src_ip", "src_mac", "src_port", "src_priority",
+		"src_translated_ip", "src_translated_port", "src_zone", "ssid", "tag", "tcp_flag", "tos",
+		"transport", "ttl", "user", "user_bunit", "user_category", "user_priority",
+		"vendor_account", "vendor_product", "vlan", "wifi",
+	}},
+	{Datamodel: "Vulnerabilities", Name: "Vulnerabilities", Fields: []string{
+		"bugtraq", "category", "cert", "cve", "cvss", "dest", "dest_bunit", "dest_category",
+		"dest_priority", "dvc", "dvc_bunit", "dvc_category", "dvc_priority", "msft", "mskb",
+		"severity", "severity_id", "signature", "signature_id", "tag", "url", "user", "user_bunit",
+		"user_category", "user_priority", "vendor_product", "xref",
+	}},
+	{Datamodel: "Web", Name: "Web", Fields: []string{
+		"action", "app", "bytes", "bytes_in", "bytes_out", "cached", "category", "cookie", "dest",
+		"dest_bunit", "dest_category", "dest_port", "dest_priority", "duration", "error_code",
+		"http_content_type", "http_method", "http_referrer", "http_referrer_domain",
+		"http_user_agent", "http_user_agent_length", "operation", "response_time", "site", "src",
+		"src_bunit", "src_category", "src_priority", "status", "storage_name", "tag", "uri_path",
+		"uri_query", "url", "url_domain", "url_length", "user", "user_bunit", "user_category",
+		"user_priority", "vendor_product",
+	}},
+	{Datamodel: "Web", Name: "Proxy", Parent: "Web"},
+	{Datamodel: "Web", Name: "Storage", Parent: "Web"},
+}
+
+// cimFieldAliases maps common vendor and raw-log field names, in lowercase, to the
+// CIM-normalized name add-ons extract them as
+var cimFieldAliases = map[string]string{
+	"c_ip":                "src",
+	"client_ip":           "src",
+	"clientip":            "src",
+	"commandline":         "process",
+	"cs_host":             "dest",
+	"cs_method":           "http_method",
+	"cs_referer":          "http_referrer",
+	"cs_uri_query":        "uri_query",
+	"cs_uri_stem":         "uri_path",
+	"cs_user_agent":       "http_user_agent",
+	"cs_username":         "user",
+	"dest_host":           "dest",
+	"destination":         "dest",
+	"destinationhostname": "dest",
+	"destinationip":       "dest_ip",
+	"destinationport":     "dest_port",
+	"dpt":                 "dest_port",
+	"dst":                 "dest",
+	"dst_ip":              "dest_ip",
+	"dst_port":            "dest_port",
+	"dstport":             "dest_port",
+	"hashes":              "process_hash",
+	"image":               "process_path",
+	"ipaddress":           "src",
+	"logontype":           "authentication_method",
+	"method":              "http_method",
+	"parentcommandline":   "parent_process",
+	"parentimage":         "parent_process_path",
+	"parentprocessid":     "parent_process_id",
+	"processid":           "process_id",
+	"queryname":           "query",
+	"referer":             "http_referrer",
+	"referrer":            "http_referrer",
+	"sc_status":           "status",
+	"servicefilename":     "service_path",
+	"servicename":         "service_name",
+	"sourceip":            "src_ip",
+	"sourceport":          "src_port",
+	"spt":                 "src_port",
+	"src_host":            "src",
+	"srcport":             "src_port",
+	"subjectdomainname":   "src_nt_domain",
+	"subjectusername":     "src_user",
+	"targetdomainname":    "dest_nt_domain",
+	"targetfilename":      "file_path",
+	"targetobject":        "registry_path",
+	"targetusername":      "user",
+	"uri":                 "uri_path",
+	"user_agent_string":   "http_user_agent",
+	"useragent":           "http_user_agent",
+	"username":            "user",
+	"workstationname":     "src",
+}
+
+// CIMDatasets returns the bundled CIM datasets, sorted by datamodel and dataset
+func CIMDatasets() []CIMDataset {
+	datasets := append([]CIMDataset{}, cimDatasets...)
+	sort.Slice(datasets, func(i, j int) bool {
+		return datasets[i].FullName() < datasets[j].FullName()
+	})
+	return datasets
+}
+
+// LookupCIMDataset returns a bundled CIM dataset with the fields it defines or inherits
+func LookupCIMDataset(datamodel, name string) (CIMDataset, bool) {
+	for _, dataset := range cimDatasets {
+		if !strings.EqualFold(dataset.Datamodel, datamodel) || !strings.EqualFold(dataset.Name, name) {
+			continue
+		}
+
+		fields := append([]string{}, dataset.Fields...)
+		for parent := dataset.Parent; parent != ""; {
+			ancestor, ok := cimDataset(dataset.Datamodel, parent)
+			if !ok {
+				break
+			}
+			fields = append(fields, ancestor.Fields...)
+			parent = ancestor.Parent
+		}
+		dataset.Fields = append(fields, cimInheritedFields...)
+		sort.Strings(dataset.Fields)
+		return dataset, true
+	}
+	return CIMDataset{}, false
+}
+
+// CIMFieldAlias returns the CIM-normalized name of a common vendor field name
+func CIMFieldAlias(field string) (string, bool) {
+	name, ok := cimFieldAliases[strings.ToLower(field)]
+	return name, ok
+}
+
+func cimDataset(datamodel, name string) (CIMDataset, bool) {
+	for _, dataset := range cimDatasets {
+		if dataset.Datamodel == datamodel && dataset.Name == name {
+			return dataset, true
+		}
+	}
+	return CIMDataset{}, false
+}
+
+// cimDatamodel returns the canonical name of a bundled CIM datamodel
+func cimDatamodel(name string) (string, bool) {
+	for _, dataset := range cimDatasets {
+		if strings.EqualFold(dataset.Datamodel, name) {
+			return dataset.Datamodel, true
+		}
+	}
+	return "", false
+}
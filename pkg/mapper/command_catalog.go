@@ -0,0 +1,363 @@
+package mapper
+
+import (
+	"sort"
+	"strings"
+)
+
+// Command types, following the Splunk search command taxonomy
+const (
+	// CommandGenerating commands produce events or results without input, usually at the
+	// start of a query
+	CommandGenerating = "generating"
+	// CommandDistributableStreaming commands process events one at a time and can run on
+	// the indexers
+	CommandDistributableStreaming = "distributable-streaming"
+	// CommandStreaming commands process events in order on the search head
+	CommandStreaming = "streaming"
+	// CommandTransforming commands turn events into a results table
+	CommandTransforming = "transforming"
+	// CommandDatasetProcessing commands need the complete result set before they can run
+	CommandDatasetProcessing = "dataset-processing"
+	// CommandOrchestrating commands control how the search runs without changing its results
+	CommandOrchestrating = "orchestrating"
+)
+
+// Command argument kinds
+const (
+	// CommandArgumentsSearch commands take search terms
+	CommandArgumentsSearch = "search"
+	// CommandArgumentsAssignments commands assign fields with "field=expression"
+	CommandArgumentsAssignments = "assignments"
+	// CommandArgumentsExpression commands evaluate their arguments as an eval expression,
+	// where bare words are fields
+	CommandArgumentsExpression = "expression"
+	// CommandArgumentsFields commands take field names as bare arguments, including
+	// function arguments
+	CommandArgumentsFields = "fields"
+)
+
+// CommandInfo describes the semantics of a SPL command
+type CommandInfo struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	ResetsFields bool     `json:"resets_fields"`           // Only the fields the command outputs are available after it
+	Arguments    string   `json:"arguments,omitempty"`     // How bare arguments are read; empty when not described
+	Options      []string `json:"options,omitempty"`       // Accepted option=value names
+	FieldOptions []string `json:"field_options,omitempty"` // Options whose value is the name of an input field
+	Keywords     []string `json:"keywords,omitempty"`      // Bare keywords accepted between field names
+	Branch       bool     `json:"branch,omitempty"`        // Runs its subsearches as separate search branches
+}
+
+// HasOption reports whether the command accepts an option=value argument
+func (c CommandInfo) HasOption(option string) bool {
+	return containsFold(c.Options, option)
+}
+
+// HasFieldOption reports whether the value of an option is the name of an input field
+func (c CommandInfo) HasFieldOption(option string) bool {
+	return containsFold(c.FieldOptions, option)
+}
+
+// HasKeyword reports whether the command accepts a bare keyword between field names
+func (c CommandInfo) HasKeyword(word string) bool {
+	return containsFold(c.Keywords, word)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+var binOptions = []string{"span", "bins", "minspan", "start", "end", "aligntime"}
+
+// commandCatalog describes every command the lexer knows. Commands whose type depends on
+// their arguments are listed with their default behavior; search is listed as it behaves
+// after a pipe.
+var commandCatalog = map[string]CommandInfo{
+	// Generating commands
+	"datamodel":         {Type: CommandGenerating},
+	"datamodelsimple":   {Type: CommandGenerating, ResetsFields: true},
+	"dbinspect":         {Type: CommandGenerating, ResetsFields: true},
+	"dbxquery":          {Type: CommandGenerating, ResetsFields: true},
+	"eventcount":        {Type: CommandGenerating, ResetsFields: true},
+	"from":              {Type: CommandGenerating},
+	"gentimes":          {Type: CommandGenerating, ResetsFields: true},
+	"history":           {Type: CommandGenerating, ResetsFields: true},
+	"inputcsv":          {Type: CommandGenerating, ResetsFields: true},
+	"inputintelligence": {Type: CommandGenerating, ResetsFields: true},
+	"inputlookup":       {Type: CommandGenerating, ResetsFields: true, Options: []string{"append", "max", "start"}},
+	"loadjob":           {Type: CommandGenerating},
+	"makeresults":       {Type: CommandGenerating, ResetsFields: true},
+	"metadata":          {Type: CommandGenerating, ResetsFields: true},
+	"metasearch":        {Type: CommandGenerating, ResetsFields: true},
+	"mpreview":          {Type: CommandGenerating},
+	"msearch":           {Type: CommandGenerating},
+	"mstats":            {Type: CommandGenerating, ResetsFields: true},
+	"multisearch":       {Type: CommandGenerating, Branch: true},
+	"pivot":             {Type: CommandGenerating, ResetsFields: true},
+	"rest":              {Type: CommandGenerating, ResetsFields: true},
+	"savedsearch":       {Type: CommandGenerating},
+	"searchtxn":         {Type: CommandGenerating},
+	"set":               {Type: CommandGenerating},
+	"tstats": {Type: CommandGenerating, ResetsFields: true, Arguments: CommandArgumentsFields,
+		Options: []string{"allow_old_summaries", "append", "chunk_size", "datamodel", "fillnull_value", "include_reduced_buckets", "local", "nodename", "prestats", "summariesonly"}},
+	"typeahead": {Type: CommandGenerating, ResetsFields: true},
+	"union":     {Type: CommandGenerating, Branch: true},
+	"walklex":   {Type: CommandGenerating, ResetsFields: true},
+
+	// Distributable streaming commands
+	"abstract":    {Type: CommandDistributableStreaming},
+	"addinfo":     {Type: CommandDistributableStreaming},
+	"addtotals":   {Type: CommandDistributableStreaming, Arguments: CommandArgumentsFields},
+	"bin":         {Type: CommandDistributableStreaming, Arguments: CommandArgumentsFields, Options: binOptions},
+	"bucket":      {Type: CommandDistributableStreaming, Arguments: CommandArgumentsFields, Options: binOptions},
+	"convert":     {Type: CommandDistributableStreaming},
+	"eval":        {Type: CommandDistributableStreaming, Arguments: CommandArgumentsAssignments},
+	"extract":     {Type: CommandDistributableStreaming},
+	"fieldformat": {Type: CommandDistributableStreaming, Arguments: CommandArgumentsAssignments},
+	"fields":      {Type: CommandDistributableStreaming, Arguments: CommandArgumentsFields},
+	"fillnull":    {Type: CommandDistributableStreaming, Arguments: CommandArgumentsFields, Options: []string{"value"}},
+	"foreach":     {Type: CommandDistributableStreaming},
+	"fromjson":    {Type: CommandDistributableStreaming},
+	"geom":        {Type: CommandDistributableStreaming},
+	"geomfilter":  {Type: CommandDistributableStreaming},
+	"highlight":   {Type: CommandDistributableStreaming},
+	"iconify":     {Type: CommandDistributableStreaming},
+	"iplocation":  {Type: CommandDistributableStreaming, Arguments: CommandArgumentsFields, Options: []string{"allfields", "lang", "prefix"}},
+	"kvform":      {Type: CommandDistributableStreaming},
+	"lookup":      {Type: CommandDistributableStreaming, Options: []string{"event_time_field", "local", "update"}},
+	"makemv":      {Type: CommandDistributableStreaming, Arguments: CommandArgumentsFields, Options: []string{"allowempty", "delim", "setsv", "tokenizer"}},
+	"multikv":     {Type: CommandDistributableStreaming},
+	"mvexpand":    {Type: CommandDistributableStreaming, Arguments: CommandArgumentsFields, Options: []string{"limit"}},
+	"nomv":        {Type: CommandDistributableStreaming, Arguments: CommandArgumentsFields},
+	"rangemap":    {Type: CommandDistributableStreaming},
+	"regex":       {Type: CommandDistributableStreaming},
+	"reltime":     {Type: CommandDistributableStreaming},
+	"rename":      {Type: CommandDistributableStreaming},
+	"replace":     {Type: CommandDistributableStreaming},
+	"rex": {Type: CommandDistributableStreaming, Options: []string{"field", "max_match", "mode", "offset_field"},
+		FieldOptions: []string{"field"}},
+	"scrub":     {Type: CommandDistributableStreaming},
+	"search":    {Type: CommandDistributableStreaming, Arguments: CommandArgumentsSearch},
+	"setfields": {Type: CommandDistributableStreaming},
+	"spath": {Type: CommandDistributableStreaming, Options: []string{"input", "output", "path"},
+		FieldOptions: []string{"input"}},
+	"strcat":      {Type: CommandDistributableStreaming},
+	"tags":        {Type: CommandDistributableStreaming},
+	"tojson":      {Type: CommandDistributableStreaming},
+	"typer":       {Type: CommandDistributableStreaming},
+	"untable":     {Type: CommandDistributableStreaming, ResetsFields: true},
+	"where":       {Type: CommandDistributableStreaming, Arguments: CommandArgumentsExpression},
+	"xmlkv":       {Type: CommandDistributableStreaming},
+	"xmlunescape": {Type: CommandDistributableStreaming},
+	"xpath":       {Type: CommandDistributableStreaming},
+
+	// Centralized streaming commands
+	"accum":       {Type: CommandStreaming},
+	"anomalies":   {Type: CommandStreaming},
+	"append":      {Type: CommandStreaming, Branch: true},
+	"appendcols":  {Type: CommandStreaming, Branch: true},
+	"appendpipe":  {Type: CommandStreaming},
+	"autoregress": {Type: CommandStreaming},
+	"cluster":     {Type: CommandStreaming},
+	"concurrency": {Type: CommandStreaming},
+	"dedup":       {Type: CommandStreaming, Arguments: CommandArgumentsFields, Options: []string{"consecutive", "keepempty", "keepevents"}},
+	"delta":       {Type: CommandStreaming},
+	"entitymerge": {Type: CommandStreaming},
+	"erex":        {Type: CommandStreaming},
+	"filldown":    {Type: CommandStreaming, Arguments: CommandArgumentsFields},
+	"head":        {Type: CommandStreaming, Options: []string{"limit", "null", "keeplast"}},
+	"join": {Type: CommandStreaming, Branch: true, Arguments: CommandArgumentsFields,
+		Options: []string{"earlier", "max", "overwrite", "type", "usetime"}},
+	"rtorder": {Type: CommandStreaming},
+	"streamstats": {Type: CommandStreaming, Arguments: CommandArgumentsFields,
+		Options: []string{"allnum", "current", "global", "reset_after", "reset_before", "reset_on_change", "time_window", "window"}},
+	"trendline": {Type: CommandStreaming},
+	"uniq":      {Type: CommandStreaming},
+
+	// Transforming commands
+	"analyzefields": {Type: CommandTransforming, ResetsFields: true},
+	"arules":        {Type: CommandTransforming, ResetsFields: true},
+	"associate":     {Type: CommandTransforming, ResetsFields: true},
+	"chart": {Type: CommandTransforming, ResetsFields: true, Arguments: CommandArgumentsFields,
+		Options:  []string{"bins", "cont", "format", "limit", "nullstr", "otherstr", "sep", "span", "usenull", "useother", "agg"},
+		Keywords: []string{"over"}},
+	"cofilter":     {Type: CommandTransforming, ResetsFields: true},
+	"contingency":  {Type: CommandTransforming, ResetsFields: true},
+	"correlate":    {Type: CommandTransforming, ResetsFields: true},
+	"ctable":       {Type: CommandTransforming, ResetsFields: true},
+	"fieldsummary": {Type: CommandTransforming, ResetsFields: true},
+	"findtypes":    {Type: CommandTransforming, ResetsFields: true},
+	"gauge":        {Type: CommandTransforming, ResetsFields: true},
+	"geostats":     {Type: CommandTransforming, ResetsFields: true, Arguments: CommandArgumentsFields},
+	"mvcombine":    {Type: CommandTransforming, Arguments: CommandArgumentsFields},
+	"rare": {Type: CommandTransforming, ResetsFields: true, Arguments: CommandArgumentsFields,
+		Options: []string{"countfield", "limit", "percentfield", "showcount", "showperc"}},
+	"sichart":     {Type: CommandTransforming, ResetsFields: true},
+	"sirare":      {Type: CommandTransforming, ResetsFields: true},
+	"sistats":     {Type: CommandTransforming, ResetsFields: true, Arguments: CommandArgumentsFields},
+	"sitimechart": {Type: CommandTransforming, ResetsFields: true},
+	"sitop":       {Type: CommandTransforming, ResetsFields: true, Arguments: CommandArgumentsFields},
+	"stats": {Type: CommandTransforming, ResetsFields: true, Arguments: CommandArgumentsFields,
+		Options: []string{"allnum", "delim", "partitions"}},
+	"timechart": {Type: CommandTransforming, ResetsFields: true, Arguments: CommandArgumentsFields,
+		Options:  []string{"agg", "bins", "cont", "fixedrange", "format", "limit", "minspan", "nullstr", "otherstr", "partial", "sep", "span", "usenull", "useother"},
+		Keywords: []string{"over"}},
+	"top": {Type: CommandTransforming, ResetsFields: true, Arguments: CommandArgumentsFields,
+		Options: []string{"countfield", "limit", "otherstr", "percentfield", "showcount", "showperc", "useother"}},
+	"typelearner": {Type: CommandTransforming, ResetsFields: true},
+	"xyseries":    {Type: CommandTransforming, ResetsFields: true},
+
+	// Dataset processing commands
+	"addcoltotals":       {Type: CommandDatasetProcessing},
+	"anomalousvalue":     {Type: CommandDatasetProcessing},
+	"anomalydetection":   {Type: CommandDatasetProcessing},
+	"awssnsalert":        {Type: CommandDatasetProcessing},
+	"bucketdir":          {Type: CommandDatasetProcessing},
+	"collect":            {Type: CommandDatasetProcessing},
+	"delete":             {Type: CommandDatasetProcessing},
+	"diff":               {Type: CommandDatasetProcessing},
+	"eventstats":         {Type: CommandDatasetProcessing, Arguments: CommandArgumentsFields, Options: []string{"allnum"}},
+	"folderize":          {Type: CommandDatasetProcessing},
+	"format":             {Type: CommandDatasetProcessing, ResetsFields: true},
+	"kmeans":             {Type: CommandDatasetProcessing},
+	"localize":           {Type: CommandDatasetProcessing, ResetsFields: true},
+	"makecontinuous":     {Type: CommandDatasetProcessing},
+	"map":                {Type: CommandDatasetProcessing, ResetsFields: true},
+	"mcollect":           {Type: CommandDatasetProcessing},
+	"meventcollect":      {Type: CommandDatasetProcessing},
+	"outlier":            {Type: CommandDatasetProcessing},
+	"outputcsv":          {Type: CommandDatasetProcessing},
+	"outputlookup":       {Type: CommandDatasetProcessing},
+	"outputtext":         {Type: CommandDatasetProcessing},
+	"overlap":            {Type: CommandDatasetProcessing},
+	"predict":            {Type: CommandDatasetProcessing},
+	"return":             {Type: CommandDatasetProcessing, ResetsFields: true},
+	"reverse":            {Type: CommandDatasetProcessing},
+	"run":                {Type: CommandDatasetProcessing},
+	"script":             {Type: CommandDatasetProcessing},
+	"selfjoin":           {Type: CommandDatasetProcessing},
+	"sendalert":          {Type: CommandDatasetProcessing},
+	"sendemail":          {Type: CommandDatasetProcessing},
+	"snowevent":          {Type: CommandDatasetProcessing},
+	"snoweventstream":    {Type: CommandDatasetProcessing},
+	"snowincident":       {Type: CommandDatasetProcessing},
+	"snowincidentstream": {Type: CommandDatasetProcessing},
+	"sort": {Type: CommandDatasetProcessing, Arguments: CommandArgumentsFields, Options: []string{"limit"},
+		Keywords: []string{"auto", "ip", "num", "str"}},
+	"table":    {Type: CommandDatasetProcessing, ResetsFields: true, Arguments: CommandArgumentsFields},
+	"tail":     {Type: CommandDatasetProcessing},
+	"timewrap": {Type: CommandDatasetProcessing},
+	"transaction": {Type: CommandDatasetProcessing, Arguments: CommandArgumentsFields,
+		Options: []string{"connected", "delim", "endswith", "keepevicted", "keeporphans", "maxevents", "maxopenevents", "maxopentxn", "maxpause", "maxspan", "mvlist", "mvraw", "nullstr", "startswith", "unifyends"}},
+	"transpose": {Type: CommandDatasetProcessing, ResetsFields: true},
+	"tscollect": {Type: CommandDatasetProcessing},
+	"x11":       {Type: CommandDatasetProcessing},
+
+	// Orchestrating commands
+	"localop": {Type: CommandOrchestrating},
+	"require": {Type: CommandOrchestrating},
+}
+
+// LookupCommand returns the catalog entry for a command name, including custom commands of
+// the default registry
+func LookupCommand(name string) (CommandInfo, bool) {
+	return defaultRegistry.LookupCommand(name)
+}
+
+// CommandCatalog returns every cataloged command and custom command of the default registry,
+// sorted by name
+func CommandCatalog() []CommandInfo {
+	return defaultRegistry.CommandCatalog()
+}
+
+// LookupCommand returns the catalog entry for a command name, including the registry's custom
+// commands
+func (r *CommandRegistry) LookupCommand(name string) (CommandInfo, bool) {
+	name = strings.ToLower(name)
+	if info, ok := commandCatalog[name]; ok {
+		info.Name = name
+		return info, true
+	}
+	return r.lookupCustomCommand(name)
+}
+
+// CommandCatalog returns every cataloged command and custom command of the registry, sorted
+// by name
+func (r *CommandRegistry) CommandCatalog() []CommandInfo {
+	r = r.orDefault()
+	commands := make([]CommandInfo, 0, len(commandCatalog))
+	for name := range commandCatalog {
+		info, _ := r.LookupCommand(name)
+		commands = append(commands, info)
+	}
+	r.mu.RLock()
+	for _, info := range r.commands {
+		commands = append(commands, info)
+	}
+	r.mu.RUnlock()
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// isScopedCommand reports whether a command runs its arguments as a separate search branch
+func (r *CommandRegistry) isScopedCommand(command string) bool {
+	info, _ := r.LookupCommand(command)
+	return info.Branch
+}
+
+// isReportingCommand reports whether a command returns a results table instead of events:
+// transforming commands and generating commands such as tstats that do not return events
+func (r *CommandRegistry) isReportingCommand(command string) bool {
+	info, ok := r.LookupCommand(command)
+	return ok && info.ResetsFields && (info.Type == CommandTransforming || info.Type == CommandGenerating)
+}
+
+// isCentralizedCommand reports whether a command needs all events on the search head, so the
+// commands after it run there instead of on the indexers
+func (r *CommandRegistry) isCentralizedCommand(command string) bool {
+	info, _ := r.LookupCommand(command)
+	return info.Type == CommandStreaming || info.Type == CommandDatasetProcessing
+}
+
+// isDistributableCommand reports whether a command processes events one at a time and can
+// run on the indexers
+func (r *CommandRegistry) isDistributableCommand(command string) bool {
+	info, _ := r.LookupCommand(command)
+	return info.Type == CommandDistributableStreaming
+}
+
+func (r *CommandRegistry) isAssignmentCommand(command string) bool {
+	info, _ := r.LookupCommand(command)
+	return info.Arguments == CommandArgumentsAssignments
+}
+
+func (r *CommandRegistry) isExpressionCommand(command string) bool {
+	info, _ := r.LookupCommand(command)
+	return info.Arguments == CommandArgumentsAssignments || info.Arguments == CommandArgumentsExpression
+}
+
+func (r *CommandRegistry) isFieldListCommand(command string) bool {
+	info, _ := r.LookupCommand(command)
+	return info.Arguments == CommandArgumentsFields
+}
+
+func (r *CommandRegistry) isCommandOption(command, option string) bool {
+	info, _ := r.LookupCommand(command)
+	return info.HasOption(option)
+}
+
+func (r *CommandRegistry) isFieldValuedOption(command, option string) bool {
+	info, _ := r.LookupCommand(command)
+	return info.HasFieldOption(option)
+}
+
+func (r *CommandRegistry) isCommandKeyword(command, word string) bool {
+	info, _ := r.LookupCommand(command)
+	return info.HasKeyword(word)
+}
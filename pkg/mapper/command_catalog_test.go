@@ -0,0 +1,90 @@
+package mapper
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCommandCatalog(t *testing.T) {
+	// Every command the lexer knows is cataloged
+	grammar, err := os.ReadFile(filepath.Join("..", "..", "grammar", "SPLLexer.g4"))
+	if err != nil {
+		t.Fatalf("Failed to read the lexer grammar: %v", err)
+	}
+	lexerCommands := make(map[string]bool)
+	rule := regexp.MustCompile(`(?s)\n(INIT_COMMAND|STD_COMMAND_AND_FUNCTION|STD_COMMAND)\b[^:]*:(.*?);`)
+	for _, match := range rule.FindAllStringSubmatch(string(grammar), -1) {
+		for _, literal := range regexp.MustCompile(`'([a-z0-9_]+)'`).FindAllStringSubmatch(match[2], -1) {
+			lexerCommands[literal[1]] = true
+		}
+	}
+	if len(lexerCommands) < 100 {
+		t.Fatalf("Expected the lexer commands, found %d", len(lexerCommands))
+	}
+	for command := range lexerCommands {
+		if _, ok := LookupCommand(command); !ok {
+			t.Errorf("Command %s is not cataloged", command)
+		}
+	}
+
+	types := map[string]bool{
+		CommandGenerating: true, CommandDistributableStreaming: true, CommandStreaming: true,
+		CommandTransforming: true, CommandDatasetProcessing: true, CommandOrchestrating: true,
+	}
+	catalog := CommandCatalog()
+	for i, info := range catalog {
+		if !lexerCommands[info.Name] {
+			t.Errorf("Cataloged command %s is not a lexer command", info.Name)
+		}
+		if !types[info.Type] {
+			t.Errorf("Command %s has unknown type %q", info.Name, info.Type)
+		}
+		if i > 0 && catalog[i-1].Name >= info.Name {
+			t.Errorf("Catalog is not sorted at %s", info.Name)
+		}
+	}
+
+	tests := []struct {
+		command      string
+		commandType  string
+		resetsFields bool
+		arguments    string
+	}{
+		{"tstats", CommandGenerating, true, CommandArgumentsFields},
+		{"EVAL", CommandDistributableStreaming, false, CommandArgumentsAssignments},
+		{"where", CommandDistributableStreaming, false, CommandArgumentsExpression},
+		{"streamstats", CommandStreaming, false, CommandArgumentsFields},
+		{"stats", CommandTransforming, true, CommandArgumentsFields},
+		{"sort", CommandDatasetProcessing, false, CommandArgumentsFields},
+		{"localop", CommandOrchestrating, false, ""},
+	}
+	for _, tt := range tests {
+		info, ok := LookupCommand(tt.command)
+		if !ok {
+			t.Errorf("Expected %s to be cataloged", tt.command)
+			continue
+		}
+		if info.Name != strings.ToLower(tt.command) || info.Type != tt.commandType ||
+			info.ResetsFields != tt.resetsFields || info.Arguments != tt.arguments {
+			t.Errorf("Unexpected catalog entry for %s: %+v", tt.command, info)
+		}
+	}
+
+	if info, _ := LookupCommand("rex"); !info.HasOption("MAX_MATCH") || !info.HasFieldOption("field") || info.HasFieldOption("mode") {
+		t.Errorf("Unexpected rex options: %+v", info)
+	}
+	if info, _ := LookupCommand("chart"); !info.HasKeyword("over") {
+		t.Errorf("Expected chart to accept over: %+v", info)
+	}
+	if _, ok := LookupCommand("notacommand"); ok {
+		t.Error("Expected unknown commands not to be cataloged")
+	}
+	for command, scoped := range map[string]bool{"append": true, "Join": true, "multisearch": true, "appendpipe": false, "eval": false} {
+		if defaultRegistry.isScopedCommand(command) != scoped {
+			t.Errorf("Expected isScopedCommand(%s) to be %v", command, scoped)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/delgado-jacob/spl-toolkit/parser"
+)
+
+// Custom function categories
+const (
+	FunctionEval  = "eval"  // Used in eval and where expressions
+	FunctionStats = "stats" // Used as an aggregation in stats, chart, timechart and similar commands
+)
+
+// Custom function argument shapes
+const (
+	FunctionArgumentField = "field" // The argument is the name of an input field
+	FunctionArgumentValue = "value" // The argument is a literal value, never a field
+)
+
+// FunctionInfo describes a custom eval or stats function
+type FunctionInfo struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// Arguments lists the shape of each positional argument; the last shape applies to
+	// any further arguments. Without shapes, arguments are read like those of built-in
+	// functions.
+	Arguments []string `json:"arguments,omitempty"`
+}
+
+// CommandRegistry holds the custom commands and functions declared by an integrator. Parsers
+// and mappers use the default registry, which the package-level functions manage, unless they
+// are given their own.
+type CommandRegistry struct {
+	mu        sync.RWMutex
+	commands  map[string]CommandInfo
+	functions map[string]FunctionInfo
+}
+
+// NewCommandRegistry creates an empty registry
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		commands:  make(map[string]CommandInfo),
+		functions: make(map[string]FunctionInfo),
+	}
+}
+
+var defaultRegistry = NewCommandRegistry()
+
+// DefaultCommandRegistry returns the registry used by parsers, mappers and analyzers that are
+// not given their own
+func DefaultCommandRegistry() *CommandRegistry {
+	return defaultRegistry
+}
+
+// orDefault returns the registry, or the default registry for a nil one
+func (r *CommandRegistry) orDefault() *CommandRegistry {
+	if r == nil {
+		return defaultRegistry
+	}
+	return r
+}
+
+var commandTypes = map[string]bool{
+	CommandGenerating:             true,
+	CommandDistributableStreaming: true,
+	CommandStreaming:              true,
+	CommandTransforming:           true,
+	CommandDatasetProcessing:      true,
+	CommandOrchestrating:          true,
+}
+
+var commandArgumentKinds = map[string]bool{
+	"":                          true,
+	CommandArgumentsSearch:      true,
+	CommandArgumentsAssignments: true,
+	CommandArgumentsExpression:  true,
+	CommandArgumentsFields:      true,
+}
+
+// RegisterCommand declares a custom search command in the default registry
+func RegisterCommand(info CommandInfo) error {
+	return defaultRegistry.RegisterCommand(info)
+}
+
+// RegisterFunction declares a custom eval or stats function in the default registry
+func RegisterFunction(info FunctionInfo) error {
+	return defaultRegistry.RegisterFunction(info)
+}
+
+// UnregisterCommand removes a custom command from the default registry
+func UnregisterCommand(name string) {
+	defaultRegistry.UnregisterCommand(name)
+}
+
+// UnregisterFunction removes a custom function from the default registry
+func UnregisterFunction(name string) {
+	defaultRegistry.UnregisterFunction(name)
+}
+
+// LookupFunction returns a custom function of the default registry
+func LookupFunction(name string) (FunctionInfo, bool) {
+	return defaultRegistry.LookupFunction(name)
+}
+
+// RegisterCommand declares a custom search command. Its name must not be a built-in
+// command or keyword; registering a custom command again replaces it.
+func (r *CommandRegistry) RegisterCommand(info CommandInfo) error {
+	name, err := customName(info.Name, "command")
+	if err != nil {
+		return err
+	}
+	if !commandTypes[info.Type] {
+		return fmt.Errorf("command %s: unknown type %q", name, info.Type)
+	}
+	if !commandArgumentKinds[info.Arguments] {
+		return fmt.Errorf("command %s: unknown arguments %q", name, info.Arguments)
+	}
+	for _, option := range info.FieldOptions {
+		if !info.HasOption(option) {
+			return fmt.Errorf("command %s: field option %s is not listed in options", name, option)
+		}
+	}
+
+	info.Name = name
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[name] = info
+	return nil
+}
+
+// RegisterFunction declares a custom eval or stats function. Its name must not be a
+// built-in function or keyword; registering a custom function again replaces it.
+func (r *CommandRegistry) RegisterFunction(info FunctionInfo) error {
+	name, err := customName(info.Name, "function")
+	if err != nil {
+		return err
+	}
+	if info.Category != FunctionEval && info.Category != FunctionStats {
+		return fmt.Errorf("function %s: unknown category %q", name, info.Category)
+	}
+	for _, shape := range info.Arguments {
+		if shape != FunctionArgumentField && shape != FunctionArgumentValue {
+			return fmt.Errorf("function %s: unknown argument shape %q", name, shape)
+		}
+	}
+
+	info.Name = name
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.functions[name] = info
+	return nil
+}
+
+// UnregisterCommand removes a custom command
+func (r *CommandRegistry) UnregisterCommand(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.commands, strings.ToLower(name))
+}
+
+// UnregisterFunction removes a custom function
+func (r *CommandRegistry) UnregisterFunction(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.functions, strings.ToLower(name))
+}
+
+// LookupFunction returns a registered custom function
+func (r *CommandRegistry) LookupFunction(name string) (FunctionInfo, bool) {
+	r = r.orDefault()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	info, ok := r.functions[strings.ToLower(name)]
+	return info, ok
+}
+
+func (r *CommandRegistry) lookupCustomCommand(name string) (CommandInfo, bool) {
+	r = r.orDefault()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	info, ok := r.commands[strings.ToLower(name)]
+	return info, ok
+}
+
+func (r *CommandRegistry) hasCustomEntries() bool {
+	r = r.orDefault()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.commands) > 0 || len(r.functions) > 0
+}
+
+// customName checks that a custom command or function name is lexed as a plain identifier,
+// so it does not shadow a built-in command, function or keyword
+func customName(name, kind string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", fmt.Errorf("%s name is required", kind)
+	}
+
+	lexer := parser.NewSPLLexer(antlr.NewInputStream(name))
+	lexer.RemoveErrorListeners()
+	tokens := lexer.GetAllTokens()
+	if len(tokens) != 1 || tokens[0].GetTokenType() != parser.SPLLexerIDENTIFIER {
+		if len(tokens) == 1 {
+			return "", fmt.Errorf("%s %s is built in", kind, name)
+		}
+		return "", fmt.Errorf("%s name %q is not a single identifier", kind, name)
+	}
+	return name, nil
+}
+
+// functionArgumentShape returns the declared shape of an argument of a custom function call
+func (r *CommandRegistry) functionArgumentShape(call *parser.ExpressionContext, argument antlr.Tree) (string, bool) {
+	if call == nil || call.Function() == nil {
+		return "", false
+	}
+	info, ok := r.LookupFunction(call.Function().GetText())
+	if !ok || len(info.Arguments) == 0 {
+		return "", false
+	}
+	for i, expression := range call.AllExpression() {
+		if antlr.Tree(expression) == argument {
+			return info.Arguments[min(i, len(info.Arguments)-1)], true
+		}
+	}
+	return "", false
+}
+
+// newQueryTokenSource returns the token source the parser reads a query from. When custom
+// commands or functions are registered, the lexer is wrapped so their names are parsed as
+// commands after a pipe and as functions before "(" instead of as plain identifiers.
+func newQueryTokenSource(lexer *parser.SPLLexer, registry *CommandRegistry) antlr.Lexer {
+	if !registry.hasCustomEntries() {
+		return lexer
+	}
+	return &customTokenSource{SPLLexer: lexer, registry: registry.orDefault(), previous: antlr.TokenInvalidType}
+}
+
+// customTokenSource retypes the identifiers that name custom commands and functions
+type customTokenSource struct {
+	*parser.SPLLexer
+	registry *CommandRegistry
+	pending  []antlr.Token
+	previous int // Type of the last token on the default channel
+}
+
+func (s *customTokenSource) NextToken() antlr.Token {
+	token := s.nextLexerToken()
+	if token.GetChannel() != antlr.TokenDefaultChannel {
+		return token
+	}
+
+	if token.GetTokenType() == parser.SPLLexerIDENTIFIER {
+		if info, ok := s.registry.lookupCustomCommand(token.GetText()); ok && s.previous == parser.SPLLexerPIPE {
+			tokenType := parser.SPLLexerSTD_COMMAND
+			if info.Type == CommandGenerating {
+				tokenType = parser.SPLLexerINIT_COMMAND
+			}
+			token = s.retype(token, tokenType)
+		} else if _, ok := s.registry.LookupFunction(token.GetText()); ok && s.peekDefaultType() == parser.SPLLexerLPAREN {
+			token = s.retype(token, parser.SPLLexerFUNCTION)
+		}
+	}
+
+	s.previous = token.GetTokenType()
+	return token
+}
+
+func (s *customTokenSource) nextLexerToken() antlr.Token {
+	if len(s.pending) > 0 {
+		token := s.pending[0]
+		s.pending = s.pending[1:]
+		return token
+	}
+	return s.SPLLexer.NextToken()
+}
+
+// peekDefaultType returns the type of the next token on the default channel
+func (s *customTokenSource) peekDefaultType() int {
+	for _, token := range s.pending {
+		if token.GetChannel() == antlr.TokenDefaultChannel {
+			return token.GetTokenType()
+		}
+	}
+	for {
+		token := s.SPLLexer.NextToken()
+		s.pending = append(s.pending, token)
+		if token.GetChannel() == antlr.TokenDefaultChannel || token.GetTokenType() == antlr.TokenEOF {
+			return token.GetTokenType()
+		}
+	}
+}
+
+func (s *customTokenSource) retype(token antlr.Token, tokenType int) antlr.Token {
+	return s.GetTokenFactory().Create(token.GetSource(), tokenType, token.GetText(), token.GetChannel(),
+		token.GetStart(), token.GetStop(), token.GetLine(), token.GetColumn())
+}
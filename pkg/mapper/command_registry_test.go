@@ -0,0 +1,185 @@
+package mapper
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCustomCommandRegistry(t *testing.T) {
+	query := "index=web | geoenrich limit=5 src=clientip host user over | eval d=geodist(lat, km) | stats count by host"
+
+	// Without declarations the custom command does not parse
+	if err := NewParser().ValidateQuery(query); err == nil {
+		t.Fatal("Expected the undeclared custom command to fail to parse")
+	}
+
+	if err := RegisterCommand(CommandInfo{
+		Name:         "geoenrich",
+		Type:         CommandDistributableStreaming,
+		Arguments:    CommandArgumentsFields,
+		Options:      []string{"limit", "src"},
+		FieldOptions: []string{"src"},
+		Keywords:     []string{"over"},
+	}); err != nil {
+		t.Fatalf("RegisterCommand failed: %v", err)
+	}
+	t.Cleanup(func() { UnregisterCommand("geoenrich") })
+	if err := RegisterCommand(CommandInfo{Name: "sampledata", Type: CommandGenerating}); err != nil {
+		t.Fatalf("RegisterCommand failed: %v", err)
+	}
+	t.Cleanup(func() { UnregisterCommand("sampledata") })
+	if err := RegisterFunction(FunctionInfo{
+		Name:      "geodist",
+		Category:  FunctionEval,
+		Arguments: []string{FunctionArgumentField, FunctionArgumentValue},
+	}); err != nil {
+		t.Fatalf("RegisterFunction failed: %v", err)
+	}
+	t.Cleanup(func() { UnregisterFunction("geodist") })
+
+	m := New()
+	mappings, _ := json.Marshal([]FieldMapping{
+		{Source: "clientip", Target: "src_ip"},
+		{Source: "host", Target: "hostname"},
+		{Source: "user", Target: "username"},
+		{Source: "lat", Target: "latitude"},
+		{Source: "km", Target: "kilometers"},
+		{Source: "limit", Target: "max_results"},
+	})
+	if err := m.LoadMappings(mappings); err != nil {
+		t.Fatalf("LoadMappings failed: %v", err)
+	}
+
+	result, err := m.MapQuery(query)
+	if err != nil {
+		t.Fatalf("MapQuery failed: %v", err)
+	}
+	expected := "index=web | geoenrich limit=5 src=src_ip hostname username over | eval d=geodist(latitude, km) | stats count by hostname"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+
+	info, err := m.DiscoverQuery(query)
+	if err != nil {
+		t.Fatalf("DiscoverQuery failed: %v", err)
+	}
+	for _, field := range []string{"clientip", "host", "user", "lat"} {
+		if !contains(info.InputFields, field) {
+			t.Errorf("Expected input field %s, got %v", field, info.InputFields)
+		}
+	}
+	for _, word := range []string{"limit", "over", "km", "geodist", "geoenrich"} {
+		if contains(info.InputFields, word) {
+			t.Errorf("Expected %s not to be an input field, got %v", word, info.InputFields)
+		}
+	}
+
+	// Generating custom commands start a query; names stay fields outside command position
+	for _, valid := range []string{"| sampledata count=3 | stats count", "index=web geoenrich=1 | eval x=geoenrich"} {
+		if err := m.ValidateQuery(valid); err != nil {
+			t.Errorf("Expected %q to parse: %v", valid, err)
+		}
+	}
+	if command, ok := LookupCommand("GEOENRICH"); !ok || command.Type != CommandDistributableStreaming {
+		t.Errorf("Expected the custom command in the catalog, got %+v", command)
+	}
+
+	errorTests := []struct {
+		name string
+		err  error
+	}{
+		{"Built-in command", RegisterCommand(CommandInfo{Name: "stats", Type: CommandTransforming})},
+		{"Built-in function", RegisterFunction(FunctionInfo{Name: "lower", Category: FunctionEval})},
+		{"Not an identifier", RegisterCommand(CommandInfo{Name: "my cmd", Type: CommandStreaming})},
+		{"Unknown type", RegisterCommand(CommandInfo{Name: "mycmd", Type: "fast"})},
+		{"Unknown arguments", RegisterCommand(CommandInfo{Name: "mycmd", Type: CommandStreaming, Arguments: "words"})},
+		{"Field option not listed", RegisterCommand(CommandInfo{Name: "mycmd", Type: CommandStreaming, FieldOptions: []string{"src"}})},
+		{"Unknown category", RegisterFunction(FunctionInfo{Name: "myfunc", Category: "chart"})},
+		{"Unknown shape", RegisterFunction(FunctionInfo{Name: "myfunc", Category: FunctionEval, Arguments: []string{"regex"}})},
+	}
+	for _, tt := range errorTests {
+		if tt.err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	UnregisterCommand("geoenrich")
+	if err := m.ValidateQuery(query); err == nil {
+		t.Error("Expected the unregistered custom command to fail to parse")
+	}
+}
+
+func TestCommandRegistryInstances(t *testing.T) {
+	query := "index=web | geoenrich src=clientip | stats count by host"
+
+	tenant := NewCommandRegistry()
+	if err := tenant.RegisterCommand(CommandInfo{
+		Name:         "geoenrich",
+		Type:         CommandDistributableStreaming,
+		Arguments:    CommandArgumentsFields,
+		Options:      []string{"src"},
+		FieldOptions: []string{"src"},
+	}); err != nil {
+		t.Fatalf("RegisterCommand failed: %v", err)
+	}
+
+	// The declaration stays in its registry
+	if _, ok := LookupCommand("geoenrich"); ok {
+		t.Error("Expected the default registry not to know geoenrich")
+	}
+	if _, ok := tenant.LookupCommand("geoenrich"); !ok {
+		t.Error("Expected the tenant registry to know geoenrich")
+	}
+	if err := NewParser().ValidateQuery(query); err == nil {
+		t.Error("Expected the default parser to reject geoenrich")
+	}
+	if err := NewParserWithRegistry(tenant).ValidateQuery(query); err != nil {
+		t.Errorf("Expected the tenant parser to accept geoenrich: %v", err)
+	}
+
+	m := New()
+	if err := m.LoadMappings([]byte(`[{"source": "clientip", "target": "src_ip"}]`)); err != nil {
+		t.Fatalf("LoadMappings failed: %v", err)
+	}
+	if _, err := m.MapQuery(query); err == nil {
+		t.Error("Expected the default mapper to reject geoenrich")
+	}
+
+	m.SetCommandRegistry(tenant)
+	if m.CommandRegistry() != tenant {
+		t.Error("Expected the mapper to use the tenant registry")
+	}
+	result, err := m.MapQuery(query)
+	if err != nil {
+		t.Fatalf("MapQuery failed: %v", err)
+	}
+	expected := "index=web | geoenrich src=src_ip | stats count by host"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+	info, err := m.DiscoverQuery(query)
+	if err != nil {
+		t.Fatalf("DiscoverQuery failed: %v", err)
+	}
+	if !contains(info.InputFields, "clientip") || contains(info.InputFields, "src") {
+		t.Errorf("Expected clientip and not src as input fields, got %v", info.InputFields)
+	}
+
+	linter := NewQueryLinter()
+	if _, err := linter.Lint(query, QueryLintOptions{}); err == nil {
+		t.Error("Expected the default linter to reject geoenrich")
+	}
+	linter.SetCommandRegistry(tenant)
+	if _, err := linter.Lint(query, QueryLintOptions{}); err != nil {
+		t.Errorf("Expected the tenant linter to accept geoenrich: %v", err)
+	}
+
+	// A nil registry restores the default registry
+	m.SetCommandRegistry(nil)
+	if m.CommandRegistry() != DefaultCommandRegistry() {
+		t.Error("Expected SetCommandRegistry(nil) to restore the default registry")
+	}
+	if _, err := m.MapQuery(query); err == nil {
+		t.Error("Expected the mapper to reject geoenrich with the default registry")
+	}
+}
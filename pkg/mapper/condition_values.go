@@ -0,0 +1,329 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// valueOperators are the operators of conditions that compare values: field_value and the
+// discovered value types
+var valueOperators = map[string]bool{
+	"equals": true, "not_equals": true, "contains": true, "starts_with": true, "ends_with": true,
+	"regex": true, "in": true, "gt": true, "gte": true, "lt": true, "lte": true, "between": true,
+}
+
+// numericOperators compare values as numbers; the others compare them as strings
+var numericOperators = map[string]bool{"gt": true, "gte": true, "lt": true, "lte": true, "between": true}
+
+// decimalPattern matches the strings that are compared as numbers. Go's ParseFloat alone
+// would also accept "inf", "nan" and hexadecimal floats.
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// validateValueOperator checks that a field_value or discovered value condition has a value
+// its operator can compare
+func validateValueOperator(condition Condition) error {
+	switch condition.Operator {
+	case "in":
+		if _, isList := conditionListValue(condition.Value); !isList || len(conditionList(condition.Value)) == 0 {
+			return fmt.Errorf("operator 'in' requires a non-empty list value")
+		}
+		for _, item := range conditionList(condition.Value) {
+			if _, ok := conditionString(item); !ok {
+				return fmt.Errorf("operator 'in' requires a list of strings, numbers or booleans")
+			}
+		}
+	case "between":
+		bounds, isList := conditionListValue(condition.Value)
+		if !isList || len(bounds) != 2 {
+			return fmt.Errorf("operator 'between' requires a [low, high] list value")
+		}
+		low, lowOK := conditionNumber(bounds[0])
+		high, highOK := conditionNumber(bounds[1])
+		if !lowOK || !highOK {
+			return fmt.Errorf("operator 'between' requires numeric bounds")
+		}
+		if low > high {
+			return fmt.Errorf("operator 'between' requires low <= high, got [%v, %v]", bounds[0], bounds[1])
+		}
+	case "gt", "gte", "lt", "lte":
+		if _, ok := conditionNumber(condition.Value); !ok {
+			return fmt.Errorf("operator '%s' requires a numeric value", condition.Operator)
+		}
+	case "regex":
+		pattern, ok := condition.Value.(string)
+		if !ok {
+			return fmt.Errorf("operator 'regex' requires a string value")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid regex pattern: %w", err)
+		}
+	case "equals", "not_equals", "contains", "starts_with", "ends_with":
+		if _, ok := conditionString(condition.Value); !ok && condition.Value != nil {
+			return fmt.Errorf("operator '%s' requires a string, number or boolean value", condition.Operator)
+		}
+	}
+	return nil
+}
+
+// matchValue reports whether a context value satisfies a value condition. A list matches
+// when any of its elements does; not_equals matches when none of them is equal.
+func matchValue(condition Condition, value interface{}, regexes regexSet) bool {
+	operator, negated := condition.Operator, false
+	if operator == "not_equals" {
+		operator, negated = "equals", true
+	}
+
+	for _, item := range conditionList(value) {
+		if matchScalar(operator, item, condition.Value, condition.IgnoreCase, regexes) {
+			return !negated
+		}
+	}
+	return negated
+}
+
+// matchScalar applies a value operator to a single context value. Regex patterns are taken
+// from regexes when they were compiled in advance.
+func matchScalar(operator string, actual, expected interface{}, ignoreCase bool, regexes regexSet) bool {
+	if numericOperators[operator] {
+		number, ok := conditionNumber(actual)
+		if !ok {
+			return false
+		}
+		if operator == "between" {
+			bounds := conditionList(expected)
+			if len(bounds) != 2 {
+				return false
+			}
+			low, lowOK := conditionNumber(bounds[0])
+			high, highOK := conditionNumber(bounds[1])
+			return lowOK && highOK && low <= number && number <= high
+		}
+
+		limit, ok := conditionNumber(expected)
+		if !ok {
+			return false
+		}
+		switch operator {
+		case "gt":
+			return number > limit
+		case "gte":
+			return number >= limit
+		case "lt":
+			return number < limit
+		default:
+			return number <= limit
+		}
+	}
+
+	switch operator {
+	case "equals":
+		return valuesEqual(actual, expected, ignoreCase)
+	case "in":
+		for _, candidate := range conditionList(expected) {
+			if valuesEqual(actual, candidate, ignoreCase) {
+				return true
+			}
+		}
+		return false
+	case "regex":
+		text, ok := conditionString(actual)
+		pattern, isString := expected.(string)
+		if !ok || !isString {
+			return false
+		}
+		re, err := regexes.compile(conditionPattern(pattern, ignoreCase))
+		return err == nil && re.MatchString(text)
+	}
+
+	text, ok := conditionString(actual)
+	part, partOK := conditionString(expected)
+	if !ok || !partOK {
+		return false
+	}
+	if ignoreCase {
+		text, part = strings.ToLower(text), strings.ToLower(part)
+	}
+	switch operator {
+	case "contains":
+		return strings.Contains(text, part)
+	case "starts_with":
+		return strings.HasPrefix(text, part)
+	case "ends_with":
+		return strings.HasSuffix(text, part)
+	}
+	return false
+}
+
+// regexSet holds compiled regex patterns by their source. A config compiles the patterns of
+// its conditions when a Mapper takes it; patterns missing from the set are compiled on use.
+type regexSet map[string]*regexp.Regexp
+
+// compile returns the compiled pattern, compiling it if the set does not hold it
+func (s regexSet) compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := s[pattern]; ok {
+		return re, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// addConditions compiles the patterns of regex and query_regex conditions, including nested
+// ones. Invalid patterns are left out; Validate reports them.
+func (s regexSet) addConditions(conditions []Condition) {
+	for _, condition := range conditions {
+		pattern, isString := condition.Value.(string)
+		switch {
+		case condition.Type == "query_regex" && isString:
+			pattern = conditionPattern(pattern, false)
+		case condition.Operator == "regex" && isString:
+			pattern = conditionPattern(pattern, condition.IgnoreCase)
+		default:
+			s.addConditions(condition.Children)
+			continue
+		}
+		if re, err := regexp.Compile(pattern); err == nil {
+			s[pattern] = re
+		}
+	}
+}
+
+// conditionPattern returns the pattern a regex condition compiles
+func conditionPattern(pattern string, ignoreCase bool) string {
+	if ignoreCase {
+		return "(?i)" + pattern
+	}
+	return pattern
+}
+
+// valuesEqual compares two scalars. When either of them is a number in the config or the
+// expression, both are compared as exact decimals, so that 3, 3.0 and "3" are equal. Two
+// strings are compared as they are written: "1.10" and "1.1" differ, and so do IDs that
+// only a float64 would confuse.
+func valuesEqual(a, b interface{}, ignoreCase bool) bool {
+	if isConditionNumber(a) || isConditionNumber(b) {
+		x, okA := conditionDecimal(a)
+		y, okB := conditionDecimal(b)
+		if okA && okB {
+			return x.Cmp(y) == 0
+		}
+	}
+
+	sa, okA := conditionString(a)
+	sb, okB := conditionString(b)
+	if !okA || !okB {
+		return false
+	}
+	if ignoreCase {
+		return strings.EqualFold(sa, sb)
+	}
+	return sa == sb
+}
+
+// isConditionNumber reports whether a value is a number rather than a string that reads
+// like one
+func isConditionNumber(value interface{}) bool {
+	if _, ok := value.(json.Number); ok {
+		return true
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// conditionDecimal converts a value to the exact decimal it is compared as for equality.
+// Floats convert from their shortest decimal form, so 0.1 equals "0.1".
+func conditionDecimal(value interface{}) (*big.Rat, bool) {
+	text, ok := conditionString(value)
+	if !ok || !decimalPattern.MatchString(text) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(text)
+}
+
+// conditionNumber converts a value to the number it is compared as. Numbers of any Go type,
+// json.Number and decimal strings such as "42" or "-1.5e3" convert; booleans do not.
+func conditionNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case string:
+		if !decimalPattern.MatchString(v) {
+			return 0, false
+		}
+		number, err := strconv.ParseFloat(v, 64)
+		return number, err == nil
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// conditionString converts a scalar to the string it is compared as: strings as they are,
+// booleans as "true" or "false" and numbers in their shortest decimal form, so the number 3
+// from JSON reads "3". Lists, objects and null do not convert.
+func conditionString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case json.Number:
+		return v.String(), true
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// conditionList returns a value as a list: lists as their elements, null as no elements and
+// anything else as a single element
+func conditionList(value interface{}) []interface{} {
+	if list, isList := conditionListValue(value); isList {
+		return list
+	}
+	if value == nil {
+		return nil
+	}
+	return []interface{}{value}
+}
+
+// conditionListValue returns the elements of a list value. Discovered values are stored as
+// []string; values decoded from JSON, YAML or TOML are []interface{}.
+func conditionListValue(value interface{}) ([]interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = item
+		}
+		return list, true
+	}
+	return nil, false
+}
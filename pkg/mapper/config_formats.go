@@ -0,0 +1,772 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFormat is a serialization format for mapping configurations
+type ConfigFormat string
+
+// Supported mapping configuration formats
+const (
+	FormatJSON ConfigFormat = "json"
+	FormatYAML ConfigFormat = "yaml"
+	FormatTOML ConfigFormat = "toml"
+)
+
+// ConfigError is a validation error located in the source of a mapping configuration
+type ConfigError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// ConfigErrors lists every validation error of a mapping configuration
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "invalid mapping config: " + strings.Join(messages, "; ")
+}
+
+// ParseConfigFormat returns the format with the given name; "yml" is accepted for YAML
+func ParseConfigFormat(name string) (ConfigFormat, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	case "toml":
+		return FormatTOML, nil
+	}
+	return "", fmt.Errorf("unsupported config format: %s (expected json, yaml or toml)", name)
+}
+
+// ConfigFormatForPath returns the format of a mapping configuration file from its extension
+func ConfigFormatForPath(path string) (ConfigFormat, error) {
+	return ParseConfigFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// LoadMappingConfigYAML loads and validates a mapping configuration from YAML.
+// Validation errors are returned as ConfigErrors with their position in the document.
+func LoadMappingConfigYAML(data []byte) (*MappingConfig, error) {
+	return loadMappingConfigFormat(data, FormatYAML)
+}
+
+// LoadMappingConfigTOML loads and validates a mapping configuration from TOML.
+// Validation errors are returned as ConfigErrors with their position in the document.
+func LoadMappingConfigTOML(data []byte) (*MappingConfig, error) {
+	return loadMappingConfigFormat(data, FormatTOML)
+}
+
+// LoadMappingConfigFormat loads and validates a mapping configuration in the given format
+func LoadMappingConfigFormat(data []byte, format ConfigFormat) (*MappingConfig, error) {
+	if format == FormatJSON {
+		return LoadMappingConfig(data)
+	}
+	return loadMappingConfigFormat(data, format)
+}
+
+func loadMappingConfigFormat(data []byte, format ConfigFormat) (*MappingConfig, error) {
+	config, err := decodeMappingConfig(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if result := config.Validate(); !result.Valid {
+		positions, err := indexConfigPositions(data, format)
+		if err != nil {
+			return nil, err
+		}
+
+		var errors ConfigErrors
+		for _, message := range result.Errors {
+			line, column := positions.lookup(validationPath(message))
+			errors = append(errors, ConfigError{Line: line, Column: column, Message: message})
+		}
+		return nil, errors
+	}
+
+	return config, nil
+}
+
+// decodeMappingConfig decodes a configuration without validating it. YAML and TOML documents
+// are decoded through their JSON form so that every format shares the JSON field names and
+// value types.
+func decodeMappingConfig(data []byte, format ConfigFormat) (*MappingConfig, error) {
+	jsonData := data
+	if format != FormatJSON {
+		var err error
+		if jsonData, err = toJSON(data, format); err != nil {
+			return nil, err
+		}
+	}
+
+	var config MappingConfig
+	if err := unmarshalConfigJSON(jsonData, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+	}
+	return &config, nil
+}
+
+// unmarshalConfigJSON decodes configuration JSON like json.Unmarshal, except that numbers in
+// untyped values such as condition values stay json.Number. A float64 would round IDs with
+// more than 15 digits.
+func unmarshalConfigJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid data after the top-level value")
+	}
+	return nil
+}
+
+// ConvertMappingConfig translates a mapping configuration document between formats.
+// The document is not validated, so partial configs such as overlays can be converted.
+func ConvertMappingConfig(data []byte, from, to ConfigFormat) ([]byte, error) {
+	jsonData := data
+	if from != FormatJSON {
+		var err error
+		if jsonData, err = toJSON(data, from); err != nil {
+			return nil, err
+		}
+	}
+
+	switch to {
+	case FormatJSON:
+		var out bytes.Buffer
+		if err := json.Indent(&out, jsonData, "", "  "); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+		}
+		out.WriteByte('\n')
+		return out.Bytes(), nil
+
+	case FormatYAML:
+		// JSON is valid YAML: decoding it into a node tree keeps the key order
+		var node yaml.Node
+		if err := yaml.Unmarshal(jsonData, &node); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+		}
+		clearNodeStyle(&node)
+
+		var out bytes.Buffer
+		encoder := yaml.NewEncoder(&out)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return nil, fmt.Errorf("failed to encode YAML: %w", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("failed to encode YAML: %w", err)
+		}
+		return out.Bytes(), nil
+
+	case FormatTOML:
+		decoder := json.NewDecoder(bytes.NewReader(jsonData))
+		decoder.UseNumber()
+		var document interface{}
+		if err := decoder.Decode(&document); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+		}
+		value, err := tomlValue(document, "")
+		if err != nil {
+			return nil, err
+		}
+
+		var out bytes.Buffer
+		if err := toml.NewEncoder(&out).Encode(value); err != nil {
+			return nil, fmt.Errorf("failed to encode TOML: %w", err)
+		}
+		return out.Bytes(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported config format: %s", to)
+}
+
+// Marshal serializes the mapping configuration in the given format
+func (mc *MappingConfig) Marshal(format ConfigFormat) ([]byte, error) {
+	data, err := marshalJSON(mc)
+	if err != nil {
+		return nil, err
+	}
+	return ConvertMappingConfig(data, FormatJSON, format)
+}
+
+// marshalJSON encodes a value without escaping "<", ">" and "&", which appear in when expressions
+func marshalJSON(value interface{}) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+// toJSON converts a YAML or TOML document to JSON
+func toJSON(data []byte, format ConfigFormat) ([]byte, error) {
+	var document interface{}
+	switch format {
+	case FormatYAML:
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+		}
+		var err error
+		if document, err = yamlValue(&node); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+		}
+	case FormatTOML:
+		var table map[string]interface{}
+		meta, err := toml.Decode(string(data), &table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+		}
+		document = orderTOMLTable(table, "", tomlKeyOrder(meta))
+	default:
+		return nil, fmt.Errorf("unsupported config format: %s", format)
+	}
+
+	jsonData, err := marshalJSON(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+	}
+	return jsonData, nil
+}
+
+// orderedObject is a JSON object that keeps the key order of the document it was decoded from
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// MarshalJSON encodes the members in document order
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		name, err := marshalJSON(key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalJSON(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// jsonNumberPattern matches the number literals JSON accepts
+var jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9]\d*)(\.\d+)?([eE][+-]?\d+)?$`)
+
+// yamlValue converts a YAML node to a value that encodes to JSON like the document reads:
+// mappings keep their key order and numbers keep their literal, so 1.0 stays 1.0
+func yamlValue(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlValue(node.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.SequenceNode:
+		items := make([]interface{}, len(node.Content))
+		for i, item := range node.Content {
+			value, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = value
+		}
+		return items, nil
+	case yaml.MappingNode:
+		object := orderedObject{values: make(map[string]interface{})}
+		if err := addYAMLMembers(&object, node, false); err != nil {
+			return nil, err
+		}
+		return object, nil
+	}
+
+	if (node.Tag == "!!int" || node.Tag == "!!float") && jsonNumberPattern.MatchString(node.Value) {
+		return json.Number(node.Value), nil
+	}
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// addYAMLMembers adds the members of a YAML mapping to an object in document order. Keys
+// merged in with << do not replace the keys the mapping sets itself.
+func addYAMLMembers(object *orderedObject, node *yaml.Node, merged bool) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Tag == "!!merge" {
+			sources := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				sources = value.Content
+			}
+			for _, source := range sources {
+				for source.Kind == yaml.AliasNode {
+					source = source.Alias
+				}
+				if source.Kind != yaml.MappingNode {
+					return fmt.Errorf("line %d: << must merge a mapping", source.Line)
+				}
+				if err := addYAMLMembers(object, source, true); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if key.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+		}
+
+		member, err := yamlValue(value)
+		if err != nil {
+			return err
+		}
+		if _, exists := object.values[key.Value]; !exists {
+			object.keys = append(object.keys, key.Value)
+		} else if merged {
+			continue
+		}
+		object.values[key.Value] = member
+	}
+	return nil
+}
+
+// tomlKeyOrder returns the keys of each table of a TOML document in the order they first
+// appear, by the dotted path of the table. The tables of an array share one path.
+func tomlKeyOrder(meta toml.MetaData) map[string][]string {
+	order := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, key := range meta.Keys() {
+		for i := range key {
+			parent, path := strings.Join(key[:i], "."), strings.Join(key[:i+1], ".")
+			if !seen[path] {
+				seen[path] = true
+				order[parent] = append(order[parent], key[i])
+			}
+		}
+	}
+	return order
+}
+
+// orderTOMLTable converts the tables of a decoded TOML value to objects in document order.
+// TOML integers decode to int64 and are encoded exactly.
+func orderTOMLTable(value interface{}, path string, order map[string][]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object := orderedObject{values: make(map[string]interface{}, len(v))}
+		for _, key := range order[path] {
+			if item, exists := v[key]; exists {
+				object.keys = append(object.keys, key)
+				object.values[key] = orderTOMLTable(item, joinTOMLPath(path, key), order)
+			}
+		}
+		// Keys the metadata does not list are kept in a stable order
+		for _, key := range sortedSet(tomlMissingKeys(v, object.values)) {
+			object.keys = append(object.keys, key)
+			object.values[key] = orderTOMLTable(v[key], joinTOMLPath(path, key), order)
+		}
+		return object
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = orderTOMLTable(item, path, order)
+		}
+		return items
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = orderTOMLTable(item, path, order)
+		}
+		return items
+	}
+	return value
+}
+
+func joinTOMLPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// tomlMissingKeys returns the keys of table that are not in ordered
+func tomlMissingKeys(table, ordered map[string]interface{}) map[string]bool {
+	missing := make(map[string]bool)
+	for key := range table {
+		if _, exists := ordered[key]; !exists {
+			missing[key] = true
+		}
+	}
+	return missing
+}
+
+// clearNodeStyle switches a node tree decoded from JSON to block style.
+// The encoder quotes strings itself wherever a plain scalar would change their type.
+func clearNodeStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearNodeStyle(child)
+	}
+}
+
+// tomlValue prepares a decoded JSON value for the TOML encoder. TOML has no null,
+// so null object members are dropped; null array elements cannot be represented.
+func tomlValue(value interface{}, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i, nil
+		}
+		if !strings.ContainsAny(string(v), ".eE") {
+			// TOML integers are 64-bit; a float would silently change the value
+			return nil, fmt.Errorf("cannot represent integer %s at %s in TOML", v, strings.TrimPrefix(path, "."))
+		}
+		return v.Float64()
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if item == nil {
+				continue
+			}
+			converted, err := tomlValue(item, path+"."+key)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = converted
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			if item == nil {
+				return nil, fmt.Errorf("cannot represent null at %s[%d] in TOML", strings.TrimPrefix(path, "."), i)
+			}
+			converted, err := tomlValue(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			result[i] = converted
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+// validationPathRegex matches the "rule[0].condition[1]" style location that prefixes
+// validation messages, including nested "child[2]" segments
+var validationPathRegex = regexp.MustCompile(`^([a-z_]+(?:\[\d+\])?)((?:\.[a-z_]+(?:\[\d+\])?)*): `)
+
+// validationPathKeys maps the names used in validation messages to document keys
+var validationPathKeys = map[string]string{
+	"mapping":             "mappings",
+	"rule":                "rules",
+	"condition":           "conditions",
+	"child":               "children",
+	"datamodel":           "datamodels",
+	"field_mapping":       "field_mappings",
+	"conditional_mapping": "conditional_mappings",
+}
+
+// validationPath returns the document path of the element a validation message refers to,
+// e.g. "rule[0].condition[1]: child[0]: ..." becomes "rules[0].conditions[1].children[0]".
+// Messages without a location, such as "version is required", name a top-level key.
+func validationPath(message string) string {
+	var segments []string
+	for {
+		match := validationPathRegex.FindStringSubmatch(message)
+		if match == nil {
+			break
+		}
+		for _, segment := range strings.Split(match[1]+match[2], ".") {
+			name, index, _ := strings.Cut(segment, "[")
+			if key, exists := validationPathKeys[name]; exists {
+				name = key
+			}
+			if index != "" {
+				name += "[" + index
+			}
+			segments = append(segments, name)
+		}
+		message = message[len(match[0]):]
+	}
+
+	if len(segments) == 0 {
+		if key, _, found := strings.Cut(message, " "); found {
+			return key
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+// configPositions maps document paths such as "rules[0].mappings[1]" to line and column
+type configPositions map[string][2]int
+
+// lookup returns the position of a path, falling back to its closest located ancestor
+// and finally to the start of the document
+func (p configPositions) lookup(path string) (int, int) {
+	for path != "" {
+		if position, exists := p[path]; exists {
+			return position[0], position[1]
+		}
+		if i := strings.LastIndexAny(path, ".["); i > 0 {
+			path = path[:i]
+		} else {
+			break
+		}
+	}
+	return 1, 1
+}
+
+// indexConfigPositions records the position of every key and sequence element of a document
+func indexConfigPositions(data []byte, format ConfigFormat) (configPositions, error) {
+	positions := make(configPositions)
+
+	switch format {
+	case FormatJSON:
+		if err := indexJSONPositions(data, positions); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+		}
+	case FormatYAML:
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+		}
+		if len(node.Content) > 0 {
+			indexYAMLPositions(node.Content[0], "", positions)
+		}
+	case FormatTOML:
+		indexTOMLPositions(data, positions)
+	}
+
+	return positions, nil
+}
+
+// indexJSONPositions walks the tokens of a JSON document recording the position of each key and element
+func indexJSONPositions(data []byte, positions configPositions) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	// next returns the next token with the offset it starts at. The decoder offset
+	// points after the previous token, before any separator.
+	next := func() (json.Token, int, error) {
+		offset := int(decoder.InputOffset())
+		for offset < len(data) && strings.IndexByte(" \t\r\n,:", data[offset]) != -1 {
+			offset++
+		}
+		token, err := decoder.Token()
+		return token, offset, err
+	}
+
+	position := func(offset int) [2]int {
+		lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+		return [2]int{bytes.Count(data[:offset], []byte("\n")) + 1, offset - lineStart + 1}
+	}
+
+	var walk func(token json.Token, path string) error
+	walk = func(token json.Token, path string) error {
+		delim, ok := token.(json.Delim)
+		if !ok {
+			return nil
+		}
+
+		for i := 0; decoder.More(); i++ {
+			var child string
+			if delim == '{' {
+				key, offset, err := next()
+				if err != nil {
+					return err
+				}
+				child = key.(string)
+				if path != "" {
+					child = path + "." + child
+				}
+				positions[child] = position(offset)
+			}
+
+			value, offset, err := next()
+			if err != nil {
+				return err
+			}
+			if delim == '[' {
+				child = fmt.Sprintf("%s[%d]", path, i)
+				positions[child] = position(offset)
+			}
+			if err := walk(value, child); err != nil {
+				return err
+			}
+		}
+
+		// Closing delimiter
+		_, err := decoder.Token()
+		return err
+	}
+
+	token, _, err := next()
+	if err != nil {
+		return err
+	}
+	return walk(token, "")
+}
+
+// indexYAMLPositions walks a YAML node tree recording the position of each key and element
+func indexYAMLPositions(node *yaml.Node, path string, positions configPositions) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			child := key.Value
+			if path != "" {
+				child = path + "." + key.Value
+			}
+			positions[child] = [2]int{key.Line, key.Column}
+			indexYAMLPositions(value, child, positions)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			positions[child] = [2]int{item.Line, item.Column}
+			indexYAMLPositions(item, child, positions)
+		}
+	}
+}
+
+// tomlHeaderRegex matches [table] and [[array.of.tables]] headers
+var tomlHeaderRegex = regexp.MustCompile(`^(\s*)(\[\[?)\s*([A-Za-z0-9_.\-"' ]+?)\s*\]\]?\s*(#.*)?$`)
+
+// tomlKeyRegex matches "key = value" lines
+var tomlKeyRegex = regexp.MustCompile(`^(\s*)("?[A-Za-z0-9_\-]+"?)\s*=\s*(.*)$`)
+
+// indexTOMLPositions records the position of table headers, keys and the elements of
+// arrays of tables (both [[headers]] and inline arrays of inline tables)
+func indexTOMLPositions(data []byte, positions configPositions) {
+	counts := make(map[string]int) // Array path -> number of elements seen
+	current := ""
+
+	// resolve turns a dotted header into a path, indexing the latest element of arrays
+	resolve := func(dotted string) string {
+		path := ""
+		for _, part := range strings.Split(dotted, ".") {
+			part = strings.Trim(strings.TrimSpace(part), `"'`)
+			if path != "" {
+				path += "."
+			}
+			path += part
+			if count, isArray := counts[path]; isArray {
+				path = fmt.Sprintf("%s[%d]", path, count-1)
+			}
+		}
+		return path
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if match := tomlHeaderRegex.FindStringSubmatch(line); match != nil {
+			column := len(match[1]) + 1
+			if match[2] == "[[" {
+				parent, last := "", match[3]
+				if dot := strings.LastIndex(match[3], "."); dot != -1 {
+					parent, last = resolve(match[3][:dot])+".", match[3][dot+1:]
+				}
+				array := parent + strings.Trim(strings.TrimSpace(last), `"'`)
+				current = fmt.Sprintf("%s[%d]", array, counts[array])
+				counts[array]++
+				positions[array] = [2]int{i + 1, column}
+			} else {
+				current = resolve(match[3])
+			}
+			positions[current] = [2]int{i + 1, column}
+			continue
+		}
+
+		match := tomlKeyRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		key := strings.Trim(match[2], `"`)
+		path := key
+		if current != "" {
+			path = current + "." + key
+		}
+		positions[path] = [2]int{i + 1, len(match[1]) + 1}
+
+		// Index the inline tables of an inline array, which may span several lines
+		value := match[3]
+		if !strings.HasPrefix(value, "[") {
+			continue
+		}
+		offset := len(line) - len(value)
+		depth, element, inString := 0, 0, byte(0)
+		for j := i; j < len(lines); j++ {
+			text := lines[j]
+			start := 0
+			if j == i {
+				start = offset
+			}
+			for k := start; k < len(text); k++ {
+				c := text[k]
+				switch {
+				case inString != 0:
+					if c == '\\' && inString == '"' {
+						k++
+					} else if c == inString {
+						inString = 0
+					}
+				case c == '"' || c == '\'':
+					inString = c
+				case c == '#':
+					k = len(text)
+				case c == '[' || c == '{':
+					if c == '{' && depth == 1 {
+						positions[fmt.Sprintf("%s[%d]", path, element)] = [2]int{j + 1, k + 1}
+						element++
+					}
+					depth++
+				case c == ']' || c == '}':
+					depth--
+				}
+			}
+			if depth <= 0 {
+				i = j
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,575 @@
+package mapper
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ConfigLintIssue is a likely mistake found in a mapping configuration
+type ConfigLintIssue struct {
+	Check    string `json:"check"`    // One of the ConfigCheck constants
+	Severity string `json:"severity"` // ConfigLintWarning or ConfigLintInfo
+	Location string `json:"location"` // e.g. "rule[1].mapping[0]" or "metadata.owner"
+	Message  string `json:"message"`
+}
+
+// Checks run by MappingConfig.Lint
+const (
+	ConfigCheckSelfMapping              = "self-mapping"
+	ConfigCheckChainedMapping           = "chained-mapping"
+	ConfigCheckMappingCycle             = "mapping-cycle"
+	ConfigCheckContradictoryRule        = "contradictory-rule"
+	ConfigCheckShadowedRule             = "shadowed-rule"
+	ConfigCheckUnusedMetadata           = "unused-metadata"
+	ConfigCheckUnreferencedDisabledRule = "unreferenced-disabled-rule"
+)
+
+// Severities of config lint issues
+const (
+	ConfigLintWarning = "warning" // The configuration probably does not do what was intended
+	ConfigLintInfo    = "info"    // The configuration contains dead weight
+)
+
+// ConfigLintOptions tunes MappingConfig.Lint
+type ConfigLintOptions struct {
+	// MetadataKeys lists the metadata keys read by the tools that consume the configuration.
+	// Other keys are reported as unused unless they mention a rule or mapping ID.
+	// The check is skipped when the list is empty.
+	MetadataKeys []string `json:"metadata_keys,omitempty"`
+}
+
+// maxConditionTerms bounds the expansion of nested and/or conditions during analysis
+const maxConditionTerms = 256
+
+// Lint looks for mistakes that Validate accepts: mappings that chain or form cycles, rules
+// whose conditions can never match or that a later rule always overrides, unused
+// metadata and disabled rules nothing refers to.
+func (mc *MappingConfig) Lint(options ConfigLintOptions) []ConfigLintIssue {
+	linter := &configLinter{config: mc}
+
+	linter.lintMappings()
+	linter.lintRules()
+	linter.lintMetadata(options)
+
+	return linter.issues
+}
+
+type configLinter struct {
+	config *MappingConfig
+	issues []ConfigLintIssue
+}
+
+func (l *configLinter) report(check, severity, location, format string, args ...interface{}) {
+	l.issues = append(l.issues, ConfigLintIssue{
+		Check:    check,
+		Severity: severity,
+		Location: location,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// mappingEdge is a field rename from source to target
+type mappingEdge struct {
+	source, target string
+	location       string
+}
+
+// mappingEdges returns the renames of a list of mappings, reporting self-mappings
+func (l *configLinter) mappingEdges(mappings []FieldMapping, location string) []mappingEdge {
+	var edges []mappingEdge
+	for i, mapping := range mappings {
+		mappingLocation := fmt.Sprintf("%s[%d]", location, i)
+		targets := mapping.Targets
+		if mapping.Target != "" {
+			targets = []string{mapping.Target}
+		}
+
+		for _, target := range targets {
+			if target != mapping.Source {
+				edges = append(edges, mappingEdge{source: mapping.Source, target: target, location: mappingLocation})
+			} else if len(targets) == 1 && !mapping.hasValueTransforms() {
+				l.report(ConfigCheckSelfMapping, ConfigLintWarning, mappingLocation,
+					"'%s' is mapped to itself, which has no effect", mapping.Source)
+			}
+		}
+	}
+	return edges
+}
+
+// lintMappings checks the base mappings, and the mappings in effect when each rule applies
+func (l *configLinter) lintMappings() {
+	base := l.mappingEdges(l.config.Mappings, "mapping")
+	l.lintMappingGraph(base, "")
+
+	for i, rule := range l.config.Rules {
+		location := fmt.Sprintf("rule[%d].mapping", i)
+		edges := l.mappingEdges(rule.Mappings, location)
+
+		// Rule mappings replace base mappings of the same source
+		overridden := make(map[string]bool)
+		for _, edge := range edges {
+			overridden[edge.source] = true
+		}
+		for _, mapping := range rule.Mappings {
+			overridden[mapping.Source] = true
+		}
+		for _, edge := range base {
+			if !overridden[edge.source] {
+				edges = append(edges, edge)
+			}
+		}
+
+		// Problems within the base mappings were reported already
+		l.lintMappingGraph(edges, location)
+	}
+}
+
+// lintMappingGraph reports cycles and chains among renames applied together. Only problems
+// involving an edge whose location starts with scope are reported.
+func (l *configLinter) lintMappingGraph(edges []mappingEdge, scope string) {
+	outgoing := make(map[string][]mappingEdge)
+	for _, edge := range edges {
+		outgoing[edge.source] = append(outgoing[edge.source], edge)
+	}
+	inScope := func(path []mappingEdge) bool {
+		for _, edge := range path {
+			if strings.HasPrefix(edge.location, scope) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Report each cycle once, from its first edge
+	inCycle := make(map[mappingEdge]bool)
+	for _, edge := range edges {
+		if inCycle[edge] {
+			continue
+		}
+		cycle := findMappingPath(outgoing, edge.target, edge.source)
+		if cycle == nil {
+			continue
+		}
+		cycle = append([]mappingEdge{edge}, cycle...)
+		fields := []string{edge.source}
+		for _, step := range cycle {
+			inCycle[step] = true
+			fields = append(fields, step.target)
+		}
+		if inScope(cycle) {
+			l.report(ConfigCheckMappingCycle, ConfigLintWarning, edge.location,
+				"mappings form a cycle: %s; each field is renamed once, so the fields trade names", strings.Join(fields, " -> "))
+		}
+	}
+
+	for _, edge := range edges {
+		if inCycle[edge] {
+			continue
+		}
+		for _, next := range outgoing[edge.target] {
+			if inCycle[next] || !inScope([]mappingEdge{edge, next}) {
+				continue
+			}
+			l.report(ConfigCheckChainedMapping, ConfigLintWarning, edge.location,
+				"'%s' is mapped to '%s', which %s maps to '%s'; mappings are applied once, so '%s' becomes '%s', not '%s'",
+				edge.source, edge.target, next.location, next.target, edge.source, edge.target, next.target)
+		}
+	}
+}
+
+// findMappingPath returns the renames leading from one field to another, if any
+func findMappingPath(outgoing map[string][]mappingEdge, from, to string) []mappingEdge {
+	visited := map[string]bool{from: true}
+	var search func(field string) []mappingEdge
+	search = func(field string) []mappingEdge {
+		for _, edge := range outgoing[field] {
+			if edge.target == to {
+				return []mappingEdge{edge}
+			}
+			if visited[edge.target] {
+				continue
+			}
+			visited[edge.target] = true
+			if path := search(edge.target); path != nil {
+				return append([]mappingEdge{edge}, path...)
+			}
+		}
+		return nil
+	}
+	return search(from)
+}
+
+// lintRules reports rules that can never match and rules overridden by a later rule
+func (l *configLinter) lintRules() {
+	terms := make([][][]Condition, len(l.config.Rules))
+	live := make([]bool, len(l.config.Rules))
+
+	for i, rule := range l.config.Rules {
+		location := fmt.Sprintf("rule[%d]", i)
+
+		expanded, ok := conditionTerms(rule.Conditions)
+		if !ok {
+			continue // Too many combinations to analyze
+		}
+
+		var satisfiable [][]Condition
+		var reasons []string
+		for _, term := range expanded {
+			if reason := contradiction(term); reason != "" {
+				reasons = append(reasons, reason)
+				continue
+			}
+			satisfiable = append(satisfiable, term)
+		}
+		terms[i] = satisfiable
+
+		if len(satisfiable) == 0 {
+			message := fmt.Sprintf("rule '%s' can never match: %s", rule.ID, strings.Join(reasons, "; "))
+			if len(reasons) > 1 {
+				message = fmt.Sprintf("rule '%s' can never match: none of its condition branches is satisfiable (%s)", rule.ID, strings.Join(reasons, "; "))
+			}
+			l.report(ConfigCheckContradictoryRule, ConfigLintWarning, location, "%s", message)
+			continue
+		}
+		live[i] = rule.Enabled
+	}
+
+	// Matching rules are applied in file order, so a later rule overrides the mappings of an earlier one
+	for i, rule := range l.config.Rules {
+		if !live[i] {
+			continue
+		}
+		for j := i + 1; j < len(l.config.Rules); j++ {
+			other := l.config.Rules[j]
+			if !live[j] {
+				continue
+			}
+			// A when expression is opaque to the analysis; it only holds whenever an identical one does
+			if other.When != "" && other.When != rule.When {
+				continue
+			}
+			if !mapsAllSources(other.Mappings, rule.Mappings) || !termsImply(terms[i], terms[j]) {
+				continue
+			}
+			l.report(ConfigCheckShadowedRule, ConfigLintWarning, fmt.Sprintf("rule[%d]", i),
+				"rule '%s' is shadowed by rule '%s', which comes later: it matches whenever this rule does and overrides all of its mappings",
+				rule.ID, other.ID)
+			break
+		}
+	}
+}
+
+// lintMetadata reports unused metadata keys and disabled rules nothing refers to
+func (l *configLinter) lintMetadata(options ConfigLintOptions) {
+	ids := make(map[string]bool)
+	for _, rule := range l.config.Rules {
+		ids[rule.ID] = true
+	}
+	for _, mapping := range l.config.Mappings {
+		if mapping.ID != "" {
+			ids[mapping.ID] = true
+		}
+	}
+
+	keys := make([]string, 0, len(l.config.Metadata))
+	for key := range l.config.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	known := make(map[string]bool)
+	for _, key := range options.MetadataKeys {
+		known[key] = true
+	}
+
+	mentioned := make(map[string]bool)
+	for _, key := range keys {
+		referenced := false
+		for _, word := range metadataWords(key, l.config.Metadata[key]) {
+			if ids[word] {
+				mentioned[word] = true
+				referenced = true
+			}
+		}
+		if len(known) > 0 && !known[key] && !referenced {
+			l.report(ConfigCheckUnusedMetadata, ConfigLintInfo, "metadata."+key,
+				"metadata key '%s' is not read by any tool and does not refer to a rule or mapping", key)
+		}
+	}
+
+	for i, rule := range l.config.Rules {
+		if !rule.Enabled && rule.ID != "" && !mentioned[rule.ID] {
+			l.report(ConfigCheckUnreferencedDisabledRule, ConfigLintInfo, fmt.Sprintf("rule[%d]", i),
+				"rule '%s' is disabled and nothing refers to it; remove it or record why it is kept in metadata", rule.ID)
+		}
+	}
+}
+
+// metadataWords returns a metadata key and every string key and value nested in its value
+func metadataWords(key string, value interface{}) []string {
+	words := []string{key}
+	switch v := value.(type) {
+	case string:
+		words = append(words, v)
+	case []interface{}:
+		for _, item := range v {
+			words = append(words, metadataWords("", item)...)
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			words = append(words, metadataWords(k, item)...)
+		}
+	}
+	return words
+}
+
+// conditionTerms expands conditions, which must all hold, into alternative lists of simple
+// conditions (disjunctive normal form). It reports false if there are too many alternatives.
+func conditionTerms(conditions []Condition) ([][]Condition, bool) {
+	terms := [][]Condition{{}}
+	for _, condition := range conditions {
+		var alternatives [][]Condition
+		switch {
+		case condition.Type == "combination" && condition.Operator == "and":
+			var ok bool
+			if alternatives, ok = conditionTerms(condition.Children); !ok {
+				return nil, false
+			}
+		case condition.Type == "combination" && condition.Operator == "or":
+			for _, child := range condition.Children {
+				childTerms, ok := conditionTerms([]Condition{child})
+				if !ok {
+					return nil, false
+				}
+				alternatives = append(alternatives, childTerms...)
+			}
+		case condition.Type == "combination" && condition.Operator == "not" && len(condition.Children) == 1 && invertible(condition.Children[0]):
+			var ok bool
+			if alternatives, ok = conditionTerms([]Condition{negateCondition(condition.Children[0])}); !ok {
+				return nil, false
+			}
+		default:
+			alternatives = [][]Condition{{condition}}
+		}
+
+		var combined [][]Condition
+		for _, term := range terms {
+			for _, alternative := range alternatives {
+				merged := append(append([]Condition(nil), term...), alternative...)
+				combined = append(combined, merged)
+			}
+		}
+		if len(combined) > maxConditionTerms {
+			return nil, false
+		}
+		terms = combined
+	}
+	return terms, true
+}
+
+// invertible reports whether negateCondition can push a negation into a condition
+func invertible(condition Condition) bool {
+	switch condition.Type {
+	case "combination", "command_present":
+		return true
+	case "field_exists":
+		return condition.Operator == "exists" || condition.Operator == "not_exists"
+	}
+	return false
+}
+
+// negateCondition returns a condition that holds exactly when the given one does not, applying
+// De Morgan's laws to combinations. Conditions without an exact inverse are wrapped in 'not'.
+func negateCondition(condition Condition) Condition {
+	negated := condition
+	switch {
+	case condition.Type == "combination" && condition.Operator == "not" && len(condition.Children) == 1:
+		return condition.Children[0]
+	case condition.Type == "combination" && (condition.Operator == "and" || condition.Operator == "or"):
+		negated.Operator = "or"
+		if condition.Operator == "or" {
+			negated.Operator = "and"
+		}
+		negated.Children = make([]Condition, len(condition.Children))
+		for i, child := range condition.Children {
+			negated.Children[i] = negateCondition(child)
+		}
+		return negated
+	case condition.Type == "command_present" || condition.Type == "field_exists" && invertible(condition):
+		negated.Operator = "not_exists"
+		if condition.Operator == "not_exists" {
+			negated.Operator = "exists"
+		}
+		return negated
+	}
+	return Condition{Type: "combination", Operator: "not", Children: []Condition{condition}}
+}
+
+// contradiction explains why a list of simple conditions can never hold together, or returns ""
+func contradiction(term []Condition) string {
+	for _, condition := range term {
+		if reason := unsupportedCondition(condition); reason != "" {
+			return reason
+		}
+	}
+
+	for i, a := range term {
+		for _, b := range term[i+1:] {
+			if isNegationOf(a, b) || isNegationOf(b, a) {
+				return fmt.Sprintf("a %s condition must both hold and not hold", conditionKind(a, b))
+			}
+			if a.Type == "command_present" && b.Type == "command_present" &&
+				strings.EqualFold(fmt.Sprint(a.Value), fmt.Sprint(b.Value)) && (a.Operator == "not_exists") != (b.Operator == "not_exists") {
+				return fmt.Sprintf("command '%v' must be both present and absent", a.Value)
+			}
+			if a.Field == "" || a.Field != b.Field {
+				continue
+			}
+			if reason := conflictingFieldConditions(a, b); reason != "" {
+				return reason
+			}
+			if reason := conflictingFieldConditions(b, a); reason != "" {
+				return reason
+			}
+		}
+	}
+	return ""
+}
+
+// isNegationOf reports whether a is a 'not' condition wrapping b
+func isNegationOf(a, b Condition) bool {
+	return a.Type == "combination" && a.Operator == "not" && len(a.Children) == 1 && reflect.DeepEqual(a.Children[0], b)
+}
+
+// conditionKind names the type of the condition a negation pair is about
+func conditionKind(a, b Condition) string {
+	if isNegationOf(a, b) {
+		return b.Type
+	}
+	return a.Type
+}
+
+// unsupportedCondition explains why a simple condition never matches, or returns ""
+func unsupportedCondition(condition Condition) string {
+	switch {
+	case condition.Type == "field_exists":
+		if condition.Operator != "exists" && condition.Operator != "not_exists" {
+			return fmt.Sprintf("field_exists conditions need the 'exists' or 'not_exists' operator, not '%s'", condition.Operator)
+		}
+	case condition.Type == "field_value" || discoveredValueTypes[condition.Type]:
+		if !valueOperators[condition.Operator] {
+			return fmt.Sprintf("operator '%s' is not supported for %s conditions, so the condition never matches", condition.Operator, condition.Type)
+		}
+	}
+	return ""
+}
+
+// conflictingFieldConditions explains why two conditions on the same field contradict, or returns ""
+func conflictingFieldConditions(a, b Condition) string {
+	switch {
+	case a.Type == "field_exists" && b.Type == "field_exists" && a.Operator != b.Operator:
+		return fmt.Sprintf("field '%s' must both exist and not exist", a.Field)
+	case a.Type == "field_exists" && a.Operator == "not_exists" && b.Type == "field_value":
+		return fmt.Sprintf("field '%s' must not exist but must have a value", a.Field)
+	case a.Type == "field_value" && b.Type == "field_value" && a.Operator == "equals":
+		ignoreCase := a.IgnoreCase || b.IgnoreCase
+		if b.Operator == "equals" && !valuesEqual(a.Value, b.Value, ignoreCase) {
+			return fmt.Sprintf("field '%s' must equal both %s and %s", a.Field, conditionValue(a.Value), conditionValue(b.Value))
+		}
+		if b.Operator == "not_equals" && valuesEqual(a.Value, b.Value, a.IgnoreCase && b.IgnoreCase) {
+			return fmt.Sprintf("field '%s' must both equal and not equal %s", a.Field, conditionValue(a.Value))
+		}
+		if b.Operator == "contains" || b.Operator == "starts_with" || b.Operator == "ends_with" {
+			if !matchScalar(b.Operator, a.Value, b.Value, ignoreCase, nil) {
+				return fmt.Sprintf("field '%s' must equal %s but %s %s", a.Field, conditionValue(a.Value),
+					strings.ReplaceAll(b.Operator, "_", " "), conditionValue(b.Value))
+			}
+		}
+		if numericOperators[b.Operator] && !matchScalar(b.Operator, a.Value, b.Value, false, nil) {
+			return fmt.Sprintf("field '%s' must equal %s, which is not %s %s", a.Field, conditionValue(a.Value),
+				b.Operator, conditionValue(b.Value))
+		}
+	}
+	return ""
+}
+
+func conditionValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return "'" + s + "'"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// termsImply reports whether every alternative of one rule's conditions guarantees some
+// alternative of another's. The check is conservative: it may miss implications.
+func termsImply(terms, others [][]Condition) bool {
+	for _, term := range terms {
+		implied := false
+		for _, other := range others {
+			if termImplies(term, other) {
+				implied = true
+				break
+			}
+		}
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+// termImplies reports whether every condition of other follows from a condition of term
+func termImplies(term, other []Condition) bool {
+	for _, required := range other {
+		found := false
+		for _, condition := range term {
+			if conditionImplies(condition, required) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// conditionImplies reports whether a simple condition guarantees another
+func conditionImplies(a, b Condition) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	if a.Type != b.Type || a.Field != b.Field {
+		return a.Type == "field_value" && b.Type == "field_exists" && a.Field == b.Field && b.Operator == "exists"
+	}
+	if a.IgnoreCase && !b.IgnoreCase {
+		return false
+	}
+
+	switch {
+	case a.Operator == "equals" && b.Operator != "not_equals" && valueOperators[b.Operator]:
+		// A single value that satisfies b
+		return matchScalar(b.Operator, a.Value, b.Value, b.IgnoreCase, nil)
+	case a.Operator == "contains" && b.Operator == "contains":
+		// A value containing a string also contains its substrings
+		return matchScalar("contains", a.Value, b.Value, b.IgnoreCase, nil)
+	}
+	return false
+}
+
+// mapsAllSources reports whether mappings map every source field that others map
+func mapsAllSources(mappings, others []FieldMapping) bool {
+	sources := make(map[string]bool)
+	for _, mapping := range mappings {
+		sources[mapping.Source] = true
+	}
+	for _, mapping := range others {
+		if !sources[mapping.Source] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,278 @@
+package mapper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigRemovals lists inherited entries an overlay removes
+type ConfigRemovals struct {
+	Mappings []string `json:"mappings,omitempty"` // Mapping IDs, or source fields for mappings without an ID
+	Rules    []string `json:"rules,omitempty"`    // Rule IDs
+}
+
+// ResolvedConfig is a flattened mapping configuration together with the file each entry came from
+type ResolvedConfig struct {
+	Config  *MappingConfig `json:"config"`
+	Origins []ConfigOrigin `json:"origins"`
+}
+
+// ConfigOrigin records where an entry of a resolved configuration was defined
+type ConfigOrigin struct {
+	Kind       string   `json:"kind"` // "mapping", "rule" or "datamodel"
+	Key        string   `json:"key"`  // Mapping ID or source, rule ID, or "source->target" datamodel pair
+	File       string   `json:"file"`
+	Overridden []string `json:"overridden,omitempty"` // Files whose entry with the same key was replaced
+	RemovedBy  string   `json:"removed_by,omitempty"` // Overlay that removed the entry; it is not in the resolved config
+}
+
+// Kinds of entry recorded in a ConfigOrigin
+const (
+	ConfigEntryMapping   = "mapping"
+	ConfigEntryRule      = "rule"
+	ConfigEntryDataModel = "datamodel"
+)
+
+// LoadMappingConfigFile loads a mapping configuration file, resolves its extends,
+// include and remove directives and validates the flattened result
+func LoadMappingConfigFile(path string) (*MappingConfig, error) {
+	config, err := readMappingConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := config.Resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if result := resolved.Config.Validate(); !result.Valid {
+		return nil, fmt.Errorf("invalid mapping config: %v", result.Errors)
+	}
+
+	return resolved.Config, nil
+}
+
+// Resolve flattens a composed configuration. file is the path the configuration was loaded
+// from; extends and include paths are relative to its directory. The parent named by extends
+// is applied first, then each include in order, then the removals and finally the entries of
+// the configuration itself. Entries override inherited ones with the same key. A file that is
+// extended or included several times is applied once, where it is first reached.
+func (mc *MappingConfig) Resolve(file string) (*ResolvedConfig, error) {
+	r := &configResolver{
+		result:  &MappingConfig{Mappings: []FieldMapping{}},
+		index:   make(map[string]int),
+		applied: make(map[string]bool),
+	}
+
+	if err := r.apply(mc, file, nil); err != nil {
+		return nil, err
+	}
+
+	return &ResolvedConfig{Config: r.result, Origins: r.origins}, nil
+}
+
+// configResolver accumulates the entries of composed configurations
+type configResolver struct {
+	result  *MappingConfig
+	origins []ConfigOrigin
+	index   map[string]int  // kind + "\x00" + key -> index in origins of the effective entry
+	applied map[string]bool // Absolute paths of the configs already merged
+}
+
+// apply merges a configuration and everything it extends or includes into the result
+func (r *configResolver) apply(mc *MappingConfig, file string, stack []string) error {
+	absolute, err := filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("failed to resolve config path %s: %w", file, err)
+	}
+	for _, seen := range stack {
+		if seen == absolute {
+			return fmt.Errorf("config include cycle: %s -> %s", strings.Join(stack, " -> "), absolute)
+		}
+	}
+	// A config reached again through another include, as in A -> B -> D and A -> C -> D,
+	// is only applied the first time, so that it does not override the configs after it
+	if r.applied[absolute] {
+		return nil
+	}
+	r.applied[absolute] = true
+	stack = append(stack, absolute)
+
+	dir := filepath.Dir(file)
+	parents := mc.Include
+	if mc.Extends != "" {
+		parents = append([]string{mc.Extends}, parents...)
+	}
+	for _, parent := range parents {
+		path := parent
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		config, err := readMappingConfigFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if err := r.apply(config, path, stack); err != nil {
+			return err
+		}
+	}
+
+	if mc.Remove != nil {
+		for _, key := range mc.Remove.Mappings {
+			if err := r.remove(ConfigEntryMapping, key, file); err != nil {
+				return err
+			}
+		}
+		for _, key := range mc.Remove.Rules {
+			if err := r.remove(ConfigEntryRule, key, file); err != nil {
+				return err
+			}
+		}
+	}
+
+	if mc.Version != "" {
+		r.result.Version = mc.Version
+	}
+	if mc.Name != "" {
+		r.result.Name = mc.Name
+	}
+	if mc.Description != "" {
+		r.result.Description = mc.Description
+	}
+	for key, value := range mc.Metadata {
+		if r.result.Metadata == nil {
+			r.result.Metadata = make(map[string]interface{})
+		}
+		r.result.Metadata[key] = value
+	}
+
+	for _, mapping := range mc.Mappings {
+		r.mergeMapping(mapping, file)
+	}
+	for _, rule := range mc.Rules {
+		r.mergeRule(rule, file)
+	}
+	for _, dm := range mc.DataModels {
+		r.mergeDataModel(dm, file)
+	}
+
+	return nil
+}
+
+// mergeMapping adds a mapping, replacing an inherited mapping with the same key
+func (r *configResolver) mergeMapping(mapping FieldMapping, file string) {
+	key := mappingKey(mapping)
+	for i, existing := range r.result.Mappings {
+		if mappingKey(existing) == key {
+			r.result.Mappings[i] = mapping
+			r.override(ConfigEntryMapping, key, file)
+			return
+		}
+	}
+	r.result.Mappings = append(r.result.Mappings, mapping)
+	r.define(ConfigEntryMapping, key, file)
+}
+
+// mergeRule adds a rule, replacing an inherited rule with the same ID
+func (r *configResolver) mergeRule(rule ConditionalRule, file string) {
+	for i, existing := range r.result.Rules {
+		if existing.ID == rule.ID {
+			r.result.Rules[i] = rule
+			r.override(ConfigEntryRule, rule.ID, file)
+			return
+		}
+	}
+	r.result.Rules = append(r.result.Rules, rule)
+	r.define(ConfigEntryRule, rule.ID, file)
+}
+
+// mergeDataModel adds a datamodel mapping, replacing an inherited one for the same datamodel pair
+func (r *configResolver) mergeDataModel(dm DataModelMapping, file string) {
+	key := dm.SourceDataModel + "->" + dm.TargetDataModel
+	for i, existing := range r.result.DataModels {
+		if existing.SourceDataModel+"->"+existing.TargetDataModel == key {
+			r.result.DataModels[i] = dm
+			r.override(ConfigEntryDataModel, key, file)
+			return
+		}
+	}
+	r.result.DataModels = append(r.result.DataModels, dm)
+	r.define(ConfigEntryDataModel, key, file)
+}
+
+// remove drops an inherited mapping or rule
+func (r *configResolver) remove(kind, key, file string) error {
+	removed := false
+	switch kind {
+	case ConfigEntryMapping:
+		for i, existing := range r.result.Mappings {
+			if mappingKey(existing) == key {
+				r.result.Mappings = append(r.result.Mappings[:i], r.result.Mappings[i+1:]...)
+				removed = true
+				break
+			}
+		}
+	case ConfigEntryRule:
+		for i, existing := range r.result.Rules {
+			if existing.ID == key {
+				r.result.Rules = append(r.result.Rules[:i], r.result.Rules[i+1:]...)
+				removed = true
+				break
+			}
+		}
+	}
+	if !removed {
+		return fmt.Errorf("%s: cannot remove %s '%s': it is not defined by an extended or included config", file, kind, key)
+	}
+
+	position := r.index[kind+"\x00"+key]
+	r.origins[position].RemovedBy = file
+	delete(r.index, kind+"\x00"+key)
+	return nil
+}
+
+// define records the origin of a new entry
+func (r *configResolver) define(kind, key, file string) {
+	r.index[kind+"\x00"+key] = len(r.origins)
+	r.origins = append(r.origins, ConfigOrigin{Kind: kind, Key: key, File: file})
+}
+
+// override records that file replaced the effective entry with the same key
+func (r *configResolver) override(kind, key, file string) {
+	origin := &r.origins[r.index[kind+"\x00"+key]]
+	origin.Overridden = append(origin.Overridden, origin.File)
+	origin.File = file
+}
+
+// mappingKey identifies a mapping across composed configs by ID, or by source field without one
+func mappingKey(mapping FieldMapping) string {
+	if mapping.ID != "" {
+		return mapping.ID
+	}
+	return mapping.Source
+}
+
+// readMappingConfigFile reads a mapping configuration without validating it, since composed
+// configs are only complete once resolved. The format follows the file extension, defaulting to JSON.
+func readMappingConfigFile(path string) (*MappingConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping config: %w", err)
+	}
+
+	format, err := ConfigFormatForPath(path)
+	if err != nil {
+		format = FormatJSON
+	}
+
+	config, err := decodeMappingConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return config, nil
+}
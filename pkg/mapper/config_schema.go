@@ -0,0 +1,450 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect of MappingConfigSchema
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaRequired lists the properties each configuration type requires
+var schemaRequired = map[string][]string{
+	"MappingConfig":         {"version"},
+	"FieldMapping":          {"source"},
+	"ValueReplacement":      {"pattern"},
+	"ConditionalRule":       {"id", "mappings"},
+	"Condition":             {"type"},
+	"DataModelMapping":      {"source_datamodel", "target_datamodel"},
+	"DataModelFieldMapping": {"source_field", "target_field"},
+}
+
+// schemaEnums lists the allowed values of string properties, keyed by "Type.property"
+var schemaEnums = map[string][]string{
+	"Condition.type":     conditionTypes,
+	"Condition.operator": conditionOperators,
+	"FieldMapping.case":  {ValueCaseLower, ValueCaseUpper},
+}
+
+// schemaConstraints adds the rules Validate applies across properties
+var schemaConstraints = map[string]map[string]interface{}{
+	"FieldMapping": {
+		"oneOf": []interface{}{
+			map[string]interface{}{"required": []string{"target"}},
+			map[string]interface{}{"required": []string{"targets"}},
+			map[string]interface{}{"required": []string{"expression"}},
+		},
+	},
+	"ConditionalRule": {
+		"anyOf": []interface{}{
+			map[string]interface{}{"required": []string{"conditions"}, "properties": map[string]interface{}{"conditions": map[string]interface{}{"minItems": 1}}},
+			map[string]interface{}{"required": []string{"when"}, "properties": map[string]interface{}{"when": map[string]interface{}{"minLength": 1}}},
+		},
+	},
+	"Condition": {
+		"allOf": []interface{}{
+			map[string]interface{}{
+				"if":   map[string]interface{}{"properties": map[string]interface{}{"type": map[string]interface{}{"enum": []string{"field_value", "field_exists"}}}},
+				"then": map[string]interface{}{"required": []string{"field"}},
+			},
+			map[string]interface{}{
+				"if":   map[string]interface{}{"properties": map[string]interface{}{"type": map[string]interface{}{"enum": []string{"command_present", "query_regex"}}}},
+				"then": map[string]interface{}{"required": []string{"value"}, "properties": map[string]interface{}{"value": map[string]interface{}{"type": "string", "minLength": 1}}},
+			},
+			map[string]interface{}{
+				"if": map[string]interface{}{"properties": map[string]interface{}{"type": map[string]interface{}{"const": "combination"}}},
+				"then": map[string]interface{}{
+					"required": []string{"operator", "children"},
+					"properties": map[string]interface{}{
+						"operator": map[string]interface{}{"enum": []string{"and", "or", "not"}},
+						"children": map[string]interface{}{"minItems": 1},
+					},
+				},
+			},
+			map[string]interface{}{
+				"if": map[string]interface{}{
+					"required":   []string{"operator"},
+					"properties": map[string]interface{}{"type": map[string]interface{}{"const": "combination"}, "operator": map[string]interface{}{"enum": []string{"and", "or"}}},
+				},
+				"then": map[string]interface{}{"properties": map[string]interface{}{"children": map[string]interface{}{"minItems": 2}}},
+			},
+			map[string]interface{}{
+				"if": map[string]interface{}{
+					"required":   []string{"operator"},
+					"properties": map[string]interface{}{"type": map[string]interface{}{"const": "combination"}, "operator": map[string]interface{}{"const": "not"}},
+				},
+				"then": map[string]interface{}{"properties": map[string]interface{}{"children": map[string]interface{}{"maxItems": 1}}},
+			},
+			map[string]interface{}{
+				"if":   map[string]interface{}{"required": []string{"operator"}, "properties": map[string]interface{}{"operator": map[string]interface{}{"const": "in"}}},
+				"then": map[string]interface{}{"required": []string{"value"}, "properties": map[string]interface{}{"value": map[string]interface{}{"type": "array", "minItems": 1}}},
+			},
+			map[string]interface{}{
+				"if": map[string]interface{}{"required": []string{"operator"}, "properties": map[string]interface{}{"operator": map[string]interface{}{"const": "between"}}},
+				"then": map[string]interface{}{
+					"required": []string{"value"},
+					"properties": map[string]interface{}{
+						"value": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}, "minItems": 2, "maxItems": 2},
+					},
+				},
+			},
+			map[string]interface{}{
+				"if":   map[string]interface{}{"required": []string{"operator"}, "properties": map[string]interface{}{"operator": map[string]interface{}{"enum": []string{"gt", "gte", "lt", "lte"}}}},
+				"then": map[string]interface{}{"required": []string{"value"}, "properties": map[string]interface{}{"value": map[string]interface{}{"type": []string{"number", "string"}}}},
+			},
+		},
+	},
+}
+
+// MappingConfigSchema returns a JSON Schema for mapping configurations, generated from the
+// MappingConfig type and the types it contains. Unknown properties are not allowed.
+func MappingConfigSchema() map[string]interface{} {
+	generator := &schemaGenerator{defs: make(map[string]interface{})}
+	schema := generator.structSchema(reflect.TypeOf(MappingConfig{}))
+	schema["$schema"] = JSONSchemaDraft
+	schema["title"] = "SPL Toolkit mapping configuration"
+	schema["$defs"] = generator.defs
+	return schema
+}
+
+// MappingConfigSchemaJSON returns MappingConfigSchema as indented JSON
+func MappingConfigSchemaJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(MappingConfigSchema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaGenerator builds schemas for Go types, collecting struct types as definitions
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		schema := map[string]interface{}{"type": "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema["additionalProperties"] = g.typeSchema(t.Elem())
+		}
+		return schema
+	case reflect.Struct:
+		if _, exists := g.defs[t.Name()]; !exists {
+			g.defs[t.Name()] = nil // Reserve the name so recursive types terminate
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	}
+	// interface{} values accept anything
+	return map[string]interface{}{}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for _, field := range configFields(t) {
+		property := g.typeSchema(field.Type)
+		if values, exists := schemaEnums[t.Name()+"."+field.name]; exists {
+			property["enum"] = values
+		}
+		properties[field.name] = property
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if required, exists := schemaRequired[t.Name()]; exists {
+		schema["required"] = required
+	}
+	for key, value := range schemaConstraints[t.Name()] {
+		schema[key] = value
+	}
+	return schema
+}
+
+// configField is a struct field serialized in mapping configurations
+type configField struct {
+	reflect.StructField
+	name string
+}
+
+// configFields returns the fields of a struct type under their JSON names
+func configFields(t reflect.Type) []configField {
+	var fields []configField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, configField{StructField: field, name: name})
+	}
+	return fields
+}
+
+// configProblem is a strict loading error with the document path it concerns
+type configProblem struct {
+	path    string // e.g. "rules[0].conditons"
+	message string
+}
+
+// LoadMappingConfigStrict loads a mapping configuration like LoadMappingConfigFormat, but also
+// rejects unknown fields, duplicate rule IDs and several mappings for the same source field.
+// All problems are returned as ConfigErrors with their position in the document.
+func LoadMappingConfigStrict(data []byte, format ConfigFormat) (*MappingConfig, error) {
+	jsonData := data
+	if format != FormatJSON {
+		var err error
+		if jsonData, err = toJSON(data, format); err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := decodeMappingConfig(jsonData, FormatJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
+	}
+
+	var problems []configProblem
+	findUnknownFields(document, reflect.TypeOf(MappingConfig{}), "", &problems)
+	problems = append(problems, config.duplicateProblems()...)
+	for _, message := range config.Validate().Errors {
+		problems = append(problems, configProblem{path: validationPath(message), message: message})
+	}
+
+	if len(problems) == 0 {
+		return config, nil
+	}
+
+	positions, err := indexConfigPositions(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	errors := make(ConfigErrors, len(problems))
+	for i, problem := range problems {
+		line, column := positions.lookup(problem.path)
+		errors[i] = ConfigError{Line: line, Column: column, Message: problem.message}
+	}
+	sort.SliceStable(errors, func(i, j int) bool {
+		if errors[i].Line != errors[j].Line {
+			return errors[i].Line < errors[j].Line
+		}
+		return errors[i].Column < errors[j].Column
+	})
+	return nil, errors
+}
+
+// findUnknownFields reports the object keys of a decoded document that the matching
+// configuration type does not define
+func findUnknownFields(value interface{}, t reflect.Type, path string, problems *[]configProblem) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		fields := configFields(t)
+		known := make(map[string]reflect.Type, len(fields))
+		names := make([]string, len(fields))
+		for i, field := range fields {
+			known[field.name] = field.Type
+			names[i] = field.name
+		}
+
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+
+			fieldType, exists := known[key]
+			if !exists {
+				message := fmt.Sprintf("unknown field '%s'", key)
+				if suggestion := closestName(key, names); suggestion != "" {
+					message += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+				}
+				if path != "" {
+					message = validationLocation(path) + ": " + message
+				}
+				*problems = append(*problems, configProblem{path: child, message: message})
+				continue
+			}
+			findUnknownFields(object[key], fieldType, child, problems)
+		}
+
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			findUnknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	}
+}
+
+// duplicateProblems reports rules sharing an ID and mappings sharing a source field
+func (mc *MappingConfig) duplicateProblems() []configProblem {
+	var problems []configProblem
+
+	checkMappings := func(mappings []FieldMapping, location, path string) {
+		first := make(map[string]int)
+		for i, mapping := range mappings {
+			if mapping.Source == "" {
+				continue
+			}
+			if j, exists := first[mapping.Source]; exists {
+				problems = append(problems, configProblem{
+					path:    fmt.Sprintf("%s[%d].source", path, i),
+					message: fmt.Sprintf("%s[%d]: duplicate mapping for source '%s' (first defined by %s[%d])", location, i, mapping.Source, location, j),
+				})
+				continue
+			}
+			first[mapping.Source] = i
+		}
+	}
+
+	checkRules := func(rules []ConditionalRule, location, path string) {
+		first := make(map[string]int)
+		for i, rule := range rules {
+			if rule.ID != "" {
+				if j, exists := first[rule.ID]; exists {
+					problems = append(problems, configProblem{
+						path:    fmt.Sprintf("%s[%d].id", path, i),
+						message: fmt.Sprintf("%s[%d]: duplicate rule id '%s' (first defined by %s[%d])", location, i, rule.ID, location, j),
+					})
+				} else {
+					first[rule.ID] = i
+				}
+			}
+			checkMappings(rule.Mappings, fmt.Sprintf("%s[%d].mapping", location, i), fmt.Sprintf("%s[%d].mappings", path, i))
+		}
+	}
+
+	checkMappings(mc.Mappings, "mapping", "mappings")
+	checkRules(mc.Rules, "rule", "rules")
+
+	for i, dm := range mc.DataModels {
+		location, path := fmt.Sprintf("datamodel[%d]", i), fmt.Sprintf("datamodels[%d]", i)
+		first := make(map[string]int)
+		for j, fm := range dm.FieldMappings {
+			if previous, exists := first[fm.SourceField]; exists && fm.SourceField != "" {
+				problems = append(problems, configProblem{
+					path:    fmt.Sprintf("%s.field_mappings[%d].source_field", path, j),
+					message: fmt.Sprintf("%s.field_mapping[%d]: duplicate mapping for source field '%s' (first defined by field_mapping[%d])", location, j, fm.SourceField, previous),
+				})
+				continue
+			}
+			first[fm.SourceField] = j
+		}
+		checkRules(dm.ConditionalMappings, location+".conditional_mapping", path+".conditional_mappings")
+	}
+
+	return problems
+}
+
+// validationLocation turns a document path into the location format of validation messages,
+// e.g. "rules[0].conditions[1]" becomes "rule[0].condition[1]"
+func validationLocation(path string) string {
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		name, index, _ := strings.Cut(segment, "[")
+		for singular, key := range validationPathKeys {
+			if key == name {
+				name = singular
+				break
+			}
+		}
+		if index != "" {
+			name += "[" + index
+		}
+		segments[i] = name
+	}
+	return strings.Join(segments, ".")
+}
+
+// closestName returns the closest candidate within a few edits of name, if any. Names of up
+// to four characters allow one edit and longer ones about one edit per three characters, so
+// that a short unknown name is not taken for a misspelling of an unrelated short name.
+func closestName(name string, candidates []string) string {
+	best, bestDistance := "", maxNameEdits(name)+1
+	for _, candidate := range candidates {
+		if distance := editDistance(name, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// maxNameEdits returns the number of edits closestName allows for a name
+func maxNameEdits(name string) int {
+	if len(name) <= 4 {
+		return 1
+	}
+	return len(name) / 3
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
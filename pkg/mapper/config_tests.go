@@ -0,0 +1,186 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfigTestSuite is a set of golden query fixtures shipped with a mapping configuration
+type ConfigTestSuite struct {
+	Config string           `json:"config,omitempty"` // Mapping config file; LoadConfigTestSuite makes it relative to the working directory
+	Tests  []ConfigTestCase `json:"tests"`
+}
+
+// ConfigTestCase is a query together with the output a mapping configuration must produce for it
+type ConfigTestCase struct {
+	Name         string                 `json:"name,omitempty"`
+	Query        string                 `json:"query"`
+	Context      map[string]interface{} `json:"context,omitempty"`       // Mapping context; discovered from the query when omitted
+	Expected     string                 `json:"expected,omitempty"`      // Expected mapped query
+	ExpectedInfo *QueryInfo             `json:"expected_info,omitempty"` // Expected discovery results; only the listed categories are compared
+}
+
+// ConfigTestResult is the outcome of a ConfigTestCase
+type ConfigTestResult struct {
+	Name   string   `json:"name"`
+	Query  string   `json:"query"`
+	Passed bool     `json:"passed"`
+	Mapped string   `json:"mapped,omitempty"`
+	Diffs  []string `json:"diffs,omitempty"` // How the output differs from the expectations
+	Error  string   `json:"error,omitempty"`
+}
+
+// LoadConfigTestSuite reads a fixture file in JSON, YAML or TOML, chosen by extension.
+// Unknown fields are rejected so that a misspelled expectation cannot pass silently.
+func LoadConfigTestSuite(path string) (*ConfigTestSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test suite: %w", err)
+	}
+
+	format, err := ConfigFormatForPath(path)
+	if err != nil {
+		format = FormatJSON
+	}
+	if format != FormatJSON {
+		if data, err = toJSON(data, format); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var suite ConfigTestSuite
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&suite); err != nil {
+		return nil, fmt.Errorf("%s: failed to unmarshal test suite: %w", path, err)
+	}
+
+	for i, test := range suite.Tests {
+		if strings.TrimSpace(test.Query) == "" {
+			return nil, fmt.Errorf("%s: test[%d]: query is required", path, i)
+		}
+		if test.Expected == "" && test.ExpectedInfo == nil {
+			return nil, fmt.Errorf("%s: test[%d]: expected or expected_info is required", path, i)
+		}
+	}
+
+	if suite.Config != "" && !filepath.IsAbs(suite.Config) {
+		suite.Config = filepath.Join(filepath.Dir(path), suite.Config)
+	}
+
+	return &suite, nil
+}
+
+// Run maps and discovers every test query with the configuration and compares the results
+// with the expectations
+func (s *ConfigTestSuite) Run(config *MappingConfig) []ConfigTestResult {
+	m := NewWithConfig(config)
+
+	results := make([]ConfigTestResult, 0, len(s.Tests))
+	for i, test := range s.Tests {
+		result := ConfigTestResult{Name: test.Name, Query: test.Query}
+		if result.Name == "" {
+			result.Name = fmt.Sprintf("test[%d]", i)
+		}
+
+		if test.Expected != "" {
+			var mapped string
+			var err error
+			if test.Context != nil {
+				mapped, err = m.MapQueryWithContext(test.Query, test.Context)
+			} else {
+				mapped, err = m.MapQuery(test.Query)
+			}
+			if err != nil {
+				result.Error = err.Error()
+				results = append(results, result)
+				continue
+			}
+
+			result.Mapped = mapped
+			if mapped != test.Expected {
+				// The same unified diff rewrite-app prints, with the expected query as the original
+				result.Diffs = append(result.Diffs, unifiedDiff("mapped query", []byte(test.Expected+"\n"), []byte(mapped+"\n")))
+			}
+		}
+
+		if test.ExpectedInfo != nil {
+			info, err := m.DiscoverQuery(test.Query)
+			if err != nil {
+				result.Error = err.Error()
+				results = append(results, result)
+				continue
+			}
+			result.Diffs = append(result.Diffs, diffQueryInfo(test.ExpectedInfo, info)...)
+		}
+
+		result.Passed = len(result.Diffs) == 0
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// diffQueryInfo compares the categories listed in expected with the discovered ones, ignoring order
+func diffQueryInfo(expected, actual *QueryInfo) []string {
+	categories := []struct {
+		name             string
+		expected, actual []string
+	}{
+		{"datamodels", expected.DataModels, actual.DataModels},
+		{"datasets", expected.Datasets, actual.Datasets},
+		{"lookups", expected.Lookups, actual.Lookups},
+		{"macros", expected.Macros, actual.Macros},
+		{"sources", expected.Sources, actual.Sources},
+		{"sourcetypes", expected.SourceTypes, actual.SourceTypes},
+		{"indexes", expected.Indexes, actual.Indexes},
+		{"hosts", expected.Hosts, actual.Hosts},
+		{"input_fields", expected.InputFields, actual.InputFields},
+		{"commands", expected.Commands, actual.Commands},
+	}
+
+	var diffs []string
+	for _, category := range categories {
+		if category.expected == nil {
+			continue
+		}
+
+		missing := subtractStrings(category.expected, category.actual)
+		unexpected := subtractStrings(category.actual, category.expected)
+		if len(missing) == 0 && len(unexpected) == 0 {
+			continue
+		}
+
+		diff := category.name + ":"
+		if len(missing) > 0 {
+			diff += fmt.Sprintf(" missing %v", missing)
+		}
+		if len(unexpected) > 0 {
+			diff += fmt.Sprintf(" unexpected %v", unexpected)
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// subtractStrings returns the sorted values of a that are not in b
+func subtractStrings(a, b []string) []string {
+	present := make(map[string]bool, len(b))
+	for _, value := range b {
+		present[value] = true
+	}
+
+	var result []string
+	for _, value := range a {
+		if !present[value] {
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,352 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CoverageConfig maps the onboarded data sources to the fields they produce and the
+// ATT&CK data components they provide
+type CoverageConfig struct {
+	DataSources []CoverageDataSource `json:"data_sources"`
+}
+
+// CoverageDataSource is an onboarded data source. Detections rely on it when they search
+// one of its sourcetypes or datamodels.
+type CoverageDataSource struct {
+	Name           string   `json:"name"`
+	Sourcetypes    []string `json:"sourcetypes,omitempty"`     // May contain * wildcards
+	Datamodels     []string `json:"datamodels,omitempty"`      // Datamodels or "Datamodel.Dataset" it populates
+	Fields         []string `json:"fields,omitempty"`          // CIM fields its sourcetypes produce
+	DataComponents []string `json:"data_components,omitempty"` // ATT&CK data components, e.g. "Process Creation"
+}
+
+// Detection is a named search
+type Detection struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// DetectionCoverage lists the data a detection relies on
+type DetectionCoverage struct {
+	Detection      string   `json:"detection"`
+	DataSources    []string `json:"data_sources"`
+	DataComponents []string `json:"data_components"`
+	Sourcetypes    []string `json:"sourcetypes,omitempty"`
+	Datamodels     []string `json:"datamodels,omitempty"` // Datamodels and "Datamodel.Dataset" references
+	// UnmatchedSourcetypes and UnmatchedDatamodels are searched by the detection but not
+	// provided by any onboarded data source
+	UnmatchedSourcetypes []string `json:"unmatched_sourcetypes,omitempty"`
+	UnmatchedDatamodels  []string `json:"unmatched_datamodels,omitempty"`
+	RequiredFields       []string `json:"required_fields"`
+	MissingFields        []string `json:"missing_fields"`  // Required fields no matched data source produces
+	Error                string   `json:"error,omitempty"` // Discovery error; the other lists are empty
+}
+
+// CoverageReport is the coverage matrix of a set of detections
+type CoverageReport struct {
+	Detections     []DetectionCoverage `json:"detections"`
+	DataComponents []string            `json:"data_components"` // Every configured or used data component, sorted
+	// Matrix lists the detections that rely on each data component
+	Matrix map[string][]string `json:"matrix"`
+	// UncoveredDataComponents are configured data components no detection relies on
+	UncoveredDataComponents []string `json:"uncovered_data_components"`
+}
+
+// coverageDefaultFields are present on every event, so detections do not require them from
+// a data source
+var coverageDefaultFields = map[string]bool{
+	"eventtype": true, "host": true, "index": true, "linecount": true, "punct": true,
+	"source": true, "sourcetype": true, "splunk_server": true, "tag": true,
+}
+
+// LoadCoverageConfig loads a coverage configuration from a JSON, YAML or TOML file
+func LoadCoverageConfig(path string) (*CoverageConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coverage config: %w", err)
+	}
+
+	format, err := ConfigFormatForPath(path)
+	if err != nil {
+		format = FormatJSON
+	}
+	config, err := ParseCoverageConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseCoverageConfig decodes and validates a coverage configuration
+func ParseCoverageConfig(data []byte, format ConfigFormat) (*CoverageConfig, error) {
+	if format != FormatJSON {
+		var err error
+		if data, err = toJSON(data, format); err != nil {
+			return nil, err
+		}
+	}
+
+	var config CoverageConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal coverage config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks that every data source has a unique name and a sourcetype or datamodel
+func (c *CoverageConfig) Validate() error {
+	names := make(map[string]bool)
+	for i, source := range c.DataSources {
+		if strings.TrimSpace(source.Name) == "" {
+			return fmt.Errorf("data_sources[%d]: name is required", i)
+		}
+		if names[source.Name] {
+			return fmt.Errorf("data_sources[%d]: duplicate name %q", i, source.Name)
+		}
+		names[source.Name] = true
+		if len(source.Sourcetypes) == 0 && len(source.Datamodels) == 0 {
+			return fmt.Errorf("data_sources[%d]: %s needs sourcetypes or datamodels", i, source.Name)
+		}
+	}
+	return nil
+}
+
+// LoadDetectionDir loads the searches in the .spl files of a directory and its
+// subdirectories. Detections are named after their path relative to the directory,
+// without the extension.
+func LoadDetectionDir(dir string) ([]Detection, error) {
+	var detections []Detection
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".spl" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		detections = append(detections, Detection{
+			Name:  filepath.ToSlash(strings.TrimSuffix(name, ".spl")),
+			Query: strings.TrimSpace(string(data)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load detections: %w", err)
+	}
+	return detections, nil
+}
+
+// AnalyzeCoverage reports the data each detection relies on and builds the matrix of data
+// components to detections
+func AnalyzeCoverage(config *CoverageConfig, detections []Detection) *CoverageReport {
+	report := &CoverageReport{
+		Detections: make([]DetectionCoverage, 0, len(detections)),
+		Matrix:     make(map[string][]string),
+	}
+
+	components := make(map[string]bool)
+	for _, source := range config.DataSources {
+		for _, component := range source.DataComponents {
+			components[component] = true
+		}
+	}
+
+	m := New()
+	for _, detection := range detections {
+		coverage := analyzeDetectionCoverage(m, config, detection)
+		for _, component := range coverage.DataComponents {
+			components[component] = true
+			report.Matrix[component] = append(report.Matrix[component], detection.Name)
+		}
+		report.Detections = append(report.Detections, coverage)
+	}
+
+	report.DataComponents = sortedSet(components)
+	report.UncoveredDataComponents = []string{}
+	for _, component := range report.DataComponents {
+		if len(report.Matrix[component]) == 0 {
+			report.UncoveredDataComponents = append(report.UncoveredDataComponents, component)
+		}
+	}
+	return report
+}
+
+// WriteCSV writes the matrix with a row per detection and an "x" in the column of each
+// data component it relies on
+func (r *CoverageReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := append([]string{"detection", "data_sources"}, r.DataComponents...)
+	if err := writer.Write(append(header, "missing_fields", "error")); err != nil {
+		return err
+	}
+
+	for _, detection := range r.Detections {
+		uses := make(map[string]bool)
+		for _, component := range detection.DataComponents {
+			uses[component] = true
+		}
+		row := []string{detection.Detection, strings.Join(detection.DataSources, ";")}
+		for _, component := range r.DataComponents {
+			cell := ""
+			if uses[component] {
+				cell = "x"
+			}
+			row = append(row, cell)
+		}
+		row = append(row, strings.Join(detection.MissingFields, ";"), detection.Error)
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func analyzeDetectionCoverage(m *Mapper, config *CoverageConfig, detection Detection) DetectionCoverage {
+	coverage := DetectionCoverage{
+		Detection:      detection.Name,
+		DataSources:    []string{},
+		DataComponents: []string{},
+		RequiredFields: []string{},
+		MissingFields:  []string{},
+	}
+
+	info, err := m.DiscoverQuery(detection.Query)
+	if err != nil {
+		coverage.Error = err.Error()
+		return coverage
+	}
+
+	coverage.Sourcetypes = info.SourceTypes
+	coverage.Datamodels = uniqueStrings(append(append([]string{}, info.DataModels...), info.Datasets...))
+
+	produced := make(map[string]bool)
+	components := make(map[string]bool)
+	matchedSourcetypes := make(map[string]bool)
+	matchedDatamodels := make(map[string]bool)
+	for _, source := range config.DataSources {
+		matched := false
+		for _, sourcetype := range coverage.Sourcetypes {
+			for _, provided := range source.Sourcetypes {
+				if wildcardMatch(provided, sourcetype) || wildcardMatch(sourcetype, provided) {
+					matchedSourcetypes[sourcetype], matched = true, true
+				}
+			}
+		}
+		for _, datamodel := range coverage.Datamodels {
+			for _, provided := range source.Datamodels {
+				if datamodelMatch(provided, datamodel) {
+					matchedDatamodels[datamodel], matched = true, true
+				}
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		coverage.DataSources = append(coverage.DataSources, source.Name)
+		for _, field := range source.Fields {
+			produced[strings.ToLower(field)] = true
+		}
+		for _, component := range source.DataComponents {
+			components[component] = true
+		}
+	}
+	coverage.DataComponents = sortedSet(components)
+
+	for _, sourcetype := range coverage.Sourcetypes {
+		if !matchedSourcetypes[sourcetype] {
+			coverage.UnmatchedSourcetypes = append(coverage.UnmatchedSourcetypes, sourcetype)
+		}
+	}
+	for _, datamodel := range coverage.Datamodels {
+		if !matchedDatamodels[datamodel] {
+			coverage.UnmatchedDatamodels = append(coverage.UnmatchedDatamodels, datamodel)
+		}
+	}
+
+	for _, field := range requiredFields(info, coverage.Datamodels) {
+		coverage.RequiredFields = append(coverage.RequiredFields, field)
+		if !produced[strings.ToLower(field)] {
+			coverage.MissingFields = append(coverage.MissingFields, field)
+		}
+	}
+	return coverage
+}
+
+// requiredFields returns the input fields of a detection without the default fields every
+// event has. Datamodel fields such as Processes.process_name are reduced to their CIM name.
+func requiredFields(info *QueryInfo, datamodels []string) []string {
+	prefixes := make(map[string]bool)
+	for _, datamodel := range datamodels {
+		for _, part := range strings.Split(datamodel, ".") {
+			prefixes[part] = true
+		}
+	}
+
+	var fields []string
+	for _, field := range info.InputFields {
+		if dot := strings.Index(field, "."); dot > 0 && prefixes[field[:dot]] {
+			field = field[strings.LastIndex(field, ".")+1:]
+		}
+		if field == "" || strings.HasPrefix(field, "_") || coverageDefaultFields[strings.ToLower(field)] {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return uniqueStrings(fields)
+}
+
+// datamodelMatch reports whether a data source populating one datamodel or dataset feeds a
+// datamodel or dataset reference. A datamodel matches all of its datasets.
+func datamodelMatch(provided, used string) bool {
+	provided, used = strings.ToLower(provided), strings.ToLower(used)
+	return provided == used || strings.HasPrefix(used, provided+".") || strings.HasPrefix(provided, used+".")
+}
+
+// wildcardMatch reports whether a value matches a case-insensitive pattern where * matches
+// any characters
+func wildcardMatch(pattern, value string) bool {
+	if !strings.Contains(pattern, "*") {
+		return strings.EqualFold(pattern, value)
+	}
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	matched, _ := regexp.MatchString("(?i)^"+strings.Join(parts, ".*")+"$", value)
+	return matched
+}
+
+func sortedSet(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package mapper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeCoverage(t *testing.T) {
+	config, err := ParseCoverageConfig([]byte(`
+data_sources:
+  - name: Windows Security
+    sourcetypes: ["WinEventLog:Security", "XmlWinEventLog:Security"]
+    datamodels: [Authentication]
+    fields: [user, src, dest, action]
+    data_components: [Logon Session Creation, User Account Authentication]
+  - name: Sysmon
+    sourcetypes: ["XmlWinEventLog:Microsoft-Windows-Sysmon/*"]
+    datamodels: [Endpoint.Processes]
+    fields: [dest, user, process_name, process]
+    data_components: [Process Creation]
+  - name: Firewall
+    sourcetypes: ["pan:traffic"]
+    data_components: [Network Connection Creation]
+`), FormatYAML)
+	if err != nil {
+		t.Fatalf("ParseCoverageConfig failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"procs.spl":             "| tstats count from datamodel=Endpoint.Processes where Processes.process_name=cmd.exe by Processes.dest Processes.parent_process",
+		"proxy.spl":             `index=proxy sourcetype="bluecoat*" | stats count by url`,
+		"broken.spl":            "index=web | stats count(",
+		"win/failed_logons.spl": `sourcetype="WinEventLog:Security" EventCode=4625 | stats count by user src`,
+		"README.md":             "not a detection",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	detections, err := LoadDetectionDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDetectionDir failed: %v", err)
+	}
+	report := AnalyzeCoverage(config, detections)
+
+	tests := []struct {
+		detection            string
+		dataSources          []string
+		dataComponents       []string
+		missingFields        []string
+		unmatchedSourcetypes []string
+		hasError             bool
+	}{
+		{detection: "broken", dataSources: []string{}, dataComponents: []string{}, missingFields: []string{}, hasError: true},
+		{detection: "procs", dataSources: []string{"Sysmon"}, dataComponents: []string{"Process Creation"}, missingFields: []string{"parent_process"}},
+		{detection: "proxy", dataSources: []string{}, dataComponents: []string{}, missingFields: []string{"url"}, unmatchedSourcetypes: []string{"bluecoat*"}},
+		{detection: "win/failed_logons", dataSources: []string{"Windows Security"}, dataComponents: []string{"Logon Session Creation", "User Account Authentication"}, missingFields: []string{"EventCode"}},
+	}
+
+	if len(report.Detections) != len(tests) {
+		t.Fatalf("Expected %d detections, got %d", len(tests), len(report.Detections))
+	}
+	for i, tt := range tests {
+		t.Run(tt.detection, func(t *testing.T) {
+			coverage := report.Detections[i]
+			if coverage.Detection != tt.detection {
+				t.Fatalf("Expected detection %s, got %s", tt.detection, coverage.Detection)
+			}
+			if (coverage.Error != "") != tt.hasError {
+				t.Errorf("Expected error %v, got %q", tt.hasError, coverage.Error)
+			}
+			if !reflect.DeepEqual(coverage.DataSources, tt.dataSources) {
+				t.Errorf("Expected data sources %v, got %v", tt.dataSources, coverage.DataSources)
+			}
+			if !reflect.DeepEqual(coverage.DataComponents, tt.dataComponents) {
+				t.Errorf("Expected data components %v, got %v", tt.dataComponents, coverage.DataComponents)
+			}
+			if !reflect.DeepEqual(coverage.MissingFields, tt.missingFields) {
+				t.Errorf("Expected missing fields %v, got %v", tt.missingFields, coverage.MissingFields)
+			}
+			if !reflect.DeepEqual(coverage.UnmatchedSourcetypes, tt.unmatchedSourcetypes) {
+				t.Errorf("Expected unmatched sourcetypes %v, got %v", tt.unmatchedSourcetypes, coverage.UnmatchedSourcetypes)
+			}
+		})
+	}
+
+	if got := report.Matrix["Process Creation"]; !reflect.DeepEqual(got, []string{"procs"}) {
+		t.Errorf("Expected Process Creation to be covered by procs, got %v", got)
+	}
+	if !reflect.DeepEqual(report.UncoveredDataComponents, []string{"Network Connection Creation"}) {
+		t.Errorf("Expected Network Connection Creation to be uncovered, got %v", report.UncoveredDataComponents)
+	}
+
+	var csv bytes.Buffer
+	if err := report.WriteCSV(&csv); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if lines[0] != "detection,data_sources,Logon Session Creation,Network Connection Creation,Process Creation,User Account Authentication,missing_fields,error" {
+		t.Errorf("Unexpected CSV header %q", lines[0])
+	}
+	if lines[2] != "procs,Sysmon,,,x,,parent_process," {
+		t.Errorf("Unexpected CSV row %q", lines[2])
+	}
+
+	invalid := []string{
+		`{"data_sources": [{"sourcetypes": ["a"]}]}`,
+		`{"data_sources": [{"name": "A", "sourcetypes": ["a"]}, {"name": "A", "sourcetypes": ["b"]}]}`,
+		`{"data_sources": [{"name": "A"}]}`,
+		`{"data_sources": [{"name": "A", "sourcetypes": ["a"], "tables": ["b"]}]}`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseCoverageConfig([]byte(data), FormatJSON); err == nil {
+			t.Errorf("Expected an error for %s", data)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Dashboard formats
+const (
+	DashboardSimpleXML = "simplexml"
+	DashboardStudio    = "studio"
+)
+
+// Dashboard holds the searches of a Simple XML or Dashboard Studio dashboard
+type Dashboard struct {
+	Name     string            `json:"name"`
+	File     string            `json:"file,omitempty"` // App-relative path when loaded from an app
+	Format   string            `json:"format"`         // DashboardSimpleXML or DashboardStudio
+	Searches []DashboardSearch `json:"searches"`
+	Panels   []DashboardPanel  `json:"panels"`
+}
+
+// DashboardSearch is a search of a dashboard: a Simple XML <search> or a Dashboard Studio
+// ds.search or ds.chain data source
+type DashboardSearch struct {
+	ID    string `json:"id,omitempty"`   // Search ID or data source ID; empty for inline searches
+	Base  string `json:"base,omitempty"` // ID of the base search a post-process search extends
+	Query string `json:"query"`          // As written, with its $token$ references
+	Line  int    `json:"line"`           // 1-based line of the query in the dashboard source
+}
+
+// DashboardPanel is a panel or input of a dashboard with the searches it displays
+type DashboardPanel struct {
+	Name     string `json:"name"`     // Title, or ID when the panel has no title
+	Searches []int  `json:"searches"` // Indexes into Dashboard.Searches
+}
+
+// LoadDashboard loads a Simple XML (.xml) or Dashboard Studio (.json) dashboard file
+func LoadDashboard(file string) (*Dashboard, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dashboard: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	dashboard, err := ParseDashboard(name, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return dashboard, nil
+}
+
+// LoadAppDashboards loads the dashboards under data/ui/views of an app directory or package.
+// A dashboard in local/ replaces the one of the same name in default/.
+func LoadAppDashboards(appPath string) ([]*Dashboard, error) {
+	_, files, err := readAppFiles(appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*Dashboard)
+	for _, layer := range appConfLayers {
+		var names []string
+		for file := range files {
+			if strings.HasPrefix(file, layer+"/data/ui/views/") {
+				names = append(names, file)
+			}
+		}
+		sort.Strings(names)
+		for _, file := range names {
+			name := strings.TrimSuffix(path.Base(file), path.Ext(file))
+			dashboard, err := ParseDashboard(name, files[file])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			dashboard.File = file
+			byName[name] = dashboard
+		}
+	}
+
+	dashboards := make([]*Dashboard, 0, len(byName))
+	for _, dashboard := range byName {
+		dashboards = append(dashboards, dashboard)
+	}
+	sort.Slice(dashboards, func(i, j int) bool { return dashboards[i].Name < dashboards[j].Name })
+	return dashboards, nil
+}
+
+// ParseDashboard extracts the searches and panels of a dashboard source: Simple XML, Dashboard
+// Studio JSON, or Dashboard Studio JSON wrapped in the <definition> of a version 2 XML
+// dashboard
+func ParseDashboard(name string, data []byte) (*Dashboard, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return parseStudioDashboard(name, data, 0, len(data))
+	}
+	if match := definitionPattern.FindSubmatchIndex(data); match != nil {
+		return parseStudioDashboard(name, data, match[2], match[3])
+	}
+	return parseSimpleXMLDashboard(name, data)
+}
+
+// Chain returns the indexes of the searches a search runs on, base search first, ending with
+// the search itself
+func (d *Dashboard) Chain(search int) ([]int, error) {
+	chain := []int{search}
+	seen := map[int]bool{search: true}
+	for current := search; d.Searches[current].Base != ""; {
+		base := d.searchByID(d.Searches[current].Base)
+		if base < 0 {
+			return nil, fmt.Errorf("unknown base search %q", d.Searches[current].Base)
+		}
+		if seen[base] {
+			return nil, fmt.Errorf("base search %q extends itself", d.Searches[base].ID)
+		}
+		seen[base] = true
+		chain = append([]int{base}, chain...)
+		current = base
+	}
+	return chain, nil
+}
+
+func (d *Dashboard) searchByID(id string) int {
+	for i, search := range d.Searches {
+		if search.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// simpleXMLPanelElements are the Simple XML elements searches are displayed in
+var simpleXMLPanelElements = map[string]bool{"panel": true, "input": true}
+
+// simpleXMLLegacySearches are the Simple XML 1 search elements; a searchPostProcess extends
+// the dashboard's searchTemplate
+var simpleXMLLegacySearches = map[string]DashboardSearch{
+	"searchString":      {},
+	"searchTemplate":    {ID: "searchTemplate"},
+	"searchPostProcess": {Base: "searchTemplate"},
+}
+
+// simpleXMLPanel is a panel being read, named once the dashboard has been read
+type simpleXMLPanel struct {
+	id, title, token string
+	searches         []int
+}
+
+func parseSimpleXMLDashboard(name string, data []byte) (*Dashboard, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	dashboard := &Dashboard{Name: name, Format: DashboardSimpleXML, Searches: []DashboardSearch{}, Panels: []DashboardPanel{}}
+	var panels []*simpleXMLPanel
+	var panelStack []*simpleXMLPanel
+	var search *DashboardSearch // The <search> being read
+	var text *strings.Builder   // Character data being captured
+	var title *string           // Where the captured text goes when it is a panel title
+
+	addSearch := func(s DashboardSearch) {
+		if strings.TrimSpace(s.Query) == "" {
+			return
+		}
+		dashboard.Searches = append(dashboard.Searches, s)
+		if len(panelStack) > 0 {
+			panel := panelStack[len(panelStack)-1]
+			panel.searches = append(panel.searches, len(dashboard.Searches)-1)
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Simple XML dashboard: %w", err)
+		}
+		line, _ := decoder.InputPos()
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			element := token.Name.Local
+			switch {
+			case simpleXMLPanelElements[element]:
+				panel := &simpleXMLPanel{id: xmlAttr(token, "id")}
+				if element == "input" {
+					panel.token = xmlAttr(token, "token")
+				}
+				panels = append(panels, panel)
+				panelStack = append(panelStack, panel)
+			case element == "search":
+				search = &DashboardSearch{ID: xmlAttr(token, "id"), Base: xmlAttr(token, "base")}
+			case element == "query" && search != nil:
+				search.Line = line
+				text = &strings.Builder{}
+			case element == "title" || element == "label":
+				if len(panelStack) > 0 && panelStack[len(panelStack)-1].title == "" {
+					title = &panelStack[len(panelStack)-1].title
+					text = &strings.Builder{}
+				}
+			default:
+				if legacy, ok := simpleXMLLegacySearches[element]; ok {
+					search = &DashboardSearch{ID: legacy.ID, Base: legacy.Base, Line: line}
+					text = &strings.Builder{}
+				}
+			}
+		case xml.CharData:
+			if text != nil {
+				text.Write(token)
+			}
+		case xml.EndElement:
+			element := token.Name.Local
+			switch {
+			case simpleXMLPanelElements[element] && len(panelStack) > 0:
+				panelStack = panelStack[:len(panelStack)-1]
+			case element == "query" && search != nil && text != nil:
+				search.Query = strings.TrimSpace(text.String())
+				text = nil
+			case element == "search" && search != nil:
+				addSearch(*search)
+				search = nil
+			case (element == "title" || element == "label") && title != nil:
+				*title = strings.TrimSpace(text.String())
+				title, text = nil, nil
+			default:
+				if _, ok := simpleXMLLegacySearches[element]; ok && search != nil && text != nil {
+					search.Query = strings.TrimSpace(text.String())
+					addSearch(*search)
+					search, text = nil, nil
+				}
+			}
+		}
+	}
+
+	for i, panel := range panels {
+		if len(panel.searches) == 0 {
+			continue
+		}
+		name := panel.title
+		switch {
+		case name != "":
+		case panel.id != "":
+			name = panel.id
+		case panel.token != "":
+			name = "input " + panel.token
+		default:
+			name = fmt.Sprintf("panel %d", i+1)
+		}
+		dashboard.Panels = append(dashboard.Panels, DashboardPanel{Name: name, Searches: panel.searches})
+	}
+	return dashboard, nil
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// studioDefinition is the part of a Dashboard Studio definition that holds searches
+type studioDefinition struct {
+	DataSources map[string]struct {
+		Type    string `json:"type"`
+		Options struct {
+			Query  string `json:"query"`
+			Extend string `json:"extend"`
+		} `json:"options"`
+	} `json:"dataSources"`
+	Visualizations map[string]studioPanel `json:"visualizations"`
+	Inputs         map[string]studioPanel `json:"inputs"`
+}
+
+type studioPanel struct {
+	Title       string            `json:"title"`
+	DataSources map[string]string `json:"dataSources"`
+}
+
+// parseStudioDashboard extracts the ds.search and ds.chain data sources of the Dashboard
+// Studio definition held in data[start:end], with the visualizations and inputs using them
+func parseStudioDashboard(name string, data []byte, start, end int) (*Dashboard, error) {
+	var definition studioDefinition
+	if err := json.Unmarshal(data[start:end], &definition); err != nil {
+		return nil, fmt.Errorf("invalid Dashboard Studio definition: %w", err)
+	}
+	queries, err := studioQueries(data, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := &Dashboard{Name: name, Format: DashboardStudio, Searches: []DashboardSearch{}, Panels: []DashboardPanel{}}
+	index := make(map[string]int)
+	for _, query := range queries {
+		source := definition.DataSources[query.location]
+		search := DashboardSearch{
+			ID:    query.location,
+			Query: source.Options.Query,
+			Line:  1 + bytes.Count(data[:query.start], []byte("\n")),
+		}
+		if source.Type == "ds.chain" {
+			search.Base = source.Options.Extend
+		}
+		index[search.ID] = len(dashboard.Searches)
+		dashboard.Searches = append(dashboard.Searches, search)
+	}
+
+	for _, panels := range []map[string]studioPanel{definition.Visualizations, definition.Inputs} {
+		ids := make([]string, 0, len(panels))
+		for id := range panels {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			panel := DashboardPanel{Name: panels[id].Title, Searches: []int{}}
+			if panel.Name == "" {
+				panel.Name = id
+			}
+			roles := make([]string, 0, len(panels[id].DataSources))
+			for role := range panels[id].DataSources {
+				roles = append(roles, role)
+			}
+			sort.Strings(roles)
+			for _, role := range roles {
+				if i, ok := index[panels[id].DataSources[role]]; ok {
+					panel.Searches = append(panel.Searches, i)
+				}
+			}
+			if len(panel.Searches) > 0 {
+				dashboard.Panels = append(dashboard.Panels, panel)
+			}
+		}
+	}
+	return dashboard, nil
+}
@@ -0,0 +1,466 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Types of the placeholders dashboard tokens are replaced with
+const (
+	DashboardTokenValue  = "value"  // A search term or field value: __tok_name__
+	DashboardTokenField  = "field"  // A field name: __tok_name__
+	DashboardTokenString = "string" // Part of a quoted string: __tok_name__
+	DashboardTokenQuoted = "quoted" // A $name|s$ reference, which Splunk quotes: "__tok_name__"
+	DashboardTokenTime   = "time"   // A time modifier such as earliest=$name$: -24h
+	DashboardTokenSpan   = "span"   // A time span such as span=$name$: 1h
+	DashboardTokenNumber = "number" // A count or comparison operand: 1
+	DashboardTokenSearch = "search" // A whole command after a pipe: search __tok_name__
+)
+
+// DashboardToken is a $token$ reference of a dashboard search with the placeholder it is
+// replaced with so the search parses
+type DashboardToken struct {
+	Name        string `json:"name"` // Token name, without its $ signs and filter
+	Type        string `json:"type"` // One of the DashboardToken types
+	Placeholder string `json:"placeholder"`
+}
+
+// dashboardTokenPattern matches $name$ token references, with an optional |filter
+var dashboardTokenPattern = regexp.MustCompile(`\$([A-Za-z0-9_.:-]+)(?:\|([a-z]+))?\$`)
+
+// tokenNameInvalid matches the characters of token names that identifiers cannot hold
+var tokenNameInvalid = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Text before a token reference that types its placeholder
+var (
+	timeTokenContext   = regexp.MustCompile(`(?i)\b(earliest|latest|_index_earliest|_index_latest|starttime|endtime)\s*=\s*$`)
+	spanTokenContext   = regexp.MustCompile(`(?i)\b(span|maxspan|maxpause)\s*=\s*$`)
+	numberTokenContext = regexp.MustCompile(`(?i)(\b(head|tail)\s+|\b(limit|bins|maxevents|max|count)\s*=\s*|[<>]=?\s*)$`)
+	fieldTokenContext  = regexp.MustCompile(`(?i)(\b(by|as|table|fields|dedup|sort|rename|top|rare)\s+([+-]\s*)?|[A-Za-z_]\(\s*)$`)
+	comparisonAfter    = regexp.MustCompile(`^\s*(!=|=|<|>)`)
+)
+
+// tokenSubstitution is a token reference replaced with a placeholder
+type tokenSubstitution struct {
+	start, end             int // Character offsets of the reference in the query
+	parsedStart, parsedEnd int // Character offsets of the placeholder in the substituted query
+}
+
+// dashboardQuery is a dashboard search with its tokens replaced by placeholders
+type dashboardQuery struct {
+	query         string
+	text          string
+	tokens        []DashboardToken
+	substitutions []tokenSubstitution
+	restore       *strings.Replacer // Replaces __tok_name__ placeholders with $name$
+}
+
+// SubstituteDashboardTokens replaces the $token$ references of a dashboard search with
+// placeholders typed by where they appear, so the search parses. Tokens used as values, field
+// names and whole commands become identifiers such as __tok_name__; tokens used as time
+// modifiers, spans and numbers become values of that type.
+func SubstituteDashboardTokens(query string) (string, []DashboardToken) {
+	substituted := substituteDashboardTokens(query)
+	return substituted.text, substituted.tokens
+}
+
+func substituteDashboardTokens(query string) *dashboardQuery {
+	result := &dashboardQuery{query: query, tokens: []DashboardToken{}}
+	identifiers := make(map[string]string) // Token name to placeholder identifier
+	taken := make(map[string]bool)
+	seen := make(map[DashboardToken]bool)
+	var restore []string
+	var text strings.Builder
+	last, runes, parsedRunes := 0, 0, 0
+
+	for _, match := range dashboardTokenPattern.FindAllStringSubmatchIndex(query, -1) {
+		start, end := match[0], match[1]
+		name := query[match[2]:match[3]]
+		filter := ""
+		if match[4] >= 0 {
+			filter = query[match[4]:match[5]]
+		}
+
+		identifier, ok := identifiers[name]
+		if !ok {
+			base := "__tok_" + tokenNameInvalid.ReplaceAllString(name, "_")
+			identifier = base + "__"
+			for i := 2; taken[identifier]; i++ {
+				identifier = fmt.Sprintf("%s_%d__", base, i)
+			}
+			identifiers[name], taken[identifier] = identifier, true
+			restore = append(restore, identifier, "$"+name+"$")
+		}
+
+		tokenType := dashboardTokenType(query[:start], query[end:], filter)
+		placeholder := identifier
+		switch tokenType {
+		case DashboardTokenQuoted:
+			placeholder = `"` + identifier + `"`
+		case DashboardTokenSearch:
+			placeholder = "search " + identifier
+		case DashboardTokenTime:
+			placeholder = "-24h"
+		case DashboardTokenSpan:
+			placeholder = "1h"
+		case DashboardTokenNumber:
+			placeholder = "1"
+		}
+		token := DashboardToken{Name: name, Type: tokenType, Placeholder: placeholder}
+		if !seen[token] {
+			seen[token] = true
+			result.tokens = append(result.tokens, token)
+		}
+
+		between := utf8.RuneCountInString(query[last:start])
+		substitution := tokenSubstitution{start: runes + between, parsedStart: parsedRunes + between}
+		substitution.end = substitution.start + utf8.RuneCountInString(query[start:end])
+		substitution.parsedEnd = substitution.parsedStart + utf8.RuneCountInString(placeholder)
+		result.substitutions = append(result.substitutions, substitution)
+		runes, parsedRunes = substitution.end, substitution.parsedEnd
+
+		text.WriteString(query[last:start])
+		text.WriteString(placeholder)
+		last = end
+	}
+	text.WriteString(query[last:])
+	result.text = text.String()
+	result.restore = strings.NewReplacer(restore...)
+	return result
+}
+
+// dashboardTokenType types a token reference from the text around it
+func dashboardTokenType(before, after, filter string) string {
+	switch {
+	case filter == "s":
+		return DashboardTokenQuoted
+	case inQuotedString(before):
+		return DashboardTokenString
+	}
+
+	// The contexts are anchored at the end of the text before the reference
+	if len(before) > 64 {
+		before = before[len(before)-64:]
+	}
+	switch {
+	case strings.HasSuffix(strings.TrimRight(before, " \t\r\n"), "|"):
+		return DashboardTokenSearch
+	case timeTokenContext.MatchString(before):
+		return DashboardTokenTime
+	case spanTokenContext.MatchString(before):
+		return DashboardTokenSpan
+	case numberTokenContext.MatchString(before):
+		return DashboardTokenNumber
+	case fieldTokenContext.MatchString(before) || comparisonAfter.MatchString(after):
+		return DashboardTokenField
+	}
+	return DashboardTokenValue
+}
+
+// inQuotedString reports whether the end of a search is inside a double-quoted string
+func inQuotedString(text string) bool {
+	quoted := false
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		}
+	}
+	return quoted
+}
+
+// originalOffset returns the character offset in the query of a character offset in the
+// substituted query. Offsets inside a placeholder map to the start of its token reference, or
+// to its end when end is set.
+func (q *dashboardQuery) originalOffset(parsed int, end bool) int {
+	shift := 0
+	for _, substitution := range q.substitutions {
+		if parsed <= substitution.parsedStart {
+			break
+		}
+		if parsed < substitution.parsedEnd {
+			if end {
+				return substitution.end
+			}
+			return substitution.start
+		}
+		shift = substitution.end - substitution.parsedEnd
+	}
+	return parsed + shift
+}
+
+// restoreMapped replaces the placeholders of a mapped version of the substituted query with
+// their token references. The placeholders are found by diffing the substituted and mapped
+// queries; a placeholder that the mapping changed is an error.
+func (q *dashboardQuery) restoreMapped(mapped string) (string, error) {
+	if len(q.substitutions) == 0 {
+		return mapped, nil
+	}
+
+	query, parsed, changed := []rune(q.query), []rune(q.text), []rune(mapped)
+	ops := diffSlices(parsed, changed)
+	var restored strings.Builder
+	next := 0
+	for i := 0; i < len(ops); {
+		if next < len(q.substitutions) && ops[i].Kind != '+' && ops[i].A == q.substitutions[next].parsedStart {
+			substitution := q.substitutions[next]
+			reference := string(query[substitution.start:substitution.end])
+			length := substitution.parsedEnd - substitution.parsedStart
+			if i+length > len(ops) {
+				return "", fmt.Errorf("the mapping changed the placeholder of %s", reference)
+			}
+			for _, op := range ops[i : i+length] {
+				if op.Kind != '=' {
+					return "", fmt.Errorf("the mapping changed the placeholder of %s", reference)
+				}
+			}
+			restored.WriteString(reference)
+			i += length
+			next++
+			continue
+		}
+		if ops[i].Kind != '-' {
+			restored.WriteRune(changed[ops[i].B])
+		}
+		i++
+	}
+
+	// Placeholders that the diff matched against other text would be typed differently
+	result := restored.String()
+	if substituteDashboardTokens(result).text != mapped {
+		return "", fmt.Errorf("the tokens of the mapped search cannot be restored")
+	}
+	return result, nil
+}
+
+// restoreQueryInfo replaces the placeholders in discovered names with their $token$ references
+func (q *dashboardQuery) restoreQueryInfo(info *QueryInfo) {
+	for _, list := range [][]string{
+		info.DataModels, info.Datasets, info.Lookups, info.Macros, info.Sources,
+		info.SourceTypes, info.Indexes, info.Hosts, info.InputFields,
+	} {
+		for i, name := range list {
+			list[i] = q.restore.Replace(name)
+		}
+	}
+}
+
+// postProcessText returns a post-process search without its leading pipe, and the number of
+// characters removed
+func postProcessText(query string) (string, int) {
+	text := strings.TrimLeft(query, " \t\r\n")
+	text = strings.TrimLeft(strings.TrimPrefix(text, "|"), " \t\r\n")
+	return text, utf8.RuneCountInString(query) - utf8.RuneCountInString(text)
+}
+
+// joinPostProcess appends a post-process search to the pipeline of its base search
+func joinPostProcess(base, postProcess string) string {
+	text, _ := postProcessText(postProcess)
+	return strings.TrimRight(base, " \t\r\n") + " | " + text
+}
+
+// DiscoverPostProcessQuery discovers a post-process search, which runs on the results of its
+// base search. Indexes, source types and the other sources come from the base search. When
+// the base search ends in a transforming command, the fields the post-process search reads
+// are columns of the base's results rather than event fields: InputFields then only holds
+// the base's input fields, and the post-process's fields are returned separately.
+func (m *Mapper) DiscoverPostProcessQuery(base, postProcess string) (*QueryInfo, []string, error) {
+	info, err := m.DiscoverQuery(joinPostProcess(base, postProcess))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hasReportingCommand(base, m.registry) {
+		return info, nil, nil
+	}
+
+	baseInfo, err := m.DiscoverQuery(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	text, _ := postProcessText(postProcess)
+	postInfo, err := m.DiscoverQuery("| " + text)
+	if err != nil {
+		return nil, nil, err
+	}
+	info.InputFields = baseInfo.InputFields
+	return info, postInfo.InputFields, nil
+}
+
+// hasReportingCommand reports whether the top-level pipeline of a search has a transforming
+// command, or a generating command such as tstats, that replaces events with a results table
+func hasReportingCommand(search string, registry *CommandRegistry) bool {
+	text, _ := blankQueryComments(search)
+	query, err := parseLintQuery(text, registry)
+	if err != nil || len(query.Pipelines) == 0 {
+		return false
+	}
+	for _, command := range query.Pipelines[0] {
+		if registry.isReportingCommand(command.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// DashboardAnalysisOptions configures AnalyzeDashboard
+type DashboardAnalysisOptions struct {
+	Mapper *Mapper          // Maps every search when set; its command registry also applies to validation
+	Linter *QueryLinter     // Defaults to a linter with the built-in rules and the mapper's command registry
+	Lint   QueryLintOptions // Rules to disable and severity overrides
+}
+
+// DashboardSearchAnalysis is the analysis of a dashboard search
+type DashboardSearchAnalysis struct {
+	DashboardSearch
+	Panels []string         `json:"panels"` // Names of the panels displaying the search's results
+	Tokens []DashboardToken `json:"tokens"`
+	// ParsedQuery is the analyzed search: the search appended to its base searches, with its
+	// tokens replaced by placeholders
+	ParsedQuery     string     `json:"parsed_query"`
+	Valid           bool       `json:"valid"`
+	ValidationError string     `json:"validation_error,omitempty"`
+	Discovery       *QueryInfo `json:"discovery,omitempty"` // Names show tokens as $token$ references
+	// BaseResultFields are the fields a post-process search reads from the results table of
+	// a transforming base search
+	BaseResultFields []string         `json:"base_result_fields,omitempty"`
+	LintIssues       []QueryLintIssue `json:"lint_issues"` // Positions are in Query
+	MappedQuery      string           `json:"mapped_query,omitempty"`
+	Errors           []string         `json:"errors,omitempty"`
+}
+
+// DashboardAnalysisReport is the analysis of the searches of a dashboard
+type DashboardAnalysisReport struct {
+	Dashboard string                    `json:"dashboard"`
+	File      string                    `json:"file,omitempty"`
+	Format    string                    `json:"format"`
+	Searches  []DashboardSearchAnalysis `json:"searches"`
+	Panels    []DashboardPanel          `json:"panels"`
+}
+
+// AnalyzeDashboard validates, discovers, lints and optionally maps every search of a
+// dashboard. Post-process searches are analyzed appended to their base searches, and their
+// lint issues are reported at their own positions.
+func AnalyzeDashboard(d *Dashboard, options DashboardAnalysisOptions) *DashboardAnalysisReport {
+	m := options.Mapper
+	if m == nil {
+		m = New()
+	}
+	if options.Linter == nil {
+		options.Linter = NewQueryLinter()
+		options.Linter.SetCommandRegistry(m.registry)
+	}
+	validator := NewParserWithRegistry(m.registry)
+
+	report := &DashboardAnalysisReport{
+		Dashboard: d.Name,
+		File:      d.File,
+		Format:    d.Format,
+		Searches:  make([]DashboardSearchAnalysis, len(d.Searches)),
+		Panels:    d.Panels,
+	}
+	for i, search := range d.Searches {
+		analysis := &report.Searches[i]
+		analysis.DashboardSearch = search
+		analysis.Panels = []string{}
+		for _, panel := range d.Panels {
+			for _, index := range panel.Searches {
+				if index == i {
+					analysis.Panels = append(analysis.Panels, panel.Name)
+					break
+				}
+			}
+		}
+		analyzeDashboardSearch(d, i, analysis, m, validator, options)
+	}
+	return report
+}
+
+// analyzeDashboardSearch analyzes one search, recording its failures instead of returning them
+func analyzeDashboardSearch(d *Dashboard, index int, analysis *DashboardSearchAnalysis, m *Mapper, validator *Parser, options DashboardAnalysisOptions) {
+	analysis.Tokens = []DashboardToken{}
+	analysis.LintIssues = []QueryLintIssue{}
+	defer func() {
+		if r := recover(); r != nil {
+			analysis.Errors = append(analysis.Errors, fmt.Sprintf("analysis failed: %v", r))
+		}
+	}()
+
+	chain, err := d.Chain(index)
+	if err != nil {
+		analysis.Errors = append(analysis.Errors, err.Error())
+		return
+	}
+	base, text := "", d.Searches[chain[0]].Query
+	ownStart, skipped := 0, 0 // Where the search's own text starts in text, and what it dropped
+	for _, i := range chain[1:] {
+		base = text
+		text = strings.TrimRight(text, " \t\r\n") + " | "
+		ownStart = utf8.RuneCountInString(text)
+		var post string
+		post, skipped = postProcessText(d.Searches[i].Query)
+		text += post
+	}
+
+	parsed := substituteDashboardTokens(text)
+	analysis.ParsedQuery = parsed.text
+	analysis.Tokens = substituteDashboardTokens(analysis.Query).tokens
+	if err := validator.ValidateQuery(parsed.text); err != nil {
+		analysis.ValidationError = err.Error()
+		return
+	}
+	analysis.Valid = true
+
+	if base == "" {
+		analysis.Discovery, err = m.DiscoverQuery(parsed.text)
+	} else {
+		analysis.Discovery, analysis.BaseResultFields, err = m.DiscoverPostProcessQuery(
+			substituteDashboardTokens(base).text, substituteDashboardTokens(analysis.Query).text)
+	}
+	if err != nil {
+		analysis.Errors = append(analysis.Errors, "discovery: "+err.Error())
+	} else {
+		parsed.restoreQueryInfo(analysis.Discovery)
+		for i, field := range analysis.BaseResultFields {
+			analysis.BaseResultFields[i] = parsed.restore.Replace(field)
+		}
+	}
+
+	if issues, err := options.Linter.Lint(parsed.text, options.Lint); err != nil {
+		analysis.Errors = append(analysis.Errors, "lint: "+err.Error())
+	} else {
+		// Keep the issues in the search's own text, at their positions in Query
+		runes := []rune(analysis.Query)
+		for _, issue := range issues {
+			start := parsed.originalOffset(issue.Offset, false) - ownStart + skipped
+			end := parsed.originalOffset(issue.EndOffset, true) - ownStart + skipped
+			if start < skipped {
+				continue
+			}
+			issue.Offset, issue.EndOffset = min(start, len(runes)), min(end, len(runes))
+			issue.Line, issue.Column = textPosition(runes, issue.Offset)
+			analysis.LintIssues = append(analysis.LintIssues, issue)
+		}
+	}
+
+	if options.Mapper != nil {
+		if analysis.MappedQuery, err = mapSearchPlaceholders(options.Mapper, analysis.Query); err != nil {
+			analysis.Errors = append(analysis.Errors, "mapping: "+err.Error())
+		}
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *DashboardAnalysisReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
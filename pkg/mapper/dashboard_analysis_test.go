@@ -0,0 +1,124 @@
+package mapper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubstituteDashboardTokens(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+		types    []string
+	}{
+		{"index=$idx$ src=$form.ip$", "index=__tok_idx__ src=__tok_form_ip__", []string{DashboardTokenValue, DashboardTokenValue}},
+		{`sourcetype="fw:$env$" user=$user|s$`, `sourcetype="fw:__tok_env__" user="__tok_user__"`, []string{DashboardTokenString, DashboardTokenQuoted}},
+		{"index=fw earliest=$time.earliest$ | timechart span=$span$ count", "index=fw earliest=-24h | timechart span=1h count", []string{DashboardTokenTime, DashboardTokenSpan}},
+		{"index=fw | where count > $min$ | head $n$", "index=fw | where count > 1 | head 1", []string{DashboardTokenNumber, DashboardTokenNumber}},
+		{"index=fw | stats count by $split$ | $filter$", "index=fw | stats count by __tok_split__ | search __tok_filter__", []string{DashboardTokenField, DashboardTokenSearch}},
+		{`index=fw message="` + strings.Repeat("x", 80) + `" | where x > $min$`, `index=fw message="` + strings.Repeat("x", 80) + `" | where x > 1`, []string{DashboardTokenNumber}},
+		{"$field$=1 $form.a$ $form_a$", "__tok_field__=1 __tok_form_a__ __tok_form_a_2__", []string{DashboardTokenField, DashboardTokenValue, DashboardTokenValue}},
+	}
+	for _, tt := range tests {
+		substituted, tokens := SubstituteDashboardTokens(tt.query)
+		if substituted != tt.expected {
+			t.Errorf("SubstituteDashboardTokens(%q) = %q, want %q", tt.query, substituted, tt.expected)
+		}
+		var types []string
+		for _, token := range tokens {
+			types = append(types, token.Type)
+		}
+		if !reflect.DeepEqual(types, tt.types) {
+			t.Errorf("Expected token types %v for %q, got %+v", tt.types, tt.query, tokens)
+		}
+	}
+}
+
+func TestAnalyzeDashboard(t *testing.T) {
+	dashboard := &Dashboard{
+		Name:   "fw",
+		Format: DashboardSimpleXML,
+		Searches: []DashboardSearch{
+			{ID: "base", Query: "sourcetype=fw src_ip=$ip$ | stats count by src_ip", Line: 3},
+			{Base: "base", Query: "| search $filter$ count>1", Line: 6},
+			{Query: "index=$idx$ | stats count(", Line: 9},
+			{Base: "missing", Query: "| head 1", Line: 12},
+		},
+		Panels: []DashboardPanel{{Name: "Sources", Searches: []int{0}}, {Name: "Busy sources", Searches: []int{1}}, {Name: "Broken", Searches: []int{2, 3}}},
+	}
+	m := New()
+	if err := m.LoadMappings([]byte(`[{"source": "src_ip", "target": "src"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	report := AnalyzeDashboard(dashboard, DashboardAnalysisOptions{Mapper: m})
+	if len(report.Searches) != 4 {
+		t.Fatalf("Expected 4 searches, got %d", len(report.Searches))
+	}
+
+	base := report.Searches[0]
+	if !base.Valid || !reflect.DeepEqual(base.Panels, []string{"Sources"}) || base.MappedQuery != "sourcetype=fw src=$ip$ | stats count by src" {
+		t.Errorf("Unexpected base search analysis %+v", base)
+	}
+	if len(base.LintIssues) != 1 || base.LintIssues[0].Rule != "missing-index" || base.LintIssues[0].EndOffset != 25 {
+		t.Errorf("Expected missing-index over the search terms, got %+v", base.LintIssues)
+	}
+
+	post := report.Searches[1]
+	if !post.Valid || post.ParsedQuery != "sourcetype=fw src_ip=__tok_ip__ | stats count by src_ip | search __tok_filter__ count>1" {
+		t.Errorf("Unexpected post-process analysis %+v", post)
+	}
+	if post.Discovery == nil || !reflect.DeepEqual(post.Discovery.SourceTypes, []string{"fw"}) || contains(post.Discovery.InputFields, "count") {
+		t.Errorf("Expected discovery relative to the base search, got %+v", post.Discovery)
+	}
+	if !reflect.DeepEqual(post.BaseResultFields, []string{"count"}) {
+		t.Errorf("Expected count to be read from the base results, got %v", post.BaseResultFields)
+	}
+	if len(post.LintIssues) != 1 || post.LintIssues[0].Rule != "search-after-stats" || post.LintIssues[0].Offset != 2 || post.LintIssues[0].Column != 3 {
+		t.Errorf("Expected only the post-process's lint issues at their own positions, got %+v", post.LintIssues)
+	}
+
+	if broken := report.Searches[2]; broken.Valid || broken.ValidationError == "" || !reflect.DeepEqual(broken.Tokens, []DashboardToken{{Name: "idx", Type: DashboardTokenValue, Placeholder: "__tok_idx__"}}) {
+		t.Errorf("Expected an invalid search with its tokens, got %+v", broken)
+	}
+	if orphan := report.Searches[3]; len(orphan.Errors) != 1 || !strings.Contains(orphan.Errors[0], "unknown base search") {
+		t.Errorf("Expected an unknown base search error, got %+v", orphan)
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteJSON(&out); err != nil || !strings.Contains(out.String(), `"base_result_fields": [`) {
+		t.Errorf("Unexpected JSON (%v):\n%s", err, out.String())
+	}
+}
+
+func TestDiscoverPostProcessQuery(t *testing.T) {
+	m := New()
+
+	// A post-process of a transforming base reads the columns of the base's results
+	info, resultFields, err := m.DiscoverPostProcessQuery("index=fw sourcetype=cisco | stats count by src_ip", "| where count > 5")
+	if err != nil {
+		t.Fatalf("Failed to discover post-process query: %v", err)
+	}
+	if !contains(info.Indexes, "fw") || !contains(info.SourceTypes, "cisco") {
+		t.Errorf("Expected the base search's sources, got %+v", info)
+	}
+	if contains(info.InputFields, "count") || !contains(info.InputFields, "src_ip") {
+		t.Errorf("Expected only the base search's input fields, got %v", info.InputFields)
+	}
+	if !reflect.DeepEqual(resultFields, []string{"count"}) {
+		t.Errorf("Expected count to be read from the base results, got %v", resultFields)
+	}
+	if !contains(info.Commands, "stats") || !contains(info.Commands, "where") {
+		t.Errorf("Expected the commands of the whole pipeline, got %v", info.Commands)
+	}
+
+	// A post-process of a non-transforming base filters its events
+	info, resultFields, err = m.DiscoverPostProcessQuery("index=fw | fields src_ip dest", "search dest=10.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to discover post-process query: %v", err)
+	}
+	if !contains(info.InputFields, "dest") || resultFields != nil {
+		t.Errorf("Expected dest to be an input field, got %v (result fields %v)", info.InputFields, resultFields)
+	}
+}
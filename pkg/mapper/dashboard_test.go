@@ -0,0 +1,98 @@
+package mapper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDashboard(t *testing.T) {
+	simpleXML := `<form version="1.1">
+  <fieldset>
+    <input type="dropdown" token="idx">
+      <label>Index</label>
+      <search><query>| eventcount summarize=false index=* | table index</query></search>
+    </input>
+  </fieldset>
+  <search id="base">
+    <query>index=$idx$ src_ip="$ip$" | stats count by src_ip</query>
+  </search>
+  <row>
+    <panel>
+      <title>Top sources &amp; counts</title>
+      <chart><search base="base"><query><![CDATA[| where count > $min$]]></query></search></chart>
+    </panel>
+    <panel id="raw">
+      <table><search ref="Saved"></search></table>
+      <event><searchString>index=fw</searchString></event>
+    </panel>
+  </row>
+</form>
+`
+	dashboard, err := ParseDashboard("fw", []byte(simpleXML))
+	if err != nil {
+		t.Fatalf("ParseDashboard failed: %v", err)
+	}
+	expected := []DashboardSearch{
+		{Query: "| eventcount summarize=false index=* | table index", Line: 5},
+		{ID: "base", Query: `index=$idx$ src_ip="$ip$" | stats count by src_ip`, Line: 9},
+		{Base: "base", Query: "| where count > $min$", Line: 14},
+		{Query: "index=fw", Line: 18},
+	}
+	if dashboard.Format != DashboardSimpleXML || !reflect.DeepEqual(dashboard.Searches, expected) {
+		t.Errorf("Unexpected Simple XML searches %+v", dashboard.Searches)
+	}
+	panels := []DashboardPanel{{Name: "Index", Searches: []int{0}}, {Name: "Top sources & counts", Searches: []int{2}}, {Name: "raw", Searches: []int{3}}}
+	if !reflect.DeepEqual(dashboard.Panels, panels) {
+		t.Errorf("Unexpected Simple XML panels %+v", dashboard.Panels)
+	}
+	if chain, err := dashboard.Chain(2); err != nil || !reflect.DeepEqual(chain, []int{1, 2}) {
+		t.Errorf("Expected the post-process search to extend base, got %v (%v)", chain, err)
+	}
+
+	studio := `{
+  "dataSources": {
+    "ds_base": {"type": "ds.search", "options": {"query": "index=fw | stats count by src_ip"}},
+    "ds_post": {"type": "ds.chain", "options": {"extend": "ds_base", "query": "| where count > $min$"}},
+    "ds_saved": {"type": "ds.savedSearch", "options": {"ref": "Saved"}}
+  },
+  "visualizations": {
+    "viz_table": {"type": "splunk.table", "title": "Counts", "dataSources": {"primary": "ds_post"}},
+    "viz_single": {"type": "splunk.singlevalue", "dataSources": {"primary": "ds_base"}}
+  }
+}`
+	for _, source := range []string{studio, `<dashboard version="2">
+  <label>Studio</label>
+  <definition><![CDATA[` + studio + `]]></definition>
+</dashboard>`} {
+		dashboard, err := ParseDashboard("studio", []byte(source))
+		if err != nil {
+			t.Fatalf("ParseDashboard failed: %v", err)
+		}
+		line := 3
+		if strings.HasPrefix(source, "<") {
+			line = 5
+		}
+		expected := []DashboardSearch{
+			{ID: "ds_base", Query: "index=fw | stats count by src_ip", Line: line},
+			{ID: "ds_post", Base: "ds_base", Query: "| where count > $min$", Line: line + 1},
+		}
+		if dashboard.Format != DashboardStudio || !reflect.DeepEqual(dashboard.Searches, expected) {
+			t.Errorf("Unexpected Dashboard Studio searches %+v", dashboard.Searches)
+		}
+		panels := []DashboardPanel{{Name: "viz_single", Searches: []int{0}}, {Name: "Counts", Searches: []int{1}}}
+		if !reflect.DeepEqual(dashboard.Panels, panels) {
+			t.Errorf("Unexpected Dashboard Studio panels %+v", dashboard.Panels)
+		}
+	}
+
+	broken := &Dashboard{Searches: []DashboardSearch{{ID: "a", Base: "b", Query: "| head 1"}, {ID: "b", Base: "a", Query: "| head 1"}, {Base: "nope", Query: "| head 1"}}}
+	for i := range broken.Searches {
+		if _, err := broken.Chain(i); err == nil {
+			t.Errorf("Expected an error for the chain of search %d", i)
+		}
+	}
+	if _, err := ParseDashboard("bad", []byte(`{"dataSources": [}`)); err == nil {
+		t.Error("Expected an error for invalid Dashboard Studio JSON")
+	}
+}
@@ -0,0 +1,41 @@
+package mapper
+
+// derivedFieldScope tracks fields created by the query itself (eval assignments,
+// renames, lookup outputs) so later references to them are not treated as input fields.
+// Subsearches and append/join/multisearch branches get their own scope.
+type derivedFieldScope struct {
+	derivedFields      map[string]struct{}
+	derivedFieldsStack []map[string]struct{}
+}
+
+// newDerivedFieldScope creates an empty top-level scope
+func newDerivedFieldScope() derivedFieldScope {
+	return derivedFieldScope{
+		derivedFields:      make(map[string]struct{}),
+		derivedFieldsStack: []map[string]struct{}{},
+	}
+}
+
+func (s *derivedFieldScope) markDerived(fieldName string) {
+	if s.derivedFields == nil {
+		s.derivedFields = make(map[string]struct{})
+	}
+	s.derivedFields[fieldName] = struct{}{}
+}
+
+func (s *derivedFieldScope) isDerived(fieldName string) bool {
+	_, exists := s.derivedFields[fieldName]
+	return exists
+}
+
+func (s *derivedFieldScope) pushDerivedContext() {
+	s.derivedFieldsStack = append(s.derivedFieldsStack, s.derivedFields)
+	s.derivedFields = make(map[string]struct{})
+}
+
+func (s *derivedFieldScope) popDerivedContext() {
+	if len(s.derivedFieldsStack) > 0 {
+		s.derivedFields = s.derivedFieldsStack[len(s.derivedFieldsStack)-1]
+		s.derivedFieldsStack = s.derivedFieldsStack[:len(s.derivedFieldsStack)-1]
+	}
+}
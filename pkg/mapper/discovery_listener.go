@@ -17,49 +17,31 @@ type FieldDiscoveryListener struct {
 	Datasets    []string // Format: "DataModel.Dataset"
 	Lookups     []string
 	Macros      []string
+	Indexes     []string
+	Hosts       []string
+	Commands    []string // Lower-case command names in order of first use
 
 	// Derived field tracking
-	derivedFields      map[string]struct{}
-	derivedFieldsStack []map[string]struct{}
+	derivedFieldScope
+
+	// Custom commands and functions; nil uses the default registry
+	registry *CommandRegistry
 }
 
 // NewFieldDiscoveryListener creates a new field discovery listener
 func NewFieldDiscoveryListener() *FieldDiscoveryListener {
 	return &FieldDiscoveryListener{
-		InputFields:        []string{},
-		SourceTypes:        []string{},
-		Sources:            []string{},
-		DataModels:         []string{},
-		Datasets:           []string{},
-		Lookups:            []string{},
-		Macros:             []string{},
-		derivedFields:      make(map[string]struct{}),
-		derivedFieldsStack: []map[string]struct{}{},
-	}
-}
-
-// Helper methods for derived field tracking
-func (l *FieldDiscoveryListener) markDerived(fieldName string) {
-	if l.derivedFields == nil {
-		l.derivedFields = make(map[string]struct{})
-	}
-	l.derivedFields[fieldName] = struct{}{}
-}
-
-func (l *FieldDiscoveryListener) isDerived(fieldName string) bool {
-	_, exists := l.derivedFields[fieldName]
-	return exists
-}
-
-func (l *FieldDiscoveryListener) pushDerivedContext() {
-	l.derivedFieldsStack = append(l.derivedFieldsStack, l.derivedFields)
-	l.derivedFields = make(map[string]struct{})
-}
-
-func (l *FieldDiscoveryListener) popDerivedContext() {
-	if len(l.derivedFieldsStack) > 0 {
-		l.derivedFields = l.derivedFieldsStack[len(l.derivedFieldsStack)-1]
-		l.derivedFieldsStack = l.derivedFieldsStack[:len(l.derivedFieldsStack)-1]
+		InputFields:       []string{},
+		SourceTypes:       []string{},
+		Sources:           []string{},
+		DataModels:        []string{},
+		Datasets:          []string{},
+		Lookups:           []string{},
+		Macros:            []string{},
+		Indexes:           []string{},
+		Hosts:             []string{},
+		Commands:          []string{},
+		derivedFieldScope: newDerivedFieldScope(),
 	}
 }
 
@@ -156,6 +138,43 @@ func (l *FieldDiscoveryListener) addMacro(macro string) {
 	l.Macros = append(l.Macros, macro)
 }
 
+func (l *FieldDiscoveryListener) addIndex(index string) {
+	if index == "" {
+		return
+	}
+	for _, existing := range l.Indexes {
+		if existing == index {
+			return
+		}
+	}
+	l.Indexes = append(l.Indexes, index)
+}
+
+func (l *FieldDiscoveryListener) addHost(host string) {
+	if host == "" {
+		return
+	}
+	for _, existing := range l.Hosts {
+		if existing == host {
+			return
+		}
+	}
+	l.Hosts = append(l.Hosts, host)
+}
+
+func (l *FieldDiscoveryListener) addCommand(command string) {
+	command = strings.ToLower(command)
+	if command == "" {
+		return
+	}
+	for _, existing := range l.Commands {
+		if existing == command {
+			return
+		}
+	}
+	l.Commands = append(l.Commands, command)
+}
+
 // EnterKEYVALUEOP handles field=value operations
 func (l *FieldDiscoveryListener) EnterKEYVALUEOP(ctx *parser.KEYVALUEOPContext) {
 	fieldName := ctx.Id().GetText()
@@ -163,7 +182,7 @@ func (l *FieldDiscoveryListener) EnterKEYVALUEOP(ctx *parser.KEYVALUEOPContext)
 	// Check if this is within an eval command (field assignment)
 	if parentCtx := ctx.GetParent(); parentCtx != nil {
 		if nextCmd, ok := parentCtx.(*parser.NextCommandContext); ok {
-			if nextCmd.Command() != nil && nextCmd.Command().GetText() == "eval" {
+			if nextCmd.Command() != nil && l.registry.isAssignmentCommand(strings.ToLower(nextCmd.Command().GetText())) {
 				// This is an eval assignment - mark the field as derived
 				l.markDerived(fieldName)
 
@@ -176,6 +195,11 @@ func (l *FieldDiscoveryListener) EnterKEYVALUEOP(ctx *parser.KEYVALUEOPContext)
 		}
 	}
 
+	// Options such as limit=10 are not fields
+	if l.registry.isCommandOption(commandNameFor(ctx), fieldName) {
+		return
+	}
+
 	// Check for special field types
 	switch strings.ToLower(fieldName) {
 	case "sourcetype":
@@ -196,6 +220,18 @@ func (l *FieldDiscoveryListener) EnterKEYVALUEOP(ctx *parser.KEYVALUEOPContext)
 				l.addDataModel(strings.Trim(value.GetText(), "\""))
 			}
 		}
+	case "index":
+		if expr := ctx.Expression(); expr != nil {
+			if value := expr.Value(); value != nil {
+				l.addIndex(strings.Trim(value.GetText(), "\""))
+			}
+		}
+	case "host":
+		if expr := ctx.Expression(); expr != nil {
+			if value := expr.Value(); value != nil {
+				l.addHost(strings.Trim(value.GetText(), "\""))
+			}
+		}
 	default:
 		// Regular field reference
 		l.addInputField(fieldName)
@@ -217,9 +253,13 @@ func (l *FieldDiscoveryListener) extractFieldReferencesFromExpression(expr parse
 		}
 	}
 
-	// Recursively check child expressions
+	// Recursively check child expressions, skipping the value arguments of custom functions
+	call, _ := expr.(*parser.ExpressionContext)
 	for _, child := range expr.GetChildren() {
 		if childExpr, ok := child.(parser.IExpressionContext); ok {
+			if shape, declared := l.registry.functionArgumentShape(call, childExpr); declared && shape != FunctionArgumentField {
+				continue
+			}
 			l.extractFieldReferencesFromExpression(childExpr)
 		}
 	}
@@ -294,8 +334,14 @@ func (l *FieldDiscoveryListener) EnterBYOP(ctx *parser.BYOPContext) {
 
 // EnterInitCommand handles commands that can appear at the beginning of a query (like | inputlookup)
 func (l *FieldDiscoveryListener) EnterInitCommand(ctx *parser.InitCommandContext) {
+	// A query that starts with search terms runs an implicit search
+	if ctx.INIT_COMMAND() == nil {
+		l.addCommand("search")
+	}
+
 	if ctx.INIT_COMMAND() != nil {
 		command := ctx.INIT_COMMAND().GetText()
+		l.addCommand(command)
 		switch strings.ToLower(command) {
 		case "inputlookup":
 			l.handleInputLookupInitCommand(ctx)
@@ -318,6 +364,12 @@ func (l *FieldDiscoveryListener) EnterNextCommand(ctx *parser.NextCommandContext
 	}
 
 	command := ctx.Command().GetText()
+	l.addCommand(command)
+
+	if l.registry.isScopedCommand(command) {
+		// Push context for subqueries
+		l.pushDerivedContext()
+	}
 
 	switch strings.ToLower(command) {
 	case "lookup":
@@ -326,9 +378,6 @@ func (l *FieldDiscoveryListener) EnterNextCommand(ctx *parser.NextCommandContext
 		l.handleRenameCommand(ctx)
 	case "fields":
 		l.handleFieldsCommand(ctx)
-	case "append", "join", "multisearch":
-		// Push context for subqueries
-		l.pushDerivedContext()
 	case "inputlookup":
 		l.handleInputLookupCommand(ctx)
 	case "datamodel":
@@ -348,8 +397,7 @@ func (l *FieldDiscoveryListener) ExitNextCommand(ctx *parser.NextCommandContext)
 		return
 	}
 
-	command := ctx.Command().GetText()
-	if command == "append" || command == "join" || command == "multisearch" {
+	if l.registry.isScopedCommand(ctx.Command().GetText()) {
 		l.popDerivedContext()
 	}
 }
@@ -711,11 +759,14 @@ func (l *FieldDiscoveryListener) isInFieldPosition(ctx *parser.FieldUseContext)
 		switch p := parent.(type) {
 		case *parser.KEYVALUEOPContext:
 			// If we're the left side of a key=value operation, we're a field reference
+			// unless the key is a command option
+			command := commandNameFor(p)
 			if p.Id() != nil && ctx.GetText() == p.Id().GetText() {
-				return true
+				return !l.registry.isCommandOption(command, p.Id().GetText())
 			}
-			// If we're in the expression (right side), we might be a field reference in eval contexts
-			return false
+			// If we're in the expression (right side), we might be a field reference in eval
+			// contexts; options such as rex field= name their input field
+			return l.registry.isFieldValuedOption(command, p.Id().GetText()) && p.Expression().GetStart() == p.Expression().GetStop()
 		case *parser.BYOPContext:
 			// If we're in a "by" clause, we're definitely a field reference
 			return true
@@ -723,10 +774,14 @@ func (l *FieldDiscoveryListener) isInFieldPosition(ctx *parser.FieldUseContext)
 			// Check the command type
 			if p.Command() != nil {
 				cmdText := strings.ToLower(p.Command().GetText())
-				switch cmdText {
-				case "fields":
+				_, custom := l.registry.lookupCustomCommand(cmdText)
+				switch {
+				case cmdText == "fields":
 					return true
-				case "lookup", "inputlookup":
+				case custom && l.registry.isFieldListCommand(cmdText):
+					// Custom commands declared to take field names, except for their keywords
+					return !l.registry.isCommandKeyword(cmdText, ctx.GetText())
+				case cmdText == "lookup" || cmdText == "inputlookup":
 					// Be more careful with lookup contexts
 					return false
 				default:
@@ -917,6 +972,9 @@ func (l *FieldDiscoveryListener) EnterExpression(ctx *parser.ExpressionContext)
 	if ctx.Function() != nil && len(ctx.AllExpression()) > 0 {
 		// This is a function call - extract field references from the arguments
 		for _, argExpr := range ctx.AllExpression() {
+			if shape, declared := l.registry.functionArgumentShape(ctx, argExpr); declared && shape != FunctionArgumentField {
+				continue
+			}
 			l.extractFieldReferencesFromExpression(argExpr)
 		}
 	}
@@ -0,0 +1,230 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/delgado-jacob/spl-toolkit/parser"
+)
+
+// validateTarget checks that exactly one of Target, Targets and Expression is set
+func (fm FieldMapping) validateTarget() error {
+	set := 0
+	if fm.Target != "" {
+		set++
+	}
+	if len(fm.Targets) > 0 {
+		set++
+	}
+	if fm.Expression != "" {
+		set++
+	}
+
+	switch {
+	case set == 0:
+		return fmt.Errorf("target field is required")
+	case set > 1:
+		return fmt.Errorf("only one of target, targets and expression may be set")
+	}
+
+	for i, target := range fm.Targets {
+		if strings.TrimSpace(target) == "" {
+			return fmt.Errorf("targets[%d]: target field is required", i)
+		}
+	}
+
+	return nil
+}
+
+// isExpansion reports whether a mapping replaces a field with a field set or an expression
+func (fm FieldMapping) isExpansion() bool {
+	return fm.Target == "" && (len(fm.Targets) > 0 || fm.Expression != "")
+}
+
+// targetText returns the text a field reference is replaced with in eval-style expressions
+func (fm FieldMapping) targetText() string {
+	if !fm.isExpansion() {
+		return fm.Target
+	}
+	if len(fm.Targets) > 0 {
+		return "coalesce(" + strings.Join(fm.Targets, ", ") + ")"
+	}
+	if isSingleTerm(fm.Expression) {
+		return fm.Expression
+	}
+	return "(" + fm.Expression + ")"
+}
+
+// isSingleTerm reports whether an expression is a single identifier or function call,
+// which can replace a field reference without parentheses
+func isSingleTerm(expression string) bool {
+	expression = strings.TrimSpace(expression)
+	open := strings.IndexByte(expression, '(')
+	if open == -1 {
+		return !strings.ContainsAny(expression, " +-*/%.=<>!,")
+	}
+	if open == 0 || !strings.HasSuffix(expression, ")") {
+		return false
+	}
+
+	depth := 0
+	for i := open; i < len(expression); i++ {
+		switch expression[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			// The call's own parenthesis must close at the very end
+			if depth == 0 && i != len(expression)-1 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// expandField rewrites a read of a field mapped to a field set or an expression.
+// Expression commands use the expression inline, other commands get a preceding eval
+// that computes the field. Filters on field sets are expanded into OR groups when the
+// surrounding operation is exited.
+func (l *FieldMappingListener) expandField(ctx *parser.FieldUseContext, mapping FieldMapping) {
+	command := commandNameFor(ctx)
+	if len(mapping.Targets) > 0 && filterOperation(ctx) != nil && l.registry.isFilterCommand(command) {
+		return
+	}
+
+	token := ctx.IDENTIFIER().GetSymbol()
+	if l.registry.isExpressionCommand(command) {
+		l.replaceToken(token, mapping.targetText(), ReplacementExpansion, mapping.Source, command)
+		return
+	}
+
+	l.insertPrecedingEval(ctx, mapping, command)
+}
+
+// insertPrecedingEval computes an expanded field with an eval placed before the command that reads it
+func (l *FieldMappingListener) insertPrecedingEval(ctx *parser.FieldUseContext, mapping FieldMapping, command string) {
+	token := ctx.IDENTIFIER().GetSymbol()
+
+	var next *parser.NextCommandContext
+	for parent := ctx.GetParent(); parent != nil && next == nil; parent = parent.GetParent() {
+		switch p := parent.(type) {
+		case *parser.NextCommandContext:
+			next = p
+		case *parser.InitCommandContext:
+			l.errors = append(l.errors, fmt.Sprintf(
+				"cannot expand mapping for field '%s' at line %d:%d in %s: the command starts the search, so no eval can precede it",
+				mapping.Source, token.GetLine(), token.GetColumn(), command))
+			return
+		}
+	}
+	if next == nil {
+		return
+	}
+
+	assignment := mapping.Source + "=" + mapping.targetText()
+	index := next.GetStart().GetTokenIndex()
+	l.precedingEvals[index] = append(l.precedingEvals[index], assignment)
+	l.recordReplacement(token, "eval "+assignment, ReplacementExpansion, mapping.Source, command)
+
+	// The field now exists under its source name for the rest of the search
+	l.markDerived(mapping.Source)
+}
+
+// flushPrecedingEvals inserts the evals collected for a command in a single eval before it
+func (l *FieldMappingListener) flushPrecedingEvals(ctx *parser.NextCommandContext) {
+	index := ctx.GetStart().GetTokenIndex()
+	if assignments, exists := l.precedingEvals[index]; exists {
+		l.rewriter.InsertBeforeDefault(index, "eval "+strings.Join(assignments, ", ")+" | ")
+		delete(l.precedingEvals, index)
+	}
+}
+
+// expandFilter replaces a filter operation on a field set with an OR group over its targets
+// (an AND group for negated filters). clause renders the filter for a single target.
+func (l *FieldMappingListener) expandFilter(ctx antlr.ParserRuleContext, mapping FieldMapping, negated bool, clause func(target string) string) {
+	clauses := make([]string, len(mapping.Targets))
+	for i, target := range mapping.Targets {
+		clauses[i] = clause(target)
+	}
+
+	separator := " OR "
+	if negated {
+		separator = " AND "
+	}
+	text := "(" + strings.Join(clauses, separator) + ")"
+
+	start, stop := ctx.GetStart(), ctx.GetStop()
+	original := l.tokenStream.GetTextFromInterval(antlr.NewInterval(start.GetTokenIndex(), stop.GetTokenIndex()))
+	l.rewriter.ReplaceDefault(start.GetTokenIndex(), stop.GetTokenIndex(), text)
+
+	l.replacements = append(l.replacements, MappingReplacement{
+		Kind:        ReplacementExpansion,
+		Field:       mapping.Source,
+		Original:    original,
+		Replacement: text,
+		Offset:      start.GetStart(),
+		Line:        start.GetLine(),
+		Column:      start.GetColumn(),
+		Command:     commandNameFor(ctx),
+	})
+}
+
+// filterExpansion returns the field set mapping of the field filtered by an operation,
+// if the filter should be expanded into an OR group
+func (l *FieldMappingListener) filterExpansion(fieldUse *parser.FieldUseContext) (FieldMapping, bool) {
+	if fieldUse == nil || fieldUse.IDENTIFIER() == nil {
+		return FieldMapping{}, false
+	}
+
+	mapping, exists := l.expansions[fieldUse.GetText()]
+	if !exists || len(mapping.Targets) == 0 || l.isDerived(mapping.Source) {
+		return FieldMapping{}, false
+	}
+	if !l.registry.isFilterCommand(commandNameFor(fieldUse)) || !l.isFieldRead(fieldUse) {
+		return FieldMapping{}, false
+	}
+
+	return mapping, true
+}
+
+// rewrittenText returns the text of a node with the rewrites applied so far
+func (l *FieldMappingListener) rewrittenText(start, stop int) string {
+	return l.rewriter.GetText(antlr.DefaultProgramName, antlr.NewInterval(start, stop))
+}
+
+// filterOperation returns the key=value or IN operation a field is filtered by, if any
+func filterOperation(ctx *parser.FieldUseContext) antlr.ParserRuleContext {
+	switch p := ctx.GetParent().(type) {
+	case *parser.KEYVALUEOPContext:
+		if p.Id() == ctx {
+			return p
+		}
+	case *parser.ValueContext:
+		if expression, ok := p.GetParent().(*parser.ExpressionContext); ok {
+			if in, ok := expression.GetParent().(*parser.INOPContext); ok && in.Expression(0) == expression {
+				return in
+			}
+		}
+	}
+	return nil
+}
+
+// fieldUseOf returns the field reference an expression consists of, if any
+func fieldUseOf(expression parser.IExpressionContext) *parser.FieldUseContext {
+	if expression == nil || expression.GetChildCount() != 1 {
+		return nil
+	}
+	value, ok := expression.GetChild(0).(*parser.ValueContext)
+	if !ok || value.GetChildCount() != 1 {
+		return nil
+	}
+	fieldUse, _ := value.GetChild(0).(*parser.FieldUseContext)
+	return fieldUse
+}
+
+// isFilterCommand checks if a command filters events with key=value and IN operations
+func (r *CommandRegistry) isFilterCommand(command string) bool {
+	return command == "search" || r.isExpressionCommand(command)
+}
@@ -1,6 +1,8 @@
 package mapper
 
 import (
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/antlr4-go/antlr/v4"
@@ -13,15 +15,48 @@ type FieldMappingListener struct {
 
 	tokenStream *antlr.CommonTokenStream
 	rewriter    *antlr.TokenStreamRewriter
-	mappings    map[string]string
+
+	// Mappings in effect at the current position; subsearches with their own scope replace them
+	mappingScope
+	subqueryScopes map[*parser.SubqueryContext]mappingScope
+	scopeStack     []mappingScope
+
+	precedingEvals map[int][]string // Command start token index -> eval assignments to insert before it
+	errors         []string
+
+	// Token indexes that have already been rewritten
+	rewritten    map[int]struct{}
+	replacements []MappingReplacement
+	collisions   []MappingCollision
+
+	// Fields derived by the query are not remapped after their derivation
+	derivedFieldScope
+
+	// Custom commands and functions; nil uses the default registry
+	registry *CommandRegistry
 }
 
 // NewFieldMappingListener creates a new field mapping listener
 func NewFieldMappingListener(tokenStream *antlr.CommonTokenStream, mappings map[string]string) *FieldMappingListener {
 	return &FieldMappingListener{
-		tokenStream: tokenStream,
-		rewriter:    antlr.NewTokenStreamRewriter(tokenStream),
-		mappings:    mappings,
+		tokenStream:       tokenStream,
+		rewriter:          antlr.NewTokenStreamRewriter(tokenStream),
+		mappingScope:      newMappingScope(mappings, nil, nil),
+		rewritten:         make(map[int]struct{}),
+		precedingEvals:    make(map[int][]string),
+		derivedFieldScope: newDerivedFieldScope(),
+	}
+}
+
+// setFieldMappings installs the value transformations and expansions of the effective mappings
+func (l *FieldMappingListener) setFieldMappings(mappings map[string]FieldMapping) {
+	for source, mapping := range mappings {
+		if transform, err := newValueTransform(mapping); err == nil && transform != nil {
+			l.valueTransforms[source] = transform
+		}
+		if mapping.isExpansion() {
+			l.expansions[source] = mapping
+		}
 	}
 }
 
@@ -35,169 +70,534 @@ func (l *FieldMappingListener) GetRewrittenText() string {
 	return strings.TrimSpace(result)
 }
 
-// EnterKEYVALUEOP handles field=value operations for field mapping
+// EnterFieldUse handles every identifier in the query. Each identifier is
+// classified by its position (command, operation and side of the operation)
+// and only rewritten when it is a read of an input field.
+func (l *FieldMappingListener) EnterFieldUse(ctx *parser.FieldUseContext) {
+	identifier := ctx.IDENTIFIER()
+	if identifier == nil {
+		return
+	}
+
+	fieldName := identifier.GetText()
+	mappedField, exists := l.mappings[fieldName]
+	if !exists && strings.Contains(fieldName, ".") {
+		l.mapConcatenation(ctx)
+		return
+	}
+	if !exists || mappedField == fieldName || l.isDerived(fieldName) {
+		return
+	}
+
+	if mapping, expands := l.expansions[fieldName]; expands {
+		if l.isFieldRead(ctx) {
+			l.expandField(ctx, mapping)
+		}
+		return
+	}
+
+	if l.isFieldRead(ctx) {
+		l.replaceToken(identifier.GetSymbol(), mappedField, ReplacementField, fieldName, commandNameFor(ctx))
+	}
+}
+
+// EnterKEYVALUEOP transforms the literal value of field=value and field!=value comparisons
 func (l *FieldMappingListener) EnterKEYVALUEOP(ctx *parser.KEYVALUEOPContext) {
-	fieldName := ctx.Id().GetText()
-
-	// Check if this field should be mapped
-	if mappedField, exists := l.mappings[fieldName]; exists {
-		// Check if this is within an eval command (field assignment) - don't map those
-		if !l.isInEvalAssignment(ctx) {
-			// Replace the field name with the mapped field
-			idCtx := ctx.Id()
-			start := idCtx.GetStart().GetTokenIndex()
-			stop := idCtx.GetStop().GetTokenIndex()
-			l.rewriter.ReplaceDefault(start, stop, mappedField)
+	if ctx.EQ() == nil && ctx.NE() == nil {
+		return
+	}
+
+	fieldUse, isField := ctx.Id().(*parser.FieldUseContext)
+	if !isField || fieldUse.IDENTIFIER() == nil {
+		return
+	}
+
+	transform := l.valueTransformFor(fieldUse.GetText())
+	if transform == nil || !l.isFieldRead(fieldUse) {
+		return
+	}
+
+	command := commandNameFor(ctx)
+	if token := l.literalValueToken(ctx.Expression(), command); token != nil {
+		l.replaceValue(token, transform, fieldUse.GetText(), command)
+	}
+}
+
+// EnterINOP transforms the literal values of "field IN (value, ...)"
+func (l *FieldMappingListener) EnterINOP(ctx *parser.INOPContext) {
+	expressions := ctx.AllExpression()
+	field := expressions[0]
+	if field.GetStart() != field.GetStop() || field.GetStart().GetTokenType() != parser.SPLLexerIDENTIFIER {
+		return
+	}
+
+	transform := l.valueTransformFor(field.GetText())
+	if transform == nil {
+		return
+	}
+
+	command := commandNameFor(ctx)
+	for _, expression := range expressions[1:] {
+		if token := l.literalValueToken(expression, command); token != nil {
+			l.replaceValue(token, transform, field.GetText(), command)
 		}
 	}
 }
 
-// EnterBYOP handles "by" operations in stats, etc.
-func (l *FieldMappingListener) EnterBYOP(ctx *parser.BYOPContext) {
-	// Map fields in "by" clause
+// GetReplacements returns every rewrite applied to the query, in query order
+func (l *FieldMappingListener) GetReplacements() []MappingReplacement {
+	sort.SliceStable(l.replacements, func(i, j int) bool {
+		return l.replacements[i].Offset < l.replacements[j].Offset
+	})
+	return l.replacements
+}
+
+// GetCollisions returns the fields derived by the query whose name clashes with a mapping target
+func (l *FieldMappingListener) GetCollisions() []MappingCollision {
+	return l.collisions
+}
+
+// ExitKEYVALUEOP expands filters on field sets and marks eval assignment targets as derived
+// once the assignment is complete, so the right-hand side still sees the original field
+func (l *FieldMappingListener) ExitKEYVALUEOP(ctx *parser.KEYVALUEOPContext) {
+	fieldUse, _ := ctx.Id().(*parser.FieldUseContext)
+	if mapping, expands := l.filterExpansion(fieldUse); expands {
+		operator := ctx.GetChild(1).(antlr.TerminalNode).GetText()
+		value := l.rewrittenText(ctx.Expression().GetStart().GetTokenIndex(), ctx.GetStop().GetTokenIndex())
+		l.expandFilter(ctx, mapping, ctx.NE() != nil, func(target string) string {
+			return target + operator + value
+		})
+		return
+	}
+
+	command := commandNameFor(ctx)
+	if l.isEvalAssignment(ctx, command) {
+		l.deriveField(ctx.Id(), command)
+	}
+}
+
+// ExitINOP expands "field IN (...)" filters on field sets
+func (l *FieldMappingListener) ExitINOP(ctx *parser.INOPContext) {
+	field := ctx.Expression(0)
+	if mapping, expands := l.filterExpansion(fieldUseOf(field)); expands {
+		list := l.rewrittenText(field.GetStop().GetTokenIndex()+1, ctx.GetStop().GetTokenIndex())
+		l.expandFilter(ctx, mapping, false, func(target string) string {
+			return target + list
+		})
+	}
+}
+
+// ExitRENAMEOP marks "AS" targets (rename, stats aliases) as derived
+func (l *FieldMappingListener) ExitRENAMEOP(ctx *parser.RENAMEOPContext) {
+	command := commandNameFor(ctx)
+	if command != "lookup" && ctx.Id() != nil {
+		l.deriveField(ctx.Id(), command)
+	}
+}
+
+// ExitOUTPUTOP marks lookup output fields as derived
+func (l *FieldMappingListener) ExitOUTPUTOP(ctx *parser.OUTPUTOPContext) {
+	if ctx.Id() != nil {
+		l.deriveField(ctx.Id(), commandNameFor(ctx))
+	}
+}
+
+// ExitOUTPUTMULTIOP marks lookup output fields as derived
+func (l *FieldMappingListener) ExitOUTPUTMULTIOP(ctx *parser.OUTPUTMULTIOPContext) {
+	command := commandNameFor(ctx)
 	for _, id := range ctx.AllId() {
-		fieldName := id.GetText()
-		if mappedField, exists := l.mappings[fieldName]; exists {
-			start := id.GetStart().GetTokenIndex()
-			stop := id.GetStop().GetTokenIndex()
-			l.rewriter.ReplaceDefault(start, stop, mappedField)
-		}
+		l.deriveField(id, command)
 	}
 }
 
-// EnterFieldUse handles general field references
-func (l *FieldMappingListener) EnterFieldUse(ctx *parser.FieldUseContext) {
-	if identifier := ctx.IDENTIFIER(); identifier != nil {
-		fieldName := identifier.GetText()
-
-		// Check if this field should be mapped
-		if mappedField, exists := l.mappings[fieldName]; exists {
-			// Only map if it's in a field position (not a value position)
-			if l.isInFieldPosition(ctx) {
-				start := identifier.GetSymbol().GetTokenIndex()
-				l.rewriter.ReplaceDefault(start, start, mappedField)
-			}
-		}
+// ExitOUTPUTMULTIINOP marks lookup output fields as derived
+func (l *FieldMappingListener) ExitOUTPUTMULTIINOP(ctx *parser.OUTPUTMULTIINOPContext) {
+	command := commandNameFor(ctx)
+	for _, id := range ctx.AllId() {
+		l.deriveField(id, command)
 	}
 }
 
-// EnterRENAMEOP handles rename operations
-func (l *FieldMappingListener) EnterRENAMEOP(ctx *parser.RENAMEOPContext) {
-	// Map the source field in rename operations
-	if expr := ctx.Expression(); expr != nil {
-		if value := expr.Value(); value != nil {
-			if id := value.Id(); id != nil {
-				fieldName := id.GetText()
-				if mappedField, exists := l.mappings[fieldName]; exists {
-					start := id.GetStart().GetTokenIndex()
-					stop := id.GetStop().GetTokenIndex()
-					l.rewriter.ReplaceDefault(start, stop, mappedField)
-				}
-			}
-		}
+// EnterNextCommand opens a derived-field scope for commands that run a separate search
+func (l *FieldMappingListener) EnterNextCommand(ctx *parser.NextCommandContext) {
+	if ctx.Command() != nil && l.registry.isScopedCommand(strings.ToLower(ctx.Command().GetText())) {
+		l.pushDerivedContext()
 	}
 }
 
-// EnterOUTPUTOP handles OUTPUT operations in lookup commands
-func (l *FieldMappingListener) EnterOUTPUTOP(ctx *parser.OUTPUTOPContext) {
-	// Map the input field in OUTPUT operations
-	if inputExpr := ctx.Expression(); inputExpr != nil {
-		if value := inputExpr.Value(); value != nil {
-			if id := value.Id(); id != nil {
-				fieldName := id.GetText()
-				if mappedField, exists := l.mappings[fieldName]; exists {
-					start := id.GetStart().GetTokenIndex()
-					stop := id.GetStop().GetTokenIndex()
-					l.rewriter.ReplaceDefault(start, stop, mappedField)
-				}
+// ExitNextCommand inserts evals for expanded fields, closes scopes and marks fields
+// extracted by rex named groups as derived
+func (l *FieldMappingListener) ExitNextCommand(ctx *parser.NextCommandContext) {
+	l.flushPrecedingEvals(ctx)
+	if ctx.Command() == nil {
+		return
+	}
+
+	command := strings.ToLower(ctx.Command().GetText())
+	if l.registry.isScopedCommand(command) {
+		l.popDerivedContext()
+		return
+	}
+
+	if command == "rex" {
+		for i := ctx.GetStart().GetTokenIndex(); i <= ctx.GetStop().GetTokenIndex(); i++ {
+			token := l.tokenStream.Get(i)
+			if token.GetTokenType() != parser.SPLLexerSTRING {
+				continue
+			}
+			for _, match := range rexNamedGroupRegex.FindAllStringSubmatch(token.GetText(), -1) {
+				l.deriveFieldAt(match[1], token, command)
 			}
 		}
 	}
 }
 
-// EnterOUTPUTMULTIOP handles multiple OUTPUT operations
-func (l *FieldMappingListener) EnterOUTPUTMULTIOP(ctx *parser.OUTPUTMULTIOPContext) {
-	// Map input fields in multi OUTPUT operations
-	for _, expr := range ctx.AllExpression() {
-		if value := expr.Value(); value != nil {
-			if id := value.Id(); id != nil {
-				fieldName := id.GetText()
-				if mappedField, exists := l.mappings[fieldName]; exists {
-					start := id.GetStart().GetTokenIndex()
-					stop := id.GetStop().GetTokenIndex()
-					l.rewriter.ReplaceDefault(start, stop, mappedField)
-				}
-			}
-		}
+// EnterSubquery opens a derived-field scope for the subsearch and switches to its mappings
+func (l *FieldMappingListener) EnterSubquery(ctx *parser.SubqueryContext) {
+	l.pushDerivedContext()
+	l.scopeStack = append(l.scopeStack, l.mappingScope)
+	if scope, scoped := l.subqueryScopes[ctx]; scoped {
+		l.mappingScope = scope
 	}
 }
 
-// EnterOUTPUTMULTIINOP handles multiple input/output operations
-func (l *FieldMappingListener) EnterOUTPUTMULTIINOP(ctx *parser.OUTPUTMULTIINOPContext) {
-	// Map input fields in multi input/output operations
-	for _, expr := range ctx.AllExpression() {
-		if value := expr.Value(); value != nil {
-			if id := value.Id(); id != nil {
-				fieldName := id.GetText()
-				if mappedField, exists := l.mappings[fieldName]; exists {
-					start := id.GetStart().GetTokenIndex()
-					stop := id.GetStop().GetTokenIndex()
-					l.rewriter.ReplaceDefault(start, stop, mappedField)
-				}
-			}
-		}
+// ExitSubquery closes the subsearch scope
+func (l *FieldMappingListener) ExitSubquery(ctx *parser.SubqueryContext) {
+	l.popDerivedContext()
+	l.mappingScope = l.scopeStack[len(l.scopeStack)-1]
+	l.scopeStack = l.scopeStack[:len(l.scopeStack)-1]
+}
+
+// deriveField marks the field named by id as derived
+func (l *FieldMappingListener) deriveField(id parser.IIdContext, command string) {
+	l.deriveFieldAt(id.GetText(), id.GetStart(), command)
+}
+
+// deriveFieldAt marks a field as derived and flags it if a mapping renames another field to the same name
+func (l *FieldMappingListener) deriveFieldAt(fieldName string, token antlr.Token, command string) {
+	l.markDerived(fieldName)
+
+	if source, clashes := l.targets[fieldName]; clashes && source != fieldName {
+		l.collisions = append(l.collisions, MappingCollision{
+			Field:         fieldName,
+			Source:        source,
+			Offset:        token.GetStart(),
+			Line:          token.GetLine(),
+			Column:        token.GetColumn(),
+			Command:       command,
+			MappingOrigin: l.origins[source],
+		})
 	}
 }
 
+// valueTransformFor returns the value transformation for a field, unless the field was derived by the query
+func (l *FieldMappingListener) valueTransformFor(fieldName string) *valueTransform {
+	transform, exists := l.valueTransforms[fieldName]
+	if !exists || l.isDerived(fieldName) {
+		return nil
+	}
+	return transform
+}
+
+// replaceValue rewrites a literal value token if the transformation changes it
+func (l *FieldMappingListener) replaceValue(token antlr.Token, transform *valueTransform, fieldName, command string) {
+	if text, changed := transform.applyToLiteral(token.GetText(), l.registry.isExpressionCommand(command)); changed {
+		l.replaceToken(token, text, ReplacementValue, fieldName, command)
+	}
+}
+
+// replaceToken rewrites a single token, ignoring tokens that were already rewritten
+func (l *FieldMappingListener) replaceToken(token antlr.Token, text, kind, fieldName, command string) {
+	index := token.GetTokenIndex()
+	if _, done := l.rewritten[index]; done {
+		return
+	}
+	l.rewritten[index] = struct{}{}
+	l.rewriter.ReplaceDefault(index, index, text)
+	l.recordReplacement(token, text, kind, fieldName, command)
+}
+
+// recordReplacement adds a rewrite at the position of token to the replacements
+func (l *FieldMappingListener) recordReplacement(token antlr.Token, text, kind, fieldName, command string) {
+	l.replacements = append(l.replacements, MappingReplacement{
+		Kind:          kind,
+		Field:         fieldName,
+		Original:      token.GetText(),
+		Replacement:   text,
+		Offset:        token.GetStart(),
+		Line:          token.GetLine(),
+		Column:        token.GetColumn(),
+		Command:       command,
+		MappingOrigin: l.origins[fieldName],
+	})
+}
+
 // Helper methods
 
-// isInEvalAssignment checks if a KEYVALUEOP is within an eval command (field assignment)
-func (l *FieldMappingListener) isInEvalAssignment(ctx *parser.KEYVALUEOPContext) bool {
+// isFieldRead determines if a field reference reads an input field and should be mapped
+func (l *FieldMappingListener) isFieldRead(ctx *parser.FieldUseContext) bool {
+	token := ctx.IDENTIFIER().GetSymbol()
+
+	// Identifiers directly followed by "(" are function names such as dc(user)
+	if next := l.nextDefaultToken(token.GetTokenIndex()); next != nil && next.GetTokenType() == parser.SPLLexerLPAREN {
+		return false
+	}
+
+	// Identifiers glued to a number are part of a literal such as span=1h or 10.0.0.1
+	if strings.HasPrefix(token.GetText(), ".") {
+		return false
+	}
+	if prev := l.previousToken(token.GetTokenIndex()); prev != nil && prev.GetTokenType() == parser.SPLLexerNUMBER {
+		return false
+	}
+
+	return l.isFieldReadPosition(ctx, commandNameFor(ctx))
+}
+
+// isFieldReadPosition determines if an identifier is in a position where command reads a field
+func (l *FieldMappingListener) isFieldReadPosition(ctx *parser.FieldUseContext, command string) bool {
+	var child antlr.Tree = ctx
 	parent := ctx.GetParent()
 	for parent != nil {
-		if nextCmd, ok := parent.(*parser.NextCommandContext); ok {
-			if nextCmd.Command() != nil && nextCmd.Command().GetText() == "eval" {
+		switch p := parent.(type) {
+		case *parser.BYOPContext:
+			// Fields in a "by" clause are always field references
+			return true
+
+		case *parser.RENAMEOPContext:
+			if child == p.Id() {
+				// "lookup table lookup_field AS event_field" reads event_field,
+				// everywhere else the AS target is a derived field
+				return command == "lookup"
+			}
+			if command == "lookup" {
+				return false
+			}
+			if command == "rename" {
+				return true
+			}
+			return l.registry.isFieldListCommand(command)
+
+		case *parser.OUTPUTOPContext, *parser.OUTPUTMULTIOPContext, *parser.OUTPUTMULTIINOPContext:
+			// Fields after OUTPUT are created by the lookup
+			_, isExpression := child.(parser.IExpressionContext)
+			return isExpression
+
+		case *parser.KEYVALUEOPContext:
+			key := p.Id().GetText()
+			if child == p.Id() {
+				if l.isEvalAssignment(p, command) {
+					return false
+				}
+				return !l.registry.isCommandOption(command, key)
+			}
+			// Right-hand side of key=value
+			if l.registry.isCommandOption(command, key) {
+				return l.registry.isFieldValuedOption(command, key)
+			}
+			return l.registry.isExpressionCommand(command)
+
+		case *parser.INOPContext:
+			if child == p.Expression(0) {
 				return true
 			}
+			return l.registry.isExpressionCommand(command)
+
+		case *parser.LIKEOPContext:
+			return child == p.Expression()
+
+		case *parser.EXPRESSIONOPContext:
+			if l.registry.isExpressionCommand(command) {
+				return true
+			}
+			if l.registry.isCommandKeyword(command, ctx.GetText()) {
+				return false
+			}
+			return l.registry.isFieldListCommand(command)
+
+		case *parser.ExpressionContext:
+			// Arguments of custom functions are read as their declared shapes
+			if shape, declared := l.registry.functionArgumentShape(p, child); declared {
+				return shape == FunctionArgumentField
+			}
+
+		case *parser.NextCommandContext, *parser.InitCommandContext, *parser.SubqueryContext:
+			return false
 		}
+
+		child = parent
 		parent = parent.GetParent()
 	}
+
 	return false
 }
 
-// isInFieldPosition determines if a field reference is in a position where it should be mapped
-func (l *FieldMappingListener) isInFieldPosition(ctx *parser.FieldUseContext) bool {
-	parent := ctx.GetParent()
+// mapConcatenation maps the operands of an identifier that the lexer reads across the "."
+// concatenation operator of eval expressions, such as src_ip."a" or src_ip.user. Field names
+// containing dots are single-quoted in eval expressions, so each operand is a field of its own.
+func (l *FieldMappingListener) mapConcatenation(ctx *parser.FieldUseContext) {
+	command := commandNameFor(ctx)
+	if !l.registry.isExpressionCommand(command) {
+		return
+	}
 
-	for parent != nil {
+	token := ctx.IDENTIFIER().GetSymbol()
+	if next := l.nextDefaultToken(token.GetTokenIndex()); next != nil && next.GetTokenType() == parser.SPLLexerLPAREN {
+		return
+	}
+	if prev := l.previousToken(token.GetTokenIndex()); prev != nil && prev.GetTokenType() == parser.SPLLexerNUMBER {
+		return
+	}
+	if !l.isFieldReadPosition(ctx, command) {
+		return
+	}
+	if _, done := l.rewritten[token.GetTokenIndex()]; done {
+		return
+	}
+
+	operands := strings.Split(token.GetText(), ".")
+	var replacements []MappingReplacement
+	offset := 0
+	for i, operand := range operands {
+		start := offset
+		offset += len([]rune(operand)) + 1
+		if l.isDerived(operand) {
+			continue
+		}
+		kind := ReplacementField
+		target, mapped := l.mappings[operand]
+		if mapping, expands := l.expansions[operand]; expands {
+			kind, target = ReplacementExpansion, mapping.targetText()
+		}
+		if !mapped || target == operand {
+			continue
+		}
+		operands[i] = target
+		replacements = append(replacements, MappingReplacement{
+			Kind:          kind,
+			Field:         operand,
+			Original:      operand,
+			Replacement:   target,
+			Offset:        token.GetStart() + start,
+			Line:          token.GetLine(),
+			Column:        token.GetColumn() + start,
+			Command:       command,
+			MappingOrigin: l.origins[operand],
+		})
+	}
+	if len(replacements) == 0 {
+		return
+	}
+
+	l.rewritten[token.GetTokenIndex()] = struct{}{}
+	l.rewriter.ReplaceDefault(token.GetTokenIndex(), token.GetTokenIndex(), strings.Join(operands, "."))
+	l.replacements = append(l.replacements, replacements...)
+}
+
+// nextDefaultToken returns the next token on the default channel after index
+func (l *FieldMappingListener) nextDefaultToken(index int) antlr.Token {
+	for i := index + 1; i < l.tokenStream.Size(); i++ {
+		token := l.tokenStream.Get(i)
+		if token.GetChannel() == antlr.TokenDefaultChannel {
+			return token
+		}
+	}
+	return nil
+}
+
+// previousToken returns the token immediately before index on any channel
+func (l *FieldMappingListener) previousToken(index int) antlr.Token {
+	if index <= 0 {
+		return nil
+	}
+	return l.tokenStream.Get(index - 1)
+}
+
+// literalValueToken returns the token of an expression consisting of a single literal value.
+// Bare identifiers are literals except in expression commands, where they reference fields.
+func (l *FieldMappingListener) literalValueToken(expression parser.IExpressionContext, command string) antlr.Token {
+	if expression == nil || expression.GetStart() != expression.GetStop() {
+		return nil
+	}
+
+	token := expression.GetStart()
+	switch token.GetTokenType() {
+	case parser.SPLLexerSTRING, parser.SPLLexerNUMBER:
+		return token
+	case parser.SPLLexerIDENTIFIER:
+		if !l.registry.isExpressionCommand(command) {
+			return token
+		}
+	}
+	return nil
+}
+
+// isEvalAssignment checks if a KEYVALUEOP is a field assignment of an eval-like command
+func (l *FieldMappingListener) isEvalAssignment(ctx *parser.KEYVALUEOPContext, command string) bool {
+	if !l.registry.isAssignmentCommand(command) || ctx.EQ() == nil {
+		return false
+	}
+	_, topLevel := ctx.GetParent().(*parser.NextCommandContext)
+	return topLevel
+}
+
+// commandNameFor returns the lower-cased name of the command that contains the given node.
+// Queries without an explicit generating command are treated as an implicit search.
+func commandNameFor(node antlr.Tree) string {
+	for parent := node.GetParent(); parent != nil; parent = parent.GetParent() {
 		switch p := parent.(type) {
-		case *parser.KEYVALUEOPContext:
-			// If we're the left side of a key=value operation, we're a field reference
-			if p.Id() != nil && ctx.GetText() == p.Id().GetText() {
-				return true
-			}
-			// If we're in the expression (right side), we might be a field reference in eval contexts
-			return false
-		case *parser.BYOPContext:
-			// If we're in a "by" clause, we're definitely a field reference
-			return true
 		case *parser.NextCommandContext:
-			// Check the command type
 			if p.Command() != nil {
-				cmdText := strings.ToLower(p.Command().GetText())
-				switch cmdText {
-				case "fields":
-					return true
-				case "lookup", "inputlookup":
-					// Be more careful with lookup contexts
-					return false
-				default:
-					return false
-				}
+				return strings.ToLower(p.Command().GetText())
 			}
+			return ""
+		case *parser.InitCommandContext:
+			return initCommandName(p)
 		}
-		parent = parent.GetParent()
 	}
+	return ""
+}
 
-	return false
+// initCommandName returns the lower-cased name of the first command in a query or subquery.
+// A leading pipe followed by a non-generating command (e.g. "| fields x") is parsed as an
+// operation whose first value is the command name.
+func initCommandName(ctx *parser.InitCommandContext) string {
+	if ctx.INIT_COMMAND() != nil {
+		return strings.ToLower(ctx.INIT_COMMAND().GetText())
+	}
+
+	if commandUse := initCommandUse(ctx); commandUse != nil {
+		return strings.ToLower(commandUse.GetText())
+	}
+
+	return "search"
 }
+
+// initCommandUse returns the command named by the first operation of a query that starts
+// with a pipe and a non-generating command, if any
+func initCommandUse(ctx *parser.InitCommandContext) *parser.CommandUseContext {
+	operations := ctx.AllOperation()
+	if len(operations) > 0 {
+		if exprOp, ok := operations[0].(*parser.EXPRESSIONOPContext); ok {
+			return leadingCommandUse(exprOp.Expression())
+		}
+	}
+	return nil
+}
+
+// leadingCommandUse returns the command used as the left-most value of an expression, if any
+func leadingCommandUse(expr parser.IExpressionContext) *parser.CommandUseContext {
+	for expr != nil {
+		if value := expr.Value(); value != nil {
+			if commandUse, ok := value.Id().(*parser.CommandUseContext); ok {
+				return commandUse
+			}
+			return nil
+		}
+		expr = expr.Expression(0)
+	}
+	return nil
+}
+
+// rexNamedGroupRegex matches the named capture groups a rex regex extracts as fields
+var rexNamedGroupRegex = regexp.MustCompile(`\(\?P?<([A-Za-z_][A-Za-z0-9_]*)>`)
@@ -0,0 +1,262 @@
+package mapper
+
+import (
+	"fmt"
+	"sort"
+)
+
+// InversionProblem describes part of a mapping configuration that cannot be inverted exactly
+type InversionProblem struct {
+	Location string `json:"location"` // e.g. "mapping[2]" or "rule[0].mapping[1]"
+	Message  string `json:"message"`
+}
+
+// RoundTripResult reports whether mapping a query and then mapping it back with the inverse
+// configuration restores the original query
+type RoundTripResult struct {
+	Query    string `json:"query"`
+	Mapped   string `json:"mapped"`
+	Restored string `json:"restored"`
+	Match    bool   `json:"match"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Invert produces the reverse mapping configuration: every mapping maps its target back to
+// its source, rule conditions refer to the mapped field names and datamodel mappings are swapped.
+// Mappings that cannot be inverted exactly are left out or kept partially and reported as problems.
+func (mc *MappingConfig) Invert() (*MappingConfig, []InversionProblem) {
+	var problems []InversionProblem
+
+	inverse := &MappingConfig{
+		Version:     mc.Version,
+		Name:        mc.Name,
+		Description: mc.Description,
+		Metadata:    mc.Metadata,
+		Mappings:    invertFieldMappings(mc.Mappings, "mapping", &problems),
+	}
+
+	basicRenames := fieldRenames(mc.Mappings)
+	for i, rule := range mc.Rules {
+		inverse.Rules = append(inverse.Rules, invertRule(rule, fmt.Sprintf("rule[%d]", i), basicRenames, &problems))
+	}
+
+	for i, dm := range mc.DataModels {
+		location := fmt.Sprintf("datamodel[%d]", i)
+		inverted := DataModelMapping{
+			SourceDataModel: dm.TargetDataModel,
+			TargetDataModel: dm.SourceDataModel,
+		}
+
+		seen := make(map[string]string)
+		for j, fm := range dm.FieldMappings {
+			if previous, exists := seen[fm.TargetField]; exists {
+				problems = append(problems, InversionProblem{
+					Location: fmt.Sprintf("%s.field_mapping[%d]", location, j),
+					Message:  fmt.Sprintf("'%s' and '%s' both map to '%s'; the inverse keeps '%s'", previous, fm.SourceField, fm.TargetField, previous),
+				})
+				continue
+			}
+			seen[fm.TargetField] = fm.SourceField
+
+			inverted.FieldMappings = append(inverted.FieldMappings, DataModelFieldMapping{
+				SourceField: fm.TargetField,
+				TargetField: fm.SourceField,
+				SourcePath:  fm.TargetPath,
+				TargetPath:  fm.SourcePath,
+			})
+		}
+
+		for j, rule := range dm.ConditionalMappings {
+			ruleLocation := fmt.Sprintf("%s.conditional_mapping[%d]", location, j)
+			inverted.ConditionalMappings = append(inverted.ConditionalMappings, invertRule(rule, ruleLocation, basicRenames, &problems))
+		}
+
+		inverse.DataModels = append(inverse.DataModels, inverted)
+	}
+
+	return inverse, problems
+}
+
+// CheckRoundTrip maps each query with the configuration and then with its inverse,
+// reporting whether the original query is restored
+func (mc *MappingConfig) CheckRoundTrip(queries []string) ([]RoundTripResult, []InversionProblem) {
+	inverse, problems := mc.Invert()
+	forward := NewWithConfig(mc)
+	backward := NewWithConfig(inverse)
+
+	results := make([]RoundTripResult, 0, len(queries))
+	for _, query := range queries {
+		result := RoundTripResult{Query: query}
+
+		mapped, err := forward.MapQuery(query)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		result.Mapped = mapped
+
+		restored, err := backward.MapQuery(mapped)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		result.Restored = restored
+		result.Match = restored == query
+
+		results = append(results, result)
+	}
+
+	return results, problems
+}
+
+// invertRule inverts the mappings of a conditional rule and renames the fields its conditions refer to
+func invertRule(rule ConditionalRule, location string, basicRenames map[string]string, problems *[]InversionProblem) ConditionalRule {
+	renames := make(map[string]string)
+	for source, target := range basicRenames {
+		renames[source] = target
+	}
+	for source, target := range fieldRenames(rule.Mappings) {
+		renames[source] = target
+	}
+
+	inverted := rule
+	inverted.Mappings = invertFieldMappings(rule.Mappings, location+".mapping", problems)
+	reportBasicCollisions(rule.Mappings, location+".mapping", basicRenames, problems)
+	inverted.Conditions = renameConditionFields(rule.Conditions, renames)
+	if rule.When != "" {
+		inverted.When = renameExpressionFields(rule.When, renames)
+	}
+	return inverted
+}
+
+// reportBasicCollisions reports rule mappings whose target a basic mapping of another source
+// also maps to. Where the rule applies both sources end up in the same field, and the inverse
+// can only map it back to the source of the rule mapping.
+func reportBasicCollisions(mappings []FieldMapping, location string, basicRenames map[string]string, problems *[]InversionProblem) {
+	overridden := make(map[string]bool) // Basic mappings the rule replaces
+	for _, mapping := range mappings {
+		overridden[mapping.Source] = true
+	}
+
+	basicSources := make(map[string]string) // Target -> first basic source still in effect
+	for _, source := range sortedKeys(basicRenames) {
+		target := basicRenames[source]
+		if _, exists := basicSources[target]; !exists && !overridden[source] {
+			basicSources[target] = source
+		}
+	}
+
+	for i, mapping := range mappings {
+		source, exists := basicSources[mapping.Target]
+		if mapping.Target == "" || !exists || source == mapping.Source {
+			continue
+		}
+		*problems = append(*problems, InversionProblem{
+			Location: fmt.Sprintf("%s[%d]", location, i),
+			Message: fmt.Sprintf("'%s' of the basic mappings and '%s' both map to '%s' where the rule applies; the inverse maps it back to '%s'",
+				source, mapping.Source, mapping.Target, mapping.Source),
+		})
+	}
+}
+
+// invertFieldMappings inverts a list of mappings, reporting those that cannot be inverted exactly
+func invertFieldMappings(mappings []FieldMapping, location string, problems *[]InversionProblem) []FieldMapping {
+	inverted := []FieldMapping{}
+	seen := make(map[string]string) // Target -> first source mapping to it
+
+	for i, mapping := range mappings {
+		report := func(format string, args ...interface{}) {
+			*problems = append(*problems, InversionProblem{
+				Location: fmt.Sprintf("%s[%d]", location, i),
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		switch {
+		case len(mapping.Targets) > 0:
+			report("'%s' maps to several fields; the inverse would map several fields to one", mapping.Source)
+			continue
+		case mapping.Expression != "":
+			report("'%s' maps to an expression, which cannot be inverted", mapping.Source)
+			continue
+		}
+
+		if previous, exists := seen[mapping.Target]; exists {
+			report("'%s' and '%s' both map to '%s'; the inverse keeps '%s'", previous, mapping.Source, mapping.Target, previous)
+			continue
+		}
+		seen[mapping.Target] = mapping.Source
+
+		result := FieldMapping{
+			ID:     mapping.ID,
+			Source: mapping.Target,
+			Target: mapping.Source,
+		}
+
+		if len(mapping.Values) > 0 {
+			// Iterate in a fixed order so the kept value is deterministic
+			values := make([]string, 0, len(mapping.Values))
+			for value := range mapping.Values {
+				values = append(values, value)
+			}
+			sort.Strings(values)
+
+			result.Values = make(map[string]string)
+			for _, value := range values {
+				replacement := mapping.Values[value]
+				if previous, exists := result.Values[replacement]; exists {
+					report("values '%s' and '%s' both map to '%s'; the inverse keeps '%s'", previous, value, replacement, previous)
+					continue
+				}
+				result.Values[replacement] = value
+
+				// Values the map does not list pass through unchanged, so the inverse
+				// cannot tell them from the ones mapped to the same value
+				if _, mapped := mapping.Values[replacement]; !mapped {
+					report("'%s' maps to '%s', which also passes through unchanged; the inverse turns both into '%s'",
+						value, replacement, value)
+				}
+			}
+		}
+		if len(mapping.Replace) > 0 {
+			report("regex value replacements of '%s' cannot be inverted", mapping.Source)
+		}
+		if mapping.Case != "" {
+			report("the %s case transform of '%s' loses information and cannot be inverted", mapping.Case, mapping.Source)
+		}
+
+		inverted = append(inverted, result)
+	}
+
+	return inverted
+}
+
+// fieldRenames returns the source -> target renames of plain field mappings
+func fieldRenames(mappings []FieldMapping) map[string]string {
+	renames := make(map[string]string)
+	for _, mapping := range mappings {
+		if mapping.Target != "" {
+			renames[mapping.Source] = mapping.Target
+		}
+	}
+	return renames
+}
+
+// renameConditionFields returns a copy of conditions with field names renamed
+func renameConditionFields(conditions []Condition, renames map[string]string) []Condition {
+	if conditions == nil {
+		return nil
+	}
+
+	renamed := make([]Condition, len(conditions))
+	for i, condition := range conditions {
+		if target, exists := renames[condition.Field]; exists {
+			condition.Field = target
+		}
+		condition.Children = renameConditionFields(condition.Children, renames)
+		renamed[i] = condition
+	}
+	return renamed
+}
@@ -1,7 +1,6 @@
 package mapper
 
 import (
-	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -13,14 +12,26 @@ import (
 // Mapper represents the main SPL field mapping engine
 type Mapper struct {
 	fieldMappings map[string]string
+	basicMappings map[string]FieldMapping // Source field -> full mapping, for reporting, value and expansion rules
 	parser        *Parser
 	config        *MappingConfig
+	registry      *CommandRegistry // Custom commands and functions the mapper's queries may use
 }
 
-// FieldMapping represents a source to target field mapping
+// FieldMapping represents a source to target field mapping.
+// Exactly one of Target, Targets and Expression is set: Targets maps one field to a set
+// of fields and Expression maps it to an eval expression.
+// Values, Replace and Case optionally rewrite literal values compared against the field;
+// a mapping whose Target equals its Source only changes values.
 type FieldMapping struct {
-	Source string `json:"source"`
-	Target string `json:"target"`
+	ID         string             `json:"id,omitempty"`
+	Source     string             `json:"source"`
+	Target     string             `json:"target,omitempty"`
+	Targets    []string           `json:"targets,omitempty"`    // Field set, e.g. ["user", "user_name"]
+	Expression string             `json:"expression,omitempty"` // Eval expression, e.g. "coalesce(src_ip, src_host)"
+	Values     map[string]string  `json:"values,omitempty"`     // Exact value -> replacement value
+	Replace    []ValueReplacement `json:"replace,omitempty"`    // Regex replacements, applied when no exact value matches
+	Case       string             `json:"case,omitempty"`       // "lower" or "upper", applied last
 }
 
 // MappingRule represents a conditional mapping rule
@@ -38,15 +49,20 @@ type QueryInfo struct {
 	Macros      []string `json:"macros"`
 	Sources     []string `json:"sources"`
 	SourceTypes []string `json:"sourcetypes"`
+	Indexes     []string `json:"indexes"`
+	Hosts       []string `json:"hosts"`
 	InputFields []string `json:"input_fields"`
+	Commands    []string `json:"commands"` // Lower-case names of the commands the query runs, including the implicit search
 }
 
 // New creates a new Mapper instance
 func New() *Mapper {
 	return &Mapper{
 		fieldMappings: make(map[string]string),
+		basicMappings: make(map[string]FieldMapping),
 		parser:        NewParser(),
 		config:        nil,
+		registry:      defaultRegistry,
 	}
 }
 
@@ -54,29 +70,72 @@ func New() *Mapper {
 func NewWithConfig(config *MappingConfig) *Mapper {
 	mapper := &Mapper{
 		fieldMappings: make(map[string]string),
+		basicMappings: make(map[string]FieldMapping),
 		parser:        NewParser(),
 		config:        config,
+		registry:      defaultRegistry,
 	}
 
-	// Load basic mappings from config
+	// Load basic mappings from config; invalid mappings are reported by config.Validate
 	for _, mapping := range config.Mappings {
-		mapper.fieldMappings[mapping.Source] = mapping.Target
+		_ = mapper.addFieldMapping(mapping)
 	}
 
+	// Compile the patterns and when expressions of the rules once rather than for every query
+	config.compile()
+
 	return mapper
 }
 
+// SetCommandRegistry makes the mapper accept the custom commands and functions of registry
+// instead of the default registry; nil restores the default registry
+func (m *Mapper) SetCommandRegistry(registry *CommandRegistry) {
+	m.registry = registry.orDefault()
+	m.parser = NewParserWithRegistry(m.registry)
+}
+
+// CommandRegistry returns the registry of custom commands and functions the mapper accepts
+func (m *Mapper) CommandRegistry() *CommandRegistry {
+	return m.registry
+}
+
 // LoadMappings loads field mappings from JSON
 func (m *Mapper) LoadMappings(jsonData []byte) error {
 	var mappings []FieldMapping
-	if err := json.Unmarshal(jsonData, &mappings); err != nil {
+	if err := unmarshalConfigJSON(jsonData, &mappings); err != nil {
+		return err
+	}
+
+	for i, mapping := range mappings {
+		if err := m.addFieldMapping(mapping); err != nil {
+			return fmt.Errorf("mapping[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks a mapping the way LoadMappings and MappingConfig.Validate do: it must set
+// exactly one of Target, Targets and Expression, and its value transforms must compile
+func (fm FieldMapping) Validate() error {
+	if err := fm.validateTarget(); err != nil {
+		return err
+	}
+	if _, err := newValueTransform(fm); err != nil {
 		return err
 	}
+	return nil
+}
 
-	for _, mapping := range mappings {
-		m.fieldMappings[mapping.Source] = mapping.Target
+// addFieldMapping registers a basic mapping, keeping the full mapping for reporting and value rules
+func (m *Mapper) addFieldMapping(mapping FieldMapping) error {
+	if err := mapping.Validate(); err != nil {
+		return err
 	}
 
+	m.fieldMappings[mapping.Source] = mapping.targetText()
+	m.basicMappings[mapping.Source] = mapping
+
 	return nil
 }
 
@@ -104,7 +163,7 @@ func (m *Mapper) DiscoverQuery(query string) (*QueryInfo, error) {
 	// Use ANTLR to parse the query with listener pattern
 	input := antlr.NewInputStream(query)
 	lexer := parser.NewSPLLexer(input)
-	stream := antlr.NewCommonTokenStream(lexer, 0)
+	stream := antlr.NewCommonTokenStream(newQueryTokenSource(lexer, m.registry), 0)
 	splParser := parser.NewSPLParser(stream)
 
 	// Remove default error listeners to avoid console output
@@ -122,6 +181,7 @@ func (m *Mapper) DiscoverQuery(query string) (*QueryInfo, error) {
 	// Extract macros using pattern matching first (since grammar doesn't support backticks yet)
 	// We do this before parsing because macros may cause parse errors
 	listener := NewFieldDiscoveryListener()
+	listener.registry = m.registry
 	macros := m.extractMacros(query)
 	for _, macro := range macros {
 		listener.addMacro(macro)
@@ -142,7 +202,10 @@ func (m *Mapper) DiscoverQuery(query string) (*QueryInfo, error) {
 				Macros:      listener.Macros,
 				Sources:     []string{},
 				SourceTypes: []string{},
+				Indexes:     []string{},
+				Hosts:       []string{},
 				InputFields: []string{},
+				Commands:    []string{},
 			}
 			return info, nil
 		}
@@ -153,17 +216,7 @@ func (m *Mapper) DiscoverQuery(query string) (*QueryInfo, error) {
 	antlr.ParseTreeWalkerDefault.Walk(listener, tree)
 
 	// Convert listener results to QueryInfo
-	info := &QueryInfo{
-		DataModels:  listener.DataModels,
-		Datasets:    listener.Datasets,
-		Lookups:     listener.Lookups,
-		Macros:      listener.Macros,
-		Sources:     listener.Sources,
-		SourceTypes: listener.SourceTypes,
-		InputFields: listener.InputFields,
-	}
-
-	return info, nil
+	return listenerQueryInfo(listener), nil
 }
 
 // GetInputFields returns all input fields required for a query
@@ -182,27 +235,57 @@ func (m *Mapper) ValidateQuery(query string) error {
 }
 
 func (m *Mapper) getEffectiveMappings(context map[string]interface{}) map[string]string {
+	result, _, _ := m.resolveMappings(context)
+	return result
+}
+
+// resolveMappings returns the effective mappings for a context together with the
+// mapping or rule each of them came from and the full mapping of each source field
+func (m *Mapper) resolveMappings(context map[string]interface{}) (map[string]string, map[string]MappingOrigin, map[string]FieldMapping) {
 	// Start with basic mappings
 	result := make(map[string]string)
+	origins := make(map[string]MappingOrigin)
+	mappings := make(map[string]FieldMapping)
 	for k, v := range m.fieldMappings {
 		result[k] = v
+		origins[k] = MappingOrigin{MappingID: m.basicMappings[k].ID}
+		mappings[k] = m.basicMappings[k]
 	}
 
 	// Add conditional mappings if config is available
 	if m.config != nil && context != nil {
-		conditionalMappings := m.config.GetMappingsForConditions(context)
-		for _, mapping := range conditionalMappings {
-			result[mapping.Source] = mapping.Target
+		apply := func(mapping FieldMapping, origin MappingOrigin) {
+			result[mapping.Source] = mapping.targetText()
+			origins[mapping.Source] = origin
+			mappings[mapping.Source] = mapping
+		}
+
+		for _, mapping := range m.config.Mappings {
+			apply(mapping, MappingOrigin{MappingID: mapping.ID})
+		}
+		for _, rule := range m.config.GetMatchingRules(context) {
+			for _, mapping := range rule.Mappings {
+				apply(mapping, MappingOrigin{MappingID: mapping.ID, RuleID: rule.ID})
+			}
 		}
 	}
 
-	return result
+	return result, origins, mappings
 }
 
 // mapQueryWithTokenRewriter uses ANTLR token stream rewriting for proper field mapping
 func (m *Mapper) mapQueryWithTokenRewriter(query string, context map[string]interface{}) (string, error) {
+	report, err := m.rewriteQuery(query, context)
+	if err != nil {
+		return "", err
+	}
+	return report.MappedQuery, nil
+}
+
+// rewriteQuery applies the effective mappings for a context and reports every rewrite
+func (m *Mapper) rewriteQuery(query string, context map[string]interface{}) (*MappingReport, error) {
 	if query == "" {
-		return "", fmt.Errorf("empty query")
+		return nil, fmt.Errorf("empty query")
 	}
 
 	// Create input stream
@@ -210,7 +293,7 @@ func (m *Mapper) mapQueryWithTokenRewriter(query string, context map[string]inte
 	lexer := parser.NewSPLLexer(input)
 
 	// Create token stream
-	stream := antlr.NewCommonTokenStream(lexer, 0)
+	stream := antlr.NewCommonTokenStream(newQueryTokenSource(lexer, m.registry), 0)
 
 	// Create parser
 	splParser := parser.NewSPLParser(stream)
@@ -232,55 +315,78 @@ func (m *Mapper) mapQueryWithTokenRewriter(query string, context map[string]inte
 
 	// Check for parse errors
 	if len(errorListener.errors) > 0 {
-		return "", fmt.Errorf("parse errors: %s", strings.Join(errorListener.errors, "; "))
+		return nil, fmt.Errorf("parse errors: %s", strings.Join(errorListener.errors, "; "))
 	}
 
-	// Create and configure the mapping listener
-	effectiveMappings := m.getEffectiveMappings(context)
+	// Create and configure the mapping listener; subsearches get the mappings of their own context
+	effectiveMappings, origins, fieldMappings := m.resolveMappings(context)
 	listener := NewFieldMappingListener(stream, effectiveMappings)
+	listener.registry = m.registry
+	listener.setFieldMappings(fieldMappings)
+	listener.origins = origins
+	listener.subqueryScopes = m.subqueryScopes(tree, stream, context)
 
 	// Walk the tree to apply mappings
 	antlr.ParseTreeWalkerDefault.Walk(listener, tree)
 
-	// Get the rewritten query
-	return listener.GetRewrittenText(), nil
+	// Fail if a one-to-many or expression mapping could not be expanded
+	if len(listener.errors) > 0 {
+		return nil, fmt.Errorf("mapping errors: %s", strings.Join(listener.errors, "; "))
+	}
+
+	return newMappingReport(query, listener, effectiveMappings, origins), nil
 }
 
-// extractQueryContextFromString extracts context from query string for conditional mappings
+// extractQueryContextFromString extracts context from query string for conditional mappings.
+// Values found only inside subsearches are left out; subsearches get their own context.
 func (m *Mapper) extractQueryContextFromString(query string) map[string]interface{} {
-	context := make(map[string]interface{})
-
-	// Use the ANTLR listener to extract context information
-	info, err := m.DiscoverQuery(query)
-	if err != nil {
-		return context // Return empty context on error
+	if query == "" {
+		return make(map[string]interface{})
 	}
 
-	// Convert discovered information to context map
-	// Store arrays to support multiple values and any-match evaluation
-	if len(info.SourceTypes) > 0 {
-		if len(info.SourceTypes) == 1 {
-			context["sourcetype"] = info.SourceTypes[0]
-		} else {
-			context["sourcetype"] = info.SourceTypes
-		}
+	input := antlr.NewInputStream(query)
+	lexer := parser.NewSPLLexer(input)
+	stream := antlr.NewCommonTokenStream(newQueryTokenSource(lexer, m.registry), 0)
+	splParser := parser.NewSPLParser(stream)
+
+	splParser.RemoveErrorListeners()
+	lexer.RemoveErrorListeners()
+	errorListener := &CustomErrorListener{
+		DefaultErrorListener: antlr.NewDefaultErrorListener(),
+		errors:               []string{},
 	}
-	if len(info.Sources) > 0 {
-		if len(info.Sources) == 1 {
-			context["source"] = info.Sources[0]
-		} else {
-			context["source"] = info.Sources
+	splParser.AddErrorListener(errorListener)
+	lexer.AddErrorListener(errorListener)
+
+	tree := splParser.Query()
+	if len(errorListener.errors) > 0 {
+		// Discovery still finds macros in queries the grammar cannot parse
+		info, err := m.DiscoverQuery(query)
+		if err != nil {
+			return make(map[string]interface{}) // Return empty context on error
 		}
+		return discoveredContext(info, query)
 	}
-	if len(info.DataModels) > 0 {
-		if len(info.DataModels) == 1 {
-			context["datamodel"] = info.DataModels[0]
-		} else {
-			context["datamodel"] = info.DataModels
-		}
+
+	discovery := newScopeDiscoveryListener(stream, m.registry)
+	antlr.ParseTreeWalkerDefault.Walk(discovery, tree)
+	for _, macro := range m.extractMacros(query) {
+		discovery.root.addMacro(macro)
 	}
 
-	return context
+	return discoveredContext(listenerQueryInfo(discovery.root), query)
+}
+
+// setContextValues stores discovered values under a context key: a single value as a string,
+// several values as a []string
+func setContextValues(context map[string]interface{}, key string, values []string) {
+	switch len(values) {
+	case 0:
+	case 1:
+		context[key] = values[0]
+	default:
+		context[key] = values
+	}
 }
 
 // isValidInputField determines if a field name is likely a valid input field
@@ -312,12 +418,13 @@ func (m *Mapper) isValidInputField(fieldName string) bool {
 		return false
 	}
 
-	// Skip SPL commands and functions
-	commands := []string{"search", "stats", "eval", "where", "by", "as", "count", "sum", "avg", "max", "min",
-		"lookup", "inputlookup", "rename", "sort", "head", "tail", "dedup", "table", "fields", "tstats",
-		"datamodel", "from", "case", "if", "OUTPUT", "output"}
-	for _, cmd := range commands {
-		if strings.EqualFold(fieldName, cmd) {
+	// Skip SPL commands, keywords and functions
+	if _, isCommand := m.registry.LookupCommand(fieldName); isCommand {
+		return false
+	}
+	keywords := []string{"by", "as", "count", "sum", "avg", "max", "min", "case", "if", "output"}
+	for _, keyword := range keywords {
+		if strings.EqualFold(fieldName, keyword) {
 			return false
 		}
 	}
@@ -2,6 +2,7 @@ package mapper
 
 import (
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -327,6 +328,255 @@ func TestConditionalMapping(t *testing.T) {
 	}
 }
 
+// TestConditionalRulePrecedence tests that matching rules apply in file order, so the last
+// rule wins a field several rules map whatever their priority
+func TestConditionalRulePrecedence(t *testing.T) {
+	sourcetype := []Condition{{Type: "sourcetype", Operator: "equals", Value: "access_combined"}}
+	config := &MappingConfig{
+		Version: "1.0",
+		Rules: []ConditionalRule{
+			{ID: "specific", Priority: 1, Enabled: true, Conditions: sourcetype, Mappings: []FieldMapping{{Source: "clientip", Target: "src"}}},
+			{ID: "generic", Priority: 10, Enabled: true, Conditions: sourcetype, Mappings: []FieldMapping{
+				{Source: "clientip", Target: "client"},
+				{Source: "status", Target: "http_status"},
+			}},
+		},
+	}
+
+	result, err := NewWithConfig(config).MapQueryWithContext("search clientip=1.2.3.4 status=404", map[string]interface{}{"sourcetype": "access_combined"})
+	if err != nil {
+		t.Fatalf("Failed to map query: %v", err)
+	}
+	expected := "search client=1.2.3.4 http_status=404"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+
+	// The linter agrees: the later rule shadows the earlier one despite its priority
+	issues := config.Lint(ConfigLintOptions{})
+	if len(issues) != 1 || issues[0].Check != ConfigCheckShadowedRule || issues[0].Location != "rule[0]" {
+		t.Errorf("Expected rule[0] to be shadowed, got %+v", issues)
+	}
+}
+
+// TestDiscoverIndexesHostsCommands tests discovery of indexes, hosts and the commands a query runs
+func TestDiscoverIndexesHostsCommands(t *testing.T) {
+	m := New()
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedIndexes  []string
+		expectedHosts    []string
+		expectedCommands []string
+	}{
+		{
+			name:             "Implicit search",
+			query:            `index=main OR index=security host="web 01" | stats count by host`,
+			expectedIndexes:  []string{"main", "security"},
+			expectedHosts:    []string{"web 01"},
+			expectedCommands: []string{"search", "stats"},
+		},
+		{
+			name:             "Generating command",
+			query:            "| tstats count from datamodel=Web where index=web by host | eval total=count | EVAL x=1",
+			expectedIndexes:  []string{"web"},
+			expectedHosts:    []string{},
+			expectedCommands: []string{"tstats", "eval"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := m.DiscoverQuery(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to discover query info: %v", err)
+			}
+			if !reflect.DeepEqual(info.Indexes, tt.expectedIndexes) {
+				t.Errorf("Expected indexes %v, got %v", tt.expectedIndexes, info.Indexes)
+			}
+			if !reflect.DeepEqual(info.Hosts, tt.expectedHosts) {
+				t.Errorf("Expected hosts %v, got %v", tt.expectedHosts, info.Hosts)
+			}
+			if !reflect.DeepEqual(info.Commands, tt.expectedCommands) {
+				t.Errorf("Expected commands %v, got %v", tt.expectedCommands, info.Commands)
+			}
+		})
+	}
+}
+
+// TestDiscoveredConditionTypes tests conditions on values discovered from the query itself
+func TestDiscoveredConditionTypes(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		query     string
+		expected  string
+	}{
+		{"index", Condition{Type: "index", Operator: "equals", Value: "security"}, "search index=main OR index=security user=bob", "search index=main OR index=security account=bob"},
+		{"index no match", Condition{Type: "index", Operator: "equals", Value: "security"}, "search index=main user=bob", "search index=main user=bob"},
+		{"host", Condition{Type: "host", Operator: "contains", Value: "web"}, "search host=web01 user=bob", "search host=web01 account=bob"},
+		{"datamodel", Condition{Type: "datamodel", Operator: "equals", Value: "Authentication"}, "| tstats count from datamodel=Authentication by user", "| tstats count from datamodel=Authentication by account"},
+		{"dataset", Condition{Type: "dataset", Operator: "equals", Value: "Authentication.Failed_Authentication"}, "| datamodel Authentication Failed_Authentication search | stats count by user", "| datamodel Authentication Failed_Authentication search | stats count by account"},
+		{"lookup", Condition{Type: "lookup", Operator: "equals", Value: "identities"}, "search user=bob | lookup identities user", "search account=bob | lookup identities user"},
+		{"command present", Condition{Type: "command_present", Value: "tstats"}, "| tstats count where index=main by user", "| tstats count where index=main by account"},
+		{"command absent", Condition{Type: "command_present", Operator: "not_exists", Value: "tstats"}, "search user=bob | stats count", "search account=bob | stats count"},
+		{"command absent no match", Condition{Type: "command_present", Operator: "not_exists", Value: "STATS"}, "search user=bob | stats count", "search user=bob | stats count"},
+		{"query regex", Condition{Type: "query_regex", Value: `(?i)earliest=-\d+d`}, "search earliest=-7d user=bob", "search earliest=-7d account=bob"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &MappingConfig{
+				Version: "1.0",
+				Rules: []ConditionalRule{{
+					ID:         "rule",
+					Enabled:    true,
+					Conditions: []Condition{tt.condition},
+					Mappings:   []FieldMapping{{Source: "user", Target: "account"}},
+				}},
+			}
+			if result := config.Validate(); !result.Valid {
+				t.Fatalf("Expected valid config, got %v", result.Errors)
+			}
+
+			result, err := NewWithConfig(config).MapQuery(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to map query: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestScopedConditionalMappings tests that subsearches and search branches get the mappings
+// of their own context
+func TestScopedConditionalMappings(t *testing.T) {
+	sourcetypeRule := func(id, sourcetype, target string) ConditionalRule {
+		return ConditionalRule{
+			ID:         id,
+			Enabled:    true,
+			Conditions: []Condition{{Type: "sourcetype", Operator: "equals", Value: sourcetype}},
+			Mappings:   []FieldMapping{{Source: "user", Target: target}},
+		}
+	}
+	config := &MappingConfig{
+		Version:  "1.0",
+		Mappings: []FieldMapping{{Source: "src", Target: "src_ip"}},
+		Rules: []ConditionalRule{
+			sourcetypeRule("web", "web", "web_user"),
+			sourcetypeRule("vpn", "vpn", "vpn_user"),
+			{
+				ID:         "prod_inputlookup",
+				Enabled:    true,
+				Conditions: []Condition{{Type: "field_value", Field: "env", Operator: "equals", Value: "prod"}, {Type: "command_present", Value: "inputlookup"}},
+				Mappings:   []FieldMapping{{Source: "user", Target: "identity"}},
+			},
+		},
+	}
+	m := NewWithConfig(config)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "append branch",
+			query:    "search sourcetype=web user=bob src=1.2.3.4 | append extendtimerange=true [search sourcetype=vpn user=bob src=1.2.3.4] | stats count by user",
+			expected: "search sourcetype=web web_user=bob src_ip=1.2.3.4 | append extendtimerange=true [search sourcetype=vpn vpn_user=bob src_ip=1.2.3.4] | stats count by web_user",
+		},
+		{
+			name:     "join branch",
+			query:    "search sourcetype=web user=bob | join type=left id [search sourcetype=vpn user=bob src=1.2.3.4]",
+			expected: "search sourcetype=web web_user=bob | join type=left id [search sourcetype=vpn vpn_user=bob src_ip=1.2.3.4]",
+		},
+		{
+			name:     "append branch without options",
+			query:    "search sourcetype=web user=bob | append [search sourcetype=vpn user=bob] | stats count by user",
+			expected: "search sourcetype=web web_user=bob | append [search sourcetype=vpn vpn_user=bob] | stats count by web_user",
+		},
+		{
+			name:     "appendcols branch",
+			query:    "search sourcetype=web user=bob | appendcols [search sourcetype=vpn user=bob | stats count by user]",
+			expected: "search sourcetype=web web_user=bob | appendcols [search sourcetype=vpn vpn_user=bob | stats count by vpn_user]",
+		},
+		{
+			name:     "multisearch branch",
+			query:    "| multisearch [search sourcetype=vpn user=bob]",
+			expected: "| multisearch [search sourcetype=vpn vpn_user=bob]",
+		},
+		{
+			name:     "multisearch branches",
+			query:    "| multisearch [search sourcetype=web user=bob] [search sourcetype=vpn user=bob src=1.2.3.4] | stats count by user",
+			expected: "| multisearch [search sourcetype=web web_user=bob] [search sourcetype=vpn vpn_user=bob src_ip=1.2.3.4] | stats count by user",
+		},
+		{
+			name:     "search subsearch",
+			query:    "search sourcetype=web [search sourcetype=vpn user=bob | fields user] | stats count by user",
+			expected: "search sourcetype=web [search sourcetype=vpn vpn_user=bob | fields vpn_user] | stats count by web_user",
+		},
+		{
+			name:     "branch without sourcetype",
+			query:    "search sourcetype=web user=bob | join user [search index=main user=bob]",
+			expected: "search sourcetype=web web_user=bob | join web_user [search index=main user=bob]",
+		},
+		{
+			name:     "outer query without sourcetype",
+			query:    "search index=main user=bob | append extendtimerange=true [search sourcetype=web user=bob]",
+			expected: "search index=main user=bob | append extendtimerange=true [search sourcetype=web web_user=bob]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := m.MapQuery(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to map query: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+
+	// Context keys that are not discovered from the query are inherited by subsearches
+	query := "search sourcetype=web user=bob | append extendtimerange=true [| inputlookup users.csv | search user=bob]"
+	context := m.extractQueryContextFromString(query)
+	context["env"] = "prod"
+	result, err := m.MapQueryWithContext(query, context)
+	if err != nil {
+		t.Fatalf("Failed to map query: %v", err)
+	}
+	expected := "search sourcetype=web web_user=bob | append extendtimerange=true [| inputlookup users.csv | search identity=bob]"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+
+	// Values found only inside a subsearch are not part of the top-level context
+	if sourcetype := context["sourcetype"]; sourcetype != "web" {
+		t.Errorf("Expected top-level sourcetype 'web', got %v", sourcetype)
+	}
+	if _, found := context["lookup"]; found {
+		t.Errorf("Expected no top-level lookup, got %v", context["lookup"])
+	}
+
+	report, err := m.MapQueryDetailed("search index=main | append extendtimerange=true [search sourcetype=vpn user=bob]")
+	if err != nil {
+		t.Fatalf("Failed to map query: %v", err)
+	}
+	if len(report.Replacements) != 1 || report.Replacements[0].RuleID != "vpn" {
+		t.Errorf("Expected one replacement from rule 'vpn', got %+v", report.Replacements)
+	}
+	for _, field := range report.UnmappedFields {
+		if field == "user" {
+			t.Errorf("Expected 'user' to count as mapped, got unmapped fields %v", report.UnmappedFields)
+		}
+	}
+}
+
 // TestComplexQueryDiscovery tests discovery on complex queries with multiple components
 func TestComplexQueryDiscovery(t *testing.T) {
 	m := New()
@@ -1062,3 +1312,538 @@ func TestMapperValidateQuery(t *testing.T) {
 		})
 	}
 }
+
+// TestMappingCoverageAcrossCommands tests that field reads are mapped in every supported command
+func TestMappingCoverageAcrossCommands(t *testing.T) {
+	m := New()
+
+	mappings := []FieldMapping{
+		{Source: "src_ip", Target: "source_ip"},
+		{Source: "dest", Target: "dest_host"},
+		{Source: "user", Target: "user_name"},
+		{Source: "msg", Target: "message"},
+		{Source: "payload", Target: "body"},
+		{Source: "field", Target: "field_renamed"},
+		{Source: "limit", Target: "limit_renamed"},
+		{Source: "dc", Target: "dc_renamed"},
+		{Source: "h", Target: "hour"},
+	}
+
+	jsonData, _ := json.Marshal(mappings)
+	if err := m.LoadMappings(jsonData); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Search filters map field names but not values or literals",
+			input:    `search src_ip=10.0.0.1 user=dest "src_ip"`,
+			expected: `search source_ip=10.0.0.1 user_name=dest "src_ip"`,
+		},
+		{
+			name:     "Eval right-hand side is mapped, assignment target is not",
+			input:    "search a | eval src_ip=lower(dest) | eval total=src_ip+dest",
+			expected: "search a | eval src_ip=lower(dest_host) | eval total=src_ip+dest_host",
+		},
+		{
+			name:     "Where expressions map both sides of comparisons",
+			input:    `search a | where src_ip!=dest AND like(user, "adm%")`,
+			expected: `search a | where source_ip!=dest_host AND like(user_name, "adm%")`,
+		},
+		{
+			name:     "IN lists map the field but not the values",
+			input:    `search user IN ("dest", "src_ip")`,
+			expected: `search user_name IN ("dest", "src_ip")`,
+		},
+		{
+			name:     "Stats function arguments and by clause",
+			input:    "search a | stats count(src_ip) dc(user) as unique_users by dest",
+			expected: "search a | stats count(source_ip) dc(user_name) as unique_users by dest_host",
+		},
+		{
+			name:     "Field list commands",
+			input:    "search a | table user src_ip | sort user | dedup src_ip dest | fields user",
+			expected: "search a | table user_name source_ip | sort user_name | dedup source_ip dest_host | fields user_name",
+		},
+		{
+			name:     "Top keeps options and maps fields",
+			input:    "search a | top limit=10 src_ip by dest",
+			expected: "search a | top limit=10 source_ip by dest_host",
+		},
+		{
+			name:     "Rex maps field= value and leaves the regex untouched",
+			input:    `search a | rex field=msg "(?<user>\w+)"`,
+			expected: `search a | rex field=message "(?<user>\w+)"`,
+		},
+		{
+			name:     "Spath maps the input field",
+			input:    "search a | spath input=payload path=user",
+			expected: "search a | spath input=body path=user",
+		},
+		{
+			name:     "Time spans are literals",
+			input:    "search a | bin _time span=1h | timechart span=1h count by user",
+			expected: "search a | bin _time span=1h | timechart span=1h count by user_name",
+		},
+		{
+			name:     "Rename maps the source field only",
+			input:    "search a | rename src_ip as dest",
+			expected: "search a | rename source_ip as dest",
+		},
+		{
+			name:     "Lookup maps the event field after AS",
+			input:    "search a | lookup geo ip as src_ip",
+			expected: "search a | lookup geo ip as source_ip",
+		},
+		{
+			name:     "Leading pipe commands are recognized",
+			input:    "| fields - src_ip user",
+			expected: "| fields - source_ip user_name",
+		},
+		{
+			name:     "Subsearches are mapped",
+			input:    "search x [search user=a | fields user]",
+			expected: "search x [search user_name=a | fields user_name]",
+		},
+		{
+			name:     "Join keys are mapped",
+			input:    "search a | join user [search b | fields user src_ip]",
+			expected: "search a | join user_name [search b | fields user_name source_ip]",
+		},
+		{
+			name:     "Join options are kept",
+			input:    "search a | join type=left max=0 user src_ip [search b]",
+			expected: "search a | join type=left max=0 user_name source_ip [search b]",
+		},
+		{
+			name:     "Concatenation operands are mapped",
+			input:    `search a | eval x=src_ip."a" | eval y="a".src_ip.user | where src_ip.dest!="x"`,
+			expected: `search a | eval x=source_ip."a" | eval y="a".source_ip.user_name | where source_ip.dest_host!="x"`,
+		},
+		{
+			name:     "Numbers with dots are not concatenations",
+			input:    "search a | where src_ip=10.0.0.1 | eval x=dest+1.5",
+			expected: "search a | where source_ip=10.0.0.1 | eval x=dest_host+1.5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := m.MapQuery(tt.input)
+			if err != nil {
+				t.Fatalf("Failed to map query: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestMapQueryDetailed tests that the mapping report explains every rewrite
+func TestMapQueryDetailed(t *testing.T) {
+	config := &MappingConfig{
+		Version: "1.0",
+		Mappings: []FieldMapping{
+			{ID: "ip", Source: "src_ip", Target: "source_ip"},
+			{Source: "bytes", Target: "bytes_total"},
+		},
+		Rules: []ConditionalRule{
+			{
+				ID: "apache",
+				Conditions: []Condition{
+					{Type: "sourcetype", Operator: "equals", Value: "access_combined"},
+				},
+				Mappings: []FieldMapping{
+					{Source: "clientip", Target: "client_address"},
+				},
+				Enabled: true,
+			},
+		},
+	}
+
+	m := NewWithConfig(config)
+
+	query := "search sourcetype=access_combined clientip=10.0.0.1 | stats count by src_ip status"
+	report, err := m.MapQueryDetailed(query)
+	if err != nil {
+		t.Fatalf("Failed to map query: %v", err)
+	}
+
+	expectedQuery := "search sourcetype=access_combined client_address=10.0.0.1 | stats count by source_ip status"
+	if report.MappedQuery != expectedQuery {
+		t.Errorf("Expected %q, got %q", expectedQuery, report.MappedQuery)
+	}
+	if report.OriginalQuery != query {
+		t.Errorf("Expected original query %q, got %q", query, report.OriginalQuery)
+	}
+
+	if len(report.Replacements) != 2 {
+		t.Fatalf("Expected 2 replacements, got %d: %+v", len(report.Replacements), report.Replacements)
+	}
+
+	first := report.Replacements[0]
+	if first.Original != "clientip" || first.Replacement != "client_address" {
+		t.Errorf("Unexpected first replacement: %+v", first)
+	}
+	if first.Offset != strings.Index(query, "clientip") || first.Line != 1 || first.Column != first.Offset {
+		t.Errorf("Unexpected position for first replacement: %+v", first)
+	}
+	if first.Command != "search" || first.RuleID != "apache" {
+		t.Errorf("Expected replacement in search caused by rule apache, got %+v", first)
+	}
+
+	second := report.Replacements[1]
+	if second.Original != "src_ip" || second.Command != "stats" || second.MappingID != "ip" || second.RuleID != "" {
+		t.Errorf("Unexpected second replacement: %+v", second)
+	}
+
+	if !contains(report.UnmappedFields, "status") {
+		t.Errorf("Expected 'status' to be reported as unmapped, got %v", report.UnmappedFields)
+	}
+	if contains(report.UnmappedFields, "src_ip") {
+		t.Errorf("Expected mapped field 'src_ip' not to be reported as unmapped, got %v", report.UnmappedFields)
+	}
+
+	if len(report.UnusedMappings) != 1 || report.UnusedMappings[0].Source != "bytes" {
+		t.Errorf("Expected only 'bytes' mapping to be unused, got %+v", report.UnusedMappings)
+	}
+}
+
+// TestDerivedFieldShadowing tests that fields derived by the query are not remapped afterwards
+func TestDerivedFieldShadowing(t *testing.T) {
+	m := New()
+
+	mappings := []FieldMapping{
+		{Source: "src_ip", Target: "source_ip"},
+		{Source: "user", Target: "user_name"},
+		{Source: "dest", Target: "dest_host"},
+	}
+
+	jsonData, _ := json.Marshal(mappings)
+	if err := m.LoadMappings(jsonData); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Eval derivation shadows later references",
+			input:    "search src_ip=1 | eval src_ip=lower(src_ip) | where src_ip=dest",
+			expected: "search source_ip=1 | eval src_ip=lower(source_ip) | where src_ip=dest_host",
+		},
+		{
+			name:     "Rename target shadows later references",
+			input:    "search a | rename dest as user | stats count by user",
+			expected: "search a | rename dest_host as user | stats count by user",
+		},
+		{
+			name:     "Lookup OUTPUT fields shadow later references",
+			input:    "search a | lookup users uid OUTPUT user | table user dest",
+			expected: "search a | lookup users uid OUTPUT user | table user dest_host",
+		},
+		{
+			name:     "Rex named groups shadow later references",
+			input:    `search a | rex field=dest "(?<user>\w+)@" | table user`,
+			expected: `search a | rex field=dest_host "(?<user>\w+)@" | table user`,
+		},
+		{
+			name:     "Subsearch derivations do not leak into the outer search",
+			input:    "search user=a [search b | eval user=dest | fields user] | table user",
+			expected: "search user_name=a [search b | eval user=dest_host | fields user] | table user_name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := m.MapQuery(tt.input)
+			if err != nil {
+				t.Fatalf("Failed to map query: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestMappingCollisions tests that derived fields clashing with a mapping target are reported
+func TestMappingCollisions(t *testing.T) {
+	config := &MappingConfig{
+		Version: "1.0",
+		Mappings: []FieldMapping{
+			{ID: "ip", Source: "src_ip", Target: "source_ip"},
+		},
+	}
+
+	m := NewWithConfig(config)
+
+	query := "search src_ip=1 | eval source_ip=lower(host) | table src_ip source_ip"
+	report, err := m.MapQueryDetailed(query)
+	if err != nil {
+		t.Fatalf("Failed to map query: %v", err)
+	}
+
+	if len(report.Collisions) != 1 {
+		t.Fatalf("Expected 1 collision, got %d: %+v", len(report.Collisions), report.Collisions)
+	}
+
+	collision := report.Collisions[0]
+	if collision.Field != "source_ip" || collision.Source != "src_ip" || collision.MappingID != "ip" {
+		t.Errorf("Unexpected collision: %+v", collision)
+	}
+	if collision.Command != "eval" || collision.Offset != strings.Index(query, "source_ip") {
+		t.Errorf("Unexpected collision position: %+v", collision)
+	}
+}
+
+// TestValueMappings tests that literal values compared against a mapped field are transformed
+func TestValueMappings(t *testing.T) {
+	m := New()
+
+	mappings := []FieldMapping{
+		{Source: "action", Target: "action", Values: map[string]string{"blocked": "deny", "allowed": "allow"}},
+		{Source: "severity", Target: "severity_level", Values: map[string]string{"3": "high", "1": "low"}},
+		{Source: "user", Target: "user_name", Case: ValueCaseLower},
+		{Source: "host", Target: "dest_host", Replace: []ValueReplacement{{Pattern: `\.corp\.example\.com$`, Replacement: ""}}},
+		{Source: "status", Target: "status", Values: map[string]string{"fail": "failure reported"}},
+	}
+
+	jsonData, _ := json.Marshal(mappings)
+	if err := m.LoadMappings(jsonData); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Search key=value literals",
+			input:    `search action=blocked severity=3 user="BOB"`,
+			expected: `search action=deny severity_level=high user_name="bob"`,
+		},
+		{
+			name:     "Not-equal comparisons are transformed, ordering comparisons are not",
+			input:    "search action!=allowed severity>3",
+			expected: "search action!=allow severity_level>3",
+		},
+		{
+			name:     "IN lists",
+			input:    `search action IN (blocked, "allowed", other)`,
+			expected: `search action IN (deny, "allow", other)`,
+		},
+		{
+			name:     "Where compares against string literals only",
+			input:    `search a | where action="blocked" AND user=other`,
+			expected: `search a | where action="deny" AND user_name=other`,
+		},
+		{
+			name:     "Where quotes values that are not numbers",
+			input:    `search a | where severity=3 OR severity!=1 | where severity IN (3, "1")`,
+			expected: `search a | where severity_level="high" OR severity_level!="low" | where severity_level IN ("high", "low")`,
+		},
+		{
+			name:     "Eval assignments are not values",
+			input:    "search a | eval severity=3",
+			expected: "search a | eval severity=3",
+		},
+		{
+			name:     "Regex replacements",
+			input:    "search host=web01.corp.example.com",
+			expected: "search dest_host=web01",
+		},
+		{
+			name:     "Values that need quoting are quoted",
+			input:    "search status=fail",
+			expected: `search status="failure reported"`,
+		},
+		{
+			name:     "Derived fields keep their values",
+			input:    `search a | eval action="x" | where action="blocked"`,
+			expected: `search a | eval action="x" | where action="blocked"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := m.MapQuery(tt.input)
+			if err != nil {
+				t.Fatalf("Failed to map query: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestValueMappingReport tests that value changes are reported alongside field renames
+func TestValueMappingReport(t *testing.T) {
+	config := &MappingConfig{
+		Version: "1.0",
+		Mappings: []FieldMapping{
+			{ID: "action", Source: "action", Target: "act", Values: map[string]string{"blocked": "deny"}},
+		},
+	}
+
+	report, err := NewWithConfig(config).MapQueryDetailed("search action=blocked")
+	if err != nil {
+		t.Fatalf("Failed to map query: %v", err)
+	}
+
+	if len(report.Replacements) != 2 {
+		t.Fatalf("Expected 2 replacements, got %d: %+v", len(report.Replacements), report.Replacements)
+	}
+
+	value := report.Replacements[1]
+	if value.Kind != ReplacementValue || value.Field != "action" || value.Original != "blocked" || value.Replacement != "deny" {
+		t.Errorf("Unexpected value replacement: %+v", value)
+	}
+	if value.Offset != 14 || value.MappingID != "action" {
+		t.Errorf("Unexpected value replacement position or origin: %+v", value)
+	}
+	if report.Replacements[0].Kind != ReplacementField {
+		t.Errorf("Expected first replacement to be a field rename, got %+v", report.Replacements[0])
+	}
+}
+
+// TestValueMappingValidation tests that invalid value transformations are rejected
+func TestValueMappingValidation(t *testing.T) {
+	config := &MappingConfig{
+		Version: "1.0",
+		Mappings: []FieldMapping{
+			{Source: "a", Target: "b", Case: "title"},
+			{Source: "c", Target: "d", Replace: []ValueReplacement{{Pattern: "(", Replacement: ""}}},
+		},
+	}
+
+	result := config.Validate()
+	if result.Valid || len(result.Errors) != 2 {
+		t.Errorf("Expected 2 validation errors, got %+v", result)
+	}
+
+	m := New()
+	if err := m.LoadMappings([]byte(`[{"source": "a", "target": "b", "case": "title"}]`)); err == nil {
+		t.Error("Expected LoadMappings to reject an invalid case transform")
+	}
+}
+
+// TestExpansionMappings tests mappings to field sets and expressions in each query context
+func TestExpansionMappings(t *testing.T) {
+	m := New()
+
+	mappings := []FieldMapping{
+		{Source: "src", Expression: "coalesce(src_ip, src_host)"},
+		{Source: "user", Targets: []string{"user", "user_name"}},
+		{Source: "bytes", Expression: "bytes_in + bytes_out"},
+		{Source: "dest", Target: "dest_host"},
+	}
+
+	jsonData, _ := json.Marshal(mappings)
+	if err := m.LoadMappings(jsonData); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Search filters on field sets become OR groups",
+			input:    "search user=bob dest=x",
+			expected: "search (user=bob OR user_name=bob) dest_host=x",
+		},
+		{
+			name:     "Negated filters become AND groups",
+			input:    "search user!=bob",
+			expected: "search (user!=bob AND user_name!=bob)",
+		},
+		{
+			name:     "IN filters are expanded per target",
+			input:    "search user IN (a, b)",
+			expected: "search (user IN (a, b) OR user_name IN (a, b))",
+		},
+		{
+			name:     "Where clauses use OR groups and inline expressions",
+			input:    `search a | where user=dest AND src="h1"`,
+			expected: `search a | where (user=dest_host OR user_name=dest_host) AND coalesce(src_ip, src_host)="h1"`,
+		},
+		{
+			name:     "Eval uses expressions inline",
+			input:    "search a | eval total=bytes+1 | eval who=user",
+			expected: "search a | eval total=(bytes_in + bytes_out)+1 | eval who=coalesce(user, user_name)",
+		},
+		{
+			name:     "Stats by fields are computed by a preceding eval",
+			input:    "search a | stats count by src user",
+			expected: "search a | eval src=coalesce(src_ip, src_host), user=coalesce(user, user_name) | stats count by src user",
+		},
+		{
+			name:     "Fields computed by a preceding eval are not expanded again",
+			input:    "search a | search src=h1 | table src",
+			expected: "search a | eval src=coalesce(src_ip, src_host) | search src=h1 | table src",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := m.MapQuery(tt.input)
+			if err != nil {
+				t.Fatalf("Failed to map query: %v", err)
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestExpansionMappingErrors tests that expansions without a valid rewrite are refused
+func TestExpansionMappingErrors(t *testing.T) {
+	m := New()
+
+	mappings := []FieldMapping{
+		{Source: "src", Expression: "coalesce(src_ip, src_host)"},
+	}
+
+	jsonData, _ := json.Marshal(mappings)
+	if err := m.LoadMappings(jsonData); err != nil {
+		t.Fatalf("Failed to load mappings: %v", err)
+	}
+
+	for _, query := range []string{"search src=h1", "| tstats count by src"} {
+		_, err := m.MapQuery(query)
+		if err == nil {
+			t.Errorf("Expected an error mapping %q", query)
+			continue
+		}
+		if !strings.Contains(err.Error(), "cannot expand mapping for field 'src'") {
+			t.Errorf("Expected a clear expansion error for %q, got: %v", query, err)
+		}
+	}
+
+	config := &MappingConfig{
+		Version: "1.0",
+		Mappings: []FieldMapping{
+			{Source: "a", Target: "b", Expression: "lower(a)"},
+			{Source: "c"},
+		},
+	}
+	if result := config.Validate(); result.Valid || len(result.Errors) != 2 {
+		t.Errorf("Expected 2 validation errors, got %+v", result)
+	}
+}
@@ -0,0 +1,175 @@
+package mapper
+
+import (
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/delgado-jacob/spl-toolkit/parser"
+)
+
+// discoveredContextKeys are the context keys filled from query discovery. Each subsearch and
+// append/join/multisearch branch replaces them with its own values; other keys are inherited.
+var discoveredContextKeys = []string{
+	"sourcetype", "source", "index", "host", "datamodel", "dataset", "lookup", "macro", "command", "query",
+}
+
+// mappingScope holds the effective mappings of the top-level query or of a subsearch
+type mappingScope struct {
+	mappings map[string]string
+	targets  map[string]string // Target field -> source field
+	origins  map[string]MappingOrigin
+
+	// Value transformations by source field, applied to literals compared against the field
+	valueTransforms map[string]*valueTransform
+	// Mappings to field sets or expressions by source field
+	expansions map[string]FieldMapping
+}
+
+// newMappingScope builds a scope from effective mappings, their origins and the full mapping of each source field
+func newMappingScope(mappings map[string]string, origins map[string]MappingOrigin, fields map[string]FieldMapping) mappingScope {
+	scope := mappingScope{
+		mappings:        mappings,
+		targets:         make(map[string]string),
+		origins:         origins,
+		valueTransforms: make(map[string]*valueTransform),
+		expansions:      make(map[string]FieldMapping),
+	}
+	for source, target := range mappings {
+		scope.targets[target] = source
+	}
+	for source, mapping := range fields {
+		if transform, err := newValueTransform(mapping); err == nil && transform != nil {
+			scope.valueTransforms[source] = transform
+		}
+		if mapping.isExpansion() {
+			scope.expansions[source] = mapping
+		}
+	}
+	return scope
+}
+
+// subqueryScopes resolves the mappings of every subsearch in tree. Conditional rules are
+// evaluated against the subsearch's own discovered values, on top of the non-discovered
+// keys of the query context. Without rules every subsearch uses the top-level mappings.
+func (m *Mapper) subqueryScopes(tree antlr.ParseTree, stream antlr.TokenStream, context map[string]interface{}) map[*parser.SubqueryContext]mappingScope {
+	if m.config == nil || context == nil || len(m.config.Rules) == 0 {
+		return nil
+	}
+
+	discovery := newScopeDiscoveryListener(stream, m.registry)
+	antlr.ParseTreeWalkerDefault.Walk(discovery, tree)
+
+	scopes := make(map[*parser.SubqueryContext]mappingScope, len(discovery.subqueries))
+	for subquery, listener := range discovery.subqueries {
+		scopeContext := make(map[string]interface{}, len(context))
+		for key, value := range context {
+			scopeContext[key] = value
+		}
+		for _, key := range discoveredContextKeys {
+			delete(scopeContext, key)
+		}
+		for key, value := range discovery.context(subquery, listener) {
+			scopeContext[key] = value
+		}
+
+		mappings, origins, fields := m.resolveMappings(scopeContext)
+		scopes[subquery] = newMappingScope(mappings, origins, fields)
+	}
+	return scopes
+}
+
+// scopeDiscoveryListener runs a separate FieldDiscoveryListener for the top-level query and
+// for each subsearch, so that every scope only sees its own values
+type scopeDiscoveryListener struct {
+	parser.BaseSPLParserListener
+
+	stream     antlr.TokenStream
+	registry   *CommandRegistry
+	root       *FieldDiscoveryListener
+	stack      []*FieldDiscoveryListener
+	subqueries map[*parser.SubqueryContext]*FieldDiscoveryListener
+}
+
+func newScopeDiscoveryListener(stream antlr.TokenStream, registry *CommandRegistry) *scopeDiscoveryListener {
+	root := NewFieldDiscoveryListener()
+	root.registry = registry
+	return &scopeDiscoveryListener{
+		stream:     stream,
+		registry:   registry,
+		root:       root,
+		stack:      []*FieldDiscoveryListener{root},
+		subqueries: make(map[*parser.SubqueryContext]*FieldDiscoveryListener),
+	}
+}
+
+func (l *scopeDiscoveryListener) current() *FieldDiscoveryListener {
+	return l.stack[len(l.stack)-1]
+}
+
+// EnterEveryRule opens a new scope at each subsearch and forwards the rule to the current scope
+func (l *scopeDiscoveryListener) EnterEveryRule(ctx antlr.ParserRuleContext) {
+	if subquery, ok := ctx.(*parser.SubqueryContext); ok {
+		listener := NewFieldDiscoveryListener()
+		listener.registry = l.registry
+		l.subqueries[subquery] = listener
+		l.stack = append(l.stack, listener)
+	}
+	ctx.EnterRule(l.current())
+}
+
+// ExitEveryRule forwards the rule to the current scope and closes subsearch scopes
+func (l *scopeDiscoveryListener) ExitEveryRule(ctx antlr.ParserRuleContext) {
+	ctx.ExitRule(l.current())
+	if _, ok := ctx.(*parser.SubqueryContext); ok {
+		l.stack = l.stack[:len(l.stack)-1]
+	}
+}
+
+// context converts the values discovered in a subsearch to a mapping context. The query key
+// holds the subsearch text without its brackets.
+func (l *scopeDiscoveryListener) context(subquery *parser.SubqueryContext, listener *FieldDiscoveryListener) map[string]interface{} {
+	start, stop := subquery.GetStart().GetTokenIndex(), subquery.GetStop().GetTokenIndex()
+	if subquery.LBRACK() != nil {
+		start++
+	}
+	if subquery.RBRACK() != nil {
+		stop--
+	}
+	text := l.stream.GetTextFromInterval(antlr.NewInterval(start, stop))
+
+	return discoveredContext(listenerQueryInfo(listener), text)
+}
+
+// listenerQueryInfo returns the values a discovery listener found
+func listenerQueryInfo(listener *FieldDiscoveryListener) *QueryInfo {
+	return &QueryInfo{
+		DataModels:  listener.DataModels,
+		Datasets:    listener.Datasets,
+		Lookups:     listener.Lookups,
+		Macros:      listener.Macros,
+		Sources:     listener.Sources,
+		SourceTypes: listener.SourceTypes,
+		Indexes:     listener.Indexes,
+		Hosts:       listener.Hosts,
+		InputFields: listener.InputFields,
+		Commands:    listener.Commands,
+	}
+}
+
+// discoveredContext converts discovered query information to a mapping context. Values are
+// stored as lists to support multiple values and any-match evaluation.
+func discoveredContext(info *QueryInfo, query string) map[string]interface{} {
+	context := make(map[string]interface{})
+	setContextValues(context, "sourcetype", info.SourceTypes)
+	setContextValues(context, "source", info.Sources)
+	setContextValues(context, "index", info.Indexes)
+	setContextValues(context, "host", info.Hosts)
+	setContextValues(context, "datamodel", info.DataModels)
+	setContextValues(context, "dataset", info.Datasets)
+	setContextValues(context, "lookup", info.Lookups)
+	setContextValues(context, "macro", info.Macros)
+	setContextValues(context, "command", info.Commands)
+
+	// The raw query, for query_regex conditions
+	context["query"] = query
+
+	return context
+}
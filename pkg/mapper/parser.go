@@ -19,6 +19,7 @@ type ASTNode struct {
 // Parser handles SPL query parsing using ANTLR4
 type Parser struct {
 	errorListener *CustomErrorListener
+	registry      *CommandRegistry
 }
 
 // CustomErrorListener handles parse errors
@@ -31,13 +32,21 @@ func (c *CustomErrorListener) SyntaxError(recognizer antlr.Recognizer, offending
 	c.errors = append(c.errors, fmt.Sprintf("line %d:%d %s", line, column, msg))
 }
 
-// NewParser creates a new Parser instance
+// NewParser creates a new Parser instance that accepts the custom commands and functions of
+// the default registry
 func NewParser() *Parser {
+	return NewParserWithRegistry(defaultRegistry)
+}
+
+// NewParserWithRegistry creates a new Parser instance that accepts the custom commands and
+// functions of registry
+func NewParserWithRegistry(registry *CommandRegistry) *Parser {
 	return &Parser{
 		errorListener: &CustomErrorListener{
 			DefaultErrorListener: antlr.NewDefaultErrorListener(),
 			errors:               []string{},
 		},
+		registry: registry.orDefault(),
 	}
 }
 
@@ -59,7 +68,7 @@ func (p *Parser) Parse(query string) (*ASTNode, error) {
 	lexer.AddErrorListener(p.errorListener)
 
 	// Create token stream
-	stream := antlr.NewCommonTokenStream(lexer, 0)
+	stream := antlr.NewCommonTokenStream(newQueryTokenSource(lexer, p.registry), 0)
 
 	// Create parser
 	splParser := parser.NewSPLParser(stream)
@@ -0,0 +1,435 @@
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/delgado-jacob/spl-toolkit/parser"
+)
+
+// QueryCostEstimate is a static estimate of how expensive a query is to run
+type QueryCostEstimate struct {
+	Query   string            `json:"query"`
+	Score   int               `json:"score"`  // Sum of the points of the factors
+	Rating  string            `json:"rating"` // QueryCostLow, QueryCostMedium or QueryCostHigh
+	Factors []QueryCostFactor `json:"factors"`
+}
+
+// QueryCostFactor is a part of a query that adds to its cost
+type QueryCostFactor struct {
+	Factor     string `json:"factor"` // One of the QueryCost factor constants
+	Points     int    `json:"points"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"` // Suggested rewrite
+	Line       int    `json:"line"`                 // 1-based line of the part of the query
+	Column     int    `json:"column"`               // 1-based column of the part of the query, in characters
+	Offset     int    `json:"offset"`               // 0-based character offset of the part of the query
+	EndOffset  int    `json:"end_offset"`           // Character offset just past the part of the query
+}
+
+// Ratings of query cost scores
+const (
+	QueryCostLow    = "low"    // Score below 20
+	QueryCostMedium = "medium" // Score from 20 to 49
+	QueryCostHigh   = "high"   // Score of 50 or more
+)
+
+// Factors of query cost estimates
+const (
+	QueryCostMissingIndex       = "missing-index"
+	QueryCostMissingTimeBounds  = "missing-time-bounds"
+	QueryCostSearchHeadCommands = "search-head-commands"
+	QueryCostSubsearch          = "subsearch"
+	QueryCostJoin               = "join"
+	QueryCostTransaction        = "transaction"
+	QueryCostAppend             = "append"
+	QueryCostRegex              = "regex"
+	QueryCostTstatsCandidate    = "tstats-candidate"
+)
+
+// Points of the cost factors
+const (
+	missingIndexPoints         = 30
+	missingTimeBoundsPoints    = 10
+	searchHeadCommandPoints    = 5
+	subsearchPoints            = 10
+	joinPoints                 = 20
+	transactionPoints          = 20
+	unboundedTransactionPoints = 10
+	appendPoints               = 10
+	regexPoints                = 5
+	heavyRegexPoints           = 10
+	tstatsCandidatePoints      = 15
+	mediumCostScore            = 20
+	highCostScore              = 50
+)
+
+// timeBoundCommands read events over the search time range
+var timeBoundCommands = map[string]bool{
+	"datamodel":   true,
+	"from":        true,
+	"mstats":      true,
+	"multisearch": true,
+	"search":      true,
+	"tstats":      true,
+}
+
+// indexedFields are the fields tstats can read without the raw events
+var indexedFields = map[string]bool{
+	"_time": true, "host": true, "index": true, "source": true, "sourcetype": true, "splunk_server": true,
+}
+
+// tstatsFunctions are the stats functions tstats supports over indexed fields
+var tstatsFunctions = map[string]bool{
+	"count": true, "dc": true, "distinct_count": true, "earliest": true, "latest": true,
+	"max": true, "min": true, "values": true,
+}
+
+// unboundedRegexPattern matches the ".*" and ".+" parts that make regexes backtrack
+var unboundedRegexPattern = regexp.MustCompile(`\.[*+]`)
+
+// EstimateQueryCost scores a query from its parse tree: searches without an index or time
+// bounds, commands that run on the search head, subsearches, join, transaction and append,
+// expensive regexes and stats searches that tstats could answer. Each factor comes with the
+// part of the query it applies to and, where possible, a suggested rewrite.
+func EstimateQueryCost(query string) (*QueryCostEstimate, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	text, _ := blankQueryComments(query)
+	parsed, err := parseLintQuery(text, defaultRegistry)
+	if err != nil {
+		return nil, err
+	}
+
+	estimator := &queryCostEstimator{query: parsed, runes: []rune(text)}
+	estimator.estimateSources()
+	for _, pipeline := range parsed.Pipelines {
+		estimator.estimatePipeline(pipeline)
+	}
+	estimator.estimateTstats()
+
+	sort.SliceStable(estimator.factors, func(i, j int) bool {
+		return estimator.factors[i].Offset < estimator.factors[j].Offset
+	})
+
+	estimate := &QueryCostEstimate{Query: query, Rating: QueryCostLow, Factors: estimator.factors}
+	if estimate.Factors == nil {
+		estimate.Factors = []QueryCostFactor{}
+	}
+	for _, factor := range estimate.Factors {
+		estimate.Score += factor.Points
+	}
+	switch {
+	case estimate.Score >= highCostScore:
+		estimate.Rating = QueryCostHigh
+	case estimate.Score >= mediumCostScore:
+		estimate.Rating = QueryCostMedium
+	}
+	return estimate, nil
+}
+
+type queryCostEstimator struct {
+	query   *LintQuery
+	runes   []rune
+	factors []QueryCostFactor
+}
+
+func (e *queryCostEstimator) add(factor string, points int, start, stop antlr.Token, suggestion, format string, args ...interface{}) {
+	offset := start.GetStart()
+	line, column := textPosition(e.runes, offset)
+	e.factors = append(e.factors, QueryCostFactor{
+		Factor:     factor,
+		Points:     points,
+		Message:    fmt.Sprintf(format, args...),
+		Suggestion: suggestion,
+		Line:       line,
+		Column:     column,
+		Offset:     offset,
+		EndOffset:  stop.GetStop() + 1,
+	})
+}
+
+// textOf returns the query text from the start of one token to the end of another
+func (e *queryCostEstimator) textOf(start, stop antlr.Token) string {
+	return strings.TrimSpace(string(e.runes[start.GetStart() : stop.GetStop()+1]))
+}
+
+// estimateSources looks for searches without an index and a query without time bounds
+func (e *queryCostEstimator) estimateSources() {
+	for index, pipeline := range e.query.Pipelines {
+		if len(pipeline) == 0 {
+			continue
+		}
+		first := pipeline[0]
+		tokens := e.query.CommandTokens(first)
+
+		// tstats over a datamodel reads its accelerated summaries, which the datamodel
+		// constraints restrict to their indexes already
+		if first.Name == "search" || first.Name == "tstats" && !readsDatamodel(tokens) {
+			term := indexTerm(tokens)
+			switch {
+			case term == nil:
+				e.add(QueryCostMissingIndex, missingIndexPoints, first.NameToken(), first.Node.GetStop(),
+					"add index=<name> for the indexes that hold the data",
+					"%s does not specify an index and reads every default index", first.Name)
+			case isAllIndexes(tokens, term):
+				e.add(QueryCostMissingIndex, missingIndexPoints, term, first.Node.GetStop(),
+					"replace index=* with the indexes that hold the data",
+					"index=* reads every index the user can access")
+			}
+		}
+
+		if index == 0 && timeBoundCommands[first.Name] && !hasTimeBounds(tokens) {
+			e.add(QueryCostMissingTimeBounds, missingTimeBoundsPoints, first.NameToken(), first.Node.GetStop(),
+				"add earliest= and latest=, or make sure the query is always run over a bounded time range",
+				"query does not set earliest or latest; its cost grows with the time range it is run over")
+		}
+	}
+}
+
+// readsDatamodel reports whether the tokens of a tstats command hold a datamodel= term
+func readsDatamodel(tokens []antlr.Token) bool {
+	for i, token := range tokens {
+		if i+1 < len(tokens) && strings.EqualFold(token.GetText(), "datamodel") &&
+			tokens[i+1].GetTokenType() == parser.SPLLexerEQ {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllIndexes reports whether an index term is index=*
+func isAllIndexes(tokens []antlr.Token, term antlr.Token) bool {
+	for i, token := range tokens {
+		if token == term && i+2 < len(tokens) {
+			value := tokens[i+2]
+			return tokens[i+1].GetTokenType() == parser.SPLLexerEQ &&
+				(value.GetText() == "*" || value.GetText() == `"*"`) &&
+				(i+3 >= len(tokens) || tokens[i+3].GetStart() != value.GetStop()+1)
+		}
+	}
+	return false
+}
+
+// hasTimeBounds reports whether tokens set earliest= or latest=
+func hasTimeBounds(tokens []antlr.Token) bool {
+	for i, token := range tokens {
+		switch strings.ToLower(token.GetText()) {
+		case "earliest", "latest", "_index_earliest", "_index_latest":
+			if i+1 < len(tokens) && tokens[i+1].GetTokenType() == parser.SPLLexerEQ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// estimatePipeline scores the commands of a query or subsearch
+func (e *queryCostEstimator) estimatePipeline(pipeline []LintCommand) {
+	if len(pipeline) > 0 && pipeline[0].Pipeline > 0 {
+		first := pipeline[0]
+		e.add(QueryCostSubsearch, subsearchPoints, first.Node.GetStart(), pipeline[len(pipeline)-1].Node.GetStop(), "",
+			"subsearch runs before the outer search and is limited in results and run time")
+	}
+
+	// Streaming commands after a centralized command run on the search head
+	var centralized *LintCommand
+	var searchHead []string
+	flush := func() {
+		if centralized != nil && len(searchHead) > 0 {
+			e.addSearchHeadCommands(*centralized, searchHead)
+		}
+		searchHead = nil
+	}
+	for i, command := range pipeline {
+		token := command.NameToken()
+		switch command.Name {
+		case "join":
+			fields := commandFieldArguments(command)
+			suggestion := "search both datasets at once and combine them with stats"
+			if len(fields) > 0 {
+				suggestion = fmt.Sprintf("search both datasets at once and combine them with | stats values(*) as * by %s", strings.Join(fields, " "))
+			}
+			e.add(QueryCostJoin, joinPoints, token, token, suggestion,
+				"join runs its subsearch separately and holds its results in memory")
+		case "transaction":
+			points, message := transactionPoints, "transaction groups events on the search head"
+			if command.Option("maxspan") == nil {
+				points += unboundedTransactionPoints
+				message += " and, without maxspan=, keeps transactions open over the whole time range"
+			}
+			suggestion := "use stats min(_time) max(_time) values(...) when events do not need start or end conditions"
+			if fields := commandFieldArguments(command); len(fields) > 0 {
+				suggestion = fmt.Sprintf("use | stats min(_time) as start max(_time) as end values(*) as * by %s when events do not need start or end conditions", strings.Join(fields, " "))
+			}
+			e.add(QueryCostTransaction, points, token, token, suggestion, "%s", message)
+		case "append", "appendcols":
+			e.add(QueryCostAppend, appendPoints, token, token,
+				"search both datasets at once with OR, or with multisearch for streaming searches",
+				"%s runs its subsearch separately and after the main search", command.Name)
+		case "rex", "regex":
+			e.estimateRegex(command)
+		}
+
+		switch {
+		case e.query.registry.isReportingCommand(command.Name):
+			// Results are small after a transforming command
+			flush()
+			centralized = nil
+		case centralized == nil && e.query.registry.isCentralizedCommand(command.Name):
+			centralized = &pipeline[i]
+		case centralized != nil && e.query.registry.isDistributableCommand(command.Name):
+			searchHead = append(searchHead, command.Name)
+		}
+	}
+	flush()
+}
+
+func (e *queryCostEstimator) addSearchHeadCommands(centralized LintCommand, commands []string) {
+	token := centralized.NameToken()
+	names := strings.Join(uniqueStrings(commands), ", ")
+	e.add(QueryCostSearchHeadCommands, searchHeadCommandPoints*len(commands), token, token,
+		fmt.Sprintf("move %s before %s", names, centralized.Name),
+		"streaming commands after %s (%s) run on the search head instead of the indexers", centralized.Name, names)
+}
+
+// estimateRegex scores a rex or regex command by the pattern it applies to every event
+func (e *queryCostEstimator) estimateRegex(command LintCommand) {
+	pattern := ""
+	for _, token := range e.query.CommandTokens(command) {
+		if token.GetTokenType() == parser.SPLLexerSTRING {
+			pattern = strings.Trim(token.GetText(), `"`)
+		}
+	}
+
+	token := command.NameToken()
+	unbounded := len(unboundedRegexPattern.FindAllString(pattern, -1))
+	if unbounded >= 3 || strings.HasPrefix(pattern, ".*") || strings.HasPrefix(pattern, ".+") || len(pattern) > 200 {
+		e.add(QueryCostRegex, regexPoints+heavyRegexPoints, token, token,
+			"anchor the pattern and replace .* with specific character classes, or extract the field at index time",
+			"%s applies a backtracking-heavy pattern to every event", command.Name)
+		return
+	}
+	e.add(QueryCostRegex, regexPoints, token, token, "", "%s applies a regular expression to every event", command.Name)
+}
+
+// estimateTstats looks for "search <indexed terms> | stats <aggregations> by <indexed fields>",
+// which tstats answers from the index without reading events
+func (e *queryCostEstimator) estimateTstats() {
+	pipeline := e.query.Pipelines[0]
+	if len(pipeline) < 2 || pipeline[0].Name != "search" || pipeline[1].Name != "stats" {
+		return
+	}
+	search, stats := pipeline[0], pipeline[1]
+
+	// Every search term must be a field=value term on an indexed field or a time bound
+	searchNode := search.Node.(*parser.InitCommandContext)
+	for _, operation := range searchNode.AllOperation() {
+		term, ok := operation.(*parser.KEYVALUEOPContext)
+		if !ok || term.EQ() == nil {
+			return
+		}
+		field := strings.ToLower(term.GetChild(0).(antlr.ParseTree).GetText())
+		if !indexedFields[field] && field != "earliest" && field != "latest" {
+			return
+		}
+	}
+	if len(searchNode.AllOperation()) == 0 {
+		return
+	}
+
+	// Every field stats reads must be indexed and every function supported by tstats
+	tokens := e.query.CommandTokens(stats)
+	var by antlr.Token
+	for i, token := range tokens[1:] {
+		previous, next := tokens[i], antlr.Token(nil)
+		if i+2 < len(tokens) {
+			next = tokens[i+2]
+		}
+		switch token.GetTokenType() {
+		case parser.SPLLexerBY:
+			by = token
+		case parser.SPLLexerAS, parser.SPLLexerLPAREN, parser.SPLLexerRPAREN, parser.SPLLexerCOMMA:
+		case parser.SPLLexerIDENTIFIER, parser.SPLLexerFUNCTION, parser.SPLLexerSTD_COMMAND_AND_FUNCTION,
+			parser.SPLLexerMODIFIER_AND_FUNCTION, parser.SPLLexerTIME_AND_FUNCTION:
+			name := strings.ToLower(token.GetText())
+			switch {
+			case previous.GetTokenType() == parser.SPLLexerAS:
+			case next != nil && next.GetTokenType() == parser.SPLLexerLPAREN || token.GetTokenType() == parser.SPLLexerFUNCTION && by == nil:
+				if !tstatsFunctions[name] {
+					return
+				}
+			case !indexedFields[name]:
+				return
+			}
+		default:
+			return
+		}
+	}
+
+	terms := searchNode.AllOperation()
+	where := e.textOf(terms[0].GetStart(), terms[len(terms)-1].GetStop())
+	aggregationsEnd := tokens[len(tokens)-1]
+	byClause := ""
+	if by != nil {
+		for i, token := range tokens {
+			if token == by {
+				aggregationsEnd = tokens[i-1]
+			}
+		}
+		byClause = " " + e.textOf(by, tokens[len(tokens)-1])
+	}
+	if aggregationsEnd == tokens[0] {
+		return
+	}
+	rewrite := fmt.Sprintf("| tstats %s where %s%s", e.textOf(tokens[1], aggregationsEnd), where, byClause)
+	if stop := stats.Node.GetStop().GetStop() + 1; stop < len(e.runes) {
+		rewrite += " " + strings.TrimSpace(string(e.runes[stop:]))
+	}
+
+	e.add(QueryCostTstatsCandidate, tstatsCandidatePoints, search.Node.GetStart(), stats.Node.GetStop(), strings.TrimSpace(rewrite),
+		"stats only reads indexed fields; tstats can answer the query without reading events")
+}
+
+// commandFieldArguments returns the bare field names given to a command, such as the fields
+// of "join type=left user host". Words directly attached to the previous argument, like the
+// unit of "maxspan=5m", are left out.
+func commandFieldArguments(command LintCommand) []string {
+	var fields []string
+	var previous antlr.Token
+	for _, child := range command.Node.GetChildren() {
+		operation, ok := child.(*parser.EXPRESSIONOPContext)
+		attached := ok && previous != nil && operation.GetStart().GetStart() == previous.GetStop()+1
+		if tree, isRule := child.(antlr.ParserRuleContext); isRule {
+			previous = tree.GetStop()
+		} else if terminal, isTerminal := child.(antlr.TerminalNode); isTerminal {
+			previous = terminal.GetSymbol()
+		}
+		if ok && !attached {
+			if value := operation.Expression().Value(); value != nil {
+				if field, ok := value.Id().(*parser.FieldUseContext); ok {
+					fields = append(fields, field.GetText())
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// uniqueStrings returns the distinct strings of a list in order of first appearance
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
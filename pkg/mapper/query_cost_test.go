@@ -0,0 +1,116 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEstimateQueryCost(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		factors    []string // factor:points in order of position
+		rating     string
+		suggestion string // Expected suggestion of the last factor, if set
+	}{
+		{
+			name:    "Bounded search",
+			query:   "index=web earliest=-1h status=500 | stats count by user",
+			factors: []string{},
+			rating:  QueryCostLow,
+		},
+		{
+			name:    "Missing index and time bounds",
+			query:   "sourcetype=access status=500 | stats count by user",
+			factors: []string{"missing-index:30", "missing-time-bounds:10"},
+			rating:  QueryCostMedium,
+		},
+		{
+			name:       "All indexes",
+			query:      "index=* earliest=-1h error | stats count by user",
+			factors:    []string{"missing-index:30"},
+			rating:     QueryCostMedium,
+			suggestion: "replace index=* with the indexes that hold the data",
+		},
+		{
+			name:    "Streaming commands on the search head",
+			query:   "index=web earliest=-1h | sort _time | eval a=1 | rex field=msg \"(?<user>\\w+)\" | stats count by user | eval b=2",
+			factors: []string{"search-head-commands:10", "regex:5"},
+			rating:  QueryCostLow,
+		},
+		{
+			name:    "Join with subsearch",
+			query:   "index=web earliest=-1h | join type=left user [search index=vpn | fields user ip]",
+			factors: []string{"join:20", "subsearch:10"},
+			rating:  QueryCostMedium,
+		},
+		{
+			name:       "Unbounded transaction",
+			query:      "index=web earliest=-1h | transaction user host | stats count",
+			factors:    []string{"transaction:30"},
+			rating:     QueryCostMedium,
+			suggestion: "use | stats min(_time) as start max(_time) as end values(*) as * by user host when events do not need start or end conditions",
+		},
+		{
+			name:    "Append and backtracking regex",
+			query:   "index=web earliest=-1h | rex \".*user=(?<user>.*) .*\" | append extendtimerange=true [search index=vpn]",
+			factors: []string{"regex:15", "append:10", "subsearch:10"},
+			rating:  QueryCostMedium,
+		},
+		{
+			name:       "Stats over indexed fields",
+			query:      "index=web sourcetype=access earliest=-1h | stats count dc(host) as hosts by sourcetype | sort count",
+			factors:    []string{"tstats-candidate:15"},
+			rating:     QueryCostLow,
+			suggestion: "| tstats count dc(host) as hosts where index=web sourcetype=access earliest=-1h by sourcetype | sort count",
+		},
+		{
+			name:    "tstats over a datamodel",
+			query:   "| tstats count from datamodel=Network_Traffic.All_Traffic where earliest=-1h by All_Traffic.src",
+			factors: []string{},
+			rating:  QueryCostLow,
+		},
+		{
+			name:    "tstats over raw indexes without an index",
+			query:   "| tstats count where earliest=-1h by host",
+			factors: []string{"missing-index:30"},
+			rating:  QueryCostMedium,
+		},
+		{
+			name:    "Stats over search-time fields",
+			query:   "index=web earliest=-1h | stats count by user",
+			factors: []string{},
+			rating:  QueryCostLow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, err := EstimateQueryCost(tt.query)
+			if err != nil {
+				t.Fatalf("EstimateQueryCost failed: %v", err)
+			}
+
+			factors := []string{}
+			score := 0
+			for _, factor := range estimate.Factors {
+				factors = append(factors, fmt.Sprintf("%s:%d", factor.Factor, factor.Points))
+				score += factor.Points
+			}
+			if strings.Join(factors, ", ") != strings.Join(tt.factors, ", ") {
+				t.Fatalf("Expected factors %v, got %+v", tt.factors, estimate.Factors)
+			}
+			if estimate.Score != score || estimate.Rating != tt.rating {
+				t.Errorf("Expected score %d rated %s, got %d rated %s", score, tt.rating, estimate.Score, estimate.Rating)
+			}
+			if tt.suggestion != "" && estimate.Factors[len(estimate.Factors)-1].Suggestion != tt.suggestion {
+				t.Errorf("Expected suggestion %q, got %q", tt.suggestion, estimate.Factors[len(estimate.Factors)-1].Suggestion)
+			}
+		})
+	}
+
+	if _, err := EstimateQueryCost("index=web | stats count("); err == nil {
+		t.Error("Expected an error for a query that does not parse")
+	}
+}
@@ -0,0 +1,527 @@
+package mapper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/delgado-jacob/spl-toolkit/parser"
+)
+
+// QueryLintIssue is a problem a lint rule found in a SPL query
+type QueryLintIssue struct {
+	Rule      string `json:"rule"`     // ID of the rule that reported the issue
+	Severity  string `json:"severity"` // QueryLintError, QueryLintWarning or QueryLintInfo
+	Message   string `json:"message"`
+	Line      int    `json:"line"`       // 1-based line of the start of the issue
+	Column    int    `json:"column"`     // 1-based column of the start of the issue, in characters
+	Offset    int    `json:"offset"`     // 0-based character offset of the start of the issue
+	EndOffset int    `json:"end_offset"` // Character offset just past the end of the issue
+}
+
+// Severities of query lint issues
+const (
+	QueryLintError   = "error"   // The query is wrong or will not finish in reasonable time
+	QueryLintWarning = "warning" // The query is slow or does something other than intended
+	QueryLintInfo    = "info"    // The query could be written more clearly
+)
+
+var queryLintSeverities = map[string]bool{QueryLintError: true, QueryLintWarning: true, QueryLintInfo: true}
+
+// QueryCheckInvalidDirective reports inline lint comments that cannot be applied
+const QueryCheckInvalidDirective = "invalid-directive"
+
+// QueryLintRule checks a parsed query. Check returns the problems it finds; the linter adds
+// the rule's ID, its configured severity and the source positions.
+type QueryLintRule interface {
+	ID() string
+	Severity() string // Default severity, one of the QueryLint severity constants
+	Description() string
+	Check(query *LintQuery) []QueryLintFinding
+}
+
+// QueryLintFinding is a problem found by a rule, spanning the tokens from Start to Stop
+type QueryLintFinding struct {
+	Start   antlr.Token
+	Stop    antlr.Token
+	Message string
+}
+
+// NewQueryLintRule creates a rule from a check function
+func NewQueryLintRule(id, severity, description string, check func(query *LintQuery) []QueryLintFinding) QueryLintRule {
+	return &queryLintRule{id: id, severity: severity, description: description, check: check}
+}
+
+type queryLintRule struct {
+	id, severity, description string
+	check                     func(query *LintQuery) []QueryLintFinding
+}
+
+func (r *queryLintRule) ID() string                                { return r.id }
+func (r *queryLintRule) Severity() string                          { return r.severity }
+func (r *queryLintRule) Description() string                       { return r.description }
+func (r *queryLintRule) Check(query *LintQuery) []QueryLintFinding { return r.check(query) }
+
+// LintQuery is a parsed query as seen by lint rules
+type LintQuery struct {
+	Text   string               // Query text, with comments replaced by spaces
+	Tree   parser.IQueryContext // Parse tree
+	Tokens []antlr.Token        // All tokens, including whitespace
+
+	// Pipelines lists the commands of the top-level query first, then those of each subsearch
+	// in order of appearance
+	Pipelines [][]LintCommand
+
+	stream   *antlr.CommonTokenStream
+	registry *CommandRegistry
+}
+
+// LintCommand is a command of a query or subsearch pipeline
+type LintCommand struct {
+	Name     string                  // Lower-cased command name; "search" for implicit searches
+	Node     antlr.ParserRuleContext // *parser.InitCommandContext or *parser.NextCommandContext
+	Pipeline int                     // Index of the pipeline in LintQuery.Pipelines
+	Index    int                     // Position of the command in its pipeline
+}
+
+// Explicit reports whether the command is written out, as opposed to the implicit search at
+// the start of a query such as "index=web error"
+func (c LintCommand) Explicit() bool {
+	switch node := c.Node.(type) {
+	case *parser.InitCommandContext:
+		return node.INIT_COMMAND() != nil || initCommandName(node) != "search"
+	case *parser.NextCommandContext:
+		return node.Command() != nil
+	}
+	return false
+}
+
+// NameToken returns the token holding the command name, or the first token of implicit searches
+func (c LintCommand) NameToken() antlr.Token {
+	switch node := c.Node.(type) {
+	case *parser.InitCommandContext:
+		if node.INIT_COMMAND() != nil {
+			return node.INIT_COMMAND().GetSymbol()
+		}
+		if commandUse := initCommandUse(node); commandUse != nil {
+			return commandUse.GetStart()
+		}
+	case *parser.NextCommandContext:
+		if node.Command() != nil {
+			return node.Command().GetStart()
+		}
+	}
+	return c.Node.GetStart()
+}
+
+// Option returns the "name=value" option given to the command, or nil
+func (c LintCommand) Option(name string) *parser.KEYVALUEOPContext {
+	for _, child := range c.Node.GetChildren() {
+		option, ok := child.(*parser.KEYVALUEOPContext)
+		if ok && option.EQ() != nil && option.GetChildCount() > 0 &&
+			strings.EqualFold(option.GetChild(0).(antlr.ParseTree).GetText(), name) {
+			return option
+		}
+	}
+	return nil
+}
+
+// CommandTokens returns the default-channel tokens of a command, leaving out its subsearches
+func (q *LintQuery) CommandTokens(command LintCommand) []antlr.Token {
+	var skipped [][2]int
+	var collect func(tree antlr.Tree)
+	collect = func(tree antlr.Tree) {
+		for _, child := range tree.GetChildren() {
+			if subquery, ok := child.(*parser.SubqueryContext); ok {
+				skipped = append(skipped, [2]int{subquery.GetStart().GetTokenIndex(), subquery.GetStop().GetTokenIndex()})
+				continue
+			}
+			collect(child)
+		}
+	}
+	collect(command.Node)
+
+	var tokens []antlr.Token
+	for index := command.Node.GetStart().GetTokenIndex(); index <= command.Node.GetStop().GetTokenIndex() && index < len(q.Tokens); index++ {
+		if len(skipped) > 0 && index >= skipped[0][0] {
+			index = skipped[0][1]
+			skipped = skipped[1:]
+			continue
+		}
+		if token := q.Tokens[index]; token.GetChannel() == antlr.TokenDefaultChannel {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// QueryLintOptions configures a lint run. Inline comments in the query apply on top of them.
+type QueryLintOptions struct {
+	Disable    []string          `json:"disable,omitempty"`    // IDs of rules not to run
+	Severities map[string]string `json:"severities,omitempty"` // Severity overrides by rule ID
+}
+
+// QueryLinter runs lint rules over SPL queries
+type QueryLinter struct {
+	rules    []QueryLintRule
+	byID     map[string]int
+	registry *CommandRegistry
+}
+
+// NewQueryLinter creates a linter with the given rules, or with the built-in rules when none
+// are given. A rule replaces an earlier rule with the same ID.
+func NewQueryLinter(rules ...QueryLintRule) *QueryLinter {
+	if len(rules) == 0 {
+		rules = BuiltinQueryLintRules()
+	}
+
+	linter := &QueryLinter{byID: make(map[string]int)}
+	for _, rule := range rules {
+		if index, exists := linter.byID[rule.ID()]; exists {
+			linter.rules[index] = rule
+			continue
+		}
+		linter.byID[rule.ID()] = len(linter.rules)
+		linter.rules = append(linter.rules, rule)
+	}
+	return linter
+}
+
+// SetCommandRegistry makes the linter accept the custom commands and functions of registry
+// instead of the default registry; nil restores the default registry
+func (l *QueryLinter) SetCommandRegistry(registry *CommandRegistry) {
+	l.registry = registry
+}
+
+// Rules returns the rules the linter runs
+func (l *QueryLinter) Rules() []QueryLintRule {
+	return append([]QueryLintRule(nil), l.rules...)
+}
+
+// Lint parses a query and returns the issues found by the enabled rules, ordered by position.
+// Unlike ValidateQuery it accepts ```comments```, which may hold lint directives:
+//
+//	```lint:disable rule-a, rule-b```       disables rules for the whole query (all rules without IDs)
+//	```lint:disable-next rule-a```          disables rules for the command that follows the comment
+//	```lint:severity rule-a=error```        changes the severity of rules
+func (l *QueryLinter) Lint(query string, options QueryLintOptions) ([]QueryLintIssue, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+	if err := l.ValidateOptions(options); err != nil {
+		return nil, err
+	}
+
+	text, comments := blankQueryComments(query)
+	lintQuery, err := parseLintQuery(text, l.registry)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &queryLintRun{
+		linter:     l,
+		query:      lintQuery,
+		runes:      []rune(text),
+		disabled:   make(map[string]bool),
+		severities: make(map[string]string),
+	}
+	for _, id := range options.Disable {
+		run.disabled[id] = true
+	}
+	for id, severity := range options.Severities {
+		run.severities[id] = severity
+	}
+	for _, comment := range comments {
+		run.applyDirective(comment)
+	}
+
+	for _, rule := range l.rules {
+		if run.disableAll || run.disabled[rule.ID()] {
+			continue
+		}
+		severity := rule.Severity()
+		if override, ok := run.severities[rule.ID()]; ok {
+			severity = override
+		}
+		for _, finding := range rule.Check(lintQuery) {
+			start, end := finding.Start.GetStart(), finding.Stop.GetStop()+1
+			if run.suppressed(rule.ID(), start) {
+				continue
+			}
+			run.report(rule.ID(), severity, start, end, finding.Message)
+		}
+	}
+
+	sort.SliceStable(run.issues, func(i, j int) bool {
+		if run.issues[i].Offset != run.issues[j].Offset {
+			return run.issues[i].Offset < run.issues[j].Offset
+		}
+		return run.issues[i].Rule < run.issues[j].Rule
+	})
+	return run.issues, nil
+}
+
+// ValidateOptions checks that options only name the linter's rules and valid severities
+func (l *QueryLinter) ValidateOptions(options QueryLintOptions) error {
+	for _, id := range options.Disable {
+		if _, ok := l.byID[id]; !ok {
+			return fmt.Errorf("unknown lint rule %q", id)
+		}
+	}
+	for id, severity := range options.Severities {
+		if _, ok := l.byID[id]; !ok {
+			return fmt.Errorf("unknown lint rule %q", id)
+		}
+		if !queryLintSeverities[severity] {
+			return fmt.Errorf("invalid severity %q for rule %q (must be error, warning or info)", severity, id)
+		}
+	}
+	return nil
+}
+
+// queryLintRun holds the state of one Lint call
+type queryLintRun struct {
+	linter *QueryLinter
+	query  *LintQuery
+	runes  []rune
+	issues []QueryLintIssue
+
+	disableAll bool
+	disabled   map[string]bool
+	severities map[string]string
+	// Character ranges of commands in which rules are disabled; no IDs means all rules
+	suppressions []lintSuppression
+}
+
+type lintSuppression struct {
+	start, end int
+	rules      map[string]bool
+}
+
+func (r *queryLintRun) report(rule, severity string, start, end int, message string) {
+	line, column := textPosition(r.runes, start)
+	r.issues = append(r.issues, QueryLintIssue{
+		Rule:      rule,
+		Severity:  severity,
+		Message:   message,
+		Line:      line,
+		Column:    column,
+		Offset:    start,
+		EndOffset: end,
+	})
+}
+
+func (r *queryLintRun) suppressed(rule string, offset int) bool {
+	for _, suppression := range r.suppressions {
+		if offset >= suppression.start && offset < suppression.end && (len(suppression.rules) == 0 || suppression.rules[rule]) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDirective applies a lint:... comment. Other comments are ignored.
+func (r *queryLintRun) applyDirective(comment queryComment) {
+	body := strings.TrimSpace(comment.text)
+	if !strings.HasPrefix(body, "lint:") {
+		return
+	}
+	directive, rest, _ := strings.Cut(body, " ")
+	args := strings.FieldsFunc(rest, func(char rune) bool {
+		return char == ',' || char == ' ' || char == '\t' || char == '\n' || char == '\r'
+	})
+
+	invalid := func(format string, args ...interface{}) {
+		r.report(QueryCheckInvalidDirective, QueryLintWarning, comment.start, comment.end, fmt.Sprintf(format, args...))
+	}
+	knownRules := func(ids []string) map[string]bool {
+		rules := make(map[string]bool)
+		for _, id := range ids {
+			if _, ok := r.linter.byID[id]; !ok {
+				invalid("unknown lint rule %q", id)
+				continue
+			}
+			rules[id] = true
+		}
+		return rules
+	}
+
+	switch directive {
+	case "lint:disable":
+		if len(args) == 0 {
+			r.disableAll = true
+		}
+		for id := range knownRules(args) {
+			r.disabled[id] = true
+		}
+	case "lint:disable-next":
+		rules := knownRules(args)
+		if len(args) > 0 && len(rules) == 0 {
+			return
+		}
+		command, ok := r.nextCommand(comment.end)
+		if !ok {
+			invalid("no command follows %s", directive)
+			return
+		}
+		r.suppressions = append(r.suppressions, lintSuppression{
+			start: command.Node.GetStart().GetStart(),
+			end:   command.Node.GetStop().GetStop() + 1,
+			rules: rules,
+		})
+	case "lint:severity":
+		if len(args) == 0 {
+			invalid("%s needs rule=severity arguments", directive)
+		}
+		for _, arg := range args {
+			id, severity, found := strings.Cut(arg, "=")
+			if !found || !queryLintSeverities[severity] {
+				invalid("invalid severity setting %q (expected rule=error, rule=warning or rule=info)", arg)
+				continue
+			}
+			if len(knownRules([]string{id})) > 0 {
+				r.severities[id] = severity
+			}
+		}
+	default:
+		invalid("unknown lint directive %q", directive)
+	}
+}
+
+// nextCommand returns the first command that starts at or after offset
+func (r *queryLintRun) nextCommand(offset int) (LintCommand, bool) {
+	var next LintCommand
+	found := false
+	for _, pipeline := range r.query.Pipelines {
+		for _, command := range pipeline {
+			start := command.Node.GetStart().GetStart()
+			if start >= offset && (!found || start < next.Node.GetStart().GetStart()) {
+				next, found = command, true
+			}
+		}
+	}
+	return next, found
+}
+
+// textPosition returns the 1-based line and column of a character offset
+func textPosition(runes []rune, offset int) (int, int) {
+	line, column := 1, 1
+	for _, char := range runes[:offset] {
+		if char == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	return line, column
+}
+
+// queryComment is a ```comment``` of a query, between the character offsets start and end
+type queryComment struct {
+	text       string
+	start, end int
+}
+
+// blankQueryComments replaces ```comments``` with spaces, which keeps the character offsets
+// of the rest of the query, and returns the comments. Newlines are kept so that lines and
+// columns stay the same. An unterminated comment is left in place.
+func blankQueryComments(query string) (string, []queryComment) {
+	const delimiter = "```"
+	if !strings.Contains(query, delimiter) {
+		return query, nil
+	}
+
+	runes := []rune(query)
+	var comments []queryComment
+	for start := 0; start+3 <= len(runes); start++ {
+		if string(runes[start:start+3]) != delimiter {
+			continue
+		}
+		end := -1
+		for i := start + 3; i+3 <= len(runes); i++ {
+			if string(runes[i:i+3]) == delimiter {
+				end = i + 3
+				break
+			}
+		}
+		if end < 0 {
+			break
+		}
+
+		comments = append(comments, queryComment{text: string(runes[start+3 : end-3]), start: start, end: end})
+		for i := start; i < end; i++ {
+			if runes[i] != '\n' && runes[i] != '\r' {
+				runes[i] = ' '
+			}
+		}
+		start = end - 1
+	}
+	return string(runes), comments
+}
+
+// parseLintQuery parses a query, accepting the custom commands and functions of registry, and
+// collects its command pipelines
+func parseLintQuery(text string, registry *CommandRegistry) (*LintQuery, error) {
+	input := antlr.NewInputStream(text)
+	lexer := parser.NewSPLLexer(input)
+	stream := antlr.NewCommonTokenStream(newQueryTokenSource(lexer, registry), 0)
+	splParser := parser.NewSPLParser(stream)
+
+	splParser.RemoveErrorListeners()
+	lexer.RemoveErrorListeners()
+	errorListener := &CustomErrorListener{
+		DefaultErrorListener: antlr.NewDefaultErrorListener(),
+		errors:               []string{},
+	}
+	splParser.AddErrorListener(errorListener)
+	lexer.AddErrorListener(errorListener)
+
+	tree := splParser.Query()
+	if len(errorListener.errors) > 0 {
+		return nil, fmt.Errorf("parse errors: %s", strings.Join(errorListener.errors, "; "))
+	}
+
+	stream.Fill()
+	query := &LintQuery{
+		Text:     text,
+		Tree:     tree,
+		Tokens:   stream.GetAllTokens(),
+		stream:   stream,
+		registry: registry,
+	}
+	antlr.ParseTreeWalkerDefault.Walk(&lintPipelineListener{query: query}, tree)
+	return query, nil
+}
+
+// lintPipelineListener collects the commands of the query and of each subsearch
+type lintPipelineListener struct {
+	parser.BaseSPLParserListener
+	query *LintQuery
+}
+
+func (l *lintPipelineListener) EnterQuery(ctx *parser.QueryContext) {
+	l.addPipeline(ctx.InitCommand(), ctx.AllNextCommand())
+}
+
+func (l *lintPipelineListener) EnterSubquery(ctx *parser.SubqueryContext) {
+	l.addPipeline(ctx.InitCommand(), ctx.AllNextCommand())
+}
+
+func (l *lintPipelineListener) addPipeline(init parser.IInitCommandContext, next []parser.INextCommandContext) {
+	pipeline := len(l.query.Pipelines)
+	var commands []LintCommand
+	if init, ok := init.(*parser.InitCommandContext); ok && init != nil && init.GetStop() != nil &&
+		init.GetStop().GetTokenIndex() >= init.GetStart().GetTokenIndex() {
+		commands = append(commands, LintCommand{Name: initCommandName(init), Node: init, Pipeline: pipeline})
+	}
+	for _, command := range next {
+		command := command.(*parser.NextCommandContext)
+		name := ""
+		if command.Command() != nil {
+			name = strings.ToLower(command.Command().GetText())
+		}
+		commands = append(commands, LintCommand{Name: name, Node: command, Pipeline: pipeline, Index: len(commands)})
+	}
+	l.query.Pipelines = append(l.query.Pipelines, commands)
+}
@@ -0,0 +1,208 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/delgado-jacob/spl-toolkit/parser"
+)
+
+// Built-in query lint rules
+const (
+	QueryCheckLeadingWildcard           = "leading-wildcard"
+	QueryCheckMissingIndex              = "missing-index"
+	QueryCheckJoinWithoutMax            = "join-without-max"
+	QueryCheckTransactionWithoutMaxspan = "transaction-without-maxspan"
+	QueryCheckSearchAfterStats          = "search-after-stats"
+	QueryCheckTableBeforeStats          = "table-before-stats"
+)
+
+// BuiltinQueryLintRules returns the rules run by NewQueryLinter when no rules are given
+func BuiltinQueryLintRules() []QueryLintRule {
+	return []QueryLintRule{
+		NewQueryLintRule(QueryCheckLeadingWildcard, QueryLintWarning,
+			"Search terms starting with a wildcard cannot use the index and scan every event",
+			checkLeadingWildcard),
+		NewQueryLintRule(QueryCheckMissingIndex, QueryLintWarning,
+			"Searches without index= run over the default indexes of the user's role",
+			checkMissingIndex),
+		NewQueryLintRule(QueryCheckJoinWithoutMax, QueryLintWarning,
+			"join without max= keeps only the first matching subsearch result",
+			checkJoinWithoutMax),
+		NewQueryLintRule(QueryCheckTransactionWithoutMaxspan, QueryLintWarning,
+			"transaction without maxspan= keeps transactions open without a time limit",
+			checkTransactionWithoutMaxspan),
+		NewQueryLintRule(QueryCheckSearchAfterStats, QueryLintInfo,
+			"Filtering the results of a transforming command is clearer with where than with search",
+			checkSearchAfterStats),
+		NewQueryLintRule(QueryCheckTableBeforeStats, QueryLintWarning,
+			"table before a transforming command moves every event to the search head; use fields",
+			checkTableBeforeStats),
+	}
+}
+
+// checkLeadingWildcard reports search terms such as *error or user=*bob
+func checkLeadingWildcard(query *LintQuery) []QueryLintFinding {
+	runes := []rune(query.Text)
+	var findings []QueryLintFinding
+	for _, pipeline := range query.Pipelines {
+		for _, command := range pipeline {
+			if command.Name != "search" {
+				continue
+			}
+
+			tokens := query.CommandTokens(command)
+			for i, token := range tokens {
+				switch token.GetTokenType() {
+				case parser.SPLLexerSTRING:
+					value := strings.Trim(token.GetText(), `"'`)
+					if len(value) > 1 && value[0] == '*' && !isWildcardBoundary(rune(value[1])) {
+						findings = append(findings, leadingWildcardFinding(token, token, token.GetText()))
+					}
+				case parser.SPLLexerMULT:
+					start := token.GetStart()
+					if start > 0 && !strings.ContainsRune(" \t\r\n=(,", runes[start-1]) {
+						continue
+					}
+					if start+1 >= len(runes) || isWildcardBoundary(runes[start+1]) {
+						continue
+					}
+
+					// The term runs over the tokens that directly follow the wildcard
+					stop, text := token, token.GetText()
+					for _, next := range tokens[i+1:] {
+						if first := []rune(next.GetText())[0]; next.GetStart() != stop.GetStop()+1 || (first != '*' && isWildcardBoundary(first)) {
+							break
+						}
+						stop, text = next, text+next.GetText()
+					}
+					findings = append(findings, leadingWildcardFinding(token, stop, text))
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// isWildcardBoundary reports whether a character ends a search term or is another wildcard
+func isWildcardBoundary(char rune) bool {
+	return unicode.IsSpace(char) || strings.ContainsRune("*|()[]\"'", char)
+}
+
+func leadingWildcardFinding(start, stop antlr.Token, term string) QueryLintFinding {
+	return QueryLintFinding{
+		Start:   start,
+		Stop:    stop,
+		Message: fmt.Sprintf("leading wildcard in %s cannot use the index and scans every event", term),
+	}
+}
+
+// checkMissingIndex reports searches at the start of a query or subsearch that do not
+// restrict the index
+func checkMissingIndex(query *LintQuery) []QueryLintFinding {
+	var findings []QueryLintFinding
+	for _, pipeline := range query.Pipelines {
+		if len(pipeline) == 0 || pipeline[0].Name != "search" {
+			continue
+		}
+		command := pipeline[0]
+
+		if indexTerm(query.CommandTokens(command)) != nil {
+			continue
+		}
+
+		start := command.NameToken()
+		findings = append(findings, QueryLintFinding{
+			Start:   start,
+			Stop:    command.Node.GetStop(),
+			Message: "search does not specify an index and runs over the default indexes; add index=",
+		})
+	}
+	return findings
+}
+
+// indexTerm returns the "index" token of an index=, index!= or index IN term, or nil
+func indexTerm(tokens []antlr.Token) antlr.Token {
+	for i, token := range tokens {
+		if i+1 < len(tokens) && strings.EqualFold(token.GetText(), "index") {
+			switch tokens[i+1].GetTokenType() {
+			case parser.SPLLexerEQ, parser.SPLLexerNE, parser.SPLLexerIN:
+				return token
+			}
+		}
+	}
+	return nil
+}
+
+func checkJoinWithoutMax(query *LintQuery) []QueryLintFinding {
+	return commandsWithoutOption(query, "join", "max",
+		"join without max= keeps only the first matching subsearch result; set max=1 or max=0 explicitly")
+}
+
+func checkTransactionWithoutMaxspan(query *LintQuery) []QueryLintFinding {
+	return commandsWithoutOption(query, "transaction", "maxspan",
+		"transaction without maxspan= keeps transactions open for the whole time range; set maxspan=")
+}
+
+// commandsWithoutOption reports the uses of a command that leave out an option
+func commandsWithoutOption(query *LintQuery, name, option, message string) []QueryLintFinding {
+	var findings []QueryLintFinding
+	for _, pipeline := range query.Pipelines {
+		for _, command := range pipeline {
+			if command.Name == name && command.Option(option) == nil {
+				token := command.NameToken()
+				findings = append(findings, QueryLintFinding{Start: token, Stop: token, Message: message})
+			}
+		}
+	}
+	return findings
+}
+
+// checkSearchAfterStats reports "| search" commands that filter the results table of a
+// transforming command or of a generating command such as tstats
+func checkSearchAfterStats(query *LintQuery) []QueryLintFinding {
+	var findings []QueryLintFinding
+	for _, pipeline := range query.Pipelines {
+		transforming := ""
+		for _, command := range pipeline {
+			switch {
+			case query.registry.isReportingCommand(command.Name):
+				transforming = command.Name
+			case transforming != "" && command.Name == "search" && command.Explicit():
+				token := command.NameToken()
+				findings = append(findings, QueryLintFinding{
+					Start:   token,
+					Stop:    token,
+					Message: fmt.Sprintf("search after %s filters a results table; use where instead", transforming),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkTableBeforeStats reports table commands followed by a transforming command
+func checkTableBeforeStats(query *LintQuery) []QueryLintFinding {
+	var findings []QueryLintFinding
+	for _, pipeline := range query.Pipelines {
+		for i, command := range pipeline {
+			if command.Name != "table" {
+				continue
+			}
+			for _, later := range pipeline[i+1:] {
+				if query.registry.isReportingCommand(later.Name) {
+					token := command.NameToken()
+					findings = append(findings, QueryLintFinding{
+						Start:   token,
+						Stop:    token,
+						Message: fmt.Sprintf("table before %s moves every event to the search head; use fields instead", later.Name),
+					})
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,174 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestQueryLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		options  QueryLintOptions
+		expected []string // rule@line:column severity
+	}{
+		{
+			name:     "Clean query",
+			query:    "index=web status=500 | stats count by host | where count > 10",
+			expected: []string{},
+		},
+		{
+			name:  "Leading wildcards",
+			query: `index=web user=*bob *error foo* "*admin" status=* | eval x=a*b`,
+			expected: []string{
+				"leading-wildcard@1:16 warning",
+				"leading-wildcard@1:21 warning",
+				"leading-wildcard@1:33 warning",
+			},
+		},
+		{
+			name:     "Missing index in query and subsearch",
+			query:    "sourcetype=access | join max=0 user [search sourcetype=vpn | fields user]",
+			expected: []string{"missing-index@1:1 warning", "missing-index@1:38 warning"},
+		},
+		{
+			name:     "Index with IN and generating commands",
+			query:    "index IN (web, proxy) error | append extendtimerange=true [| inputlookup users.csv]",
+			expected: []string{},
+		},
+		{
+			name:  "Command options",
+			query: "index=web | join user [search index=vpn] | transaction user | transaction host maxspan=1h",
+			expected: []string{
+				"join-without-max@1:13 warning",
+				"transaction-without-maxspan@1:44 warning",
+			},
+		},
+		{
+			name:  "Command order",
+			query: "index=web | table host status | stats count by host | search count>5",
+			expected: []string{
+				"table-before-stats@1:13 warning",
+				"search-after-stats@1:55 info",
+			},
+		},
+		{
+			name:     "Report-generating commands",
+			query:    "| tstats count where index=web by host | search count>5 | eventstats sum(count) as total | search total>0",
+			expected: []string{"search-after-stats@1:42 info", "search-after-stats@1:92 info"},
+		},
+		{
+			name:     "Multi-line positions",
+			query:    "index=web\n| stats count by host\n| search count>5",
+			expected: []string{"search-after-stats@3:3 info"},
+		},
+		{
+			name:     "Options disable rules and override severities",
+			query:    "error | table host | stats count by host",
+			options:  QueryLintOptions{Disable: []string{QueryCheckMissingIndex}, Severities: map[string]string{QueryCheckTableBeforeStats: QueryLintError}},
+			expected: []string{"table-before-stats@1:9 error"},
+		},
+		{
+			name:     "Disable directive",
+			query:    "```lint:disable missing-index, leading-wildcard``` *error | join user [search index=vpn]",
+			expected: []string{"join-without-max@1:61 warning"},
+		},
+		{
+			name:     "Disable all directive",
+			query:    "*error ```lint:disable``` | join user [search *vpn]",
+			expected: []string{},
+		},
+		{
+			name:  "Disable next command directive",
+			query: "index=web | ```lint:disable-next join-without-max``` join user [search index=vpn] | join host [search index=dns]",
+			expected: []string{
+				"join-without-max@1:85 warning",
+			},
+		},
+		{
+			name:     "Severity directive",
+			query:    "index=web | stats count | search count>5 ```lint:severity search-after-stats=error```",
+			expected: []string{"search-after-stats@1:27 error"},
+		},
+		{
+			name:  "Invalid directives",
+			query: "index=web ```lint:disable nope``` ```lint:severity missing-index=fatal``` ```lint:ignore``` ```a plain comment```",
+			expected: []string{
+				"invalid-directive@1:11 warning",
+				"invalid-directive@1:35 warning",
+				"invalid-directive@1:75 warning",
+			},
+		},
+	}
+
+	linter := NewQueryLinter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := linter.Lint(tt.query, tt.options)
+			if err != nil {
+				t.Fatalf("Lint failed: %v", err)
+			}
+			got := []string{}
+			for _, issue := range issues {
+				got = append(got, fmt.Sprintf("%s@%d:%d %s", issue.Rule, issue.Line, issue.Column, issue.Severity))
+			}
+			if strings.Join(got, "; ") != strings.Join(tt.expected, "; ") {
+				t.Errorf("Expected issues %v, got %v", tt.expected, issues)
+			}
+		})
+	}
+}
+
+func TestQueryLintErrors(t *testing.T) {
+	linter := NewQueryLinter()
+
+	tests := []struct {
+		name    string
+		query   string
+		options QueryLintOptions
+		errText string
+	}{
+		{"Empty query", "", QueryLintOptions{}, "empty query"},
+		{"Parse error", "index=web | stats count(", QueryLintOptions{}, "parse errors"},
+		{"Unknown disabled rule", "index=web", QueryLintOptions{Disable: []string{"nope"}}, `unknown lint rule "nope"`},
+		{"Invalid severity", "index=web", QueryLintOptions{Severities: map[string]string{QueryCheckMissingIndex: "fatal"}}, `invalid severity "fatal"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := linter.Lint(tt.query, tt.options)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+}
+
+func TestCustomQueryLintRule(t *testing.T) {
+	noDedup := NewQueryLintRule("no-dedup", QueryLintInfo, "dedup is not allowed", func(query *LintQuery) []QueryLintFinding {
+		var findings []QueryLintFinding
+		for _, pipeline := range query.Pipelines {
+			for _, command := range pipeline {
+				if command.Name == "dedup" {
+					findings = append(findings, QueryLintFinding{Start: command.Node.GetStart(), Stop: command.Node.GetStop(), Message: "remove dedup"})
+				}
+			}
+		}
+		return findings
+	})
+	replacement := NewQueryLintRule(QueryCheckMissingIndex, QueryLintError, "replaced", func(*LintQuery) []QueryLintFinding { return nil })
+
+	linter := NewQueryLinter(append(BuiltinQueryLintRules(), noDedup, replacement)...)
+	if len(linter.Rules()) != len(BuiltinQueryLintRules())+1 {
+		t.Fatalf("Expected the replacement to take the place of the built-in rule, got %d rules", len(linter.Rules()))
+	}
+
+	issues, err := linter.Lint("error | dedup host", QueryLintOptions{})
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Rule != "no-dedup" || issues[0].Offset != 8 || issues[0].EndOffset != 18 {
+		t.Errorf("Expected one no-dedup issue over offsets 8-18, got %+v", issues)
+	}
+}
@@ -0,0 +1,410 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/antlr4-go/antlr/v4"
+	"github.com/delgado-jacob/spl-toolkit/parser"
+)
+
+// QueryRewrite describes a rewrite the optimizer can apply
+type QueryRewrite struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+// Rewrites applied by OptimizeQuery. Merging consecutive eval commands is deliberately not
+// one of them: the grammar does not accept comma-separated assignments, so a merged eval could
+// never pass the re-validation every rewrite goes through.
+const (
+	RewriteSearchToBaseFilter   = "search-to-base-filter"
+	RewritePushSearchBeforeEval = "push-search-before-eval"
+	RewriteDropRedundantFields  = "drop-redundant-fields"
+)
+
+var queryRewrites = []QueryRewrite{
+	{RewriteSearchToBaseFilter, "Moves a | search filter into the base search when the commands before it are streaming and do not change the fields it filters on"},
+	{RewritePushSearchBeforeEval, "Moves a | search filter before an eval that does not assign the fields it filters on"},
+	{RewriteDropRedundantFields, "Removes a fields command that the fields command next to it makes redundant"},
+}
+
+// QueryRewrites returns the rewrites OptimizeQuery can apply
+func QueryRewrites() []QueryRewrite {
+	return append([]QueryRewrite(nil), queryRewrites...)
+}
+
+// OptimizeOptions selects the rewrites OptimizeQuery applies. No rewrite is applied unless
+// it is listed.
+type OptimizeOptions struct {
+	Rewrites []string `json:"rewrites"` // IDs of the rewrites to apply
+}
+
+// OptimizedQuery is the result of OptimizeQuery
+type OptimizedQuery struct {
+	Query     string           `json:"query"`     // Original query
+	Optimized string           `json:"optimized"` // Query after the applied rewrites
+	Applied   []AppliedRewrite `json:"applied"`
+	Skipped   []AppliedRewrite `json:"skipped,omitempty"` // Rewrites whose result did not parse
+}
+
+// AppliedRewrite explains a rewrite applied to a query, or why it was not applied
+type AppliedRewrite struct {
+	Rewrite     string `json:"rewrite"`
+	Explanation string `json:"explanation"`
+	Error       string `json:"error,omitempty"` // Parse error of the rewritten query, for skipped rewrites
+}
+
+// maxOptimizerPasses bounds the number of rewrites applied to a query
+const maxOptimizerPasses = 100
+
+// OptimizeQuery applies the selected semantics-preserving rewrites to a query, one at a time,
+// until none applies. Each rewritten query is re-validated with Parser; a rewrite whose
+// result does not parse is reported as skipped and the query is left as it was.
+func OptimizeQuery(query string, options OptimizeOptions) (*OptimizedQuery, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	enabled := make(map[string]bool)
+	for _, id := range options.Rewrites {
+		known := false
+		for _, rewrite := range queryRewrites {
+			known = known || rewrite.ID == id
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown rewrite %q", id)
+		}
+		enabled[id] = true
+	}
+
+	result := &OptimizedQuery{Query: query, Optimized: query, Applied: []AppliedRewrite{}}
+	rejected := make(map[string]bool)
+	for pass := 0; pass < maxOptimizerPasses; pass++ {
+		parsed, err := parseLintQuery(result.Optimized, defaultRegistry)
+		if err != nil {
+			return nil, err
+		}
+
+		applied := false
+		for _, candidate := range (&queryOptimizer{query: parsed, enabled: enabled}).candidates() {
+			key := candidate.rewrite + "\x00" + candidate.explanation
+			if rejected[key] {
+				continue
+			}
+
+			rewriter := antlr.NewTokenStreamRewriter(parsed.stream)
+			candidate.apply(rewriter)
+			text := strings.TrimSpace(strings.TrimSuffix(rewriter.GetTextDefault(), "<EOF>"))
+
+			if err := NewParser().ValidateQuery(text); err != nil {
+				rejected[key] = true
+				result.Skipped = append(result.Skipped, AppliedRewrite{Rewrite: candidate.rewrite, Explanation: candidate.explanation, Error: err.Error()})
+				continue
+			}
+			result.Applied = append(result.Applied, AppliedRewrite{Rewrite: candidate.rewrite, Explanation: candidate.explanation})
+			result.Optimized = text
+			applied = true
+			break
+		}
+		if !applied {
+			break
+		}
+	}
+	return result, nil
+}
+
+// rewriteCandidate is a rewrite that applies at one place in a query
+type rewriteCandidate struct {
+	rewrite     string
+	explanation string
+	apply       func(rewriter *antlr.TokenStreamRewriter)
+}
+
+type queryOptimizer struct {
+	query   *LintQuery
+	enabled map[string]bool
+}
+
+// candidates returns the enabled rewrites that apply to the query, in order of position
+func (o *queryOptimizer) candidates() []rewriteCandidate {
+	var candidates []rewriteCandidate
+	for _, pipeline := range o.query.Pipelines {
+		for i, command := range pipeline {
+			switch {
+			case command.Name == "search" && i > 0:
+				if o.enabled[RewriteSearchToBaseFilter] {
+					if candidate, ok := o.searchToBaseFilter(pipeline, i); ok {
+						candidates = append(candidates, candidate)
+					}
+				}
+				if o.enabled[RewritePushSearchBeforeEval] && pipeline[i-1].Name == "eval" {
+					if candidate, ok := o.pushSearchBeforeEval(pipeline, i); ok {
+						candidates = append(candidates, candidate)
+					}
+				}
+			case command.Name == "fields" && i > 0 && i+1 < len(pipeline) && pipeline[i+1].Name == "fields":
+				if o.enabled[RewriteDropRedundantFields] {
+					if candidate, ok := o.dropRedundantFields(pipeline, i); ok {
+						candidates = append(candidates, candidate)
+					}
+				}
+			}
+		}
+	}
+	return candidates
+}
+
+// searchToBaseFilter moves the filter of "| search" into the search at the start of the
+// pipeline when every command in between is streaming and leaves the filtered fields alone
+func (o *queryOptimizer) searchToBaseFilter(pipeline []LintCommand, index int) (rewriteCandidate, bool) {
+	base, search := pipeline[0], pipeline[index]
+	if base.Name != "search" {
+		return rewriteCandidate{}, false
+	}
+	fields, raw, ok := o.searchFilterFields(search)
+	if !ok {
+		return rewriteCandidate{}, false
+	}
+
+	var between []string
+	for _, command := range pipeline[1:index] {
+		derived, keep, ok := o.commandFieldEffect(command)
+		if !ok || (raw && derived["_raw"]) {
+			return rewriteCandidate{}, false
+		}
+		for field := range fields {
+			if derived[field] || (keep != nil && !keep[field] && field != "_raw" && field != "_time") {
+				return rewriteCandidate{}, false
+			}
+		}
+		between = append(between, command.Name)
+	}
+
+	filter := o.filterText(search)
+	for _, token := range o.query.CommandTokens(search) {
+		if token.GetTokenType() == parser.SPLLexerOR {
+			// OR binds tighter than the implicit AND, but parentheses make that obvious
+			filter = "(" + filter + ")"
+			break
+		}
+	}
+	explanation := fmt.Sprintf("moved %q from | search into the base search, which filters events before they leave the indexers", filter)
+	if len(between) > 0 {
+		explanation = fmt.Sprintf("moved %q from | search into the base search, which filters events before %s runs", filter, strings.Join(uniqueStrings(between), ", "))
+	}
+	return rewriteCandidate{
+		rewrite:     RewriteSearchToBaseFilter,
+		explanation: explanation,
+		apply: func(rewriter *antlr.TokenStreamRewriter) {
+			// The rewriter allows one operation per token, so a search right after the base
+			// search is replaced in one go
+			if index == 1 {
+				rewriter.ReplaceDefault(base.Node.GetStop().GetTokenIndex()+1, search.Node.GetStop().GetTokenIndex(), " "+filter)
+				return
+			}
+			rewriter.InsertAfterDefault(base.Node.GetStop().GetTokenIndex(), " "+filter)
+			o.deleteCommand(rewriter, pipeline, index)
+		},
+	}, true
+}
+
+// pushSearchBeforeEval swaps "| eval ... | search ..." when the eval does not assign a field
+// the search filters on
+func (o *queryOptimizer) pushSearchBeforeEval(pipeline []LintCommand, index int) (rewriteCandidate, bool) {
+	eval, search := pipeline[index-1], pipeline[index]
+	fields, raw, ok := o.searchFilterFields(search)
+	if !ok {
+		return rewriteCandidate{}, false
+	}
+	derived, _, _ := o.commandFieldEffect(eval)
+	if raw && derived["_raw"] {
+		return rewriteCandidate{}, false
+	}
+	for field := range fields {
+		if derived[field] {
+			return rewriteCandidate{}, false
+		}
+	}
+
+	text := o.commandText(search)
+	return rewriteCandidate{
+		rewrite:     RewritePushSearchBeforeEval,
+		explanation: fmt.Sprintf("moved %q before %q, which does not assign the fields it filters on", text, o.commandText(eval)),
+		apply: func(rewriter *antlr.TokenStreamRewriter) {
+			rewriter.InsertBeforeDefault(eval.Node.GetStart().GetTokenIndex(), text+" | ")
+			o.deleteCommand(rewriter, pipeline, index)
+		},
+	}, true
+}
+
+// dropRedundantFields removes one of two consecutive "fields" commands when one keeps a subset
+// of the fields the other keeps
+func (o *queryOptimizer) dropRedundantFields(pipeline []LintCommand, index int) (rewriteCandidate, bool) {
+	first, second := pipeline[index], pipeline[index+1]
+	_, firstKeep, firstOK := o.commandFieldEffect(first)
+	_, secondKeep, secondOK := o.commandFieldEffect(second)
+	if !firstOK || !secondOK || firstKeep == nil || secondKeep == nil {
+		return rewriteCandidate{}, false
+	}
+
+	drop, keep := index+1, first
+	if !isFieldSubset(firstKeep, secondKeep) {
+		if !isFieldSubset(secondKeep, firstKeep) {
+			return rewriteCandidate{}, false
+		}
+		drop, keep = index, second
+	}
+	return rewriteCandidate{
+		rewrite:     RewriteDropRedundantFields,
+		explanation: fmt.Sprintf("removed %q, which %q makes redundant", o.commandText(pipeline[drop]), o.commandText(keep)),
+		apply: func(rewriter *antlr.TokenStreamRewriter) {
+			o.deleteCommand(rewriter, pipeline, drop)
+		},
+	}, true
+}
+
+func isFieldSubset(subset, set map[string]bool) bool {
+	for field := range subset {
+		if !set[field] {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteCommand removes a command and the pipe before it
+func (o *queryOptimizer) deleteCommand(rewriter *antlr.TokenStreamRewriter, pipeline []LintCommand, index int) {
+	rewriter.DeleteDefault(pipeline[index-1].Node.GetStop().GetTokenIndex()+1, pipeline[index].Node.GetStop().GetTokenIndex())
+}
+
+// commandText returns the text of a command, from its name to its last token
+func (o *queryOptimizer) commandText(command LintCommand) string {
+	return o.text(command.NameToken(), command.Node.GetStop())
+}
+
+// filterText returns the arguments of a search command
+func (o *queryOptimizer) filterText(search LintCommand) string {
+	tokens := o.query.CommandTokens(search)
+	return o.text(tokens[1], tokens[len(tokens)-1])
+}
+
+func (o *queryOptimizer) text(start, stop antlr.Token) string {
+	return string([]rune(o.query.Text)[start.GetStart() : stop.GetStop()+1])
+}
+
+// searchFilterFields returns the fields a search command compares, and whether it has bare
+// terms, which match against _raw. It fails for searches it cannot analyze: subsearches,
+// wildcard field names and searches without terms.
+func (o *queryOptimizer) searchFilterFields(search LintCommand) (map[string]bool, bool, bool) {
+	tokens := o.query.CommandTokens(search)
+	if len(tokens) < 2 || hasSubquery(search.Node) {
+		return nil, false, false
+	}
+
+	fields := make(map[string]bool)
+	raw := false
+	for i := 1; i < len(tokens); i++ {
+		token := tokens[i]
+		if i+1 < len(tokens) && isComparisonToken(tokens[i+1]) {
+			// The field name must be a single word, not part of a wildcard or expression
+			if tokens[i-1].GetStop()+1 == token.GetStart() || token.GetTokenType() == parser.SPLLexerMULT {
+				return nil, false, false
+			}
+			fields[token.GetText()] = true
+
+			// Skip the operator and the value
+			i++
+			if tokens[i].GetTokenType() == parser.SPLLexerIN {
+				for i+1 < len(tokens) && tokens[i].GetTokenType() != parser.SPLLexerRPAREN {
+					i++
+				}
+				continue
+			}
+			for i+1 < len(tokens) && tokens[i+1].GetStart() == tokens[i].GetStop()+1 && !isComparisonToken(tokens[i+1]) {
+				i++
+			}
+			continue
+		}
+
+		switch token.GetTokenType() {
+		case parser.SPLLexerLPAREN, parser.SPLLexerRPAREN, parser.SPLLexerAND, parser.SPLLexerOR, parser.SPLLexerNOT:
+		default:
+			raw = true
+		}
+	}
+	return fields, raw, true
+}
+
+func isComparisonToken(token antlr.Token) bool {
+	switch token.GetTokenType() {
+	case parser.SPLLexerEQ, parser.SPLLexerNE, parser.SPLLexerGT, parser.SPLLexerLT,
+		parser.SPLLexerGE, parser.SPLLexerLE, parser.SPLLexerIN:
+		return true
+	}
+	return false
+}
+
+func hasSubquery(tree antlr.Tree) bool {
+	for _, child := range tree.GetChildren() {
+		if _, ok := child.(*parser.SubqueryContext); ok || hasSubquery(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandFieldEffect returns the fields a streaming command assigns and, for "fields", the
+// fields it keeps. It fails for the commands whose effect is not known.
+func (o *queryOptimizer) commandFieldEffect(command LintCommand) (map[string]bool, map[string]bool, bool) {
+	derived := make(map[string]bool)
+	tokens := o.query.CommandTokens(command)
+
+	switch command.Name {
+	case "search", "where":
+		return derived, nil, true
+	case "eval":
+		for _, child := range command.Node.GetChildren() {
+			if assignment, ok := child.(*parser.KEYVALUEOPContext); ok && assignment.EQ() != nil {
+				derived[assignment.GetChild(0).(antlr.ParseTree).GetText()] = true
+			}
+		}
+		return derived, nil, true
+	case "rex":
+		for _, token := range tokens {
+			if token.GetTokenType() == parser.SPLLexerSTRING {
+				for _, match := range rexNamedGroupRegex.FindAllStringSubmatch(token.GetText(), -1) {
+					derived[match[1]] = true
+				}
+			}
+		}
+		if mode := command.Option("mode"); mode != nil && strings.EqualFold(mode.Expression().GetText(), "sed") {
+			target := "_raw"
+			if field := command.Option("field"); field != nil {
+				target = field.Expression().GetText()
+			}
+			derived[target] = true
+		}
+		if offsetField := command.Option("offset_field"); offsetField != nil {
+			derived[offsetField.Expression().GetText()] = true
+		}
+		return derived, nil, true
+	case "rename":
+		for _, token := range tokens[1:] {
+			if token.GetTokenType() == parser.SPLLexerIDENTIFIER || token.GetTokenType() == parser.SPLLexerSTRING {
+				derived[strings.Trim(token.GetText(), `"`)] = true
+			}
+		}
+		return derived, nil, true
+	case "fields":
+		keep := make(map[string]bool)
+		for _, token := range tokens[1:] {
+			if token.GetTokenType() != parser.SPLLexerIDENTIFIER {
+				return nil, nil, false
+			}
+			keep[token.GetText()] = true
+		}
+		return derived, keep, len(keep) > 0
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,113 @@
+package mapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOptimizeQuery(t *testing.T) {
+	all := []string{RewriteSearchToBaseFilter, RewritePushSearchBeforeEval, RewriteDropRedundantFields}
+
+	tests := []struct {
+		name      string
+		query     string
+		rewrites  []string
+		optimized string
+		applied   []string
+		skipped   []string
+	}{
+		{
+			name:      "Filter after eval moves into the base search",
+			query:     "index=web | eval kb=bytes/1024 | search status=500",
+			rewrites:  all,
+			optimized: "index=web status=500 | eval kb=bytes/1024",
+			applied:   []string{RewriteSearchToBaseFilter},
+		},
+		{
+			name:      "Filter directly after the base search",
+			query:     "index=web | search status=500 OR status=503 | stats count",
+			rewrites:  all,
+			optimized: "index=web (status=500 OR status=503) | stats count",
+			applied:   []string{RewriteSearchToBaseFilter},
+		},
+		{
+			name:      "Filter on a derived field stays",
+			query:     "index=web | eval kb=bytes/1024 | rex field=msg \"(?<user>\\w+)\" | search kb>10 user=bob",
+			rewrites:  all,
+			optimized: "index=web | eval kb=bytes/1024 | rex field=msg \"(?<user>\\w+)\" | search kb>10 user=bob",
+		},
+		{
+			name:      "Filter on a field dropped by fields stays",
+			query:     "index=web | fields host | search status=500",
+			rewrites:  all,
+			optimized: "index=web | fields host | search status=500",
+		},
+		{
+			name:      "Bare terms stay after an eval of _raw",
+			query:     "index=web | eval _raw=lower(_raw) | search error",
+			rewrites:  all,
+			optimized: "index=web | eval _raw=lower(_raw) | search error",
+		},
+		{
+			name:      "Filter after stats moves before evals",
+			query:     "index=web | stats count by host | eval x=1 | eval y=2 | search host=a",
+			rewrites:  []string{RewritePushSearchBeforeEval},
+			optimized: "index=web | stats count by host | search host=a | eval x=1 | eval y=2",
+			applied:   []string{RewritePushSearchBeforeEval, RewritePushSearchBeforeEval},
+		},
+		{
+			name:      "Only selected rewrites apply",
+			query:     "index=web | stats count by host | eval x=1 | search host=a",
+			rewrites:  []string{RewriteDropRedundantFields},
+			optimized: "index=web | stats count by host | eval x=1 | search host=a",
+		},
+		{
+			name:      "Redundant fields",
+			query:     "index=web | fields host status | fields host | fields host | stats count by host",
+			rewrites:  all,
+			optimized: "index=web | fields host | stats count by host",
+			applied:   []string{RewriteDropRedundantFields, RewriteDropRedundantFields},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := OptimizeQuery(tt.query, OptimizeOptions{Rewrites: tt.rewrites})
+			if err != nil {
+				t.Fatalf("OptimizeQuery failed: %v", err)
+			}
+			if result.Optimized != tt.optimized {
+				t.Errorf("Expected %q, got %q", tt.optimized, result.Optimized)
+			}
+
+			ids := func(rewrites []AppliedRewrite) string {
+				var list []string
+				for _, rewrite := range rewrites {
+					if rewrite.Explanation == "" {
+						t.Errorf("Rewrite %s has no explanation", rewrite.Rewrite)
+					}
+					list = append(list, rewrite.Rewrite)
+				}
+				return strings.Join(list, ",")
+			}
+			if got := ids(result.Applied); got != strings.Join(tt.applied, ",") {
+				t.Errorf("Expected applied rewrites %v, got %s", tt.applied, got)
+			}
+			if got := ids(result.Skipped); got != strings.Join(tt.skipped, ",") {
+				t.Errorf("Expected skipped rewrites %v, got %s", tt.skipped, got)
+			}
+		})
+	}
+
+	if _, err := OptimizeQuery("index=web", OptimizeOptions{Rewrites: []string{"nope"}}); err == nil {
+		t.Error("Expected an error for an unknown rewrite")
+	}
+
+	// Merging evals is deliberately not a rewrite: the merged form does not re-validate
+	if _, err := OptimizeQuery("index=web | eval a=1 | eval b=2", OptimizeOptions{Rewrites: []string{"merge-eval"}}); err == nil {
+		t.Error("Expected merge-eval to be an unknown rewrite")
+	}
+	if err := NewParser().ValidateQuery("index=web | eval a=1, b=2"); err == nil {
+		t.Error("The grammar accepts comma-separated eval assignments; merge-eval could now be implemented")
+	}
+}
@@ -0,0 +1,129 @@
+package mapper
+
+import (
+	"sort"
+)
+
+// MappingReport explains every rewrite applied to a query by the mapper
+type MappingReport struct {
+	OriginalQuery  string               `json:"original_query"`
+	MappedQuery    string               `json:"mapped_query"`
+	Replacements   []MappingReplacement `json:"replacements"`
+	UnmappedFields []string             `json:"unmapped_fields"` // Input fields without an effective mapping
+	UnusedMappings []UnusedMapping      `json:"unused_mappings"` // Effective mappings that never applied
+	Collisions     []MappingCollision   `json:"collisions"`      // Derived fields that clash with a mapping target
+}
+
+// MappingOrigin identifies the mapping or conditional rule an effective mapping came from
+type MappingOrigin struct {
+	MappingID string `json:"mapping_id,omitempty"`
+	RuleID    string `json:"rule_id,omitempty"` // Empty for basic mappings
+}
+
+// Kinds of rewrite recorded in a MappingReplacement
+const (
+	ReplacementField     = "field"     // A field name was renamed
+	ReplacementValue     = "value"     // A literal value compared against a field was transformed
+	ReplacementExpansion = "expansion" // A field was expanded into a field set or an expression
+)
+
+// MappingReplacement describes a single rewrite applied to a query
+type MappingReplacement struct {
+	Kind        string `json:"kind"`
+	Field       string `json:"field"` // Source field of the mapping that caused the rewrite
+	Original    string `json:"original"`
+	Replacement string `json:"replacement"`
+	Offset      int    `json:"offset"` // Character offset of the original token in the query
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	Command     string `json:"command"`
+	MappingOrigin
+}
+
+// UnusedMapping describes an effective mapping whose source field never appeared in the query
+type UnusedMapping struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	MappingOrigin
+}
+
+// MappingCollision flags a field derived by the query whose name is also the target of a mapping.
+// After mapping, references to that name are ambiguous between the derived and the mapped field.
+type MappingCollision struct {
+	Field   string `json:"field"`  // Derived field name, equal to the mapping target
+	Source  string `json:"source"` // Source field of the clashing mapping
+	Offset  int    `json:"offset"` // Character offset of the derivation in the query
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Command string `json:"command"`
+	MappingOrigin
+}
+
+// MapQueryDetailed applies field mappings to a SPL query and reports every replacement
+func (m *Mapper) MapQueryDetailed(query string) (*MappingReport, error) {
+	context := m.extractQueryContextFromString(query)
+	return m.MapQueryDetailedWithContext(query, context)
+}
+
+// MapQueryDetailedWithContext applies field mappings with explicit context and reports every replacement
+func (m *Mapper) MapQueryDetailedWithContext(query string, context map[string]interface{}) (*MappingReport, error) {
+	report, err := m.rewriteQuery(query, context)
+	if err != nil {
+		return nil, err
+	}
+
+	// Input fields are discovered on the original query; discovery failures
+	// (e.g. macros) simply leave the list empty. Fields rewritten by a subsearch's
+	// own mappings count as mapped.
+	effectiveMappings := m.getEffectiveMappings(context)
+	replaced := make(map[string]bool)
+	for _, replacement := range report.Replacements {
+		replaced[replacement.Field] = true
+	}
+	if info, err := m.DiscoverQuery(query); err == nil {
+		for _, field := range info.InputFields {
+			if _, mapped := effectiveMappings[field]; !mapped && !replaced[field] {
+				report.UnmappedFields = append(report.UnmappedFields, field)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// newMappingReport builds a report from the listener's replacements and the effective mappings
+func newMappingReport(query string, listener *FieldMappingListener, mappings map[string]string, origins map[string]MappingOrigin) *MappingReport {
+	report := &MappingReport{
+		OriginalQuery:  query,
+		MappedQuery:    listener.GetRewrittenText(),
+		Replacements:   []MappingReplacement{},
+		UnmappedFields: []string{},
+		UnusedMappings: []UnusedMapping{},
+		Collisions:     []MappingCollision{},
+	}
+
+	used := make(map[string]bool)
+	for _, replacement := range listener.GetReplacements() {
+		report.Replacements = append(report.Replacements, replacement)
+		used[replacement.Field] = true
+	}
+
+	for source, target := range mappings {
+		if !used[source] {
+			report.UnusedMappings = append(report.UnusedMappings, UnusedMapping{
+				Source:        source,
+				Target:        target,
+				MappingOrigin: origins[source],
+			})
+		}
+	}
+	sort.Slice(report.UnusedMappings, func(i, j int) bool {
+		return report.UnusedMappings[i].Source < report.UnusedMappings[j].Source
+	})
+
+	for _, collision := range listener.GetCollisions() {
+		report.Collisions = append(report.Collisions, collision)
+	}
+
+	return report
+}
@@ -0,0 +1,746 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ruleExpression is a compiled 'when' expression of a conditional rule. The language is a
+// small CEL-style subset: identifiers name context keys, and the result must be a boolean.
+//
+//	sourcetype in ["pan:traffic", "pan:threat"] && !has(datamodel)
+//	index == "main" || host.startsWith("web") && size(command) > 2
+type ruleExpression struct {
+	source string
+	root   exprNode
+}
+
+// exprType is the static type of an expression, checked when the expression is compiled
+type exprType int
+
+const (
+	exprDyn exprType = iota // A context value, whose type is only known at evaluation time
+	exprBool
+	exprNumber
+	exprString
+	exprList
+)
+
+func (t exprType) String() string {
+	return [...]string{"dyn", "bool", "number", "string", "list"}[t]
+}
+
+// exprNode is a node of a parsed expression
+type exprNode interface {
+	column() int
+}
+
+type (
+	literalNode struct {
+		col   int
+		value interface{} // bool, json.Number or string
+	}
+	identNode struct {
+		col  int
+		name string
+	}
+	listNode struct {
+		col   int
+		items []exprNode
+	}
+	unaryNode struct {
+		col     int
+		operand exprNode
+	}
+	binaryNode struct {
+		col         int
+		op          string
+		left, right exprNode
+	}
+	callNode struct {
+		col      int
+		name     string
+		receiver exprNode // Set for method calls such as host.startsWith("web")
+		args     []exprNode
+		regex    *regexp.Regexp // Compiled pattern of matches() with a literal argument
+	}
+)
+
+func (n *literalNode) column() int { return n.col }
+func (n *identNode) column() int   { return n.col }
+func (n *listNode) column() int    { return n.col }
+func (n *unaryNode) column() int   { return n.col }
+func (n *binaryNode) column() int  { return n.col }
+func (n *callNode) column() int    { return n.col }
+
+// exprMethods maps the string methods of the language to the value operator they apply
+var exprMethods = map[string]string{
+	"contains":   "contains",
+	"startsWith": "starts_with",
+	"endsWith":   "ends_with",
+	"matches":    "regex",
+}
+
+// compileRuleExpression parses and type-checks an expression. Errors name the column of the problem.
+func compileRuleExpression(source string) (*ruleExpression, error) {
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind != tokenEOF {
+		return nil, fmt.Errorf("column %d: unexpected %s", token.col, token.describe())
+	}
+
+	t, err := checkExpression(root)
+	if err != nil {
+		return nil, err
+	}
+	if t != exprBool && t != exprDyn {
+		return nil, fmt.Errorf("column %d: expression must be a boolean, not a %s", root.column(), t)
+	}
+
+	return &ruleExpression{source: source, root: root}, nil
+}
+
+// matches evaluates the expression against a mapping context
+func (e *ruleExpression) matches(context map[string]interface{}) bool {
+	return truthy(evaluateExpression(e.root, context))
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string // Operator or identifier text, or the unquoted string
+	col  int    // 1-based column
+}
+
+func (t exprToken) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+// exprOperators lists the operators, longest first so that "<=" is not read as "<"
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "-", "(", ")", "[", "]", ",", "."}
+
+func lexExpression(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(source); {
+		c := source[i]
+		col := i + 1
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isIdentStart(c):
+			start := i
+			for i < len(source) && (isIdentStart(source[i]) || source[i] >= '0' && source[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: source[start:i], col: col})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.') {
+				i++
+			}
+			if i < len(source) && (source[i] == 'e' || source[i] == 'E') {
+				i++
+				if i < len(source) && (source[i] == '+' || source[i] == '-') {
+					i++
+				}
+				for i < len(source) && source[i] >= '0' && source[i] <= '9' {
+					i++
+				}
+			}
+			if _, err := strconv.ParseFloat(source[start:i], 64); err != nil {
+				return nil, fmt.Errorf("column %d: invalid number '%s'", col, source[start:i])
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: source[start:i], col: col})
+		case c == '"' || c == '\'':
+			text, end, err := lexString(source, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, exprToken{kind: tokenString, text: text, col: col})
+			i = end
+		default:
+			operator := ""
+			for _, candidate := range exprOperators {
+				if strings.HasPrefix(source[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("column %d: unexpected character '%c'", col, c)
+			}
+			tokens = append(tokens, exprToken{kind: tokenOperator, text: operator, col: col})
+			i += len(operator)
+		}
+	}
+	return append(tokens, exprToken{kind: tokenEOF, col: len(source) + 1}), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// lexString reads a quoted string starting at source[start], returning its value and the index after it
+func lexString(source string, start int) (string, int, error) {
+	quote := source[start]
+	var value strings.Builder
+	for i := start + 1; i < len(source); i++ {
+		switch c := source[i]; {
+		case c == quote:
+			return value.String(), i + 1, nil
+		case c == '\\' && i+1 < len(source):
+			i++
+			switch escaped := source[i]; escaped {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case '\\', '"', '\'':
+				value.WriteByte(escaped)
+			default:
+				// Keep other escapes, so regular expressions such as "\d+" read naturally
+				value.WriteByte('\\')
+				value.WriteByte(escaped)
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("column %d: unterminated string", start+1)
+}
+
+// Parser
+
+type exprParser struct {
+	tokens   []exprToken
+	position int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.position]
+}
+
+func (p *exprParser) next() exprToken {
+	token := p.tokens[p.position]
+	if token.kind != tokenEOF {
+		p.position++
+	}
+	return token
+}
+
+// accept consumes the next token if it is the given operator
+func (p *exprParser) accept(operator string) bool {
+	if token := p.peek(); token.kind == tokenOperator && token.text == operator {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(operator string) error {
+	if !p.accept(operator) {
+		token := p.peek()
+		return fmt.Errorf("column %d: expected '%s', found %s", token.col, operator, token.describe())
+	}
+	return nil
+}
+
+// parseOr parses "a || b", the loosest binding operator
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for token := p.peek(); p.accept("||"); token = p.peek() {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{col: token.col, op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseRelation()
+	if err != nil {
+		return nil, err
+	}
+	for token := p.peek(); p.accept("&&"); token = p.peek() {
+		right, err := p.parseRelation()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{col: token.col, op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseRelation parses a comparison or "in" test; relations do not chain
+func (p *exprParser) parseRelation() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	token := p.peek()
+	isRelation := token.kind == tokenOperator && strings.Contains(" == != < <= > >= ", " "+token.text+" ") ||
+		token.kind == tokenIdent && token.text == "in"
+	if !isRelation {
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{col: token.col, op: token.text, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	token := p.peek()
+	switch {
+	case p.accept("!"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{col: token.col, operand: operand}, nil
+	case p.accept("-"):
+		number := p.next()
+		if number.kind != tokenNumber {
+			return nil, fmt.Errorf("column %d: '-' must be followed by a number", token.col)
+		}
+		return &literalNode{col: token.col, value: json.Number("-" + number.text)}, nil
+	}
+	return p.parseMember()
+}
+
+// parseMember parses a primary expression followed by method calls
+func (p *exprParser) parseMember() (exprNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(".") {
+		name := p.next()
+		if name.kind != tokenIdent {
+			return nil, fmt.Errorf("column %d: expected a method name after '.', found %s", name.col, name.describe())
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		args, err := p.parseArguments(")")
+		if err != nil {
+			return nil, err
+		}
+		node = &callNode{col: name.col, name: name.text, receiver: node, args: args}
+	}
+	return node, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	token := p.next()
+	switch token.kind {
+	case tokenNumber:
+		return &literalNode{col: token.col, value: json.Number(token.text)}, nil
+	case tokenString:
+		return &literalNode{col: token.col, value: token.text}, nil
+	case tokenIdent:
+		switch token.text {
+		case "true", "false":
+			return &literalNode{col: token.col, value: token.text == "true"}, nil
+		case "in":
+			return nil, fmt.Errorf("column %d: unexpected 'in'", token.col)
+		}
+		if p.accept("(") {
+			args, err := p.parseArguments(")")
+			if err != nil {
+				return nil, err
+			}
+			return &callNode{col: token.col, name: token.text, args: args}, nil
+		}
+		return &identNode{col: token.col, name: token.text}, nil
+	case tokenOperator:
+		switch token.text {
+		case "(":
+			node, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		case "[":
+			items, err := p.parseArguments("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{col: token.col, items: items}, nil
+		}
+	}
+	return nil, fmt.Errorf("column %d: unexpected %s", token.col, token.describe())
+}
+
+// parseArguments parses a comma-separated list of expressions up to the closing operator
+func (p *exprParser) parseArguments(closing string) ([]exprNode, error) {
+	var nodes []exprNode
+	if p.accept(closing) {
+		return nodes, nil
+	}
+	for {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		if p.accept(closing) {
+			return nodes, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Type checking
+
+// checkExpression returns the static type of an expression, or an error if it is used wrongly
+func checkExpression(node exprNode) (exprType, error) {
+	switch n := node.(type) {
+	case *literalNode:
+		switch n.value.(type) {
+		case bool:
+			return exprBool, nil
+		case json.Number:
+			return exprNumber, nil
+		}
+		return exprString, nil
+
+	case *identNode:
+		return exprDyn, nil
+
+	case *listNode:
+		for _, item := range n.items {
+			t, err := checkExpression(item)
+			if err != nil {
+				return 0, err
+			}
+			if t == exprList {
+				return 0, fmt.Errorf("column %d: lists cannot contain lists", item.column())
+			}
+		}
+		return exprList, nil
+
+	case *unaryNode:
+		if err := expectTypes(n.operand, "'!'", exprBool); err != nil {
+			return 0, err
+		}
+		return exprBool, nil
+
+	case *binaryNode:
+		left, err := checkExpression(n.left)
+		if err != nil {
+			return 0, err
+		}
+		right, err := checkExpression(n.right)
+		if err != nil {
+			return 0, err
+		}
+
+		switch n.op {
+		case "&&", "||":
+			if err := operandTypes(n, left, right, exprBool); err != nil {
+				return 0, err
+			}
+		case "==", "!=":
+			if left == exprList || right == exprList {
+				return 0, fmt.Errorf("column %d: cannot compare a list with '%s'; use 'in'", n.col, n.op)
+			}
+			if left != exprDyn && right != exprDyn && (left == exprBool) != (right == exprBool) {
+				return 0, fmt.Errorf("column %d: cannot compare a %s with a %s", n.col, left, right)
+			}
+		case "in":
+			if left == exprList {
+				return 0, fmt.Errorf("column %d: the left side of 'in' must be a value, not a list", n.col)
+			}
+			if right != exprList && right != exprDyn {
+				return 0, fmt.Errorf("column %d: the right side of 'in' must be a list, not a %s", n.right.column(), right)
+			}
+		default:
+			if err := operandTypes(n, left, right, exprNumber); err != nil {
+				return 0, err
+			}
+		}
+		return exprBool, nil
+
+	case *callNode:
+		return checkCall(n)
+	}
+	return 0, fmt.Errorf("column %d: unsupported expression", node.column())
+}
+
+// operandTypes checks that both operands of a binary operator have the allowed type
+func operandTypes(n *binaryNode, left, right, allowed exprType) error {
+	use := "'" + n.op + "'"
+	if err := allowType(n.left, left, use, allowed); err != nil {
+		return err
+	}
+	return allowType(n.right, right, use, allowed)
+}
+
+// expectTypes checks an operand and that it has one of the allowed types
+func expectTypes(node exprNode, use string, allowed ...exprType) error {
+	t, err := checkExpression(node)
+	if err != nil {
+		return err
+	}
+	return allowType(node, t, use, allowed...)
+}
+
+// allowType checks that a node of type t has one of the allowed types; context values are always allowed
+func allowType(node exprNode, t exprType, use string, allowed ...exprType) error {
+	if t == exprDyn {
+		return nil
+	}
+	for _, a := range allowed {
+		if t == a {
+			return nil
+		}
+	}
+	names := make([]string, len(allowed))
+	for i, a := range allowed {
+		names[i] = a.String()
+	}
+	return fmt.Errorf("column %d: %s expects a %s, not a %s", node.column(), use, strings.Join(names, " or "), t)
+}
+
+func checkCall(n *callNode) (exprType, error) {
+	if n.receiver != nil {
+		if _, known := exprMethods[n.name]; !known {
+			return 0, fmt.Errorf("column %d: unknown method '%s'; methods are %s", n.col, n.name, strings.Join(sortedKeys(exprMethods), ", "))
+		}
+		if len(n.args) != 1 {
+			return 0, fmt.Errorf("column %d: %s() takes 1 argument", n.col, n.name)
+		}
+		if err := expectTypes(n.receiver, n.name+"()", exprString); err != nil {
+			return 0, err
+		}
+		if err := expectTypes(n.args[0], n.name+"()", exprString); err != nil {
+			return 0, err
+		}
+		if literal, ok := n.args[0].(*literalNode); ok && n.name == "matches" {
+			re, err := regexp.Compile(literal.value.(string))
+			if err != nil {
+				return 0, fmt.Errorf("column %d: invalid regular expression: %v", literal.col, err)
+			}
+			n.regex = re
+		}
+		return exprBool, nil
+	}
+
+	if len(n.args) != 1 {
+		return 0, fmt.Errorf("column %d: %s() takes 1 argument", n.col, n.name)
+	}
+	switch n.name {
+	case "has":
+		if _, ok := n.args[0].(*identNode); !ok {
+			return 0, fmt.Errorf("column %d: has() expects a context key, such as has(datamodel)", n.args[0].column())
+		}
+		return exprBool, nil
+	case "size":
+		if err := expectTypes(n.args[0], "size()", exprString, exprList); err != nil {
+			return 0, err
+		}
+		return exprNumber, nil
+	case "field":
+		if literal, ok := n.args[0].(*literalNode); ok {
+			if _, isString := literal.value.(string); isString {
+				return exprDyn, nil
+			}
+		}
+		return 0, fmt.Errorf("column %d: field() expects a quoted context key, such as field(\"Web.status\")", n.args[0].column())
+	}
+	return 0, fmt.Errorf("column %d: unknown function '%s'; functions are field, has and size", n.col, n.name)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Evaluation
+
+// evaluateExpression computes the value of an expression. Missing context keys evaluate to
+// nil, which no comparison matches. Context lists match when any element does, like conditions.
+func evaluateExpression(node exprNode, context map[string]interface{}) interface{} {
+	switch n := node.(type) {
+	case *literalNode:
+		return n.value
+
+	case *identNode:
+		return context[n.name]
+
+	case *listNode:
+		items := make([]interface{}, len(n.items))
+		for i, item := range n.items {
+			items[i] = evaluateExpression(item, context)
+		}
+		return items
+
+	case *unaryNode:
+		return !truthy(evaluateExpression(n.operand, context))
+
+	case *binaryNode:
+		switch n.op {
+		case "&&":
+			return truthy(evaluateExpression(n.left, context)) && truthy(evaluateExpression(n.right, context))
+		case "||":
+			return truthy(evaluateExpression(n.left, context)) || truthy(evaluateExpression(n.right, context))
+		}
+
+		left, right := evaluateExpression(n.left, context), evaluateExpression(n.right, context)
+		if left == nil || right == nil {
+			return false
+		}
+		switch n.op {
+		case "==", "in":
+			return anyPair(left, right, func(a, b interface{}) bool { return valuesEqual(a, b, false) })
+		case "!=":
+			return !anyPair(left, right, func(a, b interface{}) bool { return valuesEqual(a, b, false) })
+		}
+		operator := map[string]string{"<": "lt", "<=": "lte", ">": "gt", ">=": "gte"}[n.op]
+		return anyPair(left, right, func(a, b interface{}) bool { return matchScalar(operator, a, b, false, nil) })
+
+	case *callNode:
+		return evaluateCall(n, context)
+	}
+	return nil
+}
+
+func evaluateCall(n *callNode, context map[string]interface{}) interface{} {
+	if n.receiver != nil {
+		receiver, arg := evaluateExpression(n.receiver, context), evaluateExpression(n.args[0], context)
+		if receiver == nil || arg == nil {
+			return false
+		}
+		if n.regex != nil {
+			return anyPair(receiver, arg, func(a, _ interface{}) bool {
+				text, ok := conditionString(a)
+				return ok && n.regex.MatchString(text)
+			})
+		}
+		operator := exprMethods[n.name]
+		return anyPair(receiver, arg, func(a, b interface{}) bool { return matchScalar(operator, a, b, false, nil) })
+	}
+
+	switch n.name {
+	case "has":
+		_, exists := context[n.args[0].(*identNode).name]
+		return exists
+	case "size":
+		// Context keys hold one or several values, so their size is always the number of values
+		value := evaluateExpression(n.args[0], context)
+		if text, ok := value.(string); ok && !isContextValue(n.args[0]) {
+			return float64(len(text))
+		}
+		return float64(len(conditionList(value)))
+	case "field":
+		return context[n.args[0].(*literalNode).value.(string)]
+	}
+	return nil
+}
+
+// isContextValue reports whether an expression reads a context key, such as sourcetype or field("Web.status")
+func isContextValue(node exprNode) bool {
+	switch n := node.(type) {
+	case *identNode:
+		return true
+	case *callNode:
+		return n.receiver == nil && n.name == "field"
+	}
+	return false
+}
+
+// anyPair reports whether match holds for any element of a paired with any element of b
+func anyPair(a, b interface{}, match func(a, b interface{}) bool) bool {
+	for _, x := range conditionList(a) {
+		for _, y := range conditionList(b) {
+			if match(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// truthy reports whether a value counts as true: the boolean true or the string "true"
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// renameExpressionFields returns an expression with the context keys it names renamed
+func renameExpressionFields(source string, renames map[string]string) string {
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return source
+	}
+
+	var result strings.Builder
+	last := 0
+	for i, token := range tokens {
+		target, renamed := renames[token.text]
+		if token.kind != tokenIdent || !renamed {
+			continue
+		}
+		// Function and method names are not context keys
+		if i+1 < len(tokens) && tokens[i+1].kind == tokenOperator && tokens[i+1].text == "(" {
+			continue
+		}
+		if i > 0 && tokens[i-1].kind == tokenOperator && tokens[i-1].text == "." {
+			continue
+		}
+		result.WriteString(source[last : token.col-1])
+		result.WriteString(target)
+		last = token.col - 1 + len(token.text)
+	}
+	result.WriteString(source[last:])
+	return result.String()
+}
@@ -3,18 +3,25 @@ package mapper
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
 )
 
 // MappingConfig represents the complete configuration for field mappings
 type MappingConfig struct {
+	Schema      string                 `json:"$schema,omitempty"` // JSON Schema the file follows, for editors
 	Version     string                 `json:"version"`
 	Name        string                 `json:"name,omitempty"`
 	Description string                 `json:"description,omitempty"`
+	Extends     string                 `json:"extends,omitempty"` // Parent config file, resolved by Resolve
+	Include     []string               `json:"include,omitempty"` // Config files merged in order, resolved by Resolve
+	Remove      *ConfigRemovals        `json:"remove,omitempty"`  // Inherited entries to drop, resolved by Resolve
 	Mappings    []FieldMapping         `json:"mappings"`
 	Rules       []ConditionalRule      `json:"rules,omitempty"`
 	DataModels  []DataModelMapping     `json:"datamodels,omitempty"`
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+
+	regexes regexSet // Compiled condition patterns, set by compile
 }
 
 // ConditionalRule represents a conditional mapping rule for Phase 2
@@ -22,19 +29,23 @@ type ConditionalRule struct {
 	ID          string         `json:"id"`
 	Name        string         `json:"name,omitempty"`
 	Description string         `json:"description,omitempty"`
-	Conditions  []Condition    `json:"conditions"`
+	Conditions  []Condition    `json:"conditions,omitempty"`
+	When        string         `json:"when,omitempty"` // Expression that must also hold, e.g. `sourcetype in ["a", "b"] && !has(datamodel)`
 	Mappings    []FieldMapping `json:"mappings"`
 	Priority    int            `json:"priority"`
 	Enabled     bool           `json:"enabled"`
+
+	when *ruleExpression // Compiled When, set by MappingConfig.compile
 }
 
 // Condition represents a condition for conditional mapping
 type Condition struct {
-	Type     string      `json:"type"` // "field_value", "field_exists", "sourcetype", "source", "combination"
-	Field    string      `json:"field,omitempty"`
-	Operator string      `json:"operator,omitempty"` // "equals", "contains", "regex", "exists", "not_exists"
-	Value    interface{} `json:"value,omitempty"`
-	Children []Condition `json:"children,omitempty"` // For combination conditions (AND/OR)
+	Type       string      `json:"type"` // One of conditionTypes, e.g. "field_value", "sourcetype", "command_present", "combination"
+	Field      string      `json:"field,omitempty"`
+	Operator   string      `json:"operator,omitempty"` // One of conditionOperators, e.g. "equals", "in", "gt", "exists", "and"
+	Value      interface{} `json:"value,omitempty"`
+	IgnoreCase bool        `json:"ignore_case,omitempty"` // Compare strings case-insensitively
+	Children   []Condition `json:"children,omitempty"`    // For combination conditions (AND/OR/NOT)
 }
 
 // DataModelMapping represents mapping between datamodels for Phase 2
@@ -73,7 +84,7 @@ type ValidationResult struct {
 // LoadMappingConfig loads and validates a mapping configuration from JSON
 func LoadMappingConfig(jsonData []byte) (*MappingConfig, error) {
 	var config MappingConfig
-	if err := json.Unmarshal(jsonData, &config); err != nil {
+	if err := unmarshalConfigJSON(jsonData, &config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal mapping config: %w", err)
 	}
 
@@ -94,13 +105,18 @@ func (mc *MappingConfig) Validate() ValidationResult {
 		errors = append(errors, "version is required")
 	}
 
+	// Composed configs must be flattened first
+	if mc.Extends != "" || len(mc.Include) > 0 || mc.Remove != nil {
+		errors = append(errors, "extends, include and remove must be resolved with Resolve before use")
+	}
+
 	// Validate basic mappings
 	for i, mapping := range mc.Mappings {
 		if mapping.Source == "" {
 			errors = append(errors, fmt.Sprintf("mapping[%d]: source field is required", i))
 		}
-		if mapping.Target == "" {
-			errors = append(errors, fmt.Sprintf("mapping[%d]: target field is required", i))
+		if err := mapping.Validate(); err != nil {
+			errors = append(errors, fmt.Sprintf("mapping[%d]: %s", i, err.Error()))
 		}
 	}
 
@@ -109,13 +125,24 @@ func (mc *MappingConfig) Validate() ValidationResult {
 		if rule.ID == "" {
 			errors = append(errors, fmt.Sprintf("rule[%d]: id is required", i))
 		}
-		if len(rule.Conditions) == 0 {
-			errors = append(errors, fmt.Sprintf("rule[%d]: at least one condition is required", i))
+		if len(rule.Conditions) == 0 && rule.When == "" {
+			errors = append(errors, fmt.Sprintf("rule[%d]: at least one condition or a when expression is required", i))
+		}
+		if rule.When != "" {
+			if _, err := compileRuleExpression(rule.When); err != nil {
+				errors = append(errors, fmt.Sprintf("rule[%d].when: %s", i, err.Error()))
+			}
 		}
 		if len(rule.Mappings) == 0 {
 			errors = append(errors, fmt.Sprintf("rule[%d]: at least one mapping is required", i))
 		}
 
+		for j, mapping := range rule.Mappings {
+			if err := mapping.Validate(); err != nil {
+				errors = append(errors, fmt.Sprintf("rule[%d].mapping[%d]: %s", i, j, err.Error()))
+			}
+		}
+
 		// Validate conditions
 		for j, condition := range rule.Conditions {
 			if err := validateCondition(condition); err != nil {
@@ -140,13 +167,29 @@ func (mc *MappingConfig) Validate() ValidationResult {
 	}
 }
 
-func validateCondition(condition Condition) error {
-	validTypes := []string{"field_value", "field_exists", "sourcetype", "source", "combination"}
-	validOperators := []string{"equals", "contains", "regex", "exists", "not_exists", "and", "or"}
+// conditionTypes lists the valid Condition.Type values
+var conditionTypes = []string{
+	"field_value", "field_exists", "sourcetype", "source", "index", "host",
+	"datamodel", "dataset", "lookup", "macro", "command_present", "query_regex", "combination",
+}
+
+// discoveredValueTypes are the condition types that match values discovered in the query,
+// stored in the context under the same key
+var discoveredValueTypes = map[string]bool{
+	"sourcetype": true, "source": true, "index": true, "host": true,
+	"datamodel": true, "dataset": true, "lookup": true, "macro": true,
+}
+
+// conditionOperators lists the valid Condition.Operator values
+var conditionOperators = []string{
+	"equals", "not_equals", "contains", "starts_with", "ends_with", "regex", "in",
+	"gt", "gte", "lt", "lte", "between", "exists", "not_exists", "and", "or", "not",
+}
 
+func validateCondition(condition Condition) error {
 	// Check type
 	isValidType := false
-	for _, vt := range validTypes {
+	for _, vt := range conditionTypes {
 		if condition.Type == vt {
 			isValidType = true
 			break
@@ -159,7 +202,7 @@ func validateCondition(condition Condition) error {
 	// Check operator if present
 	if condition.Operator != "" {
 		isValidOperator := false
-		for _, vo := range validOperators {
+		for _, vo := range conditionOperators {
 			if condition.Operator == vo {
 				isValidOperator = true
 				break
@@ -171,17 +214,46 @@ func validateCondition(condition Condition) error {
 	}
 
 	// Type-specific validation
+	if condition.Type == "field_value" || discoveredValueTypes[condition.Type] {
+		if err := validateValueOperator(condition); err != nil {
+			return err
+		}
+	}
 	switch condition.Type {
 	case "field_value", "field_exists":
 		if condition.Field == "" {
 			return fmt.Errorf("field is required for type %s", condition.Type)
 		}
-	case "combination":
-		if len(condition.Children) < 2 {
-			return fmt.Errorf("combination conditions require at least 2 children")
+	case "command_present":
+		if command, ok := condition.Value.(string); !ok || command == "" {
+			return fmt.Errorf("command_present conditions require the command name as value")
+		}
+		if condition.Operator != "" && condition.Operator != "exists" && condition.Operator != "not_exists" {
+			return fmt.Errorf("command_present conditions support the 'exists' and 'not_exists' operators")
 		}
-		if condition.Operator != "and" && condition.Operator != "or" {
-			return fmt.Errorf("combination conditions require 'and' or 'or' operator")
+	case "query_regex":
+		pattern, ok := condition.Value.(string)
+		if !ok || pattern == "" {
+			return fmt.Errorf("query_regex conditions require a regular expression as value")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid query_regex pattern: %w", err)
+		}
+		if condition.Operator != "" && condition.Operator != "regex" {
+			return fmt.Errorf("query_regex conditions only support the 'regex' operator")
+		}
+	case "combination":
+		switch condition.Operator {
+		case "and", "or":
+			if len(condition.Children) < 2 {
+				return fmt.Errorf("combination conditions require at least 2 children")
+			}
+		case "not":
+			if len(condition.Children) != 1 {
+				return fmt.Errorf("'not' combination conditions require exactly 1 child")
+			}
+		default:
+			return fmt.Errorf("combination conditions require 'and', 'or' or 'not' operator")
 		}
 		// Recursively validate children
 		for i, child := range condition.Children {
@@ -206,20 +278,66 @@ func (mc *MappingConfig) GetMappingsForConditions(conditions map[string]interfac
 	// Add basic mappings
 	result = append(result, mc.Mappings...)
 
-	// Check conditional rules
+	// Add mappings of matching conditional rules
+	for _, rule := range mc.GetMatchingRules(conditions) {
+		result = append(result, rule.Mappings...)
+	}
+
+	return result
+}
+
+// GetMatchingRules returns the enabled conditional rules whose conditions match the given context,
+// in file order. Their mappings are applied in this order, so a later rule wins a field that
+// several rules map; Priority does not change the order.
+func (mc *MappingConfig) GetMatchingRules(conditions map[string]interface{}) []ConditionalRule {
+	var result []ConditionalRule
+
 	for _, rule := range mc.Rules {
 		if !rule.Enabled {
 			continue
 		}
 
-		if mc.evaluateConditions(rule.Conditions, conditions) {
-			result = append(result, rule.Mappings...)
+		if mc.evaluateConditions(rule.Conditions, conditions) && rule.matchesWhen(conditions) {
+			result = append(result, rule)
 		}
 	}
 
 	return result
 }
 
+// matchesWhen evaluates the rule's when expression; rules without one always match
+func (rule ConditionalRule) matchesWhen(context map[string]interface{}) bool {
+	if rule.When == "" {
+		return true
+	}
+	expression := rule.when
+	if expression == nil || expression.source != rule.When {
+		var err error
+		if expression, err = compileRuleExpression(rule.When); err != nil {
+			return false
+		}
+	}
+	return expression.matches(context)
+}
+
+// compile compiles the when expressions and condition patterns of the rules once, so that
+// evaluating them for every query does not compile them again. Rules changed afterwards are
+// compiled on each use.
+func (mc *MappingConfig) compile() {
+	regexes := make(regexSet)
+	for i := range mc.Rules {
+		rule := &mc.Rules[i]
+		rule.when = nil
+		if rule.When != "" {
+			if expression, err := compileRuleExpression(rule.When); err == nil {
+				rule.when = expression
+			}
+		}
+		regexes.addConditions(rule.Conditions)
+	}
+	mc.regexes = regexes
+}
+
 func (mc *MappingConfig) evaluateConditions(conditions []Condition, context map[string]interface{}) bool {
 	for _, condition := range conditions {
 		if !mc.evaluateCondition(condition, context) {
@@ -240,55 +358,35 @@ func (mc *MappingConfig) evaluateCondition(condition Condition, context map[stri
 		if !exists {
 			return false
 		}
+		return matchValue(condition, value, mc.regexes)
 
-		switch condition.Operator {
-		case "equals":
-			return value == condition.Value
-		case "contains":
-			if str, ok := value.(string); ok {
-				if condStr, ok := condition.Value.(string); ok {
-					return strings.Contains(str, condStr)
-				}
-			}
-		}
-
-	case "sourcetype", "source":
+	case "sourcetype", "source", "index", "host", "datamodel", "dataset", "lookup", "macro":
 		value, exists := context[condition.Type]
 		if !exists {
 			return false
 		}
-
 		// Handle both single values and arrays (any-match semantics)
-		switch condition.Operator {
-		case "equals":
-			if arr, ok := value.([]string); ok {
-				// Array case - check if any element matches
-				for _, str := range arr {
-					if str == condition.Value {
-						return true
-					}
-				}
-				return false
-			}
-			// Single value case
-			return value == condition.Value
-		case "contains":
-			if condStr, ok := condition.Value.(string); ok {
-				if arr, ok := value.([]string); ok {
-					// Array case - check if any element contains the condition
-					for _, str := range arr {
-						if strings.Contains(str, condStr) {
-							return true
-						}
-					}
-					return false
-				}
-				// Single value case
-				if str, ok := value.(string); ok {
-					return strings.Contains(str, condStr)
-				}
+		return matchValue(condition, value, mc.regexes)
+
+	case "command_present":
+		command, _ := condition.Value.(string)
+		present := false
+		for _, used := range contextValues(context["command"]) {
+			if strings.EqualFold(used, command) {
+				present = true
+				break
 			}
 		}
+		return present != (condition.Operator == "not_exists")
+
+	case "query_regex":
+		query, ok := context["query"].(string)
+		pattern, _ := condition.Value.(string)
+		if !ok {
+			return false
+		}
+		re, err := mc.regexes.compile(pattern)
+		return err == nil && re.MatchString(query)
 
 	case "combination":
 		if condition.Operator == "and" {
@@ -305,8 +403,22 @@ func (mc *MappingConfig) evaluateCondition(condition Condition, context map[stri
 				}
 			}
 			return false
+		} else if condition.Operator == "not" && len(condition.Children) == 1 {
+			return !mc.evaluateCondition(condition.Children[0], context)
 		}
 	}
 
 	return false
 }
+
+// contextValues returns a context value as a list of strings, converting scalars the way
+// conditions compare them
+func contextValues(value interface{}) []string {
+	var values []string
+	for _, item := range conditionList(value) {
+		if str, ok := conditionString(item); ok {
+			values = append(values, str)
+		}
+	}
+	return values
+}
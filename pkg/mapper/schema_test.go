@@ -2,6 +2,10 @@ package mapper
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -165,6 +169,381 @@ func TestConditionValidation(t *testing.T) {
 	}
 }
 
+func TestDiscoveredConditionValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		wantError string
+	}{
+		{"index", Condition{Type: "index", Operator: "equals", Value: "main"}, ""},
+		{"command present", Condition{Type: "command_present", Value: "tstats"}, ""},
+		{"command absent", Condition{Type: "command_present", Operator: "not_exists", Value: "tstats"}, ""},
+		{"command without name", Condition{Type: "command_present"}, "command name as value"},
+		{"command with value operator", Condition{Type: "command_present", Operator: "equals", Value: "tstats"}, "'exists' and 'not_exists'"},
+		{"query regex", Condition{Type: "query_regex", Operator: "regex", Value: `\| tstats`}, ""},
+		{"query regex without pattern", Condition{Type: "query_regex"}, "regular expression as value"},
+		{"invalid query regex", Condition{Type: "query_regex", Value: "(unclosed"}, "invalid query_regex pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCondition(tt.condition)
+			if tt.wantError == "" {
+				if err != nil {
+					t.Errorf("Expected valid condition, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantError, err)
+			}
+		})
+	}
+
+	// Contexts decoded from JSON hold []interface{} values
+	config := MappingConfig{}
+	context := map[string]interface{}{"index": []interface{}{"main", "security"}, "command": []interface{}{"search", "tstats"}}
+	if !config.evaluateCondition(Condition{Type: "index", Operator: "equals", Value: "security"}, context) {
+		t.Error("Expected index condition to match a []interface{} context value")
+	}
+	if !config.evaluateCondition(Condition{Type: "command_present", Value: "TSTATS"}, context) {
+		t.Error("Expected command_present to match command names case-insensitively")
+	}
+}
+
+func TestConditionOperators(t *testing.T) {
+	context := map[string]interface{}{
+		"status":     3.0, // Numbers decoded from JSON are float64
+		"code":       "404",
+		"bytes":      int64(1500),
+		"user":       "Admin",
+		"debug":      true,
+		"account":    "123456789012345678",
+		"version":    "1.10",
+		"sourcetype": []string{"access_combined", "iis"},
+		"host":       []interface{}{"web01", "db01"},
+	}
+
+	tests := []struct {
+		name      string
+		condition Condition
+		expected  bool
+	}{
+		{"number equals string", Condition{Type: "field_value", Field: "status", Operator: "equals", Value: "3"}, true},
+		{"string equals number", Condition{Type: "field_value", Field: "code", Operator: "equals", Value: 404}, true},
+		{"numeric strings compare as strings", Condition{Type: "field_value", Field: "code", Operator: "equals", Value: "404.0"}, false},
+		{"version strings compare as strings", Condition{Type: "field_value", Field: "version", Operator: "equals", Value: "1.1"}, false},
+		{"number equals decimal string", Condition{Type: "field_value", Field: "version", Operator: "equals", Value: 1.1}, true},
+		{"long IDs compare exactly", Condition{Type: "field_value", Field: "account", Operator: "equals", Value: "123456789012345679"}, false},
+		{"long ID numbers compare exactly", Condition{Type: "field_value", Field: "account", Operator: "equals", Value: json.Number("123456789012345679")}, false},
+		{"long ID number equals", Condition{Type: "field_value", Field: "account", Operator: "equals", Value: int64(123456789012345678)}, true},
+		{"boolean equals string", Condition{Type: "field_value", Field: "debug", Operator: "equals", Value: "true"}, true},
+		{"equals is case-sensitive", Condition{Type: "field_value", Field: "user", Operator: "equals", Value: "admin"}, false},
+		{"equals ignoring case", Condition{Type: "field_value", Field: "user", Operator: "equals", Value: "admin", IgnoreCase: true}, true},
+		{"not equals", Condition{Type: "field_value", Field: "code", Operator: "not_equals", Value: "200"}, true},
+		{"not equals any list element", Condition{Type: "sourcetype", Operator: "not_equals", Value: "iis"}, false},
+		{"not equals missing field", Condition{Type: "field_value", Field: "missing", Operator: "not_equals", Value: "200"}, false},
+		{"in", Condition{Type: "field_value", Field: "code", Operator: "in", Value: []interface{}{"403", 404.0}}, true},
+		{"in ignoring case", Condition{Type: "host", Operator: "in", Value: []interface{}{"WEB01"}, IgnoreCase: true}, true},
+		{"not in", Condition{Type: "field_value", Field: "code", Operator: "in", Value: []interface{}{"200", "500"}}, false},
+		{"starts with", Condition{Type: "sourcetype", Operator: "starts_with", Value: "access_"}, true},
+		{"ends with", Condition{Type: "host", Operator: "ends_with", Value: "01"}, true},
+		{"contains ignoring case", Condition{Type: "field_value", Field: "user", Operator: "contains", Value: "DMI", IgnoreCase: true}, true},
+		{"regex", Condition{Type: "sourcetype", Operator: "regex", Value: "^access_"}, true},
+		{"regex ignoring case", Condition{Type: "field_value", Field: "user", Operator: "regex", Value: "^admin$", IgnoreCase: true}, true},
+		{"gt", Condition{Type: "field_value", Field: "bytes", Operator: "gt", Value: 1000.0}, true},
+		{"gt numeric string", Condition{Type: "field_value", Field: "code", Operator: "gt", Value: "400"}, true},
+		{"gte", Condition{Type: "field_value", Field: "status", Operator: "gte", Value: 3}, true},
+		{"lt", Condition{Type: "field_value", Field: "status", Operator: "lt", Value: 3}, false},
+		{"lte", Condition{Type: "field_value", Field: "status", Operator: "lte", Value: 3}, true},
+		{"gt non-numeric", Condition{Type: "field_value", Field: "user", Operator: "gt", Value: 0}, false},
+		{"between", Condition{Type: "field_value", Field: "code", Operator: "between", Value: []interface{}{400.0, 499.0}}, true},
+		{"between outside", Condition{Type: "field_value", Field: "bytes", Operator: "between", Value: []interface{}{0, 1000}}, false},
+		{"not", Condition{Type: "combination", Operator: "not", Children: []Condition{
+			{Type: "field_value", Field: "code", Operator: "equals", Value: "200"},
+		}}, true},
+		{"not missing field", Condition{Type: "combination", Operator: "not", Children: []Condition{
+			{Type: "field_exists", Field: "missing", Operator: "exists"},
+		}}, true},
+		{"not of or", Condition{Type: "combination", Operator: "not", Children: []Condition{
+			{Type: "combination", Operator: "or", Children: []Condition{
+				{Type: "field_value", Field: "code", Operator: "equals", Value: "200"},
+				{Type: "sourcetype", Operator: "equals", Value: "iis"},
+			}},
+		}}, false},
+	}
+
+	config := MappingConfig{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateCondition(tt.condition); err != nil {
+				t.Fatalf("Expected valid condition, got %v", err)
+			}
+			if result := config.evaluateCondition(tt.condition, context); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestConditionOperatorValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		wantError string
+	}{
+		{"in without list", Condition{Type: "field_value", Field: "code", Operator: "in", Value: "404"}, "non-empty list"},
+		{"in with empty list", Condition{Type: "source", Operator: "in", Value: []interface{}{}}, "non-empty list"},
+		{"in with nested list", Condition{Type: "source", Operator: "in", Value: []interface{}{[]interface{}{"a"}}}, "list of strings"},
+		{"between with one bound", Condition{Type: "field_value", Field: "code", Operator: "between", Value: []interface{}{1.0}}, "[low, high]"},
+		{"between with text bounds", Condition{Type: "field_value", Field: "code", Operator: "between", Value: []interface{}{"a", "z"}}, "numeric bounds"},
+		{"between reversed", Condition{Type: "field_value", Field: "code", Operator: "between", Value: []interface{}{500.0, 400.0}}, "low <= high"},
+		{"gt without number", Condition{Type: "field_value", Field: "code", Operator: "gt", Value: "high"}, "numeric value"},
+		{"invalid regex", Condition{Type: "sourcetype", Operator: "regex", Value: "(unclosed"}, "invalid regex pattern"},
+		{"equals with list", Condition{Type: "field_value", Field: "code", Operator: "equals", Value: []interface{}{"a"}}, "string, number or boolean"},
+		{"not with two children", Condition{Type: "combination", Operator: "not", Children: []Condition{
+			{Type: "field_exists", Field: "a", Operator: "exists"},
+			{Type: "field_exists", Field: "b", Operator: "exists"},
+		}}, "exactly 1 child"},
+		{"unknown combination", Condition{Type: "combination", Operator: "xor", Children: []Condition{
+			{Type: "field_exists", Field: "a", Operator: "exists"},
+			{Type: "field_exists", Field: "b", Operator: "exists"},
+		}}, "invalid operator"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCondition(tt.condition)
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestRuleExpression(t *testing.T) {
+	context := map[string]interface{}{
+		"sourcetype": "pan:traffic",
+		"index":      []interface{}{"main", "firewall"},
+		"host":       "web01",
+		"command":    []string{"search", "stats"},
+		"status":     404.0,
+		"bytes":      "1500",
+		"debug":      true,
+		"Web.status": "200",
+		"account":    "123456789012345678",
+	}
+
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`sourcetype in ["pan:traffic", "pan:threat"] && !has(datamodel)`, true},
+		{`sourcetype == "pan:traffic"`, true},
+		{`sourcetype != "pan:traffic"`, false},
+		{`index == "firewall"`, true},
+		{`index != "summary"`, true},
+		{`"main" in index`, true},
+		{`status >= 400 && status < 500`, true},
+		{`bytes > 1000`, true},
+		{`status == "404"`, true},
+		{`host.startsWith("web") && host.endsWith("01")`, true},
+		{`host.contains("eb0")`, true},
+		{`sourcetype.matches("^pan:(traffic|threat)$")`, true},
+		{`sourcetype.matches('^PAN:')`, false},
+		{`size(command) == 2 || false`, true},
+		{`size(command) > 2`, false},
+		{`size(sourcetype) == 1`, true},
+		{`size(index) == 2`, true},
+		{`size(field("Web.status")) == 1`, true},
+		{`size(missing) == 0`, true},
+		{`size("pan:traffic") == 11`, true},
+		{`field("Web.status") == 200`, true},
+		{`debug`, true},
+		{`!(index == "main")`, false},
+		{`missing == "x"`, false},
+		{`missing != "x"`, false},
+		{`!has(missing) && has(host)`, true},
+		{`status == -404 || status > -1`, true},
+		{`account == 123456789012345678`, true},
+		{`account == 123456789012345679`, false},
+		{`bytes == "1500.0"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			expression, err := compileRuleExpression(tt.expression)
+			if err != nil {
+				t.Fatalf("Failed to compile: %v", err)
+			}
+			if result := expression.matches(context); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+
+	// size() counts the sourcetypes of a query, whether it has one or several
+	config := &MappingConfig{
+		Version: "1.0",
+		Rules: []ConditionalRule{{
+			ID:       "single_sourcetype",
+			Enabled:  true,
+			When:     "size(sourcetype) == 1",
+			Mappings: []FieldMapping{{Source: "src", Target: "src_ip"}},
+		}},
+	}
+	m := NewWithConfig(config)
+	for query, expected := range map[string]string{
+		"search sourcetype=pan:traffic src=1":                            "search sourcetype=pan:traffic src_ip=1",
+		"search (sourcetype=pan:traffic OR sourcetype=pan:threat) src=1": "search (sourcetype=pan:traffic OR sourcetype=pan:threat) src=1",
+	} {
+		result, err := m.MapQuery(query)
+		if err != nil {
+			t.Fatalf("Failed to map %q: %v", query, err)
+		}
+		if result != expected {
+			t.Errorf("Expected %q, got %q", expected, result)
+		}
+	}
+}
+
+func TestRuleExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantError  string
+	}{
+		{`sourcetype ==`, "column 14: unexpected end of expression"},
+		{`sourcetype == "a`, "column 15: unterminated string"},
+		{`sourcetype = "a"`, "column 12: unexpected character '='"},
+		{`(index == "main"`, "column 17: expected ')'"},
+		{`index == "main" index`, "column 17: unexpected 'index'"},
+		{`"pan:traffic"`, "column 1: expression must be a boolean, not a string"},
+		{`sourcetype == ["a", "b"]`, "column 12: cannot compare a list with '=='; use 'in'"},
+		{`sourcetype in "a"`, "column 15: the right side of 'in' must be a list, not a string"},
+		{`status > "high"`, "column 10: '>' expects a number, not a string"},
+		{`index && "main"`, "column 10: '&&' expects a bool, not a string"},
+		{`!size(command)`, "column 2: '!' expects a bool, not a number"},
+		{`true == 1`, "column 6: cannot compare a bool with a number"},
+		{`has("index")`, "column 5: has() expects a context key"},
+		{`lower(host) == "a"`, "column 1: unknown function 'lower'"},
+		{`host.upper()`, "column 6: unknown method 'upper'"},
+		{`host.matches("(")`, "column 14: invalid regular expression"},
+		{`field(host) == "a"`, "column 7: field() expects a quoted context key"},
+		{`[["a"]]`, "column 2: lists cannot contain lists"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := compileRuleExpression(tt.expression)
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantError, err)
+			}
+		})
+	}
+
+	// A rule may use a when expression instead of conditions; errors are reported at load time
+	config := `version: "1.0"
+rules:
+  - id: pan
+    enabled: true
+    when: 'sourcetype in ["pan:traffic", "pan:threat"] && !has(datamodel)'
+    mappings: [{source: src, target: src_ip}]
+  - id: broken
+    enabled: true
+    when: 'sourcetype in "pan:traffic"'
+    mappings: [{source: src, target: src_ip}]
+`
+	_, err := LoadMappingConfigYAML([]byte(config))
+	configErrors, ok := err.(ConfigErrors)
+	if !ok || len(configErrors) != 1 {
+		t.Fatalf("Expected one ConfigError, got %v", err)
+	}
+	if configErrors[0].Line != 9 || !strings.Contains(configErrors[0].Message, "rule[1].when: column 15") {
+		t.Errorf("Expected the when error at line 9, got %+v", configErrors[0])
+	}
+
+	valid, err := LoadMappingConfigYAML([]byte(strings.SplitAfter(config, "src_ip}]\n")[0]))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	m := NewWithConfig(valid)
+	for query, expected := range map[string]string{
+		"search sourcetype=pan:traffic src=10.0.0.1":                                        "search sourcetype=pan:traffic src_ip=10.0.0.1",
+		"search sourcetype=pan:system src=10.0.0.1":                                         "search sourcetype=pan:system src=10.0.0.1",
+		"| tstats count from datamodel=Network_Traffic where sourcetype=pan:traffic by src": "| tstats count from datamodel=Network_Traffic where sourcetype=pan:traffic by src",
+	} {
+		if result, err := m.MapQuery(query); err != nil || result != expected {
+			t.Errorf("MapQuery(%q) = %q, %v; expected %q", query, result, err, expected)
+		}
+	}
+}
+
+// TestCompiledWhenExpressions tests that a mapper compiles the when expressions of its rules
+// once, and that an expression changed afterwards is compiled again
+func TestCompiledWhenExpressions(t *testing.T) {
+	config := &MappingConfig{
+		Version: "1.0",
+		Rules: []ConditionalRule{{
+			ID:       "pan",
+			Enabled:  true,
+			When:     `sourcetype.matches("^pan:")`,
+			Mappings: []FieldMapping{{Source: "src", Target: "src_ip"}},
+		}},
+	}
+	context := map[string]interface{}{"sourcetype": "pan:traffic"}
+
+	NewWithConfig(config)
+	if config.Rules[0].when == nil || config.Rules[0].when.source != config.Rules[0].When {
+		t.Fatalf("Expected the when expression to be compiled, got %+v", config.Rules[0].when)
+	}
+	if len(config.GetMatchingRules(context)) != 1 {
+		t.Error("Expected the compiled rule to match")
+	}
+
+	config.Rules[0].When = `sourcetype == "iis"`
+	if len(config.GetMatchingRules(context)) != 0 {
+		t.Error("Expected the changed when expression to be used")
+	}
+}
+
+// TestCompiledConditionPatterns tests that a mapper compiles the regex patterns of its config
+// once, and that configs it has not compiled still match
+func TestCompiledConditionPatterns(t *testing.T) {
+	config := &MappingConfig{
+		Version: "1.0",
+		Rules: []ConditionalRule{{
+			ID:      "tstats",
+			Enabled: true,
+			Conditions: []Condition{
+				{Type: "query_regex", Operator: "regex", Value: `\| tstats`},
+				{Type: "combination", Operator: "or", Children: []Condition{
+					{Type: "sourcetype", Operator: "regex", Value: "^PAN:", IgnoreCase: true},
+					{Type: "index", Operator: "regex", Value: "("},
+				}},
+			},
+			Mappings: []FieldMapping{{Source: "src", Target: "src_ip"}},
+		}},
+	}
+	context := map[string]interface{}{"query": "| tstats count", "sourcetype": "pan:traffic"}
+
+	if len(config.GetMatchingRules(context)) != 1 {
+		t.Error("Expected the rule of an uncompiled config to match")
+	}
+
+	NewWithConfig(config)
+	for _, pattern := range []string{`\| tstats`, "(?i)^PAN:"} {
+		if _, ok := config.regexes[pattern]; !ok {
+			t.Errorf("Expected %q to be compiled, got %v", pattern, config.regexes)
+		}
+	}
+	if len(config.regexes) != 2 {
+		t.Errorf("Expected the invalid pattern to be left out, got %v", config.regexes)
+	}
+	if len(config.GetMatchingRules(context)) != 1 {
+		t.Error("Expected the rule of the compiled config to match")
+	}
+}
+
 func TestCombinationCondition(t *testing.T) {
 	// Test combination with insufficient children
 	condition := Condition{
@@ -547,3 +926,985 @@ func TestToJSON(t *testing.T) {
 		t.Errorf("Name mismatch after JSON round-trip: expected %s, got %s", config.Name, restored.Name)
 	}
 }
+
+func TestInvert(t *testing.T) {
+	config := &MappingConfig{
+		Version: "1.0",
+		Mappings: []FieldMapping{
+			{ID: "ip", Source: "src_ip", Target: "source_ip"},
+			{Source: "src_addr", Target: "source_ip"},
+			{Source: "action", Target: "action", Values: map[string]string{"blocked": "deny", "dropped": "deny", "allowed": "allow"}},
+			{Source: "user", Targets: []string{"user", "user_name"}},
+			{Source: "host", Target: "dest_host", Case: ValueCaseLower},
+		},
+		Rules: []ConditionalRule{
+			{
+				ID: "apache",
+				Conditions: []Condition{
+					{Type: "field_exists", Field: "src_ip", Operator: "exists"},
+					{Type: "sourcetype", Operator: "equals", Value: "access_combined"},
+				},
+				When:     `src_ip.startsWith("10.") && has(src_ip)`,
+				Mappings: []FieldMapping{{Source: "clientip", Target: "client_address"}},
+				Enabled:  true,
+			},
+			{
+				ID:         "vendor",
+				Conditions: []Condition{{Type: "sourcetype", Operator: "equals", Value: "vendor"}},
+				Mappings: []FieldMapping{
+					{Source: "ip", Target: "source_ip"},
+					{Source: "host", Target: "host_name"},
+					{Source: "hostname", Target: "dest_host"},
+				},
+				Enabled: true,
+			},
+		},
+		DataModels: []DataModelMapping{
+			{
+				SourceDataModel: "Vendor",
+				TargetDataModel: "Network_Traffic",
+				FieldMappings:   []DataModelFieldMapping{{SourceField: "sip", TargetField: "src"}},
+			},
+		},
+	}
+
+	inverse, problems := config.Invert()
+
+	expectedMappings := map[string]string{"source_ip": "src_ip", "action": "action", "dest_host": "host"}
+	if len(inverse.Mappings) != len(expectedMappings) {
+		t.Fatalf("Expected %d inverted mappings, got %+v", len(expectedMappings), inverse.Mappings)
+	}
+	for _, mapping := range inverse.Mappings {
+		if expectedMappings[mapping.Source] != mapping.Target {
+			t.Errorf("Unexpected inverted mapping %s -> %s", mapping.Source, mapping.Target)
+		}
+		if mapping.Source == "source_ip" && mapping.ID != "ip" {
+			t.Errorf("Expected inverted mapping to keep its ID, got %+v", mapping)
+		}
+		if mapping.Source == "action" && (mapping.Values["deny"] != "blocked" || mapping.Values["allow"] != "allowed") {
+			t.Errorf("Unexpected inverted value map: %v", mapping.Values)
+		}
+	}
+
+	rule := inverse.Rules[0]
+	if rule.Conditions[0].Field != "source_ip" || rule.Mappings[0].Source != "client_address" {
+		t.Errorf("Unexpected inverted rule: %+v", rule)
+	}
+	if rule.When != `source_ip.startsWith("10.") && has(source_ip)` {
+		t.Errorf("Expected the when expression to use the inverted field names, got %q", rule.When)
+	}
+
+	dm := inverse.DataModels[0]
+	if dm.SourceDataModel != "Network_Traffic" || dm.FieldMappings[0].SourceField != "src" {
+		t.Errorf("Unexpected inverted datamodel mapping: %+v", dm)
+	}
+
+	// many-to-one target, many-to-one values, field set and case transform, and rule mappings
+	// to targets of basic mappings; the vendor rule replaces the basic mapping of host, so
+	// hostname -> dest_host does not collide with it
+	locations := make(map[string]bool)
+	for _, problem := range problems {
+		locations[problem.Location] = true
+	}
+	for _, location := range []string{"mapping[1]", "mapping[2]", "mapping[3]", "mapping[4]", "rule[1].mapping[0]"} {
+		if !locations[location] {
+			t.Errorf("Expected a problem at %s, got %+v", location, problems)
+		}
+	}
+	// mapping[2] also maps 'allowed' to 'allow' and 'blocked' to 'deny', which pass through unchanged
+	if len(problems) != 7 {
+		t.Errorf("Expected 7 problems, got %+v", problems)
+	}
+}
+
+func TestInvertPassThroughValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string]string
+		problems int
+	}{
+		// An original 'deny' is not mapped, so the inverse would turn it into 'blocked'
+		{"replacement passes through", map[string]string{"blocked": "deny"}, 1},
+		// Every original 'deny' becomes 'blocked', so 'deny' only comes from 'blocked'
+		{"swapped values", map[string]string{"blocked": "deny", "deny": "blocked"}, 0},
+		{"identity", map[string]string{"deny": "deny"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &MappingConfig{
+				Version:  "1.0",
+				Mappings: []FieldMapping{{Source: "action", Target: "action", Values: tt.values}},
+			}
+			_, problems := config.Invert()
+			if len(problems) != tt.problems {
+				t.Errorf("Expected %d problems, got %+v", tt.problems, problems)
+			}
+		})
+	}
+}
+
+func TestCheckRoundTrip(t *testing.T) {
+	config := &MappingConfig{
+		Version: "1.0",
+		Mappings: []FieldMapping{
+			{Source: "src_ip", Target: "source_ip"},
+			{Source: "action", Target: "action", Values: map[string]string{"blocked": "deny", "dropped": "deny"}},
+		},
+	}
+
+	results, problems := config.CheckRoundTrip([]string{
+		"search src_ip=10.0.0.1 action=blocked | stats count by src_ip",
+		"search action=dropped",
+	})
+
+	// 'blocked' and 'dropped' both map to 'deny', which also passes through unchanged
+	if len(problems) != 2 {
+		t.Errorf("Expected 2 inversion problems, got %+v", problems)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	if !results[0].Match || results[0].Mapped != "search source_ip=10.0.0.1 action=deny | stats count by source_ip" {
+		t.Errorf("Expected first query to round-trip, got %+v", results[0])
+	}
+	if results[1].Match || results[1].Restored != "search action=blocked" {
+		t.Errorf("Expected second query not to round-trip, got %+v", results[1])
+	}
+}
+
+func TestResolveComposedConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.json": `{
+			"version": "1.0",
+			"name": "CIM base",
+			"mappings": [
+				{"id": "ip", "source": "src_ip", "target": "source_ip"},
+				{"source": "dst_ip", "target": "dest_ip"},
+				{"id": "legacy", "source": "old", "target": "new"}
+			],
+			"rules": [
+				{"id": "apache", "enabled": true, "conditions": [{"type": "sourcetype", "operator": "equals", "value": "access_combined"}], "mappings": [{"source": "clientip", "target": "src"}]},
+				{"id": "iis", "enabled": true, "conditions": [{"type": "sourcetype", "operator": "equals", "value": "iis"}], "mappings": [{"source": "c_ip", "target": "src"}]}
+			]
+		}`,
+		"shared/network.json": `{"mappings": [{"source": "bytes", "target": "bytes_total"}]}`,
+		"team.json": `{
+			"extends": "base.json",
+			"include": ["shared/network.json"],
+			"name": "Team overlay",
+			"remove": {"mappings": ["legacy"], "rules": ["iis"]},
+			"mappings": [{"id": "ip", "source": "src_ip", "target": "src"}]
+		}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	teamFile := filepath.Join(dir, "team.json")
+	config, err := LoadMappingConfigFile(teamFile)
+	if err != nil {
+		t.Fatalf("Failed to load composed config: %v", err)
+	}
+
+	if config.Version != "1.0" || config.Name != "Team overlay" {
+		t.Errorf("Expected inherited version and overridden name, got %q %q", config.Version, config.Name)
+	}
+	if config.Extends != "" || config.Include != nil || config.Remove != nil {
+		t.Errorf("Expected composition directives to be resolved, got %+v", config)
+	}
+
+	mappings := make(map[string]string)
+	for _, mapping := range config.Mappings {
+		mappings[mapping.Source] = mapping.Target
+	}
+	expected := map[string]string{"src_ip": "src", "dst_ip": "dest_ip", "bytes": "bytes_total"}
+	if len(mappings) != len(expected) {
+		t.Errorf("Expected mappings %v, got %v", expected, mappings)
+	}
+	for source, target := range expected {
+		if mappings[source] != target {
+			t.Errorf("Expected %s -> %s, got %s", source, target, mappings[source])
+		}
+	}
+
+	if len(config.Rules) != 1 || config.Rules[0].ID != "apache" {
+		t.Errorf("Expected only rule 'apache' to remain, got %+v", config.Rules)
+	}
+
+	raw, err := readMappingConfigFile(teamFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := raw.Resolve(teamFile)
+	if err != nil {
+		t.Fatalf("Failed to resolve config: %v", err)
+	}
+
+	origins := make(map[string]ConfigOrigin)
+	for _, origin := range resolved.Origins {
+		origins[origin.Kind+":"+origin.Key] = origin
+	}
+
+	ip := origins["mapping:ip"]
+	if ip.File != teamFile || len(ip.Overridden) != 1 || ip.Overridden[0] != filepath.Join(dir, "base.json") {
+		t.Errorf("Unexpected origin for overridden mapping: %+v", ip)
+	}
+	if origins["mapping:bytes"].File != filepath.Join(dir, "shared/network.json") {
+		t.Errorf("Unexpected origin for included mapping: %+v", origins["mapping:bytes"])
+	}
+	if origins["mapping:legacy"].RemovedBy != teamFile || origins["rule:iis"].RemovedBy != teamFile {
+		t.Errorf("Expected removed entries to record the overlay, got %+v", resolved.Origins)
+	}
+}
+
+// TestResolveDiamondInclude tests that a config included through two paths is applied once
+func TestResolveDiamondInclude(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"d.json": `{"version": "1.0", "mappings": [{"id": "ip", "source": "src_ip", "target": "d_ip"}, {"source": "user", "target": "user_name"}]}`,
+		"b.json": `{"include": ["d.json"], "mappings": [{"id": "ip", "source": "src_ip", "target": "b_ip"}]}`,
+		"c.json": `{"include": ["d.json"], "mappings": [{"source": "bytes", "target": "bytes_total"}]}`,
+		"a.json": `{"include": ["b.json", "c.json"]}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	aFile := filepath.Join(dir, "a.json")
+	raw, err := readMappingConfigFile(aFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := raw.Resolve(aFile)
+	if err != nil {
+		t.Fatalf("Failed to resolve config: %v", err)
+	}
+
+	// d.json is not applied again through c.json, so it does not undo the override of b.json
+	mappings := make(map[string]string)
+	for _, mapping := range resolved.Config.Mappings {
+		mappings[mapping.Source] = mapping.Target
+	}
+	expected := map[string]string{"src_ip": "b_ip", "user": "user_name", "bytes": "bytes_total"}
+	if !reflect.DeepEqual(mappings, expected) {
+		t.Errorf("Expected mappings %v, got %v", expected, mappings)
+	}
+
+	origins := make(map[string]ConfigOrigin)
+	for _, origin := range resolved.Origins {
+		origins[origin.Kind+":"+origin.Key] = origin
+	}
+	if len(resolved.Origins) != 3 {
+		t.Errorf("Expected 3 origins, got %+v", resolved.Origins)
+	}
+	ip := origins["mapping:ip"]
+	if ip.File != filepath.Join(dir, "b.json") || !reflect.DeepEqual(ip.Overridden, []string{filepath.Join(dir, "d.json")}) {
+		t.Errorf("Unexpected origin for the overridden mapping: %+v", ip)
+	}
+	if user := origins["mapping:user"]; user.File != filepath.Join(dir, "d.json") || len(user.Overridden) != 0 {
+		t.Errorf("Expected no override of the mapping defined once, got %+v", user)
+	}
+}
+
+func TestResolveConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	write("a.json", `{"version": "1.0", "extends": "b.json", "mappings": []}`)
+	cyclic := write("b.json", `{"version": "1.0", "include": ["a.json"], "mappings": []}`)
+	if _, err := LoadMappingConfigFile(cyclic); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Expected an include cycle error, got %v", err)
+	}
+
+	missing := write("c.json", `{"version": "1.0", "remove": {"rules": ["nope"]}, "mappings": []}`)
+	if _, err := LoadMappingConfigFile(missing); err == nil || !strings.Contains(err.Error(), "cannot remove rule 'nope'") {
+		t.Errorf("Expected an error removing an undefined rule, got %v", err)
+	}
+
+	unresolved := &MappingConfig{Version: "1.0", Extends: "base.json"}
+	if result := unresolved.Validate(); result.Valid {
+		t.Error("Expected validation to reject an unresolved composed config")
+	}
+}
+
+func TestLoadMappingConfigYAML(t *testing.T) {
+	valid := `
+version: "1.0"
+name: YAML config
+mappings:
+  - source: src_ip
+    target: source_ip
+rules:
+  - id: apache
+    enabled: true
+    priority: 1
+    conditions:
+      - type: sourcetype
+        operator: equals
+        value: access_combined
+    mappings:
+      - source: clientip
+        target: src
+`
+	config, err := LoadMappingConfigYAML([]byte(valid))
+	if err != nil {
+		t.Fatalf("Failed to load YAML config: %v", err)
+	}
+	if config.Mappings[0].Target != "source_ip" || config.Rules[0].Conditions[0].Value != "access_combined" || config.Rules[0].Priority != 1 {
+		t.Errorf("Unexpected YAML config: %+v", config)
+	}
+
+	invalid := `version: "1.0"
+mappings:
+  - source: src_ip
+    target: source_ip
+  - source: dest
+rules:
+  - id: apache
+    enabled: true
+    conditions:
+      - type: bogus
+    mappings:
+      - source: a
+        target: b
+`
+	_, err = LoadMappingConfigYAML([]byte(invalid))
+	configErrors, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("Expected ConfigErrors, got %v", err)
+	}
+
+	expected := map[string][2]int{
+		"mapping[1]: target field is required":                {5, 5},
+		"rule[0].condition[0]: invalid condition type: bogus": {10, 9},
+	}
+	if len(configErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), configErrors)
+	}
+	for _, configError := range configErrors {
+		position, exists := expected[configError.Message]
+		if !exists {
+			t.Errorf("Unexpected error: %v", configError)
+			continue
+		}
+		if configError.Line != position[0] || configError.Column != position[1] {
+			t.Errorf("Expected %q at %d:%d, got %d:%d", configError.Message, position[0], position[1], configError.Line, configError.Column)
+		}
+	}
+}
+
+func TestLoadMappingConfigTOML(t *testing.T) {
+	valid := `
+version = "1.0"
+
+[[mappings]]
+source = "src_ip"
+target = "source_ip"
+
+[[rules]]
+id = "apache"
+enabled = true
+
+  [[rules.conditions]]
+  type = "sourcetype"
+  operator = "equals"
+  value = "access_combined"
+
+  [[rules.mappings]]
+  source = "clientip"
+  target = "src"
+`
+	config, err := LoadMappingConfigTOML([]byte(valid))
+	if err != nil {
+		t.Fatalf("Failed to load TOML config: %v", err)
+	}
+	if config.Mappings[0].Target != "source_ip" || config.Rules[0].Mappings[0].Target != "src" {
+		t.Errorf("Unexpected TOML config: %+v", config)
+	}
+
+	invalid := `version = "1.0"
+mappings = [
+  { source = "src_ip", target = "source_ip" },
+  { source = "dest" },
+]
+
+[[rules]]
+id = "apache"
+enabled = true
+mappings = [{ source = "a", target = "b" }]
+
+  [[rules.conditions]]
+  type = "sourcetype"
+  operator = "equals"
+
+  [[rules.conditions]]
+  type = "bogus"
+`
+	_, err = LoadMappingConfigTOML([]byte(invalid))
+	configErrors, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("Expected ConfigErrors, got %v", err)
+	}
+
+	expected := map[string][2]int{
+		"mapping[1]: target field is required":                {4, 3},
+		"rule[0].condition[1]: invalid condition type: bogus": {16, 3},
+	}
+	if len(configErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), configErrors)
+	}
+	for _, configError := range configErrors {
+		position := expected[configError.Message]
+		if configError.Line != position[0] || configError.Column != position[1] {
+			t.Errorf("Expected %q at %d:%d, got %d:%d", configError.Message, position[0], position[1], configError.Line, configError.Column)
+		}
+	}
+}
+
+func TestConvertMappingConfig(t *testing.T) {
+	config := &MappingConfig{
+		Version:     "1.0",
+		Name:        "Round trip",
+		Description: "Every kind of entry",
+		Mappings: []FieldMapping{
+			{ID: "ip", Source: "src_ip", Target: "source_ip"},
+			{Source: "action", Target: "action", Values: map[string]string{"blocked": "deny", "1": "true"}},
+			{Source: "host", Target: "dest_host", Replace: []ValueReplacement{{Pattern: `\.corp$`, Replacement: ""}}, Case: ValueCaseLower},
+			{Source: "user", Targets: []string{"user", "user_name"}},
+			{Source: "src", Expression: "coalesce(src_ip, src_host)"},
+		},
+		Rules: []ConditionalRule{
+			{
+				ID:       "apache",
+				Priority: 2,
+				Enabled:  true,
+				Conditions: []Condition{
+					{Type: "field_value", Field: "status", Operator: "equals", Value: json.Number("404")},
+					{Type: "combination", Operator: "or", Children: []Condition{
+						{Type: "sourcetype", Operator: "equals", Value: "access_combined"},
+						{Type: "source", Operator: "contains", Value: "apache"},
+					}},
+				},
+				Mappings: []FieldMapping{{Source: "clientip", Target: "src"}},
+			},
+		},
+		DataModels: []DataModelMapping{
+			{SourceDataModel: "Vendor", TargetDataModel: "Web", FieldMappings: []DataModelFieldMapping{{SourceField: "a", TargetField: "b", SourcePath: "x.a"}}},
+		},
+		Metadata: map[string]interface{}{"owner": "detections", "reviewed": true, "tags": []interface{}{"cim", "web"}},
+	}
+
+	data, err := config.Marshal(FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %v", err)
+	}
+
+	// json -> yaml -> toml -> yaml -> json
+	formats := []ConfigFormat{FormatYAML, FormatTOML, FormatYAML, FormatJSON}
+	from := FormatJSON
+	for _, to := range formats {
+		if data, err = ConvertMappingConfig(data, from, to); err != nil {
+			t.Fatalf("Failed to convert %s to %s: %v", from, to, err)
+		}
+		from = to
+	}
+
+	restored, err := LoadMappingConfig(data)
+	if err != nil {
+		t.Fatalf("Failed to load converted config: %v", err)
+	}
+	if !reflect.DeepEqual(config, restored) {
+		t.Errorf("Config changed after conversion:\nexpected %+v\ngot      %+v", config, restored)
+	}
+
+	// TOML keeps its key order and 64-bit integers
+	tomlData := `version = "1.0"
+name = "Ordered"
+
+[metadata]
+zeta = 9007199254740993
+alpha = { second = 2, first = 1 }
+
+[[mappings]]
+target = "source_ip"
+source = "src_ip"
+`
+	expected := `{
+  "version": "1.0",
+  "name": "Ordered",
+  "metadata": {
+    "zeta": 9007199254740993,
+    "alpha": {
+      "second": 2,
+      "first": 1
+    }
+  },
+  "mappings": [
+    {
+      "target": "source_ip",
+      "source": "src_ip"
+    }
+  ]
+}
+`
+	converted, err := ConvertMappingConfig([]byte(tomlData), FormatTOML, FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to convert TOML: %v", err)
+	}
+	if string(converted) != expected {
+		t.Errorf("Expected TOML conversion in document order:\n%s\ngot:\n%s", expected, converted)
+	}
+	roundTrip, err := ConvertMappingConfig(converted, FormatJSON, FormatTOML)
+	if err != nil {
+		t.Fatalf("Failed to convert JSON back to TOML: %v", err)
+	}
+	if !strings.Contains(string(roundTrip), "zeta = 9007199254740993") {
+		t.Errorf("Expected the integer to round-trip exactly, got:\n%s", roundTrip)
+	}
+
+	if _, err := ConvertMappingConfig([]byte(`{"metadata": {"id": 18446744073709551616}}`), FormatJSON, FormatTOML); err == nil ||
+		!strings.Contains(err.Error(), "metadata.id") {
+		t.Errorf("Expected an error for an integer TOML cannot hold, got %v", err)
+	}
+
+	// YAML keeps its key order, number literals and merged keys
+	yamlData := `version: "1.0"
+name: Ordered
+defaults: &defaults
+  weight: 1.0
+  zeta: 123456789012345678
+metadata:
+  <<: *defaults
+  weight: 2.50
+  alpha: {second: 2, first: 1e3}
+`
+	expected = `{
+  "version": "1.0",
+  "name": "Ordered",
+  "defaults": {
+    "weight": 1.0,
+    "zeta": 123456789012345678
+  },
+  "metadata": {
+    "weight": 2.50,
+    "zeta": 123456789012345678,
+    "alpha": {
+      "second": 2,
+      "first": 1e3
+    }
+  }
+}
+`
+	converted, err = ConvertMappingConfig([]byte(yamlData), FormatYAML, FormatJSON)
+	if err != nil {
+		t.Fatalf("Failed to convert YAML: %v", err)
+	}
+	if string(converted) != expected {
+		t.Errorf("Expected YAML conversion in document order:\n%s\ngot:\n%s", expected, converted)
+	}
+}
+
+func TestMappingConfigSchema(t *testing.T) {
+	schema := MappingConfigSchema()
+
+	if schema["additionalProperties"] != false {
+		t.Error("Expected the schema to reject unknown top-level fields")
+	}
+	properties := schema["properties"].(map[string]interface{})
+	for _, name := range []string{"version", "mappings", "rules", "datamodels", "metadata", "extends", "include", "remove"} {
+		if _, exists := properties[name]; !exists {
+			t.Errorf("Expected property %q in schema", name)
+		}
+	}
+
+	defs := schema["$defs"].(map[string]interface{})
+	condition := defs["Condition"].(map[string]interface{})
+	conditionProperties := condition["properties"].(map[string]interface{})
+	if !reflect.DeepEqual(conditionProperties["type"].(map[string]interface{})["enum"], conditionTypes) {
+		t.Errorf("Expected condition type enum %v, got %v", conditionTypes, conditionProperties["type"])
+	}
+	if conditionProperties["children"].(map[string]interface{})["items"].(map[string]interface{})["$ref"] != "#/$defs/Condition" {
+		t.Errorf("Expected children to reference Condition, got %v", conditionProperties["children"])
+	}
+
+	// The published schema must match the types
+	generated, err := MappingConfigSchemaJSON()
+	if err != nil {
+		t.Fatalf("Failed to generate schema: %v", err)
+	}
+	published, err := os.ReadFile(filepath.Join("..", "..", "docs", "mapping-config.schema.json"))
+	if err != nil {
+		t.Fatalf("Failed to read published schema: %v", err)
+	}
+	if string(generated) != string(published) {
+		t.Error("docs/mapping-config.schema.json is out of date; regenerate it with 'spl-toolkit schema'")
+	}
+}
+
+func TestLoadMappingConfigStrict(t *testing.T) {
+	valid := `{
+  "$schema": "mapping-config.schema.json",
+  "version": "1.0",
+  "mappings": [{"source": "src_ip", "target": "source_ip"}],
+  "rules": [{
+    "id": "apache",
+    "enabled": true,
+    "conditions": [{"type": "sourcetype", "operator": "equals", "value": "access_combined"}],
+    "mappings": [{"source": "clientip", "target": "src"}]
+  }]
+}`
+	if _, err := LoadMappingConfigStrict([]byte(valid), FormatJSON); err != nil {
+		t.Fatalf("Expected valid config to load, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		format   ConfigFormat
+		config   string
+		expected []ConfigError
+	}{
+		{
+			name:   "unknown fields",
+			format: FormatJSON,
+			config: `{
+  "version": "1.0",
+  "mappings": [{"source": "src_ip", "target": "source_ip", "taget": "x"}],
+  "rules": [{
+    "id": "apache",
+    "enabled": true,
+    "conditons": [{"type": "sourcetype", "value": "access_combined"}],
+    "conditions": [{"type": "sourcetype", "operator": "equals", "value": "access_combined", "children": [], "xyz": 1}],
+    "mappings": [{"source": "clientip", "target": "src"}]
+  }],
+  "metadata": {"anything": {"goes": true}}
+}`,
+			expected: []ConfigError{
+				{Line: 3, Column: 60, Message: "mapping[0]: unknown field 'taget' (did you mean 'target'?)"},
+				{Line: 7, Column: 5, Message: "rule[0]: unknown field 'conditons' (did you mean 'conditions'?)"},
+				{Line: 8, Column: 109, Message: "rule[0].condition[0]: unknown field 'xyz'"},
+			},
+		},
+		{
+			name:   "duplicates",
+			format: FormatJSON,
+			config: `{
+  "version": "1.0",
+  "mappings": [
+    {"source": "src_ip", "target": "source_ip"},
+    {"source": "src_ip", "target": "src"}
+  ],
+  "rules": [
+    {"id": "apache", "enabled": true, "conditions": [{"type": "source", "value": "a"}], "mappings": [{"source": "a", "target": "b"}, {"source": "a", "target": "c"}]},
+    {"id": "apache", "enabled": true, "conditions": [{"type": "source", "value": "b"}], "mappings": [{"source": "a", "target": "b"}]}
+  ]
+}`,
+			expected: []ConfigError{
+				{Line: 5, Column: 6, Message: "mapping[1]: duplicate mapping for source 'src_ip' (first defined by mapping[0])"},
+				{Line: 8, Column: 135, Message: "rule[0].mapping[1]: duplicate mapping for source 'a' (first defined by rule[0].mapping[0])"},
+				{Line: 9, Column: 6, Message: "rule[1]: duplicate rule id 'apache' (first defined by rule[0])"},
+			},
+		},
+		{
+			name:   "YAML with validation errors",
+			format: FormatYAML,
+			config: `version: "1.0"
+mappings:
+  - source: src_ip
+    tagret: source_ip
+`,
+			expected: []ConfigError{
+				{Line: 3, Column: 5, Message: "mapping[0]: target field is required"},
+				{Line: 4, Column: 5, Message: "mapping[0]: unknown field 'tagret' (did you mean 'target'?)"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadMappingConfigStrict([]byte(tt.config), tt.format)
+			configErrors, ok := err.(ConfigErrors)
+			if !ok {
+				t.Fatalf("Expected ConfigErrors, got %v", err)
+			}
+			if !reflect.DeepEqual([]ConfigError(configErrors), tt.expected) {
+				t.Errorf("Expected errors:\n%v\ngot:\n%v", ConfigErrors(tt.expected), configErrors)
+			}
+		})
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	sourcetype := func(value string) Condition {
+		return Condition{Type: "sourcetype", Operator: "equals", Value: value}
+	}
+	rule := func(id string, priority int, conditions []Condition, mappings ...FieldMapping) ConditionalRule {
+		return ConditionalRule{ID: id, Priority: priority, Enabled: true, Conditions: conditions, Mappings: mappings}
+	}
+
+	tests := []struct {
+		name     string
+		config   MappingConfig
+		options  ConfigLintOptions
+		expected []string // "check location"
+	}{
+		{
+			name: "clean config",
+			config: MappingConfig{
+				Mappings: []FieldMapping{{Source: "src_ip", Target: "src"}, {Source: "action", Target: "action", Values: map[string]string{"blocked": "deny"}}},
+				Rules:    []ConditionalRule{rule("apache", 1, []Condition{sourcetype("access_combined")}, FieldMapping{Source: "clientip", Target: "src_host"})},
+			},
+		},
+		{
+			name: "self mapping, chain and cycle",
+			config: MappingConfig{
+				Mappings: []FieldMapping{
+					{Source: "host", Target: "host"},
+					{Source: "a", Target: "b"},
+					{Source: "b", Target: "c"},
+					{Source: "x", Target: "y"},
+					{Source: "y", Target: "x"},
+				},
+			},
+			expected: []string{
+				"self-mapping mapping[0]",
+				"mapping-cycle mapping[3]",
+				"chained-mapping mapping[1]",
+			},
+		},
+		{
+			name: "chain through a rule",
+			config: MappingConfig{
+				Mappings: []FieldMapping{{Source: "ip", Target: "src"}, {Source: "b", Target: "c"}},
+				Rules: []ConditionalRule{
+					rule("web", 1, []Condition{sourcetype("web")}, FieldMapping{Source: "src", Target: "src_ip"}, FieldMapping{Source: "b", Target: "d"}),
+				},
+			},
+			expected: []string{"chained-mapping mapping[0]"},
+		},
+		{
+			name: "contradictory rules",
+			config: MappingConfig{
+				Rules: []ConditionalRule{
+					rule("equals", 1, []Condition{
+						{Type: "field_value", Field: "status", Operator: "equals", Value: "200"},
+						{Type: "field_value", Field: "status", Operator: "equals", Value: "404"},
+					}, FieldMapping{Source: "a", Target: "b"}),
+					rule("exists", 2, []Condition{
+						{Type: "field_exists", Field: "user", Operator: "not_exists"},
+						{Type: "combination", Operator: "or", Children: []Condition{
+							{Type: "field_exists", Field: "user", Operator: "exists"},
+							{Type: "field_value", Field: "user", Operator: "contains", Value: "admin"},
+						}},
+					}, FieldMapping{Source: "a", Target: "b"}),
+					rule("branch", 3, []Condition{
+						{Type: "field_value", Field: "status", Operator: "equals", Value: "200"},
+						{Type: "combination", Operator: "or", Children: []Condition{
+							{Type: "field_value", Field: "status", Operator: "equals", Value: "404"},
+							{Type: "sourcetype", Operator: "equals", Value: "web"},
+						}},
+					}, FieldMapping{Source: "a", Target: "b"}),
+					rule("source_exists", 4, []Condition{{Type: "source", Operator: "exists", Value: ".*"}}, FieldMapping{Source: "a", Target: "b"}),
+					rule("sourcetypes", 5, []Condition{sourcetype("a"), sourcetype("b")}, FieldMapping{Source: "c", Target: "d"}),
+					rule("commands", 6, []Condition{
+						{Type: "command_present", Value: "tstats"},
+						{Type: "command_present", Operator: "not_exists", Value: "TSTATS"},
+					}, FieldMapping{Source: "e", Target: "f"}),
+					rule("index_not", 7, []Condition{
+						{Type: "index", Operator: "equals", Value: "main"},
+						{Type: "combination", Operator: "not", Children: []Condition{{Type: "index", Operator: "equals", Value: "main"}}},
+					}, FieldMapping{Source: "e", Target: "f"}),
+					rule("numeric", 8, []Condition{
+						{Type: "field_value", Field: "status", Operator: "equals", Value: "200"},
+						{Type: "field_value", Field: "status", Operator: "gt", Value: 300.0},
+					}, FieldMapping{Source: "e", Target: "f"}),
+					rule("coerced", 9, []Condition{
+						{Type: "field_value", Field: "status", Operator: "equals", Value: "3"},
+						{Type: "field_value", Field: "status", Operator: "equals", Value: 3.0},
+						{Type: "field_value", Field: "user", Operator: "equals", Value: "Admin", IgnoreCase: true},
+						{Type: "field_value", Field: "user", Operator: "equals", Value: "admin"},
+					}, FieldMapping{Source: "e", Target: "f"}),
+					rule("de_morgan", 10, []Condition{
+						{Type: "field_exists", Field: "user", Operator: "exists"},
+						{Type: "combination", Operator: "not", Children: []Condition{
+							{Type: "combination", Operator: "or", Children: []Condition{
+								{Type: "field_exists", Field: "user", Operator: "exists"},
+								sourcetype("web"),
+							}},
+						}},
+					}, FieldMapping{Source: "e", Target: "f"}),
+				},
+			},
+			expected: []string{
+				"contradictory-rule rule[0]",
+				"contradictory-rule rule[1]",
+				"contradictory-rule rule[3]",
+				"contradictory-rule rule[5]",
+				"contradictory-rule rule[6]",
+				"contradictory-rule rule[7]",
+				"contradictory-rule rule[9]",
+			},
+		},
+		{
+			name: "shadowed rules",
+			config: MappingConfig{
+				Rules: []ConditionalRule{
+					rule("narrow", 5, []Condition{sourcetype("access_combined"), {Type: "field_exists", Field: "status", Operator: "exists"}},
+						FieldMapping{Source: "clientip", Target: "client"}),
+					rule("extra_field", 5, []Condition{sourcetype("access_combined")},
+						FieldMapping{Source: "clientip", Target: "client"}, FieldMapping{Source: "bytes", Target: "bytes_out"}),
+					rule("other_sourcetype", 5, []Condition{sourcetype("iis")},
+						FieldMapping{Source: "clientip", Target: "client"}),
+					rule("broad", 1, []Condition{{Type: "sourcetype", Operator: "contains", Value: "access"}},
+						FieldMapping{Source: "clientip", Target: "src"}, FieldMapping{Source: "status", Target: "http_status"}),
+					// Comes after the rule that would shadow it, so its mappings win
+					rule("late_narrow", 5, []Condition{sourcetype("access_combined"), {Type: "field_exists", Field: "status", Operator: "exists"}},
+						FieldMapping{Source: "clientip", Target: "client"}),
+				},
+			},
+			expected: []string{"shadowed-rule rule[0]"},
+		},
+		{
+			name: "metadata and disabled rules",
+			config: MappingConfig{
+				Rules: []ConditionalRule{
+					{ID: "legacy", Enabled: false, Conditions: []Condition{sourcetype("old")}, Mappings: []FieldMapping{{Source: "a", Target: "b"}}},
+					{ID: "documented", Enabled: false, Conditions: []Condition{sourcetype("older")}, Mappings: []FieldMapping{{Source: "a", Target: "b"}}},
+				},
+				Metadata: map[string]interface{}{
+					"owner":          "detections",
+					"ticket":         "SEC-1",
+					"disabled_rules": map[string]interface{}{"reason": []interface{}{"documented"}},
+				},
+			},
+			options: ConfigLintOptions{MetadataKeys: []string{"owner"}},
+			expected: []string{
+				"unused-metadata metadata.ticket",
+				"unreferenced-disabled-rule rule[0]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Version = "1.0"
+			var got []string
+			for _, issue := range tt.config.Lint(tt.options) {
+				got = append(got, issue.Check+" "+issue.Location)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected issues %v, got %v", tt.expected, got)
+				for _, issue := range tt.config.Lint(tt.options) {
+					t.Logf("%s %s: %s", issue.Check, issue.Location, issue.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	dir := t.TempDir()
+	config := `{
+  "version": "1.0",
+  "mappings": [{"source": "src_ip", "target": "src"}],
+  "rules": [{
+    "id": "apache",
+    "enabled": true,
+    "conditions": [{"type": "sourcetype", "operator": "equals", "value": "access_combined"}],
+    "mappings": [{"source": "clientip", "target": "client"}]
+  }]
+}`
+	suite := `config: config.json
+tests:
+  - name: base mapping
+    query: search src_ip=1.2.3.4
+    expected: search src=1.2.3.4
+  - name: discovered context
+    query: search sourcetype=access_combined clientip=1.2.3.4
+    expected: search sourcetype=access_combined client=1.2.3.4
+  - name: explicit context
+    query: search clientip=1.2.3.4
+    context: {sourcetype: access_combined}
+    expected: search client=1.2.3.4
+  - name: wrong expectation
+    query: search src_ip=1.2.3.4
+    expected: search source=1.2.3.4
+  - name: discovery
+    query: search sourcetype=access_combined | lookup geo ip
+    expected_info:
+      sourcetypes: [access_combined]
+      lookups: [geoip]
+  - query: search index=main | stats count by host
+    expected_info:
+      datamodels: []
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	suitePath := filepath.Join(dir, "config.test.yaml")
+	if err := os.WriteFile(suitePath, []byte(suite), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadConfigTestSuite(suitePath)
+	if err != nil {
+		t.Fatalf("Failed to load test suite: %v", err)
+	}
+	if loaded.Config != filepath.Join(dir, "config.json") {
+		t.Errorf("Expected config path relative to the suite, got %s", loaded.Config)
+	}
+
+	mappingConfig, err := LoadMappingConfigFile(loaded.Config)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	results := loaded.Run(mappingConfig)
+
+	expected := []struct {
+		name   string
+		passed bool
+		diffs  []string
+	}{
+		{"base mapping", true, nil},
+		{"discovered context", true, nil},
+		{"explicit context", true, nil},
+		{"wrong expectation", false, []string{"--- a/mapped query\n+++ b/mapped query\n@@ -1 +1 @@\n-search source=1.2.3.4\n+search src=1.2.3.4\n"}},
+		{"discovery", false, []string{"lookups: missing [geoip] unexpected [geo]"}},
+		{"test[5]", true, nil},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, want := range expected {
+		got := results[i]
+		if got.Name != want.name || got.Passed != want.passed || !reflect.DeepEqual(got.Diffs, want.diffs) {
+			t.Errorf("Result %d: expected %s passed=%v diffs=%q, got %s passed=%v diffs=%q error=%q",
+				i, want.name, want.passed, want.diffs, got.Name, got.Passed, got.Diffs, got.Error)
+		}
+	}
+
+	// Misspelled expectations are rejected rather than passing silently
+	typo := filepath.Join(dir, "typo.json")
+	if err := os.WriteFile(typo, []byte(`{"tests": [{"query": "search a=1", "expect": "search b=1"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfigTestSuite(typo); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("Expected an unknown field error, got %v", err)
+	}
+}
@@ -0,0 +1,360 @@
+package mapper
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SplunkApp holds the saved searches and macros of a Splunk app
+type SplunkApp struct {
+	Name          string        `json:"name"`
+	SavedSearches []SavedSearch `json:"saved_searches"`
+	Macros        []SearchMacro `json:"macros"`
+}
+
+// SavedSearch is a stanza of savedsearches.conf
+type SavedSearch struct {
+	Name     string `json:"name"`
+	Search   string `json:"search"`
+	File     string `json:"file"` // App-relative path of the file that sets search, e.g. local/savedsearches.conf
+	Line     int    `json:"line"` // 1-based line of the search setting
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// SearchMacro is a stanza of macros.conf
+type SearchMacro struct {
+	Name       string   `json:"name"` // Stanza name; macros with arguments are named "name(count)"
+	Args       []string `json:"args,omitempty"`
+	Definition string   `json:"definition"`
+	IsEval     bool     `json:"iseval,omitempty"` // The definition is an eval expression producing the search
+	File       string   `json:"file"`
+	Line       int      `json:"line"`
+}
+
+// maxMacroDepth bounds nested macro expansion, so recursive macros fail instead of looping
+const maxMacroDepth = 20
+
+// macroPattern matches `name` and `name(arguments)` macro invocations
+var macroPattern = regexp.MustCompile("`([A-Za-z0-9_][A-Za-z0-9_.:-]*)(?:\\(([^`]*)\\))?`")
+
+// appConfLayers are the layers of an app's configuration, lowest precedence first
+var appConfLayers = []string{"default", "local"}
+
+// LoadSplunkApp loads the saved searches and macros of a Splunk app from its directory or
+// from a .spl, .tgz or .tar.gz package. Settings in local/ override those in default/.
+func LoadSplunkApp(appPath string) (*SplunkApp, error) {
+	name, files, err := readAppFiles(appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &SplunkApp{Name: name, SavedSearches: []SavedSearch{}, Macros: []SearchMacro{}}
+	for _, stanza := range mergeAppConf(files, "savedsearches.conf") {
+		search, ok := stanza.settings["search"]
+		if !ok {
+			continue
+		}
+		disabled := stanza.settings["disabled"]
+		app.SavedSearches = append(app.SavedSearches, SavedSearch{
+			Name:     stanza.name,
+			Search:   search.Value,
+			File:     search.file,
+			Line:     search.Line,
+			Disabled: isConfTrue(disabled.Value),
+		})
+	}
+	for _, stanza := range mergeAppConf(files, "macros.conf") {
+		definition, ok := stanza.settings["definition"]
+		if !ok {
+			continue
+		}
+		macro := SearchMacro{
+			Name:       stanza.name,
+			Definition: definition.Value,
+			IsEval:     isConfTrue(stanza.settings["iseval"].Value),
+			File:       definition.file,
+			Line:       definition.Line,
+		}
+		if args := strings.TrimSpace(stanza.settings["args"].Value); args != "" {
+			for _, arg := range strings.Split(args, ",") {
+				macro.Args = append(macro.Args, strings.TrimSpace(arg))
+			}
+		}
+		app.Macros = append(app.Macros, macro)
+	}
+	return app, nil
+}
+
+// Macro returns the macro a `name` or `name(arguments)` invocation with count arguments
+// refers to
+func (a *SplunkApp) Macro(name string, count int) (SearchMacro, bool) {
+	if count > 0 {
+		name = fmt.Sprintf("%s(%d)", name, count)
+	}
+	for _, macro := range a.Macros {
+		if macro.Name == name {
+			return macro, true
+		}
+	}
+	return SearchMacro{}, false
+}
+
+// ExpandMacros replaces the macro invocations in a search with their definitions, with
+// $argument$ references replaced by the invocation's arguments. Nested macros are
+// expanded too; undefined, eval-based and recursive macros are errors.
+func (a *SplunkApp) ExpandMacros(search string) (string, error) {
+	return a.expandMacros(search, 0)
+}
+
+func (a *SplunkApp) expandMacros(search string, depth int) (string, error) {
+	if depth > maxMacroDepth {
+		return "", fmt.Errorf("macros are nested more than %d deep", maxMacroDepth)
+	}
+
+	comments := commentRanges(search)
+	var result strings.Builder
+	last := 0
+	for _, match := range macroPattern.FindAllStringSubmatchIndex(search, -1) {
+		start, end := match[0], match[1]
+		if inRanges(comments, start) || inRanges(comments, end-1) {
+			continue
+		}
+
+		name := search[match[2]:match[3]]
+		var args []string
+		if match[4] >= 0 {
+			args = splitMacroArgs(search[match[4]:match[5]])
+		}
+		macro, ok := a.Macro(name, len(args))
+		if !ok {
+			return "", fmt.Errorf("undefined macro %s", search[start:end])
+		}
+		if macro.IsEval {
+			return "", fmt.Errorf("macro %s is eval-based and cannot be expanded statically", macro.Name)
+		}
+		if len(macro.Args) != len(args) {
+			return "", fmt.Errorf("macro %s declares %d arguments but is called with %d", macro.Name, len(macro.Args), len(args))
+		}
+
+		definition := macro.Definition
+		for i, arg := range macro.Args {
+			definition = strings.ReplaceAll(definition, "$"+arg+"$", args[i])
+		}
+		expanded, err := a.expandMacros(definition, depth+1)
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(search[last:start])
+		result.WriteString(expanded)
+		last = end
+	}
+	result.WriteString(search[last:])
+	return result.String(), nil
+}
+
+// commentRanges returns the byte ranges of the ```comments``` of a search
+func commentRanges(search string) [][2]int {
+	const delimiter = "```"
+	var ranges [][2]int
+	for offset := 0; ; {
+		start := strings.Index(search[offset:], delimiter)
+		if start < 0 {
+			return ranges
+		}
+		start += offset
+		end := strings.Index(search[start+len(delimiter):], delimiter)
+		if end < 0 {
+			return ranges
+		}
+		end += start + 2*len(delimiter)
+		ranges = append(ranges, [2]int{start, end})
+		offset = end
+	}
+}
+
+func inRanges(ranges [][2]int, offset int) bool {
+	for _, r := range ranges {
+		if offset >= r[0] && offset < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// splitMacroArgs splits macro arguments on the commas outside quotes and parentheses
+func splitMacroArgs(text string) []string {
+	var args []string
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && quoted:
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, strings.TrimSpace(text[start:]))
+}
+
+// appSetting is a setting of a merged app configuration with the file that set it
+type appSetting struct {
+	ConfSetting
+	file string
+}
+
+type appStanza struct {
+	name     string
+	settings map[string]appSetting
+}
+
+// mergeAppConf merges a .conf file across the layers of an app, in order of first
+// appearance. The [default] stanza is not returned.
+func mergeAppConf(files map[string][]byte, name string) []appStanza {
+	var stanzas []appStanza
+	index := make(map[string]int)
+	for _, layer := range appConfLayers {
+		file := layer + "/" + name
+		data, ok := files[file]
+		if !ok {
+			continue
+		}
+		for _, stanza := range ParseConf(data) {
+			if stanza.Name == "default" {
+				continue
+			}
+			i, ok := index[stanza.Name]
+			if !ok {
+				i = len(stanzas)
+				index[stanza.Name] = i
+				stanzas = append(stanzas, appStanza{name: stanza.Name, settings: make(map[string]appSetting)})
+			}
+			for _, setting := range stanza.Settings {
+				stanzas[i].settings[setting.Key] = appSetting{ConfSetting: setting, file: file}
+			}
+		}
+	}
+	return stanzas
+}
+
+// isAppPackage reports whether a path names a packaged app
+func isAppPackage(appPath string) bool {
+	lower := strings.ToLower(appPath)
+	return strings.HasSuffix(lower, ".spl") || strings.HasSuffix(lower, ".tgz") || strings.HasSuffix(lower, ".tar.gz")
+}
+
+// readAppFiles reads the configuration files of an app directory or package, keyed by
+// their slash-separated path within the app, and returns the app's name
+func readAppFiles(appPath string) (string, map[string][]byte, error) {
+	files := make(map[string][]byte)
+	info, err := os.Stat(appPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read app: %w", err)
+	}
+
+	if info.IsDir() {
+		err := filepath.WalkDir(appPath, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || !isAppConfigFile(file) {
+				return err
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			relative, err := filepath.Rel(appPath, file)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(relative)] = data
+			return nil
+		})
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read app: %w", err)
+		}
+		return filepath.Base(filepath.Clean(appPath)), files, nil
+	}
+
+	if !isAppPackage(appPath) {
+		return "", nil, fmt.Errorf("%s: not an app directory or .spl, .tgz or .tar.gz package", appPath)
+	}
+	name := ""
+	err = readAppPackage(appPath, func(header *tar.Header, reader io.Reader) error {
+		app, relative, ok := strings.Cut(strings.TrimPrefix(path.Clean(header.Name), "./"), "/")
+		if !ok || header.Typeflag != tar.TypeReg || !isAppConfigFile(relative) {
+			return nil
+		}
+		if name == "" {
+			name = app
+		}
+		if app != name {
+			return nil
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		files[relative] = data
+		return nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", appPath, err)
+	}
+	if name == "" {
+		return "", nil, fmt.Errorf("%s: package contains no app configuration", appPath)
+	}
+	return name, files, nil
+}
+
+// readAppPackage calls visit for each entry of a gzipped tar package
+func readAppPackage(packagePath string, visit func(header *tar.Header, reader io.Reader) error) error {
+	file, err := os.Open(packagePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := visit(header, tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+// isAppConfigFile reports whether a file of an app holds searches: .conf files and
+// dashboard sources
+func isAppConfigFile(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".conf", ".xml", ".json":
+		return true
+	}
+	return false
+}
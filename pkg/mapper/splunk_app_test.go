@@ -0,0 +1,97 @@
+package mapper
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLoadSplunkApp(t *testing.T) {
+	files := map[string]string{
+		"default/savedsearches.conf": "[default]\ndisabled = 0\n\n[Failed Logons]\nsearch = `win` | stats count by user\n\n[Proxy]\nsearch = `proxy(web, 500)`\n",
+		"local/savedsearches.conf":   "[Proxy]\ndisabled = 1\n\n[Failed Logons]\nsearch = `win` `failed(4625)` \\\n| stats count by user\n",
+		"default/macros.conf":        "[win]\ndefinition = index=wineventlog\n\n[failed(1)]\nargs = code\ndefinition = EventCode=$code$\n\n[proxy(2)]\nargs = idx, status\ndefinition = index=$idx$ status=$status$\n\n[dyn]\niseval = 1\ndefinition = \"index=\" . \"main\"\n\n[loop]\ndefinition = `loop`\n",
+		"default/app.conf":           "[launcher]\nversion = 1.0\n",
+	}
+
+	dir, packagePath := writeTestApp(t, files)
+
+	for _, path := range []string{dir, packagePath} {
+		app, err := LoadSplunkApp(path)
+		if err != nil {
+			t.Fatalf("LoadSplunkApp(%s) failed: %v", path, err)
+		}
+		if app.Name != "my_app" || len(app.SavedSearches) != 2 || len(app.Macros) != 5 {
+			t.Fatalf("Unexpected app from %s: %+v", path, app)
+		}
+
+		logons := app.SavedSearches[0]
+		if logons.Name != "Failed Logons" || logons.File != "local/savedsearches.conf" || logons.Line != 5 || logons.Disabled {
+			t.Errorf("Expected local search to override default, got %+v", logons)
+		}
+		if !app.SavedSearches[1].Disabled {
+			t.Errorf("Expected Proxy to be disabled by local, got %+v", app.SavedSearches[1])
+		}
+
+		expanded, err := app.ExpandMacros(logons.Search)
+		if err != nil || expanded != "index=wineventlog EventCode=4625 \n| stats count by user" {
+			t.Errorf("Unexpected expansion %q (%v)", expanded, err)
+		}
+		if expanded, err := app.ExpandMacros(app.SavedSearches[1].Search); err != nil || expanded != "index=web status=500" {
+			t.Errorf("Unexpected expansion with arguments %q (%v)", expanded, err)
+		}
+		if expanded, err := app.ExpandMacros("index=web ```keep `win` comments``` | stats count"); err != nil || !strings.Contains(expanded, "```keep `win` comments```") {
+			t.Errorf("Expected comments to be kept, got %q (%v)", expanded, err)
+		}
+		for _, search := range []string{"`nope`", "`dyn`", "`loop`", "`failed(1, 2)`"} {
+			if _, err := app.ExpandMacros(search); err == nil {
+				t.Errorf("Expected an error expanding %s", search)
+			}
+		}
+	}
+
+	if _, err := LoadSplunkApp(filepath.Join(dir, "default", "app.conf")); err == nil {
+		t.Error("Expected an error for a file that is not an app package")
+	}
+}
+
+// writeTestApp writes an app named my_app as a directory and as a .spl package
+func writeTestApp(t *testing.T, files map[string]string) (string, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "my_app")
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := files[name]
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		header := &tar.Header{Name: "my_app/" + name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		tarWriter.Write([]byte(content))
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+	packagePath := filepath.Join(t.TempDir(), "my_app.spl")
+	if err := os.WriteFile(packagePath, archive.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, packagePath
+}
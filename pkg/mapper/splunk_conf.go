@@ -0,0 +1,121 @@
+package mapper
+
+import (
+	"strings"
+)
+
+// ConfStanza is a stanza of a Splunk .conf file
+type ConfStanza struct {
+	Name     string
+	Line     int // 1-based line of the stanza header; 0 for settings before the first header
+	Settings []ConfSetting
+}
+
+// ConfSetting is a "key = value" setting of a .conf stanza
+type ConfSetting struct {
+	Key   string
+	Value string // Continuation lines are joined with newlines, without their backslashes
+	Line  int    // 1-based line of the key
+	// ValueStart and ValueEnd are the byte offsets of the value as written in the file,
+	// including continuation backslashes and line breaks
+	ValueStart int
+	ValueEnd   int
+}
+
+// Get returns the value of the last setting of a key in the stanza
+func (s ConfStanza) Get(key string) (string, bool) {
+	setting, ok := s.Setting(key)
+	return setting.Value, ok
+}
+
+// Setting returns the last setting of a key in the stanza
+func (s ConfStanza) Setting(key string) (ConfSetting, bool) {
+	for i := len(s.Settings) - 1; i >= 0; i-- {
+		if s.Settings[i].Key == key {
+			return s.Settings[i], true
+		}
+	}
+	return ConfSetting{}, false
+}
+
+// ParseConf parses a Splunk .conf file. Settings before the first stanza header belong to
+// the "default" stanza, lines starting with # are comments and a line ending with a
+// backslash continues on the next line. Lines that are neither are ignored, as Splunk does.
+func ParseConf(data []byte) []ConfStanza {
+	text := string(data)
+	stanzas := []ConfStanza{{Name: "default"}}
+	current := &stanzas[0]
+
+	line, offset := 0, 0
+	nextLine := func() (string, int, bool) {
+		if offset >= len(text) {
+			return "", offset, false
+		}
+		start := offset
+		end := strings.IndexByte(text[start:], '\n')
+		if end < 0 {
+			offset = len(text)
+		} else {
+			offset = start + end + 1
+		}
+		line++
+		return strings.TrimSuffix(strings.TrimSuffix(text[start:offset], "\n"), "\r"), start, true
+	}
+
+	for {
+		raw, start, ok := nextLine()
+		if !ok {
+			break
+		}
+		trimmed := strings.TrimSpace(raw)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			stanzas = append(stanzas, ConfStanza{Name: trimmed[1 : len(trimmed)-1], Line: line})
+			current = &stanzas[len(stanzas)-1]
+			continue
+		}
+
+		equals := strings.IndexByte(raw, '=')
+		if equals < 0 {
+			continue
+		}
+		setting := ConfSetting{Key: strings.TrimSpace(raw[:equals]), Line: line}
+		valueOffset := equals + 1
+		for valueOffset < len(raw) && (raw[valueOffset] == ' ' || raw[valueOffset] == '\t') {
+			valueOffset++
+		}
+		setting.ValueStart = start + valueOffset
+		value := raw[valueOffset:]
+		setting.ValueEnd = start + len(raw)
+
+		var parts []string
+		for strings.HasSuffix(value, "\\") {
+			parts = append(parts, strings.TrimSuffix(value, "\\"))
+			next, nextStart, ok := nextLine()
+			if !ok {
+				value = ""
+				break
+			}
+			value = next
+			setting.ValueEnd = nextStart + len(next)
+		}
+		setting.Value = strings.TrimRight(strings.Join(append(parts, value), "\n"), " \t")
+		current.Settings = append(current.Settings, setting)
+	}
+
+	if len(stanzas[0].Settings) == 0 {
+		stanzas = stanzas[1:]
+	}
+	return stanzas
+}
+
+// isConfTrue reports whether a .conf boolean setting is true
+func isConfTrue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "t", "true", "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package mapper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConf(t *testing.T) {
+	conf := "# comment\nglobal = 1\n\n[Failed Logons]\nsearch = index=win \\\r\n| stats count by user\r\ncron_schedule=*/5 * * * *\n[Other]\nnot a setting\nsearch = index=web  \n"
+	stanzas := ParseConf([]byte(conf))
+
+	var names []string
+	for _, stanza := range stanzas {
+		names = append(names, stanza.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"default", "Failed Logons", "Other"}) {
+		t.Fatalf("Expected stanzas default, Failed Logons and Other, got %v", names)
+	}
+
+	search, ok := stanzas[1].Setting("search")
+	if !ok || search.Value != "index=win \n| stats count by user" || search.Line != 5 {
+		t.Errorf("Unexpected continued setting %+v", search)
+	}
+	if raw := conf[search.ValueStart:search.ValueEnd]; raw != "index=win \\\r\n| stats count by user" {
+		t.Errorf("Unexpected raw value %q", raw)
+	}
+	if value, _ := stanzas[1].Get("cron_schedule"); value != "*/5 * * * *" {
+		t.Errorf("Expected cron_schedule */5 * * * *, got %q", value)
+	}
+	if value, _ := stanzas[2].Get("search"); value != "index=web" {
+		t.Errorf("Expected trailing whitespace to be trimmed, got %q", value)
+	}
+}
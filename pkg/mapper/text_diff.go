@@ -0,0 +1,241 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffOp is a step of an edit script from a to b: keep a[A] as b[B] ('='), delete a[A]
+// ('-') or insert b[B] ('+')
+type diffOp struct {
+	Kind byte
+	A, B int
+}
+
+// diffSlices returns the shortest edit script from a to b, using the linear-space variant of
+// Myers' algorithm: the middle snake of each range splits it in two, so memory stays
+// proportional to len(a)+len(b) however far apart the slices are
+func diffSlices[T comparable](a, b []T) []diffOp {
+	var ops []diffOp
+	diffRange(a, b, 0, len(a), 0, len(b), &ops)
+	return ops
+}
+
+// diffRange appends the edit script from a[aLo:aHi] to b[bLo:bHi] to ops
+func diffRange[T comparable](a, b []T, aLo, aHi, bLo, bHi int, ops *[]diffOp) {
+	for aLo < aHi && bLo < bHi && a[aLo] == b[bLo] {
+		*ops = append(*ops, diffOp{Kind: '=', A: aLo, B: bLo})
+		aLo, bLo = aLo+1, bLo+1
+	}
+	suffix := 0
+	for aHi-suffix > aLo && bHi-suffix > bLo && a[aHi-suffix-1] == b[bHi-suffix-1] {
+		suffix++
+	}
+	aHi, bHi = aHi-suffix, bHi-suffix
+
+	switch {
+	case aLo == aHi:
+		for y := bLo; y < bHi; y++ {
+			*ops = append(*ops, diffOp{Kind: '+', A: aLo, B: y})
+		}
+	case bLo == bHi:
+		for x := aLo; x < aHi; x++ {
+			*ops = append(*ops, diffOp{Kind: '-', A: x, B: bLo})
+		}
+	default:
+		if x, y, ok := middleSnake(a[aLo:aHi], b[bLo:bHi]); ok {
+			diffRange(a, b, aLo, aLo+x, bLo, bLo+y, ops)
+			diffRange(a, b, aLo+x, aHi, bLo+y, bHi, ops)
+		} else {
+			for x := aLo; x < aHi; x++ {
+				*ops = append(*ops, diffOp{Kind: '-', A: x, B: bLo})
+			}
+			for y := bLo; y < bHi; y++ {
+				*ops = append(*ops, diffOp{Kind: '+', A: aHi, B: y})
+			}
+		}
+	}
+
+	for i := 0; i < suffix; i++ {
+		*ops = append(*ops, diffOp{Kind: '=', A: aHi + i, B: bHi + i})
+	}
+}
+
+// middleSnake runs Myers' search forward from the start and backward from the end of a and b
+// until the paths overlap, and returns the point where they meet. It reports false when a and
+// b have nothing in common.
+func middleSnake[T comparable](a, b []T) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	forward, backward := make([]int, 2*offset+1), make([]int, 2*offset+1)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	odd := delta%2 != 0
+	// Diagonals that ran off the edges are not searched again
+	forwardStart, forwardEnd, backwardStart, backwardEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + forwardStart; k <= d-forwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				forwardEnd += 2
+			case y > m:
+				forwardStart += 2
+			case odd:
+				if i := offset + delta - k; i >= 0 && i < len(backward) && backward[i] != -1 && x >= n-backward[i] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + backwardStart; k <= d-backwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x, y = x+1, y+1
+			}
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				backwardEnd += 2
+			case y > m:
+				backwardStart += 2
+			case !odd:
+				if i := offset + delta - k; i >= 0 && i < len(forward) && forward[i] != -1 {
+					forwardX := forward[i]
+					forwardY := forwardX - (i - offset)
+					if forwardX >= n-x {
+						return forwardX, forwardY, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// diffContextLines is the number of unchanged lines around each hunk of a unified diff
+const diffContextLines = 3
+
+// unifiedDiff returns the unified diff of two versions of a file, or "" when they are equal
+func unifiedDiff(name string, original, changed []byte) string {
+	a, b := splitDiffLines(string(original)), splitDiffLines(string(changed))
+	ops := diffSlices(a, b)
+
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		if ops[start].Kind == '=' {
+			start++
+			continue
+		}
+
+		// A hunk runs from diffContextLines before a change to diffContextLines after the
+		// last change that is not separated from it by more than twice that many lines
+		first := max(start-diffContextLines, 0)
+		end := start
+		for end < len(ops) {
+			if ops[end].Kind != '=' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].Kind == '=' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContextLines {
+				end = min(end+diffContextLines, len(ops))
+				break
+			}
+			end = next
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+		}
+		aStart, bStart, aCount, bCount := hunkRange(ops[first:end])
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", formatHunkRange(aStart, aCount), formatHunkRange(bStart, bCount))
+		for _, op := range ops[first:end] {
+			line := ""
+			switch op.Kind {
+			case '=':
+				line = " " + a[op.A]
+			case '-':
+				line = "-" + a[op.A]
+			case '+':
+				line = "+" + b[op.B]
+			}
+			out.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = end
+	}
+	return out.String()
+}
+
+// hunkRange returns the 0-based first lines and the line counts of a hunk in both files
+func hunkRange(ops []diffOp) (aStart, bStart, aCount, bCount int) {
+	aStart, bStart = -1, -1
+	for _, op := range ops {
+		if op.Kind != '+' {
+			if aStart < 0 {
+				aStart = op.A
+			}
+			aCount++
+		}
+		if op.Kind != '-' {
+			if bStart < 0 {
+				bStart = op.B
+			}
+			bCount++
+		}
+	}
+	// An empty range is reported at the line before it
+	if aStart < 0 {
+		aStart = ops[0].A - 1
+	}
+	if bStart < 0 {
+		bStart = ops[0].B - 1
+	}
+	return aStart, bStart, aCount, bCount
+}
+
+func formatHunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitDiffLines splits text into lines that keep their line breaks
+func splitDiffLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,61 @@
+package mapper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffSlices(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		edits int // Deletions and insertions of the shortest edit script
+	}{
+		{"", "", 0},
+		{"abc", "abc", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"abcabba", "cbabac", 5},
+		{"index=web user=bob", "index=web username=bob", 4},
+		{"xyz", "abc", 6},
+	}
+	for _, tc := range cases {
+		a, b := []rune(tc.a), []rune(tc.b)
+		ops := diffSlices(a, b)
+
+		// Replaying the script must turn a into b
+		x, y, edits := 0, 0, 0
+		for _, op := range ops {
+			if op.A != x || op.B != y {
+				t.Fatalf("%q -> %q: op %c at %d,%d, expected %d,%d", tc.a, tc.b, op.Kind, op.A, op.B, x, y)
+			}
+			switch op.Kind {
+			case '=':
+				if a[x] != b[y] {
+					t.Fatalf("%q -> %q: kept %q as %q", tc.a, tc.b, a[x], b[y])
+				}
+				x, y = x+1, y+1
+			case '-':
+				x, edits = x+1, edits+1
+			case '+':
+				y, edits = y+1, edits+1
+			}
+		}
+		if x != len(a) || y != len(b) {
+			t.Errorf("%q -> %q: script stops at %d,%d", tc.a, tc.b, x, y)
+		}
+		if edits != tc.edits {
+			t.Errorf("%q -> %q: expected %d edits, got %d", tc.a, tc.b, tc.edits, edits)
+		}
+	}
+
+	// Files that differ everywhere are diffed without memory growing with the distance
+	var original, changed strings.Builder
+	for i := 0; i < 5000; i++ {
+		original.WriteString("line a\n")
+		changed.WriteString("line b\n")
+	}
+	diff := unifiedDiff("big.conf", []byte(original.String()), []byte(changed.String()))
+	if !strings.HasPrefix(diff, "--- a/big.conf\n+++ b/big.conf\n@@ -1,5000 +1,5000 @@\n") {
+		t.Errorf("Unexpected diff header: %.80q", diff)
+	}
+}
@@ -0,0 +1,122 @@
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ValueReplacement rewrites field values matching a regular expression.
+// Replacement may reference capture groups as $1 or ${name}.
+type ValueReplacement struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// Value case transformations supported by FieldMapping.Case
+const (
+	ValueCaseLower = "lower"
+	ValueCaseUpper = "upper"
+)
+
+// valueTransform is the compiled form of the value rules of a FieldMapping.
+// Exact value maps win over regex replacements; the case transform is applied last.
+type valueTransform struct {
+	values       map[string]string
+	replacements []compiledValueReplacement
+	caseMode     string
+}
+
+type compiledValueReplacement struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// hasValueTransforms reports whether a mapping changes field values as well as the field name
+func (fm FieldMapping) hasValueTransforms() bool {
+	return len(fm.Values) > 0 || len(fm.Replace) > 0 || fm.Case != ""
+}
+
+// newValueTransform compiles the value rules of a mapping, returning nil if it has none
+func newValueTransform(mapping FieldMapping) (*valueTransform, error) {
+	if !mapping.hasValueTransforms() {
+		return nil, nil
+	}
+
+	transform := &valueTransform{
+		values:   mapping.Values,
+		caseMode: mapping.Case,
+	}
+
+	switch mapping.Case {
+	case "", ValueCaseLower, ValueCaseUpper:
+	default:
+		return nil, fmt.Errorf("invalid case transform '%s' (expected '%s' or '%s')", mapping.Case, ValueCaseLower, ValueCaseUpper)
+	}
+
+	for i, replacement := range mapping.Replace {
+		pattern, err := regexp.Compile(replacement.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("replace[%d]: invalid pattern: %w", i, err)
+		}
+		transform.replacements = append(transform.replacements, compiledValueReplacement{
+			pattern:     pattern,
+			replacement: replacement.Replacement,
+		})
+	}
+
+	return transform, nil
+}
+
+// apply transforms a literal value, reporting whether it changed
+func (t *valueTransform) apply(value string) (string, bool) {
+	result := value
+	if mapped, exists := t.values[value]; exists {
+		result = mapped
+	} else {
+		for _, replacement := range t.replacements {
+			result = replacement.pattern.ReplaceAllString(result, replacement.replacement)
+		}
+	}
+
+	switch t.caseMode {
+	case ValueCaseLower:
+		result = strings.ToLower(result)
+	case ValueCaseUpper:
+		result = strings.ToUpper(result)
+	}
+
+	return result, result != value
+}
+
+// bareValueRegex matches values that can be written without quotes in a search
+var bareValueRegex = regexp.MustCompile(`^[A-Za-z0-9_.:*/-]+$`)
+
+// numericValueRegex matches values that can be written without quotes in an eval expression
+var numericValueRegex = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// applyToLiteral transforms the text of a literal token, keeping or adding quotes as needed.
+// In expression commands such as where and eval a bare word is a field name, so values that
+// are not numbers are always quoted.
+func (t *valueTransform) applyToLiteral(text string, expression bool) (string, bool) {
+	if len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		value, changed := t.apply(text[1 : len(text)-1])
+		if !changed {
+			return text, false
+		}
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`, true
+	}
+
+	value, changed := t.apply(text)
+	if !changed {
+		return text, false
+	}
+	bare := bareValueRegex
+	if expression {
+		bare = numericValueRegex
+	}
+	if !bare.MatchString(value) {
+		value = `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return value, true
+}